@@ -0,0 +1,238 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+// An edit is a line of an edit script: ' ' keeps, '-' deletes
+// and '+' inserts the line.
+type edit struct {
+	op   byte
+	line string
+}
+
+// diff returns a unified diff of the old and new contents of a file,
+// or nil if they are equal.
+func diff(oldName string, old []byte, newName string, new []byte) []byte {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+	edits := compare(nil, lines(old), lines(new))
+
+	// list the deleted lines of a change before the inserted ones
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		end := k
+		for end < len(edits) && edits[end].op != ' ' {
+			end++
+		}
+		run := edits[k:end]
+		sort.SliceStable(run, func(i, j int) bool {
+			return run[i].op == '-' && run[j].op == '+'
+		})
+		k = end
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	line := [2]int{1, 1} // current line numbers in old and new
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			line[0]++
+			line[1]++
+			k++
+			continue
+		}
+
+		// a hunk starts context lines before the change and extends
+		// until the next run of more than 2*context unchanged lines
+		start := k - context
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			n := end
+			for n < len(edits) && edits[n].op == ' ' {
+				n++
+			}
+			if n == len(edits) || n-end > 2*context {
+				end += context
+				if end > len(edits) {
+					end = len(edits)
+				}
+				break
+			}
+			end = n
+		}
+
+		first := [2]int{line[0] - (k - start), line[1] - (k - start)}
+		var count [2]int
+		var hunk bytes.Buffer
+		for _, e := range edits[start:end] {
+			switch e.op {
+			case ' ':
+				count[0]++
+				count[1]++
+			case '-':
+				count[0]++
+			case '+':
+				count[1]++
+			}
+			fmt.Fprintf(&hunk, "%c%s\n", e.op, e.line)
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", span(first[0], count[0]), span(first[1], count[1]))
+		buf.Write(hunk.Bytes())
+
+		line[0] = first[0] + count[0]
+		line[1] = first[1] + count[1]
+		k = end
+	}
+	return buf.Bytes()
+}
+
+// compare appends a shortest edit script from x to y to edits.
+// It trims the common prefix and suffix and computes the rest with
+// the linear space variant of the algorithm in E. W. Myers, "An O(ND)
+// Difference Algorithm and Its Variations" (1986): it finds the middle
+// snake of a shortest edit script and recurses on both sides of it.
+func compare(edits []edit, x, y []string) []edit {
+	n := 0
+	for n < len(x) && n < len(y) && x[n] == y[n] {
+		n++
+	}
+	for _, l := range x[:n] {
+		edits = append(edits, edit{' ', l})
+	}
+	x, y = x[n:], y[n:]
+	n = 0
+	for n < len(x) && n < len(y) && x[len(x)-1-n] == y[len(y)-1-n] {
+		n++
+	}
+	suffix := x[len(x)-n:]
+	x, y = x[:len(x)-n], y[:len(y)-n]
+
+	switch {
+	case len(x) == 0:
+		for _, l := range y {
+			edits = append(edits, edit{'+', l})
+		}
+	case len(y) == 0:
+		for _, l := range x {
+			edits = append(edits, edit{'-', l})
+		}
+	default:
+		// x and y differ in their first and last lines, so the
+		// edit script has at least two edits and both sides of
+		// the middle snake are shorter
+		x0, y0, x1, y1 := middleSnake(x, y)
+		edits = compare(edits, x[:x0], y[:y0])
+		for _, l := range x[x0:x1] {
+			edits = append(edits, edit{' ', l})
+		}
+		edits = compare(edits, x[x1:], y[y1:])
+	}
+
+	for _, l := range suffix {
+		edits = append(edits, edit{' ', l})
+	}
+	return edits
+}
+
+// middleSnake returns the middle snake x[x0:x1] == y[y0:y1] of a
+// shortest edit script from x to y. It extends the furthest reaching
+// paths from the start and from the end of x and y in turns until
+// they overlap on a diagonal.
+func middleSnake(x, y []string) (x0, y0, x1, y1 int) {
+	n, m := len(x), len(y)
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+
+	// vf[off+k] is the furthest x on diagonal k = x-y of a forward
+	// path, vb[off+k] the furthest distance from the end of x on
+	// diagonal k = (n-x)-(m-y) of a backward path
+	off := max + 1
+	vf := make([]int, 2*off+1)
+	vb := make([]int, 2*off+1)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			i := vf[off+k+1] // down from diagonal k+1
+			if k != -d && (k == d || vf[off+k-1] >= vf[off+k+1]) {
+				i = vf[off+k-1] + 1 // right from diagonal k-1
+			}
+			j := i - k
+			si, sj := i, j
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			vf[off+k] = i
+			if r := delta - k; odd && -d < r && r < d && i+vb[off+r] >= n {
+				return si, sj, i, j
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			i := vb[off+k+1]
+			if k != -d && (k == d || vb[off+k-1] >= vb[off+k+1]) {
+				i = vb[off+k-1] + 1
+			}
+			j := i - k
+			si, sj := i, j
+			for i < n && j < m && x[n-1-i] == y[m-1-j] {
+				i++
+				j++
+			}
+			vb[off+k] = i
+			if r := delta - k; !odd && -d <= r && r <= d && i+vf[off+r] >= n {
+				return n - i, m - j, n - si, m - sj
+			}
+		}
+	}
+	panic("no middle snake")
+}
+
+// lines splits s into lines without their line terminators.
+// A final line without terminator is marked as in diff(1).
+func lines(s []byte) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	l := strings.Split(string(s), "\n")
+	if last := len(l) - 1; l[last] == "" {
+		l = l[:last]
+	} else {
+		l[last] += "\n\\ No newline at end of file"
+	}
+	return l
+}
+
+// span formats a line range of a hunk header.
+func span(first, count int) string {
+	if count == 0 {
+		first-- // an empty range names the line before it
+	}
+	if count == 1 {
+		return fmt.Sprint(first)
+	}
+	return fmt.Sprintf("%d,%d", first, count)
+}
@@ -4,13 +4,405 @@
 // https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
 // located in the root directory of this source tree.
 
+// Jindo is a tool for managing jindo source code.
+//
+// Usage:
+//
+//	jindo <command> [-v] [files or directories]
+//
+// The commands are:
+//
+//	parse   parse files and report syntax errors
+//	dump    print the syntax trees of files
+//	fmt     print files in canonical form
+//	build   translate programs to Go and build them with the go command
+//	check   parse and type-check files
+//	disasm  compile files to bytecode and print the disassembly
+//	lsp     run a language server on standard input and output
+//	run     type-check and run a program
+//
+// Directories are searched recursively for .paw files. The run
+// command accepts a single file, or a directory containing a
+// single .paw file. The -v flag prints a trace of the parser.
+//
+// The fmt command accepts additional flags:
+//
+//	-d  print diffs instead of the formatted source
+//	-l  list files whose formatting differs from the canonical form
+//	-w  write the result to the source file instead of standard output
+//
+// The build command translates each file to a Go program and builds it
+// into an executable named after the file, or the name given by -o; it
+// requires the go command. The -go flag prints the Go source instead.
+//
+// The lsp command takes no files; it speaks the Language Server Protocol
+// over standard input and output until the client asks it to exit.
+//
+// The run command accepts the -vm flag, which compiles the program to
+// bytecode and runs it on the virtual machine instead of interpreting
+// its syntax tree.
+//
+// Jindo exits with status 1 if any file has errors, and with
+// status 2 for usage errors.
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"io/fs"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/gogen"
+	"jindo/pkg/jindo/interp"
+	"jindo/pkg/jindo/lsp"
 	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/types"
+	"jindo/pkg/jindo/vm"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
+// ext is the file name extension of jindo source files.
+const ext = ".paw"
+
+// A command is a jindo subcommand. Its run function is called
+// for each file named on the command line and returns the first
+// error encountered; errors are reported by the caller.
+type command struct {
+	name  string
+	short string
+	run   func(filename string, mode parser.Mode) error
+}
+
+var commands = []*command{
+	{"parse", "parse files and report syntax errors", parseCmd},
+	{"dump", "print the syntax trees of files", dumpCmd},
+	{"fmt", "print files in canonical form", fmtCmd},
+	{"build", "translate programs to Go and build them with the go command", buildCmd},
+	{"check", "parse and type-check files", checkCmd},
+	{"disasm", "compile files to bytecode and print the disassembly", disasmCmd},
+	{"lsp", "run a language server on standard input and output", nil},
+	{"run", "type-check and run a program", runCmd},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: jindo <command> [-v] [files or directories]\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-8s%s\n", cmd.name, cmd.short)
+	}
+	os.Exit(2)
+}
+
 func main() {
-	fmt.Println(parser.ParseFile("", nil))
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "jindo: unknown command %q\n", os.Args[1])
+		usage()
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	verbose := flags.Bool("v", false, "print a trace of the parser")
+	if cmd.name == "fmt" {
+		fmtDiff = flags.Bool("d", false, "print diffs instead of the formatted source")
+		fmtList = flags.Bool("l", false, "list files whose formatting differs from the canonical form")
+		fmtWrite = flags.Bool("w", false, "write the result to the source file instead of standard output")
+	}
+	if cmd.name == "run" {
+		runVM = flags.Bool("vm", false, "run the program on the bytecode virtual machine")
+	}
+	if cmd.name == "build" {
+		buildGo = flags.Bool("go", false, "print the Go source instead of building it")
+		buildOutput = flags.String("o", "", "write the executable to the named file")
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: jindo %s [-v] [files or directories]\n", cmd.name)
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+	flags.Parse(os.Args[2:])
+
+	var mode parser.Mode
+	if *verbose {
+		mode |= parser.Trace
+	}
+
+	if cmd.name == "lsp" {
+		if flags.NArg() > 0 {
+			flags.Usage()
+		}
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "jindo lsp: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jindo: %s\n", err)
+		os.Exit(1)
+	}
+	switch {
+	case len(files) == 0:
+		fmt.Fprintf(os.Stderr, "jindo %s: no %s files\n", cmd.name, ext)
+		flags.Usage()
+	case cmd.name == "run" && len(files) > 1:
+		fmt.Fprintf(os.Stderr, "jindo run: cannot run %d files; run expects a single program\n", len(files))
+		os.Exit(2)
+	case cmd.name == "build" && *buildOutput != "" && len(files) > 1:
+		fmt.Fprintf(os.Stderr, "jindo build: -o cannot be used with %d files\n", len(files))
+		os.Exit(2)
+	}
+
+	exitCode := 0
+	for _, filename := range files {
+		if err := cmd.run(filename, mode); err != nil {
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// sourceFiles returns the source files named by args, searching
+// directories recursively for files with the extension ext.
+func sourceFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ext) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// report prints err to standard error.
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err)
+}
+
+func parseCmd(filename string, mode parser.Mode) error {
+	_, err := parser.ParseFile(filename, report, mode)
+	return err
+}
+
+func dumpCmd(filename string, mode parser.Mode) error {
+	f, err := parser.ParseFile(filename, report, mode)
+	if f != nil {
+		ast.Fdump(os.Stdout, f)
+	}
+	return err
+}
+
+// Flags of the fmt command.
+var (
+	fmtDiff  = new(bool)
+	fmtList  = new(bool)
+	fmtWrite = new(bool)
+)
+
+func fmtCmd(filename string, mode parser.Mode) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		report(err)
+		return err
+	}
+	f, err := parser.Parse(position.NewFileBase(filename), bytes.NewReader(src), report, mode|parser.ParseComments)
+	if err != nil {
+		return err // don't print a partial syntax tree
+	}
+	var buf bytes.Buffer
+	if _, err := parser.Fprint(&buf, f, 0); err != nil {
+		report(err)
+		return err
+	}
+	res := buf.Bytes()
+
+	if !*fmtDiff && !*fmtList && !*fmtWrite {
+		_, err = os.Stdout.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if *fmtList {
+		fmt.Println(filename)
+	}
+	if *fmtWrite {
+		perm := os.FileMode(0644)
+		if info, err := os.Stat(filename); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := os.WriteFile(filename, res, perm); err != nil {
+			report(err)
+			return err
+		}
+	}
+	if *fmtDiff {
+		os.Stdout.Write(diff("a/"+filename, src, "b/"+filename, res))
+	}
+	return nil
+}
+
+// check parses and type-checks the named file.
+func check(filename string, mode parser.Mode) (*ast.File, *types.Info, error) {
+	f, err := parser.ParseFile(filename, report, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := types.Check(f, report)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// compile parses, type-checks and compiles the named file to bytecode.
+func compile(filename string, mode parser.Mode) (*vm.Program, error) {
+	f, info, err := check(filename, mode)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := vm.Compile(f, info)
+	if err != nil {
+		report(err)
+		return nil, err
+	}
+	return prog, nil
+}
+
+// Flags of the build command.
+var (
+	buildGo     = new(bool)
+	buildOutput = new(string)
+)
+
+func buildCmd(filename string, mode parser.Mode) error {
+	f, info, err := check(filename, mode)
+	if err != nil {
+		return err
+	}
+	var src bytes.Buffer
+	if err := gogen.Generate(&src, f, info); err != nil {
+		report(err)
+		return err
+	}
+	if *buildGo {
+		_, err := os.Stdout.Write(src.Bytes())
+		return err
+	}
+	if name := f.SpaceName.Value; name != "main" {
+		err := fmt.Errorf("%s: cannot build space %s: only space main is a program", filename, name)
+		report(err)
+		return err
+	}
+
+	out := *buildOutput
+	if out == "" {
+		out = strings.TrimSuffix(filepath.Base(filename), ext)
+	}
+	if out, err = filepath.Abs(out); err != nil {
+		report(err)
+		return err
+	}
+
+	// build the program as a module of its own in a temporary directory
+	dir, err := os.MkdirTemp("", "jindo-build-")
+	if err != nil {
+		report(err)
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// Go 1.22 gives each loop iteration its own loop variables, as jindo
+	// does, and range loops over maps range over functions as of Go 1.23
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module main\n\ngo 1.23\n"), 0644); err != nil {
+		report(err)
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), src.Bytes(), 0644); err != nil {
+		report(err)
+		return err
+	}
+	cmd := exec.Command("go", "build", "-o", out, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		err = fmt.Errorf("%s: go build: %v", filename, err)
+		report(err)
+		return err
+	}
+	return nil
+}
+
+func checkCmd(filename string, mode parser.Mode) error {
+	_, _, err := check(filename, mode)
+	return err
+}
+
+func disasmCmd(filename string, mode parser.Mode) error {
+	prog, err := compile(filename, mode)
+	if err != nil {
+		return err
+	}
+	if err := vm.Disassemble(os.Stdout, prog); err != nil {
+		report(err)
+		return err
+	}
+	return nil
+}
+
+// runVM is the -vm flag of the run command.
+var runVM = new(bool)
+
+func runCmd(filename string, mode parser.Mode) error {
+	if *runVM {
+		prog, err := compile(filename, mode)
+		if err != nil {
+			return err
+		}
+		err = vm.Run(prog, os.Stdout)
+		if err != nil {
+			report(err)
+		}
+		return err
+	}
+
+	f, info, err := check(filename, mode)
+	if err != nil {
+		return err
+	}
+	if err := interp.Run(f, info, os.Stdout); err != nil {
+		report(err)
+		return err
+	}
+	return nil
 }
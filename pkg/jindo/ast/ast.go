@@ -15,10 +15,13 @@ type Node interface {
 	GetPos() position.Pos
 	aNode()
 	SetPos(pos position.Pos)
+	Comments() *Comments
+	SetComments(c *Comments)
 }
 
 type node struct {
-	Pos position.Pos
+	Pos      position.Pos
+	comments *Comments // nil means no comment(s) attached
 }
 
 func (n *node) GetPos() position.Pos { return n.Pos }
@@ -26,6 +29,8 @@ func (*node) aNode()                 {}
 func (n *node) SetPos(pos position.Pos) {
 	n.Pos = pos
 }
+func (n *node) Comments() *Comments     { return n.comments }
+func (n *node) SetComments(c *Comments) { n.comments = c }
 
 type File struct {
 	SpaceName *Name
@@ -57,11 +62,24 @@ type (
 		decl
 	}
 
+	//              NameList
+	//              NameList      = Values
+	//              NameList Type = Values
+	ConstDecl struct {
+		Group    *Group // nil means not part of a group
+		NameList *Name
+		Type     Expr // nil means no type
+		Values   Expr // nil means no values (inherited from the previous ConstDecl of the group)
+		decl
+	}
+
+	//              Name TParamList Type
 	TypeDecl struct {
-		Group *Group
-		Name  *Name
-		Alias bool
-		Type  Expr
+		Group      *Group
+		Name       *Name
+		TParamList []*Field // nil means no type parameters
+		Alias      bool
+		Type       Expr
 		decl
 	}
 
@@ -74,11 +92,13 @@ type (
 	}
 
 	FuncDecl struct {
-		Group  *Group // nil means not part of a group
-		Param  []*Field
-		Name   *Name // identifier
-		Return Expr  // nil means no return type
-		Body   *BlockStmt
+		Group      *Group // nil means not part of a group
+		Recv       *Field // nil means regular function
+		Param      []*Field
+		Name       *Name    // identifier
+		TParamList []*Field // nil means no type parameters
+		Return     Expr     // nil means no return type; a *ListExpr for several results
+		Body       *BlockStmt
 		decl
 	}
 )
@@ -87,6 +107,31 @@ type decl struct{ node }
 
 func (*decl) aDecl() {}
 
+// IsGeneric reports whether d declares a generic function or a method
+// of a generic type.
+func (d *FuncDecl) IsGeneric() bool {
+	if d.TParamList != nil {
+		return true
+	}
+	if d.Recv != nil {
+		typ, _ := d.RecvType()
+		_, ok := typ.(*IndexExpr)
+		return ok
+	}
+	return false
+}
+
+// RecvType returns the receiver type of the method d without the
+// indirection of a pointer receiver, and reports whether the receiver
+// is a pointer.
+func (d *FuncDecl) RecvType() (typ Expr, ptr bool) {
+	typ = d.Recv.Type
+	if x, ok := typ.(*Operation); ok && x.Op == token.Mul && x.Y == nil {
+		return x.X, true
+	}
+	return typ, false
+}
+
 func NewName(pos position.Pos, value string) *Name {
 	n := new(Name)
 	n.Pos = pos
@@ -106,9 +151,12 @@ const (
 	DeclSt
 	DefineSt
 	AssignSt
+	LabeledSt
 	IfSt
 	ForSt
+	RangeSt
 	WhileSt
+	SwitchSt
 	BlockSt
 )
 
@@ -134,21 +182,23 @@ type (
 	}
 
 	IncDecStmt struct {
-		X   Expr
-		Tok token.Token
+		X  Expr
+		Op token.Operator // Add (x++) or Sub (x--)
 		simpleStmt
 	}
 
 	ContinueStmt struct {
+		Label *Name // nil means no label
 		simpleStmt
 	}
 
 	BreakStmt struct {
+		Label *Name // nil means no label
 		simpleStmt
 	}
 
 	ReturnStmt struct {
-		Result Expr
+		Result Expr // nil means no result; a *ListExpr for several results
 		stmt
 	}
 
@@ -157,12 +207,14 @@ type (
 		stmt
 	}
 
+	// Lhs := Rhs; Lhs and Rhs are *ListExpr nodes for several operands
 	DefineStmt struct {
 		Lhs Expr
 		Rhs Expr
 		simpleStmt
 	}
 
+	// Lhs Op= Rhs; Lhs and Rhs are *ListExpr nodes for several operands
 	AssignStmt struct {
 		Lhs Expr
 		Op  token.Operator
@@ -170,6 +222,20 @@ type (
 		simpleStmt
 	}
 
+	// Lhs := range X; Lhs is nil for range X and a *ListExpr for two variables
+	RangeClause struct {
+		Lhs Expr
+		X   Expr
+		simpleStmt
+	}
+
+	// Label: Stmt
+	LabeledStmt struct {
+		Label *Name
+		Stmt  Stmt
+		stmt
+	}
+
 	IfStmt struct {
 		Cond  Expr
 		Block *BlockStmt
@@ -178,7 +244,7 @@ type (
 	}
 
 	ForStmt struct {
-		Init SimpleStmt
+		Init SimpleStmt // a *RangeClause for a range loop, with nil Cond and Post
 		Cond Expr
 		Post SimpleStmt
 		Body *BlockStmt
@@ -191,6 +257,14 @@ type (
 		stmt
 	}
 
+	// switch Tag { Body[0] Body[1] ... }
+	SwitchStmt struct {
+		Tag    Expr // nil means no tag; a *TypeSwitchGuard for a type switch
+		Body   []*CaseClause
+		Rbrace position.Pos
+		stmt
+	}
+
 	simpleStmt struct {
 		stmt
 	}
@@ -202,6 +276,14 @@ type (
 	}
 )
 
+// case Cases: Body
+type CaseClause struct {
+	Cases Expr // nil means default clause; a *ListExpr for several cases
+	Body  []Stmt
+	Colon position.Pos
+	node
+}
+
 func (s *stmt) StmtType() StmtType {
 	//TODO implement me
 	panic("implement me")
@@ -244,6 +326,22 @@ type (
 	SliceLit struct {
 		ElemType Expr
 		Elems    []Expr
+		Lines    bool // the closing } is on a line of its own
+		expr
+	}
+
+	// Type { ElemList[0], ElemList[1], ... }
+	CompositeLit struct {
+		Type     Expr // nil means no literal type
+		ElemList []Expr
+		NKeys    int  // number of elements with keys
+		Lines    bool // the closing } is on a line of its own
+		expr
+	}
+
+	// Key: Value
+	KeyValueExpr struct {
+		Key, Value Expr
 		expr
 	}
 
@@ -262,6 +360,38 @@ type (
 		expr
 	}
 
+	// map[Key]Value
+	MapType struct {
+		Key, Value Expr
+		expr
+	}
+
+	// struct { FieldList[0]; FieldList[1]; ... }
+	StructType struct {
+		FieldList []*Field
+		expr
+	}
+
+	// interface { MethodList[0]; MethodList[1]; ... }
+	InterfaceType struct {
+		MethodList []*Field // method specs; Field.Type is a *FuncType
+		expr
+	}
+
+	// func(Param[0], Param[1], ...) Return
+	FuncType struct {
+		Param  []*Field
+		Return Expr // nil means no result; a *ListExpr for several results
+		expr
+	}
+
+	// func(Type.Param[0], ...) Type.Return { Body }
+	FuncLit struct {
+		Type *FuncType
+		Body *BlockStmt
+		expr
+	}
+
 	// X.Sel
 	SelectorExpr struct {
 		X   Expr
@@ -269,9 +399,29 @@ type (
 		expr
 	}
 
+	// X.(Type)
+	AssertExpr struct {
+		X    Expr
+		Type Expr
+		expr
+	}
+
+	// Lhs := X.(type)
+	TypeSwitchGuard struct {
+		Lhs *Name // nil means no Lhs :=
+		X   Expr  // X.(type)
+		expr
+	}
+
 	IndexExpr struct {
 		X     Expr
-		Index Expr
+		Index Expr // a *ListExpr for several type arguments
+		expr
+	}
+
+	// ElemList[0], ElemList[1], ...
+	ListExpr struct {
+		ElemList []Expr
 		expr
 	}
 
@@ -295,6 +445,33 @@ type expr struct{ node }
 
 func (*expr) aExpr() {}
 
+// A Group is shared by the declarations of a parenthesized group.
+// Its comments are those preceding the group, following its closing
+// parenthesis on the same line, and following its last declaration.
 type Group struct {
-	_ int // not empty so we are guaranteed different Group instances
+	comments *Comments // nil means no comment(s) attached; also keeps Group instances distinct
+}
+
+func (g *Group) Comments() *Comments     { return g.comments }
+func (g *Group) SetComments(c *Comments) { g.comments = c }
+
+// ----------------------------------------------------------------------------
+// Comments
+
+// A Comment represents a single //-style or /*-style comment,
+// or an empty line if Text is empty.
+type Comment struct {
+	Text string // comment text, including // or /* */ but excluding the final newline
+}
+
+// Comments holds the comments attached to a node. The parser attaches
+// comments to declarations, declaration groups, statements, struct
+// fields, interface method specs, parameters, arguments, elements of
+// composite literals and binary operations only, and only if requested.
+// The comments of a list of fields sharing the same type are attached
+// to its first field.
+type Comments struct {
+	Alone []Comment // comments on lines of their own preceding the node, such as doc comments
+	After []Comment // comments following the node on the same line
+	Final []Comment // File, BlockStmt, Group, SwitchStmt, StructType, InterfaceType, CallExpr, SliceLit and CompositeLit only: comments following the last declaration, statement, field, method, argument or element; Operation only: comments following the operator on the same line
 }
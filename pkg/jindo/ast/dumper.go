@@ -100,10 +100,9 @@ func (p *dumper) printf(format string, args ...interface{}) {
 // dump prints the contents of x.
 // If x is the reflect.Value of a struct s, where &s
 // implements ast.Node, then &s should be passed for n -
-// this permits printing of the unexported span and
-// comments fields of the embedded isast.Node field by
-// calling the Span() and Comment() instead of using
-// reflection.
+// this permits printing of the unexported comments
+// field of the embedded node field by calling Comments()
+// instead of using reflection.
 func (p *dumper) dump(x reflect.Value, n Node) {
 	switch x.Kind() {
 	case reflect.Interface:
@@ -172,12 +171,11 @@ func (p *dumper) dump(x reflect.Value, n Node) {
 		if n != nil {
 			p.printf("\n")
 			first = false
-			// p.printf("Span: %s\n", n.Span())
-			// if c := *n.Comments(); c != nil {
-			// 	p.printf("Comments: ")
-			// 	p.dump(reflect.ValueOf(c), nil) // a Comment is not a ast.Node
-			// 	p.printf("\n")
-			// }
+			if c := n.Comments(); c != nil {
+				p.printf("Comments: ")
+				p.dump(reflect.ValueOf(c), nil) // a Comment is not a ast.Node
+				p.printf("\n")
+			}
 		}
 
 		for i, n := 0, typ.NumField(); i < n; i++ {
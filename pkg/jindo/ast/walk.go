@@ -0,0 +1,335 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements syntax tree traversal.
+
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, in source order,
+// followed by a call of w.Visit(nil).
+//
+// The instances of generic declarations created by the type checker
+// are not children of the generic declarations.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// File
+	case *File:
+		if n.SpaceName != nil {
+			Walk(v, n.SpaceName)
+		}
+		walkDeclList(v, n.DeclList)
+
+	// Declarations
+	case *ImportDecl:
+		if n.Path != nil {
+			Walk(v, n.Path)
+		}
+
+	case *OperDecl:
+		if n.TypeL != nil {
+			Walk(v, n.TypeL)
+		}
+		if n.TypeR != nil {
+			Walk(v, n.TypeR)
+		}
+		if n.Return != nil {
+			Walk(v, n.Return)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *ConstDecl:
+		if n.NameList != nil {
+			Walk(v, n.NameList)
+		}
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		if n.Values != nil {
+			Walk(v, n.Values)
+		}
+
+	case *TypeDecl:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		for _, f := range n.TParamList {
+			Walk(v, f)
+		}
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+
+	case *VarDecl:
+		if n.NameList != nil {
+			Walk(v, n.NameList)
+		}
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		if n.Values != nil {
+			Walk(v, n.Values)
+		}
+
+	case *FuncDecl:
+		if n.Recv != nil {
+			Walk(v, n.Recv)
+		}
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		for _, f := range n.TParamList {
+			Walk(v, f)
+		}
+		for _, f := range n.Param {
+			Walk(v, f)
+		}
+		if n.Return != nil {
+			Walk(v, n.Return)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	// Statements
+	case *ExprStmt:
+		Walk(v, n.X)
+
+	case *EmptyStmt:
+		// nothing to do
+
+	case *ContinueStmt:
+		if n.Label != nil {
+			Walk(v, n.Label)
+		}
+
+	case *BreakStmt:
+		if n.Label != nil {
+			Walk(v, n.Label)
+		}
+
+	case *LabeledStmt:
+		Walk(v, n.Label)
+		Walk(v, n.Stmt)
+
+	case *IncDecStmt:
+		Walk(v, n.X)
+
+	case *ReturnStmt:
+		if n.Result != nil {
+			Walk(v, n.Result)
+		}
+
+	case *DeclStmt:
+		walkDeclList(v, n.DeclList)
+
+	case *DefineStmt:
+		Walk(v, n.Lhs)
+		Walk(v, n.Rhs)
+
+	case *RangeClause:
+		if n.Lhs != nil {
+			Walk(v, n.Lhs)
+		}
+		Walk(v, n.X)
+
+	case *AssignStmt:
+		Walk(v, n.Lhs)
+		if n.Rhs != nil {
+			Walk(v, n.Rhs)
+		}
+
+	case *IfStmt:
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		Walk(v, n.Block)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+
+	case *ForStmt:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		if n.Post != nil {
+			Walk(v, n.Post)
+		}
+		Walk(v, n.Body)
+
+	case *WhileStmt:
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		Walk(v, n.Body)
+
+	case *SwitchStmt:
+		if n.Tag != nil {
+			Walk(v, n.Tag)
+		}
+		for _, c := range n.Body {
+			Walk(v, c)
+		}
+
+	case *CaseClause:
+		if n.Cases != nil {
+			Walk(v, n.Cases)
+		}
+		for _, s := range n.Body {
+			Walk(v, s)
+		}
+
+	case *BlockStmt:
+		for _, s := range n.StmtList {
+			Walk(v, s)
+		}
+
+	// Expressions
+	case *BadExpr, *Name, *BasicLit:
+		// nothing to do
+
+	case *SliceLit:
+		if n.ElemType != nil {
+			Walk(v, n.ElemType)
+		}
+		walkExprList(v, n.Elems)
+
+	case *CompositeLit:
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		walkExprList(v, n.ElemList)
+
+	case *KeyValueExpr:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+
+	case *Operation:
+		Walk(v, n.X)
+		if n.Y != nil {
+			Walk(v, n.Y)
+		}
+
+	case *ParenExpr:
+		Walk(v, n.X)
+
+	case *SliceType:
+		Walk(v, n.Elem)
+
+	case *MapType:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+
+	case *StructType:
+		for _, f := range n.FieldList {
+			Walk(v, f)
+		}
+
+	case *InterfaceType:
+		for _, f := range n.MethodList {
+			Walk(v, f)
+		}
+
+	case *FuncType:
+		for _, f := range n.Param {
+			Walk(v, f)
+		}
+		if n.Return != nil {
+			Walk(v, n.Return)
+		}
+
+	case *FuncLit:
+		Walk(v, n.Type)
+		Walk(v, n.Body)
+
+	case *SelectorExpr:
+		Walk(v, n.X)
+		Walk(v, n.Sel)
+
+	case *AssertExpr:
+		Walk(v, n.X)
+		Walk(v, n.Type)
+
+	case *TypeSwitchGuard:
+		if n.Lhs != nil {
+			Walk(v, n.Lhs)
+		}
+		Walk(v, n.X)
+
+	case *IndexExpr:
+		Walk(v, n.X)
+		Walk(v, n.Index)
+
+	case *ListExpr:
+		walkExprList(v, n.ElemList)
+
+	case *CallExpr:
+		Walk(v, n.Func)
+		walkExprList(v, n.ArgList)
+
+	case *Field:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkDeclList(v Visitor, list []Decl) {
+	for _, d := range list {
+		Walk(v, d)
+	}
+}
+
+func walkExprList(v Visitor, list []Expr) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order: It starts by
+// calling f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package ast_test
+
+import (
+	"fmt"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"strings"
+	"testing"
+)
+
+const src = `space main
+
+type Vec []int
+
+oper (a Vec) add (b Vec) Vec { return a }
+
+func main() {
+	var s = []int{1, 2}
+	for i := 0; i < 2; i += 1 {
+		s[i] = -s[i]
+	}
+	while true { break }
+}
+`
+
+// A tracer records the nodes visited by Walk, indented by depth.
+type tracer struct {
+	buf   *strings.Builder
+	depth int
+}
+
+func (t tracer) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		return nil
+	}
+	fmt.Fprintf(t.buf, "%s%T", strings.Repeat(". ", t.depth), n)
+	switch n := n.(type) {
+	case *ast.Name:
+		fmt.Fprintf(t.buf, " %s", n.Value)
+	case *ast.BasicLit:
+		fmt.Fprintf(t.buf, " %s", n.Value)
+	case *ast.Operation:
+		fmt.Fprintf(t.buf, " %s", n.Op)
+	}
+	t.buf.WriteByte('\n')
+	return tracer{t.buf, t.depth + 1}
+}
+
+const want = `*ast.File
+. *ast.Name main
+. *ast.TypeDecl
+. . *ast.Name Vec
+. . *ast.SliceType
+. . . *ast.Name int
+. *ast.OperDecl
+. . *ast.Field
+. . . *ast.Name a
+. . . *ast.Name Vec
+. . *ast.Field
+. . . *ast.Name b
+. . . *ast.Name Vec
+. . *ast.Name Vec
+. . *ast.BlockStmt
+. . . *ast.ReturnStmt
+. . . . *ast.Name a
+. *ast.FuncDecl
+. . *ast.Name main
+. . *ast.BlockStmt
+. . . *ast.DeclStmt
+. . . . *ast.VarDecl
+. . . . . *ast.Name s
+. . . . . *ast.SliceLit
+. . . . . . *ast.Name int
+. . . . . . *ast.BasicLit 1
+. . . . . . *ast.BasicLit 2
+. . . *ast.ForStmt
+. . . . *ast.DefineStmt
+. . . . . *ast.Name i
+. . . . . *ast.BasicLit 0
+. . . . *ast.Operation <
+. . . . . *ast.Name i
+. . . . . *ast.BasicLit 2
+. . . . *ast.AssignStmt
+. . . . . *ast.Name i
+. . . . . *ast.BasicLit 1
+. . . . *ast.BlockStmt
+. . . . . *ast.AssignStmt
+. . . . . . *ast.IndexExpr
+. . . . . . . *ast.Name s
+. . . . . . . *ast.Name i
+. . . . . . *ast.Operation -
+. . . . . . . *ast.IndexExpr
+. . . . . . . . *ast.Name s
+. . . . . . . . *ast.Name i
+. . . *ast.WhileStmt
+. . . . *ast.Name true
+. . . . *ast.BlockStmt
+. . . . . *ast.BreakStmt
+`
+
+func parse(t *testing.T) *ast.File {
+	t.Helper()
+	f, err := parser.Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		t.FailNow()
+	}
+	return f
+}
+
+func TestWalk(t *testing.T) {
+	var buf strings.Builder
+	ast.Walk(tracer{&buf, 0}, parse(t))
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	// count the names outside of function bodies
+	var names []string
+	ast.Inspect(parse(t), func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			return false
+		case *ast.Name:
+			names = append(names, n.Value)
+		}
+		return true
+	})
+	if got, want := strings.Join(names, " "), "main Vec int a Vec b Vec Vec main"; got != want {
+		t.Errorf("got names %q, want %q", got, want)
+	}
+}
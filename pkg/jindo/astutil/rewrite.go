@@ -0,0 +1,445 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// Package astutil contains utilities for working with syntax trees.
+package astutil
+
+import (
+	"fmt"
+	"jindo/pkg/jindo/ast"
+	"reflect"
+)
+
+// An ApplyFunc is invoked by Apply for each node n, even if n is nil,
+// before and/or after the node's children, using a Cursor describing
+// the current node and providing operations on it.
+//
+// The return value of ApplyFunc controls the syntax tree traversal.
+// See Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree recursively, starting with root,
+// and calling pre and post for each node as described below.
+// Apply returns the syntax tree, possibly modified.
+//
+// If pre is not nil, it is called for each node before the node's
+// children are traversed (pre-order). If pre returns false, no
+// children are traversed, and post is not called for that node.
+//
+// If post is not nil, and a prior call of pre didn't return false,
+// post is called for each node after its children are traversed
+// (post-order). If post returns false, traversal is terminated and
+// Apply returns immediately.
+//
+// Only fields that refer to syntax tree nodes are considered children;
+// the operator overloads and instances recorded by the type checker
+// are not.
+// Children are traversed in source order, as by ast.Walk.
+//
+// Apply never changes the positions of existing nodes. A node that
+// replaces or is inserted next to the current node takes the position
+// of the current node if its own position is unknown, so that errors
+// reported for rewritten trees still point into the source.
+//
+// Apply panics if a Cursor operation is used to store a node of the
+// wrong type in a field or list.
+func Apply(root ast.Node, pre, post ApplyFunc) (result ast.Node) {
+	parent := &struct{ ast.Node }{root}
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = parent.Node
+	}()
+	a := &application{pre: pre, post: post}
+	a.apply(parent, "Node", nil, root)
+	return
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during Apply.
+// Information about the node and its parent is available
+// from the Node, Parent, Name, and Index methods.
+//
+// If p is a variable of type and value of the current parent node
+// c.Parent(), and f is the field identifier with name c.Name(),
+// the following invariants hold:
+//
+//	p.f            == c.Node()  if c.Index() <  0
+//	p.f[c.Index()] == c.Node()  if c.Index() >= 0
+//
+// The methods Replace, Delete, InsertBefore, and InsertAfter
+// can be used to change the syntax tree.
+type Cursor struct {
+	parent ast.Node
+	name   string
+	iter   *iterator // valid if non-nil
+	node   ast.Node
+}
+
+// Node returns the current Node.
+func (c *Cursor) Node() ast.Node { return c.node }
+
+// Parent returns the parent of the current Node.
+func (c *Cursor) Parent() ast.Node { return c.parent }
+
+// Name returns the name of the parent Node field that contains the
+// current Node. If the parent is a *ast.File and the current Node
+// is its SpaceName, Name returns "SpaceName".
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index >= 0 of the current Node in the slice of
+// Nodes that contains it, or a value < 0 if the current Node is not
+// part of a slice. The index of the current node changes if
+// InsertBefore is called while processing the current node.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// field returns the current node's parent field value.
+func (c *Cursor) field() reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(c.parent)).FieldByName(c.name)
+}
+
+// Replace replaces the current Node with n.
+// The replacement node is not walked by Apply.
+func (c *Cursor) Replace(n ast.Node) {
+	c.inherit(n)
+	v := c.field()
+	if i := c.Index(); i >= 0 {
+		v = v.Index(i)
+	}
+	v.Set(value(v.Type(), n))
+	c.node = n
+}
+
+// Delete deletes the current Node from its containing slice.
+// If the current Node is not part of a slice, Delete panics.
+func (c *Cursor) Delete() {
+	i := c.Index()
+	if i < 0 {
+		panic("Delete node not contained in slice")
+	}
+	v := c.field()
+	l := v.Len()
+	reflect.Copy(v.Slice(i, l), v.Slice(i+1, l))
+	v.Index(l - 1).Set(reflect.Zero(v.Type().Elem()))
+	v.SetLen(l - 1)
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current Node in its containing slice.
+// If the current Node is not part of a slice, InsertAfter panics.
+// Apply does not walk n.
+func (c *Cursor) InsertAfter(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertAfter node not contained in slice")
+	}
+	c.inherit(n)
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+2, l), v.Slice(i+1, l))
+	v.Index(i + 1).Set(value(v.Type().Elem(), n))
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current Node in its containing slice.
+// If the current Node is not part of a slice, InsertBefore panics.
+// Apply does not walk n.
+func (c *Cursor) InsertBefore(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertBefore node not contained in slice")
+	}
+	c.inherit(n)
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+1, l), v.Slice(i, l))
+	v.Index(i).Set(value(v.Type().Elem(), n))
+	c.iter.index++
+}
+
+// inherit gives n the position of the current node if n's
+// position is unknown.
+func (c *Cursor) inherit(n ast.Node) {
+	if isNil(n) || isNil(c.node) || n.GetPos().IsKnown() {
+		return
+	}
+	n.SetPos(c.node.GetPos())
+}
+
+// value returns the reflect.Value of n for storing in a location
+// of type typ; a nil n yields the zero value of typ.
+func value(typ reflect.Type, n ast.Node) reflect.Value {
+	if n == nil {
+		return reflect.Zero(typ)
+	}
+	v := reflect.ValueOf(n)
+	if !v.Type().AssignableTo(typ) {
+		panic(fmt.Sprintf("astutil: cannot use %T as %s", n, typ))
+	}
+	return v
+}
+
+// isNil reports whether n is nil or a nil pointer.
+func isNil(n ast.Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// application carries all the shared data so we can pass it around cheaply.
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+// An iterator controls iteration over a slice of nodes.
+type iterator struct {
+	index, step int
+}
+
+func (a *application) apply(parent ast.Node, name string, iter *iterator, n ast.Node) {
+	// convert typed nil into untyped nil
+	if isNil(n) {
+		n = nil
+	}
+
+	// avoid heap-allocating a new cursor for each apply call; reuse a.cursor instead
+	saved := a.cursor
+	a.cursor.parent = parent
+	a.cursor.name = name
+	a.cursor.iter = iter
+	a.cursor.node = n
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	// walk children
+	// (the order of the cases matches the order of the corresponding node types in ast.go)
+	switch n := n.(type) {
+	case nil:
+		// nothing to do
+
+	// File
+	case *ast.File:
+		a.apply(n, "SpaceName", nil, n.SpaceName)
+		a.applyList(n, "DeclList")
+
+	// Declarations
+	case *ast.ImportDecl:
+		a.apply(n, "Path", nil, n.Path)
+
+	case *ast.OperDecl:
+		a.apply(n, "TypeL", nil, n.TypeL)
+		a.apply(n, "TypeR", nil, n.TypeR)
+		a.apply(n, "Return", nil, n.Return)
+		a.apply(n, "Body", nil, n.Body)
+
+	case *ast.ConstDecl:
+		a.apply(n, "NameList", nil, n.NameList)
+		a.apply(n, "Type", nil, n.Type)
+		a.apply(n, "Values", nil, n.Values)
+
+	case *ast.TypeDecl:
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "TParamList")
+		a.apply(n, "Type", nil, n.Type)
+
+	case *ast.VarDecl:
+		a.apply(n, "NameList", nil, n.NameList)
+		a.apply(n, "Type", nil, n.Type)
+		a.apply(n, "Values", nil, n.Values)
+
+	case *ast.FuncDecl:
+		a.apply(n, "Recv", nil, n.Recv)
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "TParamList")
+		a.applyList(n, "Param")
+		a.apply(n, "Return", nil, n.Return)
+		a.apply(n, "Body", nil, n.Body)
+
+	// Statements
+	case *ast.ExprStmt:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.ContinueStmt:
+		a.apply(n, "Label", nil, n.Label)
+
+	case *ast.BreakStmt:
+		a.apply(n, "Label", nil, n.Label)
+
+	case *ast.LabeledStmt:
+		a.apply(n, "Label", nil, n.Label)
+		a.apply(n, "Stmt", nil, n.Stmt)
+
+	case *ast.IncDecStmt:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.ReturnStmt:
+		a.apply(n, "Result", nil, n.Result)
+
+	case *ast.DeclStmt:
+		a.applyList(n, "DeclList")
+
+	case *ast.DefineStmt:
+		a.apply(n, "Lhs", nil, n.Lhs)
+		a.apply(n, "Rhs", nil, n.Rhs)
+
+	case *ast.RangeClause:
+		a.apply(n, "Lhs", nil, n.Lhs)
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.AssignStmt:
+		a.apply(n, "Lhs", nil, n.Lhs)
+		a.apply(n, "Rhs", nil, n.Rhs)
+
+	case *ast.IfStmt:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Block", nil, n.Block)
+		a.apply(n, "Else", nil, n.Else)
+
+	case *ast.ForStmt:
+		a.apply(n, "Init", nil, n.Init)
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Post", nil, n.Post)
+		a.apply(n, "Body", nil, n.Body)
+
+	case *ast.WhileStmt:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Body", nil, n.Body)
+
+	case *ast.SwitchStmt:
+		a.apply(n, "Tag", nil, n.Tag)
+		a.applyList(n, "Body")
+
+	case *ast.CaseClause:
+		a.apply(n, "Cases", nil, n.Cases)
+		a.applyList(n, "Body")
+
+	case *ast.BlockStmt:
+		a.applyList(n, "StmtList")
+
+	// Expressions
+	case *ast.BadExpr, *ast.Name, *ast.BasicLit:
+		// nothing to do
+
+	case *ast.SliceLit:
+		a.apply(n, "ElemType", nil, n.ElemType)
+		a.applyList(n, "Elems")
+
+	case *ast.CompositeLit:
+		a.apply(n, "Type", nil, n.Type)
+		a.applyList(n, "ElemList")
+
+	case *ast.KeyValueExpr:
+		a.apply(n, "Key", nil, n.Key)
+		a.apply(n, "Value", nil, n.Value)
+
+	case *ast.Operation:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Y", nil, n.Y)
+
+	case *ast.ParenExpr:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.SliceType:
+		a.apply(n, "Elem", nil, n.Elem)
+
+	case *ast.MapType:
+		a.apply(n, "Key", nil, n.Key)
+		a.apply(n, "Value", nil, n.Value)
+
+	case *ast.StructType:
+		a.applyList(n, "FieldList")
+
+	case *ast.InterfaceType:
+		a.applyList(n, "MethodList")
+
+	case *ast.FuncType:
+		a.applyList(n, "Param")
+		a.apply(n, "Return", nil, n.Return)
+
+	case *ast.FuncLit:
+		a.apply(n, "Type", nil, n.Type)
+		a.apply(n, "Body", nil, n.Body)
+
+	case *ast.SelectorExpr:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Sel", nil, n.Sel)
+
+	case *ast.AssertExpr:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Type", nil, n.Type)
+
+	case *ast.TypeSwitchGuard:
+		a.apply(n, "Lhs", nil, n.Lhs)
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.IndexExpr:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Index", nil, n.Index)
+
+	case *ast.ListExpr:
+		a.applyList(n, "ElemList")
+
+	case *ast.CallExpr:
+		a.apply(n, "Func", nil, n.Func)
+		a.applyList(n, "ArgList")
+
+	case *ast.Field:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Type", nil, n.Type)
+
+	default:
+		panic(fmt.Sprintf("Apply: unexpected node type %T", n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+func (a *application) applyList(parent ast.Node, name string) {
+	// avoid heap-allocating a new iterator for each applyList call; reuse a.iter instead
+	saved := a.iter
+	a.iter.index = 0
+	for {
+		// must reload parent.name each time, since cursor modifications might change it
+		v := reflect.Indirect(reflect.ValueOf(parent)).FieldByName(name)
+		if a.iter.index >= v.Len() {
+			break
+		}
+
+		// element x may be nil in a bad syntax tree
+		var x ast.Node
+		if e := v.Index(a.iter.index); e.IsValid() && !(e.Kind() == reflect.Interface && e.IsNil()) {
+			x = e.Interface().(ast.Node)
+		}
+
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, x)
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
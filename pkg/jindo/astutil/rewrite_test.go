@@ -0,0 +1,247 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package astutil
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/types"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, src string) *ast.File {
+	t.Helper()
+	f, err := parser.Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		t.FailNow()
+	}
+	return f
+}
+
+func format(t *testing.T, n ast.Node) string {
+	t.Helper()
+	var buf strings.Builder
+	if _, err := parser.Fprint(&buf, n, 0); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+var rewriteTests = []struct {
+	name      string
+	src, want string
+	pre, post ApplyFunc
+}{
+	{
+		name: "while to for",
+		src: `space main
+func main() {
+	i := 0
+	while i > 0 {
+		i -= 1
+	}
+}`,
+		want: `space main
+
+func main() {
+	i := 0
+	for i > 0 {
+		i -= 1
+	}
+}
+`,
+		post: func(c *Cursor) bool {
+			if w, ok := c.Node().(*ast.WhileStmt); ok {
+				c.Replace(&ast.ForStmt{Cond: w.Cond, Body: w.Body})
+			}
+			return true
+		},
+	},
+	{
+		name: "delete and insert statements",
+		src: `space main
+func main() {
+	println(1)
+	println(2)
+	println(3)
+}`,
+		want: `space main
+
+func main() {
+	println(0)
+	println(1)
+	println(3)
+	println(4)
+}
+`,
+		pre: func(c *Cursor) bool {
+			s, ok := c.Node().(*ast.ExprStmt)
+			if !ok {
+				return true
+			}
+			switch parser.String(s) {
+			case "println(1)":
+				c.InsertBefore(stmt("println(0)"))
+			case "println(2)":
+				c.Delete()
+			case "println(3)":
+				c.InsertAfter(stmt("println(4)"))
+			}
+			return false
+		},
+	},
+	{
+		name: "insert declarations",
+		src: `space main
+func main() {}`,
+		want: `space main
+
+var x = 1
+
+func main() {}
+
+func f() {}
+`,
+		pre: func(c *Cursor) bool {
+			if _, ok := c.Node().(*ast.FuncDecl); ok && c.Name() == "DeclList" {
+				f := parseDecls("var x = 1\nfunc f() {}")
+				c.InsertBefore(f[0])
+				c.InsertAfter(f[1])
+			}
+			return true
+		},
+	},
+	{
+		name: "stop",
+		src: `space main
+var a = 1
+var b = 2`,
+		want: `space main
+
+var a = 2
+var b = 2
+`,
+		post: func(c *Cursor) bool {
+			if lit, ok := c.Node().(*ast.BasicLit); ok {
+				c.Replace(&ast.BasicLit{Value: "2", Kind: lit.Kind})
+				return false
+			}
+			return true
+		},
+	},
+}
+
+// stmt returns the first statement of a function body holding src.
+func stmt(src string) ast.Stmt {
+	d := parseDecls("func f() {\n" + src + "\n}")[0].(*ast.FuncDecl)
+	return d.Body.StmtList[0]
+}
+
+func parseDecls(src string) []ast.Decl {
+	f, err := parser.Parse(position.NewFileBase("decls.paw"), strings.NewReader("space main\n"+src), nil, 0)
+	if err != nil {
+		panic(err)
+	}
+	return f.DeclList
+}
+
+func TestApply(t *testing.T) {
+	for _, test := range rewriteTests {
+		f := parse(t, test.src)
+		n := Apply(f, test.pre, test.post)
+		if got := format(t, n); got != test.want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestApplyRoot(t *testing.T) {
+	f := parse(t, "space main\nvar x = 1")
+	x := f.DeclList[0].(*ast.VarDecl).Values
+	n := Apply(x, func(c *Cursor) bool {
+		if c.Parent() != nil && c.Name() == "Node" {
+			c.Replace(ast.NewName(position.Pos{}, "y"))
+		}
+		return false
+	}, nil)
+	if y, ok := n.(*ast.Name); !ok || y.Value != "y" {
+		t.Fatalf("got %s, want y", parser.String(n))
+	}
+	if got, want := n.GetPos().String(), "test.paw:2:9"; got != want {
+		t.Errorf("replacement has position %s, want %s", got, want)
+	}
+}
+
+// TestOverloads replaces the operations that use an operator
+// overload by calls of a function declared for the overload.
+func TestOverloads(t *testing.T) {
+	f := parse(t, `space main
+type Vec []int
+oper (a Vec) add (b Vec) Vec { return Vec([]int{a[0] + b[0]}) }
+func main() {
+	v := Vec([]int{1})
+	println(v + v)
+}`)
+	info, err := types.Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		t.FailNow()
+	}
+
+	funcs := make(map[*ast.OperDecl]string)
+	Apply(f, func(c *Cursor) bool {
+		d, ok := c.Node().(*ast.OperDecl)
+		if !ok {
+			return true
+		}
+		// replace the overload by a function
+		name := "vec_" + d.Oper.OperName()
+		funcs[d] = name
+		c.Replace(&ast.FuncDecl{
+			Name:   ast.NewName(d.GetPos(), name),
+			Param:  []*ast.Field{d.TypeL, d.TypeR},
+			Return: d.Return,
+			Body:   d.Body,
+		})
+		return false
+	}, func(c *Cursor) bool {
+		x, ok := c.Node().(*ast.Operation)
+		if d := info.Overloads[x]; ok && d != nil {
+			c.Replace(&ast.CallExpr{
+				Func:    ast.NewName(x.GetPos(), funcs[d]),
+				ArgList: []ast.Expr{x.X, x.Y},
+			})
+		}
+		return true
+	})
+
+	want := `space main
+
+type Vec []int
+
+func vec_add(a Vec, b Vec) Vec {
+	return Vec([]int{a[0] + b[0]})
+}
+
+func main() {
+	v := Vec([]int{1})
+	println(vec_add(v, v))
+}
+`
+	if got := format(t, f); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	main := f.DeclList[2].(*ast.FuncDecl)
+	call := main.Body.StmtList[1].(*ast.ExprStmt).X.(*ast.CallExpr).ArgList[0].(*ast.CallExpr)
+	if got, want := call.GetPos().String(), "test.paw:6:12"; got != want {
+		t.Errorf("call has position %s, want %s", got, want)
+	}
+	if x := f.DeclList[1].GetPos(); !x.IsKnown() {
+		t.Errorf("function replacing the overload has unknown position")
+	}
+}
@@ -0,0 +1,1175 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// Package gogen translates jindo source files to Go.
+//
+// The space of a file becomes a Go package, and functions, variables
+// and types map to the corresponding Go declarations. The basic types
+// int, float, rune, string and bool become int, float64, rune, string
+// and bool. A while loop becomes a for loop with a condition only, and
+// a range loop becomes a Go range loop, which iterates over the runes
+// of a string like jindo's. Constants are declared with the values
+// computed by the type checker, so grouped constant declarations and
+// iota need no translation.
+//
+// Each operator declaration becomes a function named after the
+// operator and its operand types, such as
+//
+//	oper (a Vec) add (b Vec) Vec { ... }
+//
+// which becomes
+//
+//	func oper_add_Vec_Vec(a Vec, b Vec) Vec { ... }
+//
+// and binary operations resolved to an overload by the type checker
+// are rewritten into calls of these functions. Where the receiver of
+// the overload is the right operand, as for a reversed overload, the
+// operands are passed through a function literal unless they call no
+// function, so that they are still evaluated from left to right.
+//
+// An overload may implement the method of a jindo interface that is
+// named like its operator. Go interfaces are implemented by methods
+// only, so such an overload is also given a method that calls it:
+//
+//	func (x Vec) add(y Vec) Vec { return oper_add_Vec_Vec(x, y) }
+//
+// Generic functions and types are translated as their instances, which
+// the type checker declares: the instance max[int] of a function max
+// becomes a function max_int, and the instance Stack[int] of a type
+// Stack becomes a type Stack_int with the methods of Stack.
+//
+// Jindo names that are reserved in Go, such as len, get an underscore
+// appended. Should one of these made-up names, or the name of an
+// instance or operator function, be declared or used by the source file
+// as well, underscores are appended until it is unique.
+//
+// Switch statements become Go switch statements, which like jindo's
+// have no fallthrough between clauses.
+//
+// Function literals become Go function literals. Like in jindo, each
+// iteration of a for loop has its own copy of the variables declared by
+// the loop's init statement as of Go 1.22.
+//
+// Maps become Go maps. Go iterates over a map in unspecified order,
+// while jindo visits the entries in the order of their keys, so a
+// range loop over a map ranges over the iterator function jindoRange,
+// which sorts the keys first. Ranging over functions requires Go 1.23.
+//
+// Pointer types, address-of and indirection map to their Go
+// counterparts, and so do methods with pointer receivers. Pointers used
+// as map keys are ordered by address.
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/constant"
+	"go/format"
+	gotoken "go/token"
+	"io"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"jindo/pkg/jindo/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error describes an error in the translation of a file.
+// Error implements the error interface.
+type Error struct {
+	Pos position.Pos
+	Msg string
+}
+
+func (err Error) Error() string {
+	if !err.Pos.IsKnown() {
+		return err.Msg
+	}
+	return fmt.Sprintf("%s: %s", err.Pos, err.Msg)
+}
+
+var _ error = Error{} // verify that Error implements error
+
+// Generate writes the Go translation of the file f to w. The file
+// must have been type-checked without errors, and info must hold the
+// type information collected by types.Check. The output is formatted
+// as by gofmt.
+func Generate(w io.Writer, f *ast.File, info *types.Info) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if e, ok := p.(Error); ok {
+				err = e
+				return
+			}
+			panic(p)
+		}
+	}()
+
+	g := &generator{
+		info:   info,
+		opers:  make(map[*ast.OperDecl]string),
+		used:   make(map[*types.Var]bool),
+		bridge: make(map[string]bool),
+		names:  make(map[string]bool),
+		made:   make(map[interface{}]string),
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n, ok := n.(*ast.Name); ok {
+			g.names[n.Value] = true
+		}
+		return true
+	})
+	g.file(f)
+
+	// assemble the file with the imports and helpers
+	// that the translation needs
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by jindo from %s. DO NOT EDIT.\n\n", filepath.Base(f.GetPos().Base().Filename()))
+	fmt.Fprintf(&out, "package %s\n\n", g.ident(f.SpaceName.Value))
+	var imports []string
+	if g.needFmt {
+		imports = append(imports, "fmt")
+	}
+	if g.needRange {
+		imports = append(imports, "reflect", "sort")
+	}
+	switch len(imports) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&out, "import %q\n\n", imports[0])
+	default:
+		out.WriteString("import (\n")
+		for _, path := range imports {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.buf.Bytes())
+	if g.needPrint {
+		out.WriteString(printHelper)
+	}
+	if g.needRange {
+		out.WriteString(rangeHelper)
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		// the generator produced invalid Go; report it with the source
+		return Error{f.GetPos(), fmt.Sprintf("invalid Go output: %s\n%s", err, out.Bytes())}
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// printHelper implements the print builtin, which does not
+// separate its operands by blanks.
+const printHelper = `
+func jindoPrint(args ...interface{}) {
+	for _, x := range args {
+		fmt.Print(x)
+	}
+}
+`
+
+// rangeHelper implements range loops over maps, which visit the
+// entries in the order of their keys, skipping the entries deleted
+// by earlier iterations. Keys are ordered like jindo orders them:
+// numbers and strings by value, false before true, pointers by
+// address, structs by their fields in order, and interface values by
+// their dynamic type, then value, with nil first.
+const rangeHelper = `
+func jindoRange[K comparable, V any](m map[K]V) func(yield func(K, V) bool) {
+	return func(yield func(K, V) bool) {
+		keys := make([]K, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return jindoCompare(reflect.ValueOf(&keys[i]).Elem(), reflect.ValueOf(&keys[j]).Elem()) < 0
+		})
+		for _, k := range keys {
+			if v, ok := m[k]; ok && !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+func jindoCompare(x, y reflect.Value) int {
+	switch x.Kind() {
+	case reflect.Int, reflect.Int32:
+		return jindoSign(x.Int() < y.Int(), x.Int() > y.Int())
+	case reflect.Float64:
+		return jindoSign(x.Float() < y.Float(), x.Float() > y.Float())
+	case reflect.String:
+		return jindoSign(x.String() < y.String(), x.String() > y.String())
+	case reflect.Bool:
+		return jindoSign(!x.Bool() && y.Bool(), x.Bool() && !y.Bool())
+	case reflect.Pointer:
+		return jindoSign(x.Pointer() < y.Pointer(), x.Pointer() > y.Pointer())
+	case reflect.Struct:
+		for i := 0; i < x.NumField(); i++ {
+			if c := jindoCompare(x.Field(i), y.Field(i)); c != 0 {
+				return c
+			}
+		}
+	case reflect.Interface:
+		if x.IsNil() || y.IsNil() {
+			return jindoSign(x.IsNil() && !y.IsNil(), !x.IsNil() && y.IsNil())
+		}
+		x, y = x.Elem(), y.Elem()
+		if tx, ty := jindoTypeName(x.Type()), jindoTypeName(y.Type()); tx != ty {
+			return jindoSign(tx < ty, tx > ty)
+		}
+		return jindoCompare(x, y)
+	}
+	return 0
+}
+
+func jindoTypeName(t reflect.Type) string {
+	switch s := t.String(); {
+	case s == "float64":
+		return "float"
+	case s == "int32":
+		return "rune"
+	case t.Kind() == reflect.Pointer:
+		return "*" + jindoTypeName(t.Elem())
+	case t.Name() != "":
+		return t.Name()
+	default:
+		return s
+	}
+}
+
+func jindoSign(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return +1
+	}
+	return 0
+}
+`
+
+type generator struct {
+	info   *types.Info
+	buf    bytes.Buffer
+	indent int
+	opers  map[*ast.OperDecl]string // Go function names of operator declarations
+	used   map[*types.Var]bool      // variables that are read
+	bridge map[string]bool          // methods declared for overloads, by "T.name"
+	names  map[string]bool          // names of the source file and identifiers made up for it
+	made   map[interface{}]string   // identifiers made up by mangle, by key
+
+	needFmt   bool // the output uses package fmt
+	needPrint bool // the output uses jindoPrint
+	needRange bool // the output uses jindoRange
+}
+
+func (g *generator) errorf(pos position.Pos, format string, args ...interface{}) {
+	panic(Error{pos, fmt.Sprintf(format, args...)})
+}
+
+// printf writes a line of output at the current indentation.
+func (g *generator) printf(format string, args ...interface{}) {
+	g.buf.WriteString(strings.Repeat("\t", g.indent))
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// ----------------------------------------------------------------------------
+// Names
+
+// goReserved holds the Go keywords and predeclared identifiers that
+// are not jindo identifiers, and the names used by generated code.
+// jindo names that collide with them are renamed.
+var goReserved = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+
+	"any": true, "byte": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "complex64": true, "complex128": true, "copy": true,
+	"error": true, "float32": true, "float64": true, "imag": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "iota": true, "make": true,
+	"max": true, "min": true, "new": true, "panic": true,
+	"real": true, "recover": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true, "comparable": true,
+
+	"fmt": true, "reflect": true, "sort": true,
+	"jindoPrint": true, "jindoRange": true, "jindoCompare": true, "jindoTypeName": true, "jindoSign": true,
+}
+
+// ident returns the Go identifier for the jindo name, which may be the
+// name of an instance.
+func (g *generator) ident(name string) string {
+	if strings.Contains(name, "[") {
+		return g.mangle(name, instName(name))
+	}
+	if goReserved[name] {
+		return g.mangle(name, name+"_")
+	}
+	return name
+}
+
+// mangle returns the Go identifier made up for key, which is name unless
+// the source file or an identifier made up for another key uses name
+// already. Jindo identifiers are Go identifiers, so no spelling of a
+// made-up name is safe by itself; underscores are appended to name until
+// it is unique.
+func (g *generator) mangle(key interface{}, name string) string {
+	if s, ok := g.made[key]; ok {
+		return s
+	}
+	for g.names[name] {
+		name += "_"
+	}
+	g.names[name] = true
+	g.made[key] = name
+	return name
+}
+
+// instName returns the Go identifier for the instance of a generic
+// function or type named name, such as max[int] or Pair[string, []int],
+// which become max_int and Pair_string_Sint.
+func instName(name string) string {
+	name = strings.NewReplacer("[]", "S", "*", "P", ", ", "_", "[", "_", "]", "").Replace(name)
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// typ returns the Go form of the type T.
+func (g *generator) typ(T types.Type) string {
+	switch t := T.(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.Bool, types.UntypedBool:
+			return "bool"
+		case types.Int, types.UntypedInt:
+			return "int"
+		case types.Float, types.UntypedFloat:
+			return "float64"
+		case types.Rune, types.UntypedRune:
+			return "rune"
+		case types.String, types.UntypedString:
+			return "string"
+		}
+	case *types.Slice:
+		return "[]" + g.typ(t.Elem())
+	case *types.Pointer:
+		return "*" + g.typ(t.Elem())
+	case *types.Map:
+		return "map[" + g.typ(t.Key()) + "]" + g.typ(t.Elem())
+	case *types.Struct:
+		var b strings.Builder
+		b.WriteString("struct{")
+		for i := 0; i < t.NumFields(); i++ {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			f := t.Field(i)
+			b.WriteString(g.ident(f.Name()))
+			b.WriteByte(' ')
+			b.WriteString(g.typ(f.Type()))
+		}
+		b.WriteByte('}')
+		return b.String()
+	case *types.Interface:
+		if t.NumMethods() == 0 {
+			return "interface{}"
+		}
+		var b strings.Builder
+		b.WriteString("interface{ ")
+		for i := 0; i < t.NumMethods(); i++ {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			m := t.Method(i)
+			b.WriteString(g.ident(m.Name()))
+			b.WriteString(g.signature(m.Type().(*types.Signature)))
+		}
+		b.WriteString(" }")
+		return b.String()
+	case *types.Named:
+		if t.TypeArgs() != nil {
+			return g.ident(t.String())
+		}
+		return g.ident(t.Obj().Name())
+	case *types.Signature:
+		return "func" + g.signature(t)
+	case *types.Tuple:
+		list := make([]string, t.Len())
+		for i := range list {
+			list[i] = g.typ(t.At(i).Type())
+		}
+		return "(" + strings.Join(list, ", ") + ")"
+	}
+	g.errorf(position.Pos{}, "cannot translate type %s", T)
+	return ""
+}
+
+// signature returns the Go form of the signature sig
+// without the leading func keyword.
+func (g *generator) signature(sig *types.Signature) string {
+	var list []string
+	for _, p := range sig.Params() {
+		// parameters are either all named or all unnamed
+		s := g.typ(p.Type())
+		if p.Name() != "" {
+			s = g.ident(p.Name()) + " " + s
+		}
+		list = append(list, s)
+	}
+	res := ""
+	if sig.Result() != nil {
+		res = " " + g.typ(sig.Result())
+	}
+	return "(" + strings.Join(list, ", ") + ")" + res
+}
+
+// typeOf returns the Go form of the type of x.
+func (g *generator) typeOf(x ast.Expr) string {
+	t := g.info.TypeOf(x)
+	if t == nil {
+		g.errorf(x.GetPos(), "missing type for %s", types.ExprString(x))
+	}
+	return g.typ(t)
+}
+
+// operName returns the Go function name for the operator declaration d.
+func (g *generator) operName(d *ast.OperDecl) string {
+	mangle := func(x ast.Expr) string {
+		return instName(g.typeOf(x))
+	}
+	return g.mangle(d, fmt.Sprintf("oper_%s_%s_%s", d.Oper.OperName(), mangle(d.TypeL.Type), mangle(d.TypeR.Type)))
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+func (g *generator) file(f *ast.File) {
+	list := g.info.Instantiated(f.DeclList)
+	assigned := make(map[*ast.Name]bool)
+	for _, d := range list {
+		switch d := d.(type) {
+		case *ast.OperDecl:
+			g.opers[d] = g.operName(d)
+			assignments(d.Body.StmtList, assigned)
+		case *ast.FuncDecl:
+			if d.Body != nil {
+				assignments(d.Body.StmtList, assigned)
+			}
+		}
+		ast.Inspect(d, func(n ast.Node) bool {
+			if x, ok := n.(*ast.FuncLit); ok {
+				assignments(x.Body.StmtList, assigned)
+			}
+			return true
+		})
+	}
+	for n, obj := range g.info.Uses {
+		if v, ok := obj.(*types.Var); ok && !assigned[n] {
+			g.used[v] = true
+		}
+	}
+
+	for i, d := range list {
+		if i > 0 {
+			g.buf.WriteByte('\n')
+		}
+		switch d := d.(type) {
+		case *ast.ConstDecl:
+			g.constDecl(d)
+
+		case *ast.VarDecl:
+			g.varDecl(d, false)
+
+		case *ast.TypeDecl:
+			g.printf("type %s %s", g.ident(d.Name.Value), g.typ(g.info.TypeOf(d.Type)))
+
+		case *ast.FuncDecl:
+			if d.Body == nil {
+				g.errorf(d.GetPos(), "missing function body for %s", d.Name.Value)
+			}
+			name := g.ident(d.Name.Value)
+			if r := d.Recv; r != nil {
+				recv := g.typeOf(r.Type)
+				if r.Name != nil {
+					recv = g.ident(r.Name.Value) + " " + recv
+				}
+				name = fmt.Sprintf("(%s) %s", recv, name)
+			}
+			g.funcDecl(name, d.Param, d.Return, d.Body)
+
+		case *ast.OperDecl:
+			g.funcDecl(g.opers[d], []*ast.Field{d.TypeL, d.TypeR}, d.Return, d.Body)
+			if g.implements(d) {
+				g.operMethod(d)
+			}
+
+		default:
+			g.errorf(d.GetPos(), "unexpected declaration %T", d)
+		}
+	}
+}
+
+// implements reports whether the overload d implements a method of
+// an interface type of the file: whether its receiver is a defined type
+// without a method named like the operator, and an interface has such a
+// method with the operand and result of d.
+func (g *generator) implements(d *ast.OperDecl) bool {
+	recv, _ := g.info.TypeOf(d.TypeL.Type).(*types.Named)
+	if recv == nil {
+		return false
+	}
+	name := d.Oper.OperName()
+	for i := 0; i < recv.NumMethods(); i++ {
+		if recv.Method(i).Name() == name {
+			return false
+		}
+	}
+	var result types.Type
+	if d.Return != nil {
+		result = g.info.TypeOf(d.Return)
+	}
+	sig := types.NewSignature([]*types.Var{types.NewVar(position.Pos{}, "", g.info.TypeOf(d.TypeR.Type))}, result)
+	for _, T := range g.info.Types {
+		if t, ok := T.Underlying().(*types.Interface); ok {
+			for i := 0; i < t.NumMethods(); i++ {
+				if m := t.Method(i); m.Name() == name && types.Identical(m.Type(), sig) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// operMethod declares the method that calls the overload d.
+func (g *generator) operMethod(d *ast.OperDecl) {
+	name := d.Oper.OperName()
+	key := g.info.TypeOf(d.TypeL.Type).String() + "." + name
+	if g.bridge[key] {
+		g.errorf(d.GetPos(), "cannot translate overload %s: another overload of %s implements an interface method", name, key)
+	}
+	g.bridge[key] = true
+	call := fmt.Sprintf("%s(x, y)", g.opers[d])
+	res := ""
+	if d.Return != nil {
+		res = " " + g.typeOf(d.Return)
+		call = "return " + call
+	}
+	g.printf("")
+	g.printf("func (x %s) %s(y %s)%s { %s }", g.typeOf(d.TypeL.Type), g.ident(name), g.typeOf(d.TypeR.Type), res, call)
+}
+
+func (g *generator) funcDecl(name string, params []*ast.Field, result ast.Expr, body *ast.BlockStmt) {
+	var list []string
+	for _, p := range params {
+		pname := "_"
+		if p.Name != nil {
+			pname = g.ident(p.Name.Value)
+		}
+		list = append(list, pname+" "+g.typeOf(p.Type))
+	}
+	res := ""
+	if results, ok := result.(*ast.ListExpr); ok {
+		var rlist []string
+		for _, x := range results.ElemList {
+			rlist = append(rlist, g.typeOf(x))
+		}
+		res = " (" + strings.Join(rlist, ", ") + ")"
+	} else if result != nil {
+		res = " " + g.typeOf(result)
+	}
+	g.printf("func %s(%s)%s {", name, strings.Join(list, ", "), res)
+	g.block(body.StmtList)
+	g.printf("}")
+}
+
+// constDecl translates a constant declaration. Untyped constants
+// remain untyped in Go.
+func (g *generator) constDecl(d *ast.ConstDecl) {
+	c, _ := g.info.Defs[d.NameList].(*types.Const)
+	if c == nil {
+		g.errorf(d.GetPos(), "missing constant %s", d.NameList.Value)
+	}
+	typ := ""
+	if b, ok := c.Type().(*types.Basic); !ok || b.Info()&types.IsUntyped == 0 {
+		typ = " " + g.typ(c.Type())
+	}
+	g.printf("const %s%s = %s", g.ident(d.NameList.Value), typ, constLit(c.Val(), c.Type()))
+}
+
+// constLit returns a Go literal for the constant value v of type T.
+func constLit(v constant.Value, T types.Type) string {
+	switch v.Kind() {
+	case constant.Int:
+		if b, ok := T.(*types.Basic); ok && b.Kind() == types.UntypedRune {
+			if i, ok := constant.Int64Val(v); ok && utf8.ValidRune(rune(i)) && int64(rune(i)) == i {
+				return strconv.QuoteRune(rune(i))
+			}
+		}
+	case constant.Float:
+		f, _ := constant.Float64Val(v)
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if constant.Compare(constant.MakeFromLiteral(s, gotoken.FLOAT, 0), gotoken.EQL, v) {
+			if !strings.ContainsAny(s, ".e") {
+				s += ".0" // keep the literal a float
+			}
+			return s
+		}
+		// keep the value exact, as in 1.0 / 3
+		return "(" + constant.Num(v).ExactString() + ".0 / " + constant.Denom(v).ExactString() + ")"
+	}
+	return v.ExactString()
+}
+
+// varDecl translates a variable declaration. Go rejects local
+// variables that are never read, so these are marked as used.
+func (g *generator) varDecl(d *ast.VarDecl, local bool) {
+	name := g.ident(d.NameList.Value)
+	switch {
+	case d.Type == nil:
+		g.printf("var %s = %s", name, g.expr(d.Values))
+	case d.Values == nil:
+		g.printf("var %s %s", name, g.typeOf(d.Type))
+	default:
+		g.printf("var %s %s = %s", name, g.typeOf(d.Type), g.expr(d.Values))
+	}
+	if local {
+		g.markUsed(d.NameList)
+	}
+}
+
+func (g *generator) markUsed(n *ast.Name) {
+	if v, _ := g.info.Defs[n].(*types.Var); v != nil && !g.used[v] {
+		g.printf("_ = %s", g.ident(n.Value))
+	}
+}
+
+// assignments records the names assigned to by the statements in
+// list. A variable is read unless it only occurs in this position.
+func assignments(list []ast.Stmt, assigned map[*ast.Name]bool) {
+	var stmts func(list []ast.Stmt)
+	var stmt func(s ast.Stmt)
+	stmt = func(s ast.Stmt) {
+		switch s := s.(type) {
+		case *ast.AssignStmt:
+			for _, x := range parser.UnpackList(s.Lhs) {
+				if n, ok := unparen(x).(*ast.Name); ok {
+					assigned[n] = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if n, ok := unparen(s.X).(*ast.Name); ok {
+				assigned[n] = true
+			}
+		case *ast.BlockStmt:
+			stmts(s.StmtList)
+		case *ast.LabeledStmt:
+			stmt(s.Stmt)
+		case *ast.IfStmt:
+			stmts(s.Block.StmtList)
+			stmt(s.Else)
+		case *ast.ForStmt:
+			stmt(s.Init)
+			stmt(s.Post)
+			stmts(s.Body.StmtList)
+		case *ast.WhileStmt:
+			stmts(s.Body.StmtList)
+		case *ast.SwitchStmt:
+			for _, c := range s.Body {
+				stmts(c.Body)
+			}
+		}
+	}
+	stmts = func(list []ast.Stmt) {
+		for _, s := range list {
+			stmt(s)
+		}
+	}
+	stmts(list)
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+// block translates the statements of a block at one more indentation.
+func (g *generator) block(list []ast.Stmt) {
+	g.indent++
+	for _, s := range list {
+		g.stmt(s)
+	}
+	g.indent--
+}
+
+func (g *generator) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case nil, *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.BlockStmt:
+		g.printf("{")
+		g.block(s.StmtList)
+		g.printf("}")
+
+	case *ast.DeclStmt:
+		for _, d := range s.DeclList {
+			switch d := d.(type) {
+			case *ast.ConstDecl:
+				g.constDecl(d)
+			case *ast.VarDecl:
+				g.varDecl(d, true)
+			}
+		}
+
+	case *ast.IfStmt:
+		g.ifStmt(s, "")
+
+	case *ast.ForStmt:
+		if r, ok := s.Init.(*ast.RangeClause); ok {
+			g.rangeStmt(s, r)
+			break
+		}
+		g.printf("for %s; %s; %s {", g.simpleStmt(s.Init), g.exprOrNil(s.Cond), g.simpleStmt(s.Post))
+		g.block(s.Body.StmtList)
+		g.printf("}")
+
+	case *ast.WhileStmt:
+		g.printf("for %s {", g.expr(s.Cond))
+		g.block(s.Body.StmtList)
+		g.printf("}")
+
+	case *ast.SwitchStmt:
+		g.switchStmt(s)
+
+	case *ast.ReturnStmt:
+		if s.Result == nil {
+			g.printf("return")
+		} else {
+			g.printf("return %s", g.expr(s.Result))
+		}
+
+	case *ast.LabeledStmt:
+		g.printf("%s:", g.ident(s.Label.Value))
+		g.stmt(s.Stmt)
+
+	case *ast.BreakStmt:
+		if s.Label != nil {
+			g.printf("break %s", g.ident(s.Label.Value))
+		} else {
+			g.printf("break")
+		}
+
+	case *ast.ContinueStmt:
+		if s.Label != nil {
+			g.printf("continue %s", g.ident(s.Label.Value))
+		} else {
+			g.printf("continue")
+		}
+
+	case ast.SimpleStmt:
+		g.printf("%s", g.simpleStmt(s))
+		if s, ok := s.(*ast.DefineStmt); ok {
+			for _, x := range parser.UnpackList(s.Lhs) {
+				if n, ok := x.(*ast.Name); ok {
+					g.markUsed(n)
+				}
+			}
+		}
+
+	default:
+		g.errorf(s.GetPos(), "unexpected statement %T", s)
+	}
+}
+
+// ifStmt translates s, which follows the text prefix ("" or "} else ").
+func (g *generator) ifStmt(s *ast.IfStmt, prefix string) {
+	g.printf("%sif %s {", prefix, g.expr(s.Cond))
+	g.block(s.Block.StmtList)
+	switch e := s.Else.(type) {
+	case nil:
+		g.printf("}")
+	case *ast.IfStmt:
+		g.ifStmt(e, "} else ")
+	case *ast.BlockStmt:
+		g.printf("} else {")
+		g.block(e.StmtList)
+		g.printf("}")
+	default:
+		g.errorf(e.GetPos(), "invalid else branch %T", e)
+	}
+}
+
+// rangeStmt translates the range loop s with range clause r. Go
+// rejects iteration variables that are never read, so these are
+// marked as used at the start of the loop body.
+func (g *generator) rangeStmt(s *ast.ForStmt, r *ast.RangeClause) {
+	g.printf("for %s {", g.simpleStmt(r))
+	g.indent++
+	for _, x := range parser.UnpackList(r.Lhs) {
+		if n, ok := x.(*ast.Name); ok {
+			g.markUsed(n)
+		}
+	}
+	g.indent--
+	g.block(s.Body.StmtList)
+	g.printf("}")
+}
+
+// switchStmt translates s. Go rejects the variable of a type switch
+// if no clause reads it, so such a variable is marked as used in the
+// first clause.
+func (g *generator) switchStmt(s *ast.SwitchStmt) {
+	guard, _ := s.Tag.(*ast.TypeSwitchGuard)
+	// a switch without clauses declares no variable
+	unused := guard != nil && guard.Lhs != nil && len(s.Body) > 0
+	switch {
+	case guard != nil:
+		x := g.expr(guard.X) + ".(type)"
+		if unused {
+			x = g.ident(guard.Lhs.Value) + " := " + x
+			for _, c := range s.Body {
+				if v, _ := g.info.Implicits[c].(*types.Var); v != nil && g.used[v] {
+					unused = false
+				}
+			}
+		}
+		g.printf("switch %s {", x)
+	case s.Tag != nil:
+		g.printf("switch %s {", g.expr(s.Tag))
+	default:
+		g.printf("switch {")
+	}
+	for _, c := range s.Body {
+		if c.Cases == nil {
+			g.printf("default:")
+		} else {
+			list := parser.UnpackList(c.Cases)
+			cases := make([]string, len(list))
+			for i, x := range list {
+				if guard != nil && g.isNil(x) {
+					cases[i] = "nil"
+				} else if guard != nil {
+					cases[i] = g.typeOf(x)
+				} else {
+					cases[i] = g.expr(x)
+				}
+			}
+			g.printf("case %s:", strings.Join(cases, ", "))
+		}
+		if unused {
+			g.indent++
+			g.printf("_ = %s", g.ident(guard.Lhs.Value))
+			g.indent--
+			unused = false
+		}
+		g.block(c.Body)
+	}
+	g.printf("}")
+}
+
+// simpleStmt returns the translation of s, which may be nil.
+func (g *generator) simpleStmt(s ast.SimpleStmt) string {
+	switch s := s.(type) {
+	case nil, *ast.EmptyStmt:
+		return ""
+
+	case *ast.ExprStmt:
+		return g.expr(s.X)
+
+	case *ast.DefineStmt:
+		return fmt.Sprintf("%s := %s", g.expr(s.Lhs), g.expr(s.Rhs))
+
+	case *ast.RangeClause:
+		x := g.expr(s.X)
+		if t := g.info.TypeOf(s.X); t != nil {
+			if _, ok := t.Underlying().(*types.Map); ok {
+				g.needRange = true
+				x = "jindoRange(" + x + ")"
+			}
+		}
+		if s.Lhs == nil {
+			return "range " + x
+		}
+		return fmt.Sprintf("%s := range %s", g.expr(s.Lhs), x)
+
+	case *ast.AssignStmt:
+		lhs := g.expr(s.Lhs)
+		switch {
+		case g.info.Overloads[s] != nil:
+			return fmt.Sprintf("%s = %s", lhs, g.oper(g.info.Overloads[s], s.Op, s.Lhs, s.Rhs))
+		case s.Op == token.NoneOp:
+			return fmt.Sprintf("%s = %s", lhs, g.expr(s.Rhs))
+		}
+		return fmt.Sprintf("%s %s= %s", lhs, s.Op, g.expr(s.Rhs))
+
+	case *ast.IncDecStmt:
+		return fmt.Sprintf("%s%s%s", g.expr(s.X), s.Op, s.Op)
+	}
+	g.errorf(s.GetPos(), "unexpected statement %T", s)
+	return ""
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+func (g *generator) exprOrNil(x ast.Expr) string {
+	if x == nil {
+		return ""
+	}
+	return g.expr(x)
+}
+
+func (g *generator) exprList(list []ast.Expr) string {
+	var b strings.Builder
+	for i, x := range list {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(g.expr(x))
+	}
+	return b.String()
+}
+
+func (g *generator) expr(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.Name:
+		if d := g.info.Instances[x].Decl; d != nil {
+			return g.ident(d.Name.Value)
+		}
+		if tname, ok := g.info.Uses[x].(*types.TypeName); ok && tname.Parent() == types.Universe {
+			return g.typ(tname.Type())
+		}
+		return g.ident(x.Value)
+
+	case *ast.BasicLit:
+		return x.Value
+
+	case *ast.ParenExpr:
+		return "(" + g.expr(x.X) + ")"
+
+	case *ast.SliceType:
+		return "[]" + g.expr(x.Elem)
+
+	case *ast.MapType:
+		return "map[" + g.expr(x.Key) + "]" + g.expr(x.Value)
+
+	case *ast.StructType, *ast.InterfaceType, *ast.FuncType:
+		return g.typeOf(x)
+
+	case *ast.FuncLit:
+		return g.funcLit(x)
+
+	case *ast.AssertExpr:
+		return fmt.Sprintf("%s.(%s)", g.operand(x.X, unaryPrec, false), g.typeOf(x.Type))
+
+	case *ast.SliceLit:
+		return fmt.Sprintf("%s{%s}", g.typeOf(x), g.exprList(x.Elems))
+
+	case *ast.CompositeLit:
+		if x.Type == nil {
+			// the type is elided in the Go literal as well
+			return fmt.Sprintf("{%s}", g.exprList(x.ElemList))
+		}
+		return fmt.Sprintf("%s{%s}", g.typeOf(x), g.exprList(x.ElemList))
+
+	case *ast.KeyValueExpr:
+		return fmt.Sprintf("%s: %s", g.expr(x.Key), g.expr(x.Value))
+
+	case *ast.SelectorExpr:
+		return fmt.Sprintf("%s.%s", g.operand(x.X, unaryPrec, false), g.ident(x.Sel.Value))
+
+	case *ast.Operation:
+		if x.Y == nil {
+			return x.Op.String() + g.operand(x.X, unaryPrec, false)
+		}
+		if d := g.info.Overloads[x]; d != nil {
+			return g.oper(d, x.Op, x.X, x.Y)
+		}
+		prec := goPrec[x.Op]
+		return fmt.Sprintf("%s %s %s", g.operand(x.X, prec, false), x.Op, g.operand(x.Y, prec, true))
+
+	case *ast.ListExpr:
+		return g.exprList(x.ElemList)
+
+	case *ast.IndexExpr:
+		if name, ok := x.X.(*ast.Name); ok && g.info.Instances[name].Decl != nil {
+			return g.expr(name) // instantiation
+		}
+		s := fmt.Sprintf("%s[%s]", g.operand(x.X, unaryPrec, false), g.expr(x.Index))
+		if t := g.info.TypeOf(x.X); t != nil {
+			if b, ok := t.Underlying().(*types.Basic); ok && b.Info()&types.IsString != 0 {
+				// indexing a string yields an int in jindo and a byte in Go
+				s = "int(" + s + ")"
+			}
+		}
+		return s
+
+	case *ast.CallExpr:
+		return g.callExpr(x)
+	}
+	g.errorf(x.GetPos(), "unexpected expression %T", x)
+	return ""
+}
+
+// funcLit returns the translation of the function literal x. Its
+// lines are indented by go/format.
+func (g *generator) funcLit(x *ast.FuncLit) string {
+	outer, indent := g.buf, g.indent
+	g.buf, g.indent = bytes.Buffer{}, 0
+	g.funcDecl("", x.Type.Param, x.Type.Return, x.Body)
+	s := strings.TrimSuffix(g.buf.String(), "\n")
+	g.buf, g.indent = outer, indent
+	return s
+}
+
+// Go operator precedences; unaryPrec binds tighter than
+// any binary operator.
+const unaryPrec = 6
+
+var goPrec = map[token.Operator]int{
+	token.OrOr:   1,
+	token.AndAnd: 2,
+	token.Eql:    3, token.Neq: 3, token.Lss: 3, token.Leq: 3, token.Gtr: 3, token.Geq: 3,
+	token.Add: 4, token.Sub: 4, token.Or: 4, token.Xor: 4,
+	token.Mul: 5, token.Div: 5, token.Rem: 5, token.Shl: 5, token.Shr: 5, token.And: 5, token.AndNot: 5,
+}
+
+// operand translates the operand x of an operation with precedence
+// prec, parenthesizing x if Go would otherwise group it differently.
+func (g *generator) operand(x ast.Expr, prec int, right bool) string {
+	s := g.expr(x)
+	if op, ok := x.(*ast.Operation); ok && g.info.Overloads[op] == nil {
+		p := unaryPrec
+		if op.Y != nil {
+			p = goPrec[op.Op]
+		}
+		if p < prec || right && p == prec {
+			s = "(" + s + ")"
+		}
+	}
+	return s
+}
+
+// oper returns the call of the operator overload d for x op y.
+// If the receiver of d is the right operand, the operands are passed
+// through a function literal, so that they are evaluated from left
+// to right as in jindo.
+func (g *generator) oper(d *ast.OperDecl, op token.Operator, x, y ast.Expr) string {
+	if _, swapped := op.Overloaded(); d.Oper.IsReversed() == swapped {
+		return fmt.Sprintf("%s(%s, %s)", g.opers[d], g.expr(x), g.expr(y))
+	}
+	if simple(x) || simple(y) {
+		return fmt.Sprintf("%s(%s, %s)", g.opers[d], g.expr(y), g.expr(x))
+	}
+	res, ret := "", ""
+	if d.Return != nil {
+		res, ret = " "+g.typeOf(d.Return), "return "
+	}
+	return fmt.Sprintf("func(a %s, b %s)%s { %s%s(b, a) }(%s, %s)", g.typeOf(d.TypeR.Type), g.typeOf(d.TypeL.Type), res, ret, g.opers[d], g.expr(x), g.expr(y))
+}
+
+// simple reports whether the evaluation of x calls no function,
+// so that it may be evaluated out of order.
+func simple(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Name, *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return simple(x.X)
+	}
+	return false
+}
+
+func (g *generator) callExpr(x *ast.CallExpr) string {
+	// conversions
+	if T := g.info.Types[x.Func]; T != nil && g.isType(x.Func) {
+		arg := g.expr(x.ArgList[0])
+		if isString(T) && !isString(g.info.TypeOf(x.ArgList[0])) {
+			// an integer converts to the UTF-8 encoding of the rune
+			arg = "rune(" + arg + ")"
+		}
+		if _, ok := T.(*types.Pointer); ok {
+			return fmt.Sprintf("(%s)(%s)", g.typ(T), arg)
+		}
+		return fmt.Sprintf("%s(%s)", g.typ(T), arg)
+	}
+
+	// builtins
+	if n, ok := x.Func.(*ast.Name); ok {
+		if _, ok := g.info.Uses[n].(*types.Builtin); ok {
+			switch n.Value {
+			case "print":
+				g.needFmt = true
+				g.needPrint = true
+				return fmt.Sprintf("jindoPrint(%s)", g.exprList(x.ArgList))
+			case "println":
+				g.needFmt = true
+				return fmt.Sprintf("fmt.Println(%s)", g.exprList(x.ArgList))
+			}
+			return fmt.Sprintf("%s(%s)", n.Value, g.exprList(x.ArgList))
+		}
+	}
+
+	return fmt.Sprintf("%s(%s)", g.operand(x.Func, unaryPrec, false), g.exprList(x.ArgList))
+}
+
+// isType reports whether x denotes a type.
+func (g *generator) isType(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Name:
+		_, ok := g.info.Uses[x].(*types.TypeName)
+		return ok
+	case *ast.SliceType, *ast.MapType, *ast.StructType, *ast.InterfaceType, *ast.FuncType:
+		return true
+	case *ast.IndexExpr:
+		return g.isType(x.X) // instance of a generic type
+	case *ast.Operation:
+		return x.Op == token.Mul && x.Y == nil && g.isType(x.X) // pointer type
+	case *ast.ParenExpr:
+		return g.isType(x.X)
+	}
+	return false
+}
+
+func isString(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// isNil reports whether x denotes the predeclared nil.
+func (g *generator) isNil(x ast.Expr) bool {
+	n, _ := unparen(x).(*ast.Name)
+	_, ok := g.info.Uses[n].(*types.Nil)
+	return ok
+}
+
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = p.X
+	}
+}
@@ -0,0 +1,578 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package gogen
+
+import (
+	"bytes"
+	"jindo/pkg/jindo/interp"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/types"
+	"jindo/pkg/jindo/vm"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const src = `space main
+
+type Vec []int
+
+var origin = Vec([]int{0, 0})
+
+const (
+	Small = iota + 1
+	Large
+)
+
+type Point struct {
+	x, y int
+}
+
+func (p Point) sum() int {
+	return p.x + p.y
+}
+
+func (p *Point) move(dx int) {
+	p.x += dx
+}
+
+oper (a Vec) add (b Vec) Vec {
+	return Vec([]int{a[0] + b[0], a[1] + b[1]})
+}
+
+oper (a Vec) rmul (k int) Vec {
+	return Vec([]int{k * a[0], k * a[1]})
+}
+
+type Summer interface {
+	sum() int
+}
+
+type Adder interface {
+	add(b Vec) Vec
+}
+
+type Box[T any] struct {
+	val T
+}
+
+func (b Box[T]) get() T {
+	return b.val
+}
+
+func max[T ordered](a T, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func max_string(s string) string {
+	return s + "!"
+}
+
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+func main() {
+	var unused float = 1.5
+	v := Vec([]int{1, 2})
+	w := origin + v
+	w += 2 * v
+	println(len(v) * Vec([]int{3, 4}))
+	x := 0
+	while w[0] > x {
+		x = x + 3 * 3 - 1 + 1
+		if x == 9 {
+			break
+		}
+	}
+	print("w", w, string(65 + x), "\n")
+	const scale float = 1 / 2.0
+	p := Point{y: x}
+	p.x += len(w)
+	println(len(w), x, "abc"[1], unused / 2, Large * scale, p, p.sum())
+	var s Summer = p
+	var a Adder = v
+	println(s.sum(), s.(Point).x, a.add(w), s == p)
+	_, ok := s.(Point)
+	println(ok)
+	b := Box[string]{"b"}
+	println(max(v[1], 1), max[float](2, unused), max("a", b.get()), b, max_string("c"))
+	q, r := divmod(17, 5)
+	q, r = r, q
+	println(q, r)
+	add := func(k int) int { return k + q }
+	var fs []func() int
+	for i := 0; i < 3; i += 1 {
+		fs = append(fs, func() int { return add(i) })
+	}
+	println(fs[0](), fs[2]())
+	for i := 0; i < 4; i += 1 {
+		switch i {
+		case 0, 1:
+			print("low ")
+		case 3:
+			break
+		default:
+			print(i, " ")
+		}
+	}
+	var e interface{} = p
+	switch v := e.(type) {
+	case int, string:
+		println("basic")
+	case Summer:
+		println(v.sum())
+	}
+	switch {
+	case q > r:
+		println("q")
+	default:
+		println("r")
+	}
+	for i, c := range "hé" {
+		print(string(c))
+	}
+	total := 0
+	for _, k := range w {
+		total += k
+	}
+	println(total)
+outer:
+	for _, k := range w {
+		for j := 0; j < k; j += 1 {
+			if j == 2 {
+				continue outer
+			}
+			if k > 7 {
+				break outer
+			}
+			print(j)
+		}
+	}
+	println()
+	ages := map[string]int{"bo": 3, "al": 5}
+	ages["cy"] = 1
+	ages["al"] += 1
+	age, ok := ages["zz"]
+	delete(ages, "bo")
+	for k, v := range ages {
+		print(k, v, " ")
+	}
+	println(ages, len(ages), age, ok)
+	pt := Point{1, 2}
+	pt.move(2)
+	pp := &pt
+	pp.y = 5
+	var np *Point
+	println(pt, *pp, pp.sum(), np == nil, &Point{3, 4})
+}
+`
+
+const want = `// Code generated by jindo from test.paw. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+type Vec []int
+
+var origin = Vec([]int{0, 0})
+
+const Small = 1
+
+const Large = 2
+
+type Point struct {
+	x int
+	y int
+}
+
+func (p Point) sum() int {
+	return p.x + p.y
+}
+
+func (p *Point) move(dx int) {
+	p.x += dx
+}
+
+func oper_add_Vec_Vec(a Vec, b Vec) Vec {
+	return Vec([]int{a[0] + b[0], a[1] + b[1]})
+}
+
+func (x Vec) add(y Vec) Vec { return oper_add_Vec_Vec(x, y) }
+
+func oper_rmul_Vec_int(a Vec, k int) Vec {
+	return Vec([]int{k * a[0], k * a[1]})
+}
+
+type Summer interface{ sum() int }
+
+type Adder interface{ add(b Vec) Vec }
+
+type Box_string struct{ val string }
+
+func (b Box_string) get() string {
+	return b.val
+}
+
+func max_int(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func max_float(a float64, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func max_string_(a string, b string) string {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func max_string(s string) string {
+	return s + "!"
+}
+
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+func main() {
+	var unused float64 = 1.5
+	v := Vec([]int{1, 2})
+	w := oper_add_Vec_Vec(origin, v)
+	w = oper_add_Vec_Vec(w, oper_rmul_Vec_int(v, 2))
+	fmt.Println(func(a int, b Vec) Vec { return oper_rmul_Vec_int(b, a) }(len(v), Vec([]int{3, 4})))
+	x := 0
+	for w[0] > x {
+		x = x + 3*3 - 1 + 1
+		if x == 9 {
+			break
+		}
+	}
+	jindoPrint("w", w, string(rune(65+x)), "\n")
+	const scale float64 = 0.5
+	p := Point{y: x}
+	p.x += len(w)
+	fmt.Println(len(w), x, int("abc"[1]), unused/2, Large*scale, p, p.sum())
+	var s Summer = p
+	var a Adder = v
+	fmt.Println(s.sum(), s.(Point).x, a.add(w), s == p)
+	_, ok := s.(Point)
+	fmt.Println(ok)
+	b := Box_string{"b"}
+	fmt.Println(max_int(v[1], 1), max_float(2, unused), max_string_("a", b.get()), b, max_string("c"))
+	q, r := divmod(17, 5)
+	q, r = r, q
+	fmt.Println(q, r)
+	add := func(k int) int {
+		return k + q
+	}
+	var fs []func() int
+	for i := 0; i < 3; i += 1 {
+		fs = append(fs, func() int {
+			return add(i)
+		})
+	}
+	fmt.Println(fs[0](), fs[2]())
+	for i := 0; i < 4; i += 1 {
+		switch i {
+		case 0, 1:
+			jindoPrint("low ")
+		case 3:
+			break
+		default:
+			jindoPrint(i, " ")
+		}
+	}
+	var e interface{} = p
+	switch v := e.(type) {
+	case int, string:
+		fmt.Println("basic")
+	case Summer:
+		fmt.Println(v.sum())
+	}
+	switch {
+	case q > r:
+		fmt.Println("q")
+	default:
+		fmt.Println("r")
+	}
+	for i, c := range "hé" {
+		_ = i
+		jindoPrint(string(rune(c)))
+	}
+	total := 0
+	for _, k := range w {
+		total += k
+	}
+	fmt.Println(total)
+outer:
+	for _, k := range w {
+		for j := 0; j < k; j += 1 {
+			if j == 2 {
+				continue outer
+			}
+			if k > 7 {
+				break outer
+			}
+			jindoPrint(j)
+		}
+	}
+	fmt.Println()
+	ages := map[string]int{"bo": 3, "al": 5}
+	ages["cy"] = 1
+	ages["al"] += 1
+	age, ok := ages["zz"]
+	delete(ages, "bo")
+	for k, v := range jindoRange(ages) {
+		jindoPrint(k, v, " ")
+	}
+	fmt.Println(ages, len(ages), age, ok)
+	pt := Point{1, 2}
+	pt.move(2)
+	pp := &pt
+	pp.y = 5
+	var np *Point
+	fmt.Println(pt, *pp, pp.sum(), np == nil, &Point{3, 4})
+}
+
+func jindoPrint(args ...interface{}) {
+	for _, x := range args {
+		fmt.Print(x)
+	}
+}
+
+func jindoRange[K comparable, V any](m map[K]V) func(yield func(K, V) bool) {
+	return func(yield func(K, V) bool) {
+		keys := make([]K, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return jindoCompare(reflect.ValueOf(&keys[i]).Elem(), reflect.ValueOf(&keys[j]).Elem()) < 0
+		})
+		for _, k := range keys {
+			if v, ok := m[k]; ok && !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+func jindoCompare(x, y reflect.Value) int {
+	switch x.Kind() {
+	case reflect.Int, reflect.Int32:
+		return jindoSign(x.Int() < y.Int(), x.Int() > y.Int())
+	case reflect.Float64:
+		return jindoSign(x.Float() < y.Float(), x.Float() > y.Float())
+	case reflect.String:
+		return jindoSign(x.String() < y.String(), x.String() > y.String())
+	case reflect.Bool:
+		return jindoSign(!x.Bool() && y.Bool(), x.Bool() && !y.Bool())
+	case reflect.Pointer:
+		return jindoSign(x.Pointer() < y.Pointer(), x.Pointer() > y.Pointer())
+	case reflect.Struct:
+		for i := 0; i < x.NumField(); i++ {
+			if c := jindoCompare(x.Field(i), y.Field(i)); c != 0 {
+				return c
+			}
+		}
+	case reflect.Interface:
+		if x.IsNil() || y.IsNil() {
+			return jindoSign(x.IsNil() && !y.IsNil(), !x.IsNil() && y.IsNil())
+		}
+		x, y = x.Elem(), y.Elem()
+		if tx, ty := jindoTypeName(x.Type()), jindoTypeName(y.Type()); tx != ty {
+			return jindoSign(tx < ty, tx > ty)
+		}
+		return jindoCompare(x, y)
+	}
+	return 0
+}
+
+func jindoTypeName(t reflect.Type) string {
+	switch s := t.String(); {
+	case s == "float64":
+		return "float"
+	case s == "int32":
+		return "rune"
+	case t.Kind() == reflect.Pointer:
+		return "*" + jindoTypeName(t.Elem())
+	case t.Name() != "":
+		return t.Name()
+	default:
+		return s
+	}
+}
+
+func jindoSign(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return +1
+	}
+	return 0
+}
+`
+
+func TestGenerate(t *testing.T) {
+	f, err := parser.Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		t.FailNow()
+	}
+	info, err := types.Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		t.FailNow()
+	}
+	var out bytes.Buffer
+	if err := Generate(&out, f, info); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// the Go program must behave like the jindo program
+	var interpOut strings.Builder
+	if err := interp.Run(f, info, &interpOut); err != nil {
+		t.Fatal(err)
+	}
+	if goOut := goRun(t, out.Bytes()); goOut != interpOut.String() {
+		t.Errorf("go run printed %q, want %q", goOut, interpOut.String())
+	}
+}
+
+// goRun runs the Go program src and returns its output. It skips the
+// test in short mode or if the go command is not available.
+func goRun(t *testing.T, src []byte) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping go run in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "run", file)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %s\n%s", err, out)
+	}
+	return string(out)
+}
+
+// backendTests are programs for which the interpreter, the VM and the
+// Go translation must print the same.
+var backendTests = []string{
+	// untyped constants take the type they are converted to
+	`space main
+	type P struct{ x float }
+	func half(x float) float { return x / 2 }
+	func main() {
+		var g float = 1
+		println(g / 3, half(3))
+		fs := []float{1, 2}
+		m := map[string]float{"a": 1}
+		var c float
+		c = 5
+		p := P{1}
+		println(fs[0] / 2, m["a"] / 4, c / 2, p.x / 4)
+	}`,
+	// a nil case matches an interface value without dynamic type
+	`space main
+	func kind(e interface{}) string {
+		switch v := e.(type) {
+		case nil:
+			if v == nil {
+				return "nil"
+			}
+		case int:
+			return "int"
+		}
+		return "other"
+	}
+	func main() {
+		var e interface{}
+		println(kind(e), kind(1), kind("a"))
+		for _, x := range []interface{}{e, 1, "a"} {
+			switch x.(type) {
+			case string, nil:
+				println("string or nil")
+			default:
+				println("other")
+			}
+		}
+	}`,
+	// constants are exact
+	`space main
+	const big = 1 << 70
+	const third = 1.0 / 3
+	func main() {
+		var f float = third * 3
+		println(big >> 68, 0.1 + 0.2, -0.0, f)
+	}`,
+}
+
+func TestBackends(t *testing.T) {
+	for _, src := range backendTests {
+		f, err := parser.Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, 0)
+		if err != nil {
+			t.FailNow()
+		}
+		info, err := types.Check(f, func(err error) { t.Error(err) })
+		if err != nil {
+			t.FailNow()
+		}
+		var interpOut, vmOut strings.Builder
+		if err := interp.Run(f, info, &interpOut); err != nil {
+			t.Fatal(err)
+		}
+		prog, err := vm.Compile(f, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.Run(prog, &vmOut); err != nil {
+			t.Fatal(err)
+		}
+		if vmOut.String() != interpOut.String() {
+			t.Errorf("%s: VM printed %q, interpreter %q", src, vmOut.String(), interpOut.String())
+		}
+		var out bytes.Buffer
+		if err := Generate(&out, f, info); err != nil {
+			t.Fatal(err)
+		}
+		if goOut := goRun(t, out.Bytes()); goOut != interpOut.String() {
+			t.Errorf("%s: go run printed %q, interpreter %q", src, goOut, interpOut.String())
+		}
+	}
+}
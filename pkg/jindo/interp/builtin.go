@@ -0,0 +1,196 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package interp
+
+import (
+	"io"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+	"strings"
+)
+
+// universe returns the environment holding the predeclared
+// constants and functions.
+func universe() *env {
+	e := newEnv(nil)
+	e.define("true", true)
+	e.define("false", false)
+	e.define("nil", nil)
+	for _, b := range builtins {
+		e.define(b.Name, b)
+	}
+	return e
+}
+
+// predeclaredTypes returns the declarations of the predeclared types
+// that are not basic types: any is an alias for interface{}.
+func predeclaredTypes() map[string]*ast.TypeDecl {
+	any := &ast.TypeDecl{Name: ast.NewName(position.Pos{}, "any"), Alias: true, Type: new(ast.InterfaceType)}
+	return map[string]*ast.TypeDecl{"any": any}
+}
+
+var builtins = []*Builtin{
+	{"print", builtinPrint},
+	{"println", builtinPrintln},
+	{"len", builtinLen},
+	{"append", builtinAppend},
+	{"delete", builtinDelete},
+}
+
+func builtinPrint(in *interpreter, pos position.Pos, args []Value) Value {
+	var b strings.Builder
+	for _, x := range args {
+		b.WriteString(format(x))
+	}
+	in.write(b.String())
+	return nil
+}
+
+func builtinPrintln(in *interpreter, pos position.Pos, args []Value) Value {
+	var b strings.Builder
+	for i, x := range args {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(format(x))
+	}
+	b.WriteByte('\n')
+	in.write(b.String())
+	return nil
+}
+
+func builtinLen(in *interpreter, pos position.Pos, args []Value) Value {
+	if len(args) != 1 {
+		in.errorf(pos, "wrong number of arguments to len: got %d, want 1", len(args))
+	}
+	switch x := args[0].(type) {
+	case string:
+		return int64(len(x))
+	case []Value:
+		return int64(len(x))
+	case *Map:
+		return int64(len(x.Keys))
+	}
+	in.errorf(pos, "invalid argument %s for len", format(args[0]))
+	return nil
+}
+
+func builtinAppend(in *interpreter, pos position.Pos, args []Value) Value {
+	if len(args) == 0 {
+		in.errorf(pos, "not enough arguments to append")
+	}
+	s, ok := args[0].([]Value)
+	if !ok {
+		in.errorf(pos, "first argument to append must be a slice, got %s", format(args[0]))
+	}
+	r := append(s, args[1:]...)
+	if len(r) > cap(s) {
+		// the elements were copied to a new array; its structs
+		// are copies as well
+		for i := range s {
+			r[i] = copyValue(r[i])
+		}
+	}
+	return r
+}
+
+func builtinDelete(in *interpreter, pos position.Pos, args []Value) Value {
+	if len(args) != 2 {
+		in.errorf(pos, "wrong number of arguments to delete: got %d, want 2", len(args))
+	}
+	m, ok := args[0].(*Map)
+	if !ok {
+		in.errorf(pos, "first argument to delete must be a map, got %s", format(args[0]))
+	}
+	in.checkKey(pos, args[1])
+	m.delete(args[1])
+	return nil
+}
+
+// isType reports whether name denotes a basic or declared type.
+func (in *interpreter) isType(name string) bool {
+	switch name {
+	case "int", "rune", "float", "string", "bool":
+		return true
+	}
+	return in.types[name] != nil
+}
+
+// convert converts x to the type denoted by typ.
+func (in *interpreter) convert(pos position.Pos, typ ast.Expr, x Value) Value {
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		if d := in.types[in.typeKey(t)]; d != nil {
+			return in.convert(pos, d.Type, x)
+		}
+	case *ast.Name:
+		if d := in.types[t.Value]; d != nil {
+			return in.convert(pos, d.Type, x)
+		}
+		switch t.Value {
+		case "int", "rune":
+			switch x := x.(type) {
+			case int64:
+				return x
+			case float64:
+				return int64(x)
+			}
+		case "float":
+			switch x := x.(type) {
+			case int64:
+				return float64(x)
+			case float64:
+				return x
+			}
+		case "string":
+			switch x := x.(type) {
+			case string:
+				return x
+			case int64:
+				return string(rune(x))
+			}
+		case "bool":
+			if x, ok := x.(bool); ok {
+				return x
+			}
+		}
+	case *ast.SliceType:
+		if x, ok := x.([]Value); ok {
+			return x
+		}
+	case *ast.MapType:
+		if x, ok := x.(*Map); ok {
+			return x
+		}
+	case *ast.StructType:
+		if x, ok := x.(*Struct); ok {
+			return x
+		}
+	case *ast.Operation:
+		if x, ok := x.(Pointer); ok {
+			return x
+		}
+	case *ast.ParenExpr:
+		return in.convert(pos, t.X, x)
+	case *ast.InterfaceType:
+		// the value has been boxed as recorded by the type checker
+		return x
+	case *ast.FuncType:
+		switch x.(type) {
+		case nil, *Func, *Closure, *Builtin:
+			return x
+		}
+	}
+	in.errorf(pos, "cannot convert %s to %s", format(x), typeString(typ))
+	return nil
+}
+
+func (in *interpreter) write(s string) {
+	if _, err := io.WriteString(in.out, s); err != nil {
+		in.errorf(position.Pos{}, "write error: %s", err)
+	}
+}
@@ -0,0 +1,1345 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// Package interp implements a tree-walking interpreter for jindo programs.
+package interp
+
+import (
+	"fmt"
+	"go/constant"
+	"io"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"jindo/pkg/jindo/types"
+	"strconv"
+)
+
+// Error describes a runtime error. Error implements the error interface.
+type Error struct {
+	Pos position.Pos
+	Msg string
+}
+
+func (err Error) Error() string {
+	if !err.Pos.IsKnown() {
+		return err.Msg
+	}
+	return fmt.Sprintf("%s: %s", err.Pos, err.Msg)
+}
+
+var _ error = Error{} // verify that Error implements error
+
+// maxDepth bounds the call depth so that runaway recursion is
+// reported as a runtime error instead of exhausting the Go stack.
+const maxDepth = 10000
+
+// Run executes the program f by calling its main function.
+// Output of the print and println builtins is written to out.
+// Run returns the first runtime error encountered, if any.
+//
+// Operator overloads and methods are only invoked for operations
+// and selectors that the type checker has resolved in info, and values
+// are only converted to interface values where info records their
+// dynamic type; see types.Check. If info is nil, f is run as if the
+// type checker had recorded none of these.
+func Run(f *ast.File, info *types.Info, out io.Writer) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if e, ok := p.(Error); ok {
+				err = e
+				return
+			}
+			panic(p)
+		}
+	}()
+
+	if info == nil {
+		info = new(types.Info)
+	}
+	in := &interpreter{
+		out:   out,
+		info:  info,
+		types: predeclaredTypes(),
+	}
+	in.globals = newEnv(universe())
+	in.declare(f)
+
+	v := in.globals.vars["main"]
+	if v == nil {
+		in.errorf(f.GetPos(), "function main is undeclared in space %s", f.SpaceName.Value)
+	}
+	main, ok := (*v).(*Func)
+	if !ok {
+		in.errorf(f.GetPos(), "cannot run non-function main")
+	}
+	if len(main.Decl.Param) != 0 || main.Decl.Return != nil {
+		in.errorf(main.Decl.GetPos(), "func main must have no arguments and no return values")
+	}
+	in.call(main.Decl.GetPos(), main, nil)
+	return nil
+}
+
+type interpreter struct {
+	out     io.Writer
+	info    *types.Info
+	globals *env
+	types   map[string]*ast.TypeDecl
+	depth   int // current call depth
+}
+
+func (in *interpreter) errorf(pos position.Pos, format string, args ...interface{}) {
+	panic(Error{pos, fmt.Sprintf(format, args...)})
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+// declare enters the top-level declarations of f into the global
+// environment. Functions and types are entered first, and constants
+// before variables, so that variable initializers may refer to them.
+// Generic functions and types are represented by their instances,
+// which are named like max[int] and Stack[int].
+func (in *interpreter) declare(f *ast.File) {
+	list := in.info.Instantiated(f.DeclList)
+	for _, d := range list {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				in.globals.define(d.Name.Value, &Func{Decl: d})
+			}
+		case *ast.TypeDecl:
+			in.types[d.Name.Value] = d
+		}
+	}
+	in.constDecls(in.globals, f.DeclList)
+	for _, d := range f.DeclList {
+		if d, ok := d.(*ast.VarDecl); ok {
+			in.varDecl(in.globals, d)
+		}
+	}
+}
+
+// constDecls defines the constant declarations in list. The values of
+// constants known to the type checker are its exact values; others are
+// evaluated here. A declaration without values repeats the type and
+// values of the last declaration with values in its group, and iota
+// denotes the index of a declaration in its group.
+func (in *interpreter) constDecls(e *env, list []ast.Decl) {
+	var last *ast.ConstDecl // last declaration with values in the current group
+	index := 0
+	for _, d := range list {
+		c, _ := d.(*ast.ConstDecl)
+		if c == nil || c.Group == nil || last == nil || c.Group != last.Group {
+			last, index = nil, 0
+		}
+		if c == nil {
+			continue
+		}
+		if last == nil || c.Type != nil || c.Values != nil {
+			last = c
+		}
+		if obj, ok := in.info.Defs[c.NameList].(*types.Const); ok {
+			// uses of the constant are evaluated from info.Values, so
+			// an untyped constant too large for any value is left out
+			if v, ok := exactValue(obj.Val(), obj.Type()); ok {
+				e.define(c.NameList.Value, v)
+			}
+			index++
+			continue
+		}
+		if last.Values == nil {
+			in.errorf(c.GetPos(), "missing value of constant %s", c.NameList.Value)
+		}
+		scope := newEnv(e)
+		scope.define("iota", int64(index))
+		v := in.expr(scope, last.Values)
+		if last.Type != nil {
+			v = in.convert(c.GetPos(), last.Type, v)
+		}
+		e.define(c.NameList.Value, v)
+		index++
+	}
+}
+
+func (in *interpreter) varDecl(e *env, d *ast.VarDecl) {
+	var v Value
+	if d.Values != nil {
+		v = in.expr(e, d.Values)
+	} else {
+		v = in.zero(d.Type)
+	}
+	e.define(d.NameList.Value, v)
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+// A ctrl reports how the execution of a statement completed.
+type ctrl int
+
+const (
+	ctrlNone ctrl = iota
+	ctrlBreak
+	ctrlContinue
+	ctrlReturn
+)
+
+// A frame holds the state of a function activation.
+type frame struct {
+	result Value
+	target ast.Stmt // statement referred to by the last break or continue statement
+}
+
+// refers reports whether the last break or continue statement refers
+// to s. Without a target recorded by the type checker, it refers to
+// the innermost enclosing statement.
+func (fr *frame) refers(s ast.Stmt) bool {
+	return fr.target == s || fr.target == nil
+}
+
+// stop reports whether the loop s stops after an iteration of its
+// body that completed with c, and if so, how s completes.
+func (fr *frame) stop(s ast.Stmt, c ctrl) (bool, ctrl) {
+	switch {
+	case c == ctrlNone, c == ctrlContinue && fr.refers(s):
+		return false, ctrlNone
+	case c == ctrlBreak && fr.refers(s):
+		return true, ctrlNone
+	}
+	return true, c
+}
+
+func (in *interpreter) call(pos position.Pos, fn *Func, args []Value) Value {
+	d := fn.Decl
+	if d.Recv != nil {
+		// the receiver is passed as the first argument
+		recv := fn.Recv
+		if p, ok := recv.(Pointer); ok {
+			if _, ptr := d.RecvType(); !ptr {
+				recv = copyValue(*in.deref(pos, p))
+			}
+		}
+		params := append([]*ast.Field{d.Recv}, d.Param...)
+		args = append([]Value{recv}, args...)
+		return in.invoke(pos, in.globals, d.Name.Value, params, d.Return, d.Body, args)
+	}
+	return in.invoke(pos, in.globals, d.Name.Value, d.Param, d.Return, d.Body, args)
+}
+
+// callClosure calls the function literal of the closure fn.
+func (in *interpreter) callClosure(pos position.Pos, fn *Closure, args []Value) Value {
+	t := fn.Lit.Type
+	return in.invoke(pos, fn.Env, "func literal", t.Param, t.Return, fn.Lit.Body, args)
+}
+
+// oper calls the operator overload d for the operation x op y.
+func (in *interpreter) oper(pos position.Pos, d *ast.OperDecl, op token.Operator, x, y Value) Value {
+	if _, swapped := op.Overloaded(); d.Oper.IsReversed() != swapped {
+		x, y = y, x // the receiver is the right operand
+	}
+	return in.invoke(pos, in.globals, "operator "+d.Oper.OperName(), []*ast.Field{d.TypeL, d.TypeR}, d.Return, d.Body, []Value{x, y})
+}
+
+// invoke runs the body of the function or operator named name in a
+// new environment nested in outer.
+func (in *interpreter) invoke(pos position.Pos, outer *env, name string, params []*ast.Field, ret ast.Expr, body *ast.BlockStmt, args []Value) Value {
+	if len(args) != len(params) {
+		in.errorf(pos, "wrong number of arguments in call to %s: got %d, want %d", name, len(args), len(params))
+	}
+	if body == nil {
+		in.errorf(pos, "missing function body for %s", name)
+	}
+	if in.depth >= maxDepth {
+		in.errorf(pos, "stack overflow in call to %s", name)
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+
+	e := newEnv(outer)
+	for i, p := range params {
+		if p.Name != nil {
+			e.define(p.Name.Value, args[i])
+		}
+	}
+
+	fr := new(frame)
+	if in.stmtList(fr, e, body.StmtList) != ctrlReturn && ret != nil {
+		in.errorf(body.Rbrace, "missing return at end of function %s", name)
+	}
+	return fr.result
+}
+
+func (in *interpreter) stmtList(fr *frame, e *env, list []ast.Stmt) ctrl {
+	for _, s := range list {
+		if c := in.stmt(fr, e, s); c != ctrlNone {
+			return c
+		}
+	}
+	return ctrlNone
+}
+
+func (in *interpreter) stmt(fr *frame, e *env, s ast.Stmt) ctrl {
+	switch s := s.(type) {
+	case nil, *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.ExprStmt:
+		in.expr(e, s.X)
+
+	case *ast.DeclStmt:
+		in.constDecls(e, s.DeclList)
+		for _, d := range s.DeclList {
+			if d, ok := d.(*ast.VarDecl); ok {
+				in.varDecl(e, d)
+			}
+		}
+
+	case *ast.DefineStmt:
+		lhs := parser.UnpackList(s.Lhs)
+		vals := in.values(e, s.Rhs, len(lhs))
+		for i, x := range lhs {
+			name, ok := x.(*ast.Name)
+			if !ok {
+				in.errorf(s.GetPos(), "non-name on left side of :=")
+			}
+			if ref := e.vars[name.Value]; ref != nil {
+				set(ref, vals[i]) // redeclared in the same block
+			} else {
+				e.define(name.Value, vals[i])
+			}
+		}
+
+	case *ast.AssignStmt:
+		in.assign(e, s)
+
+	case *ast.BlockStmt:
+		return in.stmtList(fr, newEnv(e), s.StmtList)
+
+	case *ast.IfStmt:
+		if in.cond(e, s.Cond) {
+			return in.stmtList(fr, newEnv(e), s.Block.StmtList)
+		} else if s.Else != nil {
+			return in.stmt(fr, e, s.Else)
+		}
+
+	case *ast.ForStmt:
+		return in.forStmt(fr, e, s)
+
+	case *ast.WhileStmt:
+		for in.cond(e, s.Cond) {
+			c := in.stmtList(fr, newEnv(e), s.Body.StmtList)
+			if stop, c := fr.stop(s, c); stop {
+				return c
+			}
+		}
+
+	case *ast.SwitchStmt:
+		if c := in.switchStmt(fr, e, s); c != ctrlBreak || !fr.refers(s) {
+			return c
+		}
+
+	case *ast.LabeledStmt:
+		return in.stmt(fr, e, s.Stmt)
+
+	case *ast.ReturnStmt:
+		if list, ok := s.Result.(*ast.ListExpr); ok {
+			fr.result = Tuple(in.values(e, list, len(list.ElemList)))
+		} else if s.Result != nil {
+			fr.result = in.expr(e, s.Result)
+		}
+		return ctrlReturn
+
+	case *ast.BreakStmt:
+		fr.target = in.info.Targets[s]
+		return ctrlBreak
+
+	case *ast.ContinueStmt:
+		fr.target = in.info.Targets[s]
+		return ctrlContinue
+
+	default:
+		in.errorf(s.GetPos(), "unexpected statement %T", s)
+	}
+	return ctrlNone
+}
+
+func (in *interpreter) forStmt(fr *frame, e *env, s *ast.ForStmt) ctrl {
+	if r, ok := s.Init.(*ast.RangeClause); ok {
+		return in.rangeStmt(fr, e, s, r)
+	}
+	e = newEnv(e)
+	if s.Init != nil {
+		in.stmt(fr, e, s.Init)
+	}
+	for s.Cond == nil || in.cond(e, s.Cond) {
+		c := in.stmtList(fr, newEnv(e), s.Body.StmtList)
+		if stop, c := fr.stop(s, c); stop {
+			return c
+		}
+		// each iteration has its own copy of the variables declared
+		// by Init, which closures created by the iteration refer to
+		e = e.copy()
+		if s.Post != nil {
+			in.stmt(fr, e, s.Post)
+		}
+	}
+	return ctrlNone
+}
+
+// rangeStmt runs the range loop s with range clause r. The range
+// expression is evaluated once: a slice is iterated over the elements
+// it has when the loop starts, a string over its runes, and a map over
+// the keys it has when the loop starts, in increasing order, skipping
+// those deleted in the meantime. Each iteration has its own iteration
+// variables.
+func (in *interpreter) rangeStmt(fr *frame, e *env, s *ast.ForStmt, r *ast.RangeClause) ctrl {
+	lhs := parser.UnpackList(r.Lhs)
+	iter := func(key, val Value) ctrl {
+		e := newEnv(e)
+		for i, x := range lhs {
+			if i == 0 {
+				e.define(x.(*ast.Name).Value, key)
+			} else {
+				e.define(x.(*ast.Name).Value, val)
+			}
+		}
+		return in.stmtList(fr, newEnv(e), s.Body.StmtList)
+	}
+	switch x := in.expr(e, r.X).(type) {
+	case []Value:
+		for i := range x {
+			if stop, c := fr.stop(s, iter(int64(i), copyValue(x[i]))); stop {
+				return c
+			}
+		}
+	case string:
+		for i, r := range x {
+			if stop, c := fr.stop(s, iter(int64(i), int64(r))); stop {
+				return c
+			}
+		}
+	case *Map:
+		keys := append([]Value(nil), x.Keys...)
+		for _, k := range keys {
+			// entries deleted by an earlier iteration are skipped
+			i, ok := x.find(k)
+			if !ok {
+				continue
+			}
+			if stop, c := fr.stop(s, iter(k, copyValue(x.Elems[i]))); stop {
+				return c
+			}
+		}
+	}
+	return ctrlNone
+}
+
+// switchStmt runs the clause of s whose case matches the tag, or the
+// default clause if none does. The cases are evaluated in order until
+// one matches.
+func (in *interpreter) switchStmt(fr *frame, e *env, s *ast.SwitchStmt) ctrl {
+	g, _ := s.Tag.(*ast.TypeSwitchGuard)
+	var tag Value = true
+	switch {
+	case g != nil:
+		tag = in.expr(e, g.X)
+	case s.Tag != nil:
+		tag = in.expr(e, s.Tag)
+	}
+	var def *ast.CaseClause
+	for _, c := range s.Body {
+		if c.Cases == nil {
+			def = c
+			continue
+		}
+		for _, x := range parser.UnpackList(c.Cases) {
+			if g != nil && in.hasType(tag, x) || g == nil && in.binary(x.GetPos(), token.Eql, in.expr(e, x), tag).(bool) {
+				return in.caseBody(fr, e, c, g, tag)
+			}
+		}
+	}
+	if def != nil {
+		return in.caseBody(fr, e, def, g, tag)
+	}
+	return ctrlNone
+}
+
+// caseBody runs the statements of the clause c of a switch statement.
+// In a type switch declaring a variable, the variable holds the value
+// of tag, the interface value switched on, or its dynamic value if c
+// lists exactly one type and that is not an interface type or nil.
+func (in *interpreter) caseBody(fr *frame, e *env, c *ast.CaseClause, g *ast.TypeSwitchGuard, tag Value) ctrl {
+	e = newEnv(e)
+	if g != nil && g.Lhs != nil {
+		v := tag
+		if list := parser.UnpackList(c.Cases); len(list) == 1 && !in.isNil(list[0]) {
+			if _, ok := in.underlying(list[0]).(*ast.InterfaceType); !ok {
+				v = copyValue(tag.(*Iface).Value)
+			}
+		}
+		e.define(g.Lhs.Value, v)
+	}
+	return in.stmtList(fr, e, c.Body)
+}
+
+func (in *interpreter) assign(e *env, s *ast.AssignStmt) {
+	if lhs := parser.UnpackList(s.Lhs); len(lhs) > 1 {
+		// the locations are determined before the values are
+		// evaluated, and assigned once all values are known
+		locs := make([]location, len(lhs))
+		for i, x := range lhs {
+			locs[i] = in.location(e, x)
+		}
+		for i, v := range in.values(e, s.Rhs, len(lhs)) {
+			in.store(locs[i], v)
+		}
+		return
+	}
+	loc := in.location(e, s.Lhs)
+	v := in.expr(e, s.Rhs)
+	switch d := in.info.Overloads[s]; {
+	case d != nil:
+		v = in.oper(s.GetPos(), d, s.Op, in.box(s.Lhs, in.load(loc)), v)
+	case s.Op != token.NoneOp:
+		v = in.binary(s.GetPos(), s.Op, in.load(loc), v)
+	}
+	in.store(loc, v)
+}
+
+// A location is the target of an assignment: a variable, slice element
+// or struct field, possibly pointed to, or the element of a map for a key.
+type location struct {
+	ref *Value // or nil for a map element
+	m   *Map
+	key Value
+	pos position.Pos
+}
+
+// location returns the location denoted by x.
+func (in *interpreter) location(e *env, x ast.Expr) location {
+	if ix, ok := parser.Unparen(x).(*ast.IndexExpr); ok {
+		v := in.expr(e, ix.X)
+		if m, ok := v.(*Map); ok {
+			k := in.expr(e, ix.Index)
+			in.checkKey(ix.Index.GetPos(), k)
+			return location{m: m, key: k, pos: ix.Index.GetPos()}
+		}
+		return location{ref: in.indexRef(e, ix, v)}
+	}
+	return location{ref: in.ref(e, x)}
+}
+
+// load returns the value held by the location l.
+func (in *interpreter) load(l location) Value {
+	if l.ref != nil {
+		return *l.ref
+	}
+	v, _ := in.lookup(l.pos, l.m, l.key)
+	return v
+}
+
+// store assigns v to the location l; assigning to the element of a
+// map enters the key into the map.
+func (in *interpreter) store(l location, v Value) {
+	if l.ref != nil {
+		set(l.ref, v)
+		return
+	}
+	if l.m.Nil {
+		in.errorf(l.pos, "assignment to entry in nil map")
+	}
+	l.m.set(l.key, v)
+}
+
+// lookup returns the element of the map m for key k, or the zero value
+// of its element type if there is none, and whether there is one.
+func (in *interpreter) lookup(pos position.Pos, m *Map, k Value) (Value, bool) {
+	in.checkKey(pos, k)
+	if i, ok := m.find(k); ok {
+		return copyValue(m.Elems[i]), true
+	}
+	return m.Zero(), false
+}
+
+// checkKey reports an error if the map key k is an interface value,
+// or holds one, whose dynamic type is not comparable.
+func (in *interpreter) checkKey(pos position.Pos, k Value) {
+	switch k := k.(type) {
+	case *Iface:
+		switch k.Value.(type) {
+		case []Value, *Map, *Func, *Closure, *Builtin:
+			in.errorf(pos, "hash of unhashable type %s", k.Type)
+		}
+		in.checkKey(pos, k.Value)
+	case *Struct:
+		for _, f := range k.Fields {
+			in.checkKey(pos, f)
+		}
+	}
+}
+
+// values evaluates the expression list x of n values: either n
+// expressions, a single call of a function with n results, a single
+// map index expression providing the element and whether the key is
+// present, or a single type assertion providing the asserted value, or
+// the zero value of the type, and whether the assertion holds.
+func (in *interpreter) values(e *env, x ast.Expr, n int) []Value {
+	list := parser.UnpackList(x)
+	if a, ok := parser.Unparen(x).(*ast.AssertExpr); ok && n == 2 {
+		v, T := in.expr(e, a.X), in.info.TypeOf(a.Type)
+		ok := in.hasType(v, a.Type)
+		switch {
+		case !ok:
+			v = in.zeroOf(T)
+		case !types.IsInterface(T):
+			v = copyValue(v.(*Iface).Value)
+		}
+		return []Value{in.box(x, v), ok}
+	}
+	if ix, ok := parser.Unparen(x).(*ast.IndexExpr); ok && n == 2 {
+		m, ok := in.expr(e, ix.X).(*Map)
+		if !ok {
+			in.errorf(x.GetPos(), "assignment mismatch: %d values expected", n)
+		}
+		elem, ok := in.lookup(ix.Index.GetPos(), m, in.expr(e, ix.Index))
+		return []Value{in.box(x, elem), ok}
+	}
+	if len(list) == 1 && n > 1 {
+		t, ok := in.expr(e, list[0]).(Tuple)
+		if !ok || len(t) != n {
+			in.errorf(x.GetPos(), "assignment mismatch: %d values expected", n)
+		}
+		return t
+	}
+	vals := make([]Value, len(list))
+	for i, x := range list {
+		vals[i] = in.expr(e, x)
+	}
+	return vals
+}
+
+// ref returns a reference to the variable, slice element or struct
+// field denoted by x, possibly through a pointer. Fields are selected
+// through pointers to their struct. Other expressions, which the type
+// checker permits only as operands of selectors of pointers and method
+// values, denote a new variable holding their value.
+func (in *interpreter) ref(e *env, x ast.Expr) *Value {
+	switch x := x.(type) {
+	case *ast.Name:
+		if x.Value == "_" {
+			return new(Value)
+		}
+		v := e.lookup(x.Value)
+		if v == nil {
+			in.errorf(x.GetPos(), "undefined: %s", x.Value)
+		}
+		return v
+	case *ast.IndexExpr:
+		v := in.expr(e, x.X)
+		if m, ok := v.(*Map); ok {
+			elem, _ := in.lookup(x.Index.GetPos(), m, in.expr(e, x.Index))
+			return &elem
+		}
+		return in.indexRef(e, x, v)
+	case *ast.SelectorExpr:
+		if in.info.Methods[x] != nil || in.info.Specs[x] != nil {
+			break
+		}
+		v := *in.ref(e, x.X)
+		if p, ok := v.(Pointer); ok {
+			v = *in.deref(x.GetPos(), p)
+		}
+		s, ok := v.(*Struct)
+		if !ok {
+			in.errorf(x.GetPos(), "cannot assign to field of non-struct")
+		}
+		return &s.Fields[in.field(x.Sel, s)]
+	case *ast.Operation:
+		if x.Op == token.Mul && x.Y == nil {
+			return in.deref(x.GetPos(), in.expr(e, x.X))
+		}
+	case *ast.ParenExpr:
+		return in.ref(e, x.X)
+	}
+	v := in.expr(e, x)
+	return &v
+}
+
+// deref returns the variable that the pointer v points to.
+func (in *interpreter) deref(pos position.Pos, v Value) *Value {
+	p, ok := v.(Pointer)
+	if !ok {
+		in.errorf(pos, "cannot indirect %s", format(v))
+	}
+	if p.Ref == nil {
+		in.errorf(pos, "invalid memory address or nil pointer dereference")
+	}
+	return p.Ref
+}
+
+// indexRef returns a reference to the element of the slice v
+// selected by the index expression x.
+func (in *interpreter) indexRef(e *env, x *ast.IndexExpr, v Value) *Value {
+	s, ok := v.([]Value)
+	if !ok {
+		in.errorf(x.GetPos(), "cannot assign to index of non-slice")
+	}
+	return &s[in.index(x.Index.GetPos(), in.expr(e, x.Index), len(s))]
+}
+
+func (in *interpreter) cond(e *env, x ast.Expr) bool {
+	b, ok := in.expr(e, x).(bool)
+	if !ok {
+		in.errorf(x.GetPos(), "non-boolean condition")
+	}
+	return b
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+// expr evaluates x. If the type checker recorded the conversion of x
+// to an interface type, the result is an interface value.
+func (in *interpreter) expr(e *env, x ast.Expr) Value {
+	return in.box(x, in.rawExpr(e, x))
+}
+
+// box returns v as an interface value if the type checker recorded
+// the conversion of the expression x to an interface type, and v
+// otherwise.
+func (in *interpreter) box(x ast.Expr, v Value) Value {
+	if typ := in.info.Dynamic[x]; typ != nil {
+		return &Iface{typ, v}
+	}
+	return v
+}
+
+func (in *interpreter) rawExpr(e *env, x ast.Expr) Value {
+	// constant expressions are evaluated by the type checker
+	if v, ok := in.info.Values[x]; ok {
+		return in.constValue(x.GetPos(), v, in.info.TypeOf(x))
+	}
+
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		in.errorf(x.GetPos(), "invalid expression")
+
+	case *ast.Name:
+		if d := in.info.Instances[x].Decl; d != nil {
+			return &Func{Decl: d}
+		}
+		if _, ok := in.info.Uses[x].(*types.Nil); ok {
+			return in.zeroOf(in.info.Types[x])
+		}
+		v := e.lookup(x.Value)
+		if v == nil {
+			in.errorf(x.GetPos(), "undefined: %s", x.Value)
+		}
+		return copyValue(*v)
+
+	case *ast.BasicLit:
+		return in.literal(x)
+
+	case *ast.ParenExpr:
+		return in.expr(e, x.X)
+
+	case *ast.SliceLit:
+		s := make([]Value, len(x.Elems))
+		for i, elem := range x.Elems {
+			s[i] = in.elem(e, elem, x.ElemType)
+		}
+		return s
+
+	case *ast.CompositeLit:
+		return in.compositeLit(e, x, x.Type)
+
+	case *ast.Operation:
+		if x.Y == nil {
+			switch x.Op {
+			case token.And:
+				return Pointer{in.ref(e, x.X)}
+			case token.Mul:
+				return copyValue(*in.deref(x.GetPos(), in.expr(e, x.X)))
+			}
+			return in.unary(x.GetPos(), x.Op, in.expr(e, x.X))
+		}
+		switch x.Op {
+		case token.AndAnd:
+			return in.cond(e, x.X) && in.cond(e, x.Y)
+		case token.OrOr:
+			return in.cond(e, x.X) || in.cond(e, x.Y)
+		}
+		if d := in.info.Overloads[x]; d != nil {
+			return in.oper(x.GetPos(), d, x.Op, in.expr(e, x.X), in.expr(e, x.Y))
+		}
+		return in.binary(x.GetPos(), x.Op, in.expr(e, x.X), in.expr(e, x.Y))
+
+	case *ast.IndexExpr:
+		if name, ok := x.X.(*ast.Name); ok && in.info.Instances[name].Decl != nil {
+			return in.expr(e, name) // instantiation
+		}
+		v := in.expr(e, x.X)
+		i := in.expr(e, x.Index)
+		switch v := v.(type) {
+		case []Value:
+			return copyValue(v[in.index(x.Index.GetPos(), i, len(v))])
+		case string:
+			return int64(v[in.index(x.Index.GetPos(), i, len(v))])
+		case *Map:
+			elem, _ := in.lookup(x.Index.GetPos(), v, i)
+			return elem
+		}
+		in.errorf(x.GetPos(), "cannot index %s", format(v))
+
+	case *ast.SelectorExpr:
+		if d := in.info.Methods[x]; d != nil {
+			return &Func{d, in.recv(e, x, d)}
+		}
+		if in.info.Specs[x] != nil {
+			m, _ := in.info.Uses[x.Sel].(*types.Func)
+			return in.dynMethod(x.GetPos(), in.expr(e, x.X), m)
+		}
+		v := in.expr(e, x.X)
+		if p, ok := v.(Pointer); ok {
+			// the struct pointed to is not a copy
+			v = copyValue(*in.deref(x.GetPos(), p))
+		}
+		s, ok := v.(*Struct)
+		if !ok {
+			in.errorf(x.GetPos(), "cannot select field %s of non-struct", x.Sel.Value)
+		}
+		return s.Fields[in.field(x.Sel, s)]
+
+	case *ast.AssertExpr:
+		return in.assert(x.GetPos(), in.expr(e, x.X), x.Type)
+
+	case *ast.FuncLit:
+		return &Closure{x, e}
+
+	case *ast.CallExpr:
+		return in.callExpr(e, x)
+	}
+
+	in.errorf(x.GetPos(), "unexpected expression %T", x)
+	return nil
+}
+
+// recv evaluates the receiver of the method value x of the method d.
+// A method with a pointer receiver selected on a variable rather than a
+// pointer gets the address of the variable, and a method with a value
+// receiver selected on a pointer gets the value pointed to.
+func (in *interpreter) recv(e *env, x *ast.SelectorExpr, d *ast.FuncDecl) Value {
+	if _, ptr := d.RecvType(); ptr {
+		ref := in.ref(e, x.X)
+		if p, ok := (*ref).(Pointer); ok {
+			return p
+		}
+		return Pointer{ref}
+	}
+	v := in.expr(e, x.X)
+	if p, ok := v.(Pointer); ok {
+		return copyValue(*in.deref(x.GetPos(), p))
+	}
+	return v
+}
+
+// dynMethod returns the method value for the interface method m, bound
+// to the dynamic value of the interface value v. The method is a method
+// or, lacking that, an operator overload of the dynamic type.
+func (in *interpreter) dynMethod(pos position.Pos, v Value, m *types.Func) Value {
+	i, ok := v.(*Iface)
+	if !ok {
+		in.errorf(pos, "invalid memory address or nil pointer dereference")
+	}
+	switch d := types.MethodDecl(i.Type, m).(type) {
+	case *ast.FuncDecl:
+		return &Func{d, i.Value}
+	case *ast.OperDecl:
+		name := "operator " + d.Oper.OperName()
+		return &Builtin{name, func(in *interpreter, pos position.Pos, args []Value) Value {
+			args = append([]Value{i.Value}, args...)
+			return in.invoke(pos, in.globals, name, []*ast.Field{d.TypeL, d.TypeR}, d.Return, d.Body, args)
+		}}
+	}
+	in.errorf(pos, "%s has no method %s", i.Type, m.Name())
+	return nil
+}
+
+// assert evaluates the type assertion v.(typ) of the interface value v.
+func (in *interpreter) assert(pos position.Pos, v Value, typ ast.Expr) Value {
+	i, _ := v.(*Iface)
+	if i == nil {
+		in.errorf(pos, "interface conversion: interface is nil, not %s", typeString(typ))
+	}
+	T := in.info.TypeOf(typ)
+	if t, ok := T.Underlying().(*types.Interface); ok {
+		if m, _ := types.MissingMethod(i.Type, t); m != nil {
+			in.errorf(pos, "interface conversion: %s is not %s: missing method %s", i.Type, T, m.Name())
+		}
+		return v
+	}
+	if !types.Identical(i.Type, T) {
+		in.errorf(pos, "interface conversion: interface is %s, not %s", i.Type, T)
+	}
+	return copyValue(i.Value)
+}
+
+// hasType reports whether the interface value v holds a value of the
+// type typ, as tested by a case of a type switch. A nil case matches
+// the nil interface value.
+func (in *interpreter) hasType(v Value, typ ast.Expr) bool {
+	if in.isNil(typ) {
+		return v == nil
+	}
+	i, _ := v.(*Iface)
+	if i == nil {
+		return false
+	}
+	T := in.info.TypeOf(typ)
+	if t, ok := T.Underlying().(*types.Interface); ok {
+		return types.Implements(i.Type, t)
+	}
+	return types.Identical(i.Type, T)
+}
+
+// isNil reports whether x denotes the predeclared nil.
+func (in *interpreter) isNil(x ast.Expr) bool {
+	n, _ := parser.Unparen(x).(*ast.Name)
+	_, ok := in.info.Uses[n].(*types.Nil)
+	return ok
+}
+
+// field returns the index of the field of s selected by sel.
+func (in *interpreter) field(sel *ast.Name, s *Struct) int {
+	i := s.field(sel.Value)
+	if i < 0 {
+		in.errorf(sel.GetPos(), "undefined field %s", sel.Value)
+	}
+	return i
+}
+
+// compositeLit evaluates the composite literal x of type typ,
+// which is x.Type or, if that is elided, the element type of
+// the enclosing literal.
+func (in *interpreter) compositeLit(e *env, x *ast.CompositeLit, typ ast.Expr) Value {
+	if typ == nil {
+		in.errorf(x.GetPos(), "missing type in composite literal")
+	}
+	switch t := in.underlying(typ).(type) {
+	case *ast.StructType:
+		s := in.zero(t).(*Struct)
+		for i, elem := range x.ElemList {
+			if kv, ok := elem.(*ast.KeyValueExpr); ok {
+				key, _ := kv.Key.(*ast.Name)
+				if key == nil {
+					in.errorf(kv.GetPos(), "invalid field name in struct literal")
+				}
+				i = in.field(key, s)
+				elem = kv.Value
+			}
+			if i >= len(t.FieldList) {
+				in.errorf(elem.GetPos(), "too many values in struct literal")
+			}
+			s.Fields[i] = in.elem(e, elem, t.FieldList[i].Type)
+		}
+		return s
+	case *ast.SliceType:
+		s := make([]Value, len(x.ElemList))
+		for i, elem := range x.ElemList {
+			s[i] = in.elem(e, elem, t.Elem)
+		}
+		return s
+	case *ast.MapType:
+		m := &Map{Zero: func() Value { return in.zero(t.Value) }}
+		for _, elem := range x.ElemList {
+			kv, ok := elem.(*ast.KeyValueExpr)
+			if !ok {
+				in.errorf(elem.GetPos(), "missing key in map literal")
+			}
+			k := in.elem(e, kv.Key, t.Key)
+			in.checkKey(kv.Key.GetPos(), k)
+			m.set(k, in.elem(e, kv.Value, t.Value))
+		}
+		return m
+	}
+	in.errorf(x.GetPos(), "invalid composite literal type %s", typeString(typ))
+	return nil
+}
+
+// elem evaluates the element x of a composite literal whose element
+// type is typ; x may be a composite literal with elided type.
+func (in *interpreter) elem(e *env, x ast.Expr, typ ast.Expr) Value {
+	if lit, ok := x.(*ast.CompositeLit); ok && lit.Type == nil {
+		return in.compositeLit(e, lit, typ)
+	}
+	return in.expr(e, x)
+}
+
+func (in *interpreter) index(pos position.Pos, i Value, n int) int {
+	k, ok := i.(int64)
+	if !ok {
+		in.errorf(pos, "non-integer index %s", format(i))
+	}
+	if k < 0 || k >= int64(n) {
+		in.errorf(pos, "index out of range [%d] with length %d", k, n)
+	}
+	return int(k)
+}
+
+func (in *interpreter) callExpr(e *env, x *ast.CallExpr) Value {
+	// conversions
+	if in.isConversion(e, x.Func) {
+		if len(x.ArgList) != 1 {
+			in.errorf(x.GetPos(), "wrong number of arguments in conversion to %s", typeString(x.Func))
+		}
+		return in.convert(x.GetPos(), x.Func, in.expr(e, x.ArgList[0]))
+	}
+
+	fn := in.expr(e, x.Func)
+	args := make([]Value, len(x.ArgList))
+	for i, arg := range x.ArgList {
+		args[i] = in.expr(e, arg)
+	}
+	switch fn := fn.(type) {
+	case *Func:
+		return in.call(x.GetPos(), fn, args)
+	case *Closure:
+		return in.callClosure(x.GetPos(), fn, args)
+	case *Builtin:
+		return fn.fn(in, x.GetPos(), args)
+	}
+	if fn == nil {
+		in.errorf(x.GetPos(), "invalid memory address or nil pointer dereference")
+	}
+	in.errorf(x.GetPos(), "cannot call non-function %s", format(fn))
+	return nil
+}
+
+// isConversion reports whether the function fun of a call denotes a
+// type: a type name, a slice, map or pointer type or an instance of a
+// generic type.
+func (in *interpreter) isConversion(e *env, fun ast.Expr) bool {
+	switch fun := fun.(type) {
+	case *ast.Name:
+		return e.lookup(fun.Value) == nil && in.isType(fun.Value)
+	case *ast.IndexExpr:
+		return in.types[in.typeKey(fun)] != nil
+	case *ast.SliceType, *ast.MapType:
+		return true
+	case *ast.Operation:
+		// a pointer type, or the indirection of a function pointer
+		return fun.Op == token.Mul && fun.Y == nil && in.isConversion(e, fun.X)
+	case *ast.ParenExpr:
+		return in.isConversion(e, fun.X)
+	}
+	return false
+}
+
+// constValue returns the value of the constant v of type T. Untyped
+// constants have the type the type checker converted them to.
+func (in *interpreter) constValue(pos position.Pos, v constant.Value, T types.Type) Value {
+	x, ok := exactValue(v, T)
+	if !ok {
+		in.errorf(pos, "invalid constant %s", v)
+	}
+	return x
+}
+
+// exactValue is like constValue but reports whether v can be represented
+// instead of an error.
+func exactValue(v constant.Value, T types.Type) (Value, bool) {
+	switch v.Kind() {
+	case constant.Bool:
+		return constant.BoolVal(v), true
+	case constant.String:
+		return constant.StringVal(v), true
+	case constant.Int, constant.Float:
+		float := v.Kind() == constant.Float
+		if T != nil {
+			b, ok := T.Underlying().(*types.Basic)
+			float = ok && b.Info()&types.IsFloat != 0
+		}
+		if float {
+			f, _ := constant.Float64Val(v)
+			return f, true
+		}
+		if i, ok := constant.Int64Val(constant.ToInt(v)); ok {
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+func (in *interpreter) literal(x *ast.BasicLit) Value {
+	if x.Bad {
+		in.errorf(x.GetPos(), "invalid literal %s", x.Value)
+	}
+	switch x.Kind {
+	case token.IntLit:
+		v, err := strconv.ParseInt(x.Value, 0, 64)
+		if err != nil {
+			in.errorf(x.GetPos(), "invalid integer literal %s", x.Value)
+		}
+		return v
+	case token.FloatLit:
+		v, err := strconv.ParseFloat(x.Value, 64)
+		if err != nil {
+			in.errorf(x.GetPos(), "invalid float literal %s", x.Value)
+		}
+		return v
+	case token.RuneLit:
+		r, _, _, err := strconv.UnquoteChar(x.Value[1:len(x.Value)-1], '\'')
+		if err != nil {
+			in.errorf(x.GetPos(), "invalid rune literal %s", x.Value)
+		}
+		return int64(r)
+	case token.StringLit:
+		s, err := strconv.Unquote(x.Value)
+		if err != nil {
+			in.errorf(x.GetPos(), "invalid string literal %s", x.Value)
+		}
+		return s
+	}
+	in.errorf(x.GetPos(), "unsupported literal %s", x.Value)
+	return nil
+}
+
+func (in *interpreter) unary(pos position.Pos, op token.Operator, x Value) Value {
+	switch op {
+	case token.Add:
+		switch x.(type) {
+		case int64, float64:
+			return x
+		}
+	case token.Sub:
+		switch x := x.(type) {
+		case int64:
+			return -x
+		case float64:
+			return -x
+		}
+	case token.Not:
+		if x, ok := x.(bool); ok {
+			return !x
+		}
+	}
+	in.errorf(pos, "invalid operation: operator %s not defined on %s", op, format(x))
+	return nil
+}
+
+func (in *interpreter) binary(pos position.Pos, op token.Operator, x, y Value) Value {
+	if op == token.Eql || op == token.Neq {
+		if eq, ok := nilEqual(x, y); ok {
+			return eq == (op == token.Eql)
+		}
+		if isIface(x) && isIface(y) {
+			return in.ifaceEqual(pos, x, y) == (op == token.Eql)
+		}
+	}
+
+	// mixed int and float operands are computed in float
+	switch xv := x.(type) {
+	case int64:
+		if _, ok := y.(float64); ok {
+			x = float64(xv)
+		}
+	case float64:
+		if yv, ok := y.(int64); ok {
+			y = float64(yv)
+		}
+	}
+
+	switch x := x.(type) {
+	case int64:
+		if y, ok := y.(int64); ok {
+			return in.intOp(pos, op, x, y)
+		}
+	case float64:
+		if y, ok := y.(float64); ok {
+			return in.floatOp(pos, op, x, y)
+		}
+	case string:
+		if y, ok := y.(string); ok {
+			switch op {
+			case token.Add:
+				return x + y
+			case token.Eql:
+				return x == y
+			case token.Neq:
+				return x != y
+			case token.Lss:
+				return x < y
+			case token.Leq:
+				return x <= y
+			case token.Gtr:
+				return x > y
+			case token.Geq:
+				return x >= y
+			}
+		}
+	case bool:
+		if y, ok := y.(bool); ok {
+			switch op {
+			case token.Eql:
+				return x == y
+			case token.Neq:
+				return x != y
+			}
+		}
+	case Pointer:
+		if y, ok := y.(Pointer); ok {
+			switch op {
+			case token.Eql:
+				return x == y
+			case token.Neq:
+				return x != y
+			}
+		}
+	case *Struct:
+		if y, ok := y.(*Struct); ok && len(x.Fields) == len(y.Fields) {
+			switch op {
+			case token.Eql, token.Neq:
+				eq := true
+				for i := range x.Fields {
+					if !in.binary(pos, token.Eql, x.Fields[i], y.Fields[i]).(bool) {
+						eq = false
+						break
+					}
+				}
+				return eq == (op == token.Eql)
+			}
+		}
+	}
+	in.errorf(pos, "invalid operation: %s %s %s", format(x), op, format(y))
+	return nil
+}
+
+// nilEqual reports whether the values x and y of a slice, map or
+// function type, one of which is nil, are equal, and whether they
+// are of such a type.
+func nilEqual(x, y Value) (eq, ok bool) {
+	switch x := x.(type) {
+	case []Value:
+		y, ok := y.([]Value)
+		return ok && x == nil && y == nil, ok
+	case *Map:
+		y, ok := y.(*Map)
+		return ok && x.Nil && y.Nil, ok
+	case *Func, *Closure, *Builtin:
+		return false, y == nil
+	case nil:
+		switch y.(type) {
+		case *Func, *Closure, *Builtin:
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// isIface reports whether v is an interface value.
+func isIface(v Value) bool {
+	switch v.(type) {
+	case nil, *Iface:
+		return true
+	}
+	return false
+}
+
+// ifaceEqual reports whether the interface values x and y are equal:
+// both are nil, or their dynamic types and values are equal.
+func (in *interpreter) ifaceEqual(pos position.Pos, x, y Value) bool {
+	xi, _ := x.(*Iface)
+	yi, _ := y.(*Iface)
+	if xi == nil || yi == nil {
+		return xi == yi
+	}
+	if !types.Identical(xi.Type, yi.Type) {
+		return false
+	}
+	switch xi.Value.(type) {
+	case []Value, *Map, *Func, *Closure, *Builtin:
+		in.errorf(pos, "comparing uncomparable type %s", xi.Type)
+	}
+	return in.binary(pos, token.Eql, xi.Value, yi.Value).(bool)
+}
+
+func (in *interpreter) intOp(pos position.Pos, op token.Operator, x, y int64) Value {
+	switch op {
+	case token.Add:
+		return x + y
+	case token.Sub:
+		return x - y
+	case token.Mul:
+		return x * y
+	case token.Div, token.Rem:
+		if y == 0 {
+			in.errorf(pos, "integer divide by zero")
+		}
+		if op == token.Div {
+			return x / y
+		}
+		return x % y
+	case token.And:
+		return x & y
+	case token.Or:
+		return x | y
+	case token.Xor:
+		return x ^ y
+	case token.AndNot:
+		return x &^ y
+	case token.Shl, token.Shr:
+		if y < 0 {
+			in.errorf(pos, "negative shift amount")
+		}
+		if op == token.Shl {
+			return x << uint64(y)
+		}
+		return x >> uint64(y)
+	case token.Eql:
+		return x == y
+	case token.Neq:
+		return x != y
+	case token.Lss:
+		return x < y
+	case token.Leq:
+		return x <= y
+	case token.Gtr:
+		return x > y
+	case token.Geq:
+		return x >= y
+	}
+	in.errorf(pos, "invalid operation: operator %s not defined on int", op)
+	return nil
+}
+
+func (in *interpreter) floatOp(pos position.Pos, op token.Operator, x, y float64) Value {
+	switch op {
+	case token.Add:
+		return x + y
+	case token.Sub:
+		return x - y
+	case token.Mul:
+		return x * y
+	case token.Div:
+		return x / y
+	case token.Eql:
+		return x == y
+	case token.Neq:
+		return x != y
+	case token.Lss:
+		return x < y
+	case token.Leq:
+		return x <= y
+	case token.Gtr:
+		return x > y
+	case token.Geq:
+		return x >= y
+	}
+	in.errorf(pos, "invalid operation: operator %s not defined on float", op)
+	return nil
+}
@@ -0,0 +1,607 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package interp
+
+import (
+	"fmt"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"jindo/pkg/jindo/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Value is a jindo runtime value. Its dynamic type is one of
+//
+//	int64    for int and rune values
+//	float64  for float values
+//	string   for string values
+//	bool     for bool values
+//	[]Value  for slices
+//	*Map     for maps
+//	Pointer  for pointers
+//	*Struct  for structs
+//	*Func    for declared functions and method values
+//	*Closure for function literals
+//	*Builtin for predeclared functions
+//	*Iface   for non-nil interface values
+//	Tuple    for the results of a function with several results
+//
+// The nil interface value and the zero value of function types are nil.
+type Value interface{}
+
+// A Tuple holds the results of a call of a function with several
+// results. Tuples are unpacked by the statement using the call.
+type Tuple []Value
+
+// A Struct is a struct value. Struct values are copied when they are
+// read from a variable, slice element or field, so that every variable
+// holds a struct of its own, and assigned to a variable field by field
+// (see set), so that pointers to its fields remain valid.
+type Struct struct {
+	Names  []string // field names, in declaration order
+	Fields []Value
+}
+
+// field returns the index of the field named name, or -1.
+func (s *Struct) field(name string) int {
+	for i, n := range s.Names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyValue returns a copy of v if v is a struct, and v otherwise.
+// Nested structs are copied as well.
+func copyValue(v Value) Value {
+	s, ok := v.(*Struct)
+	if !ok {
+		return v
+	}
+	c := &Struct{Names: s.Names, Fields: make([]Value, len(s.Fields))}
+	for i, f := range s.Fields {
+		c.Fields[i] = copyValue(f)
+	}
+	return c
+}
+
+// set assigns v to the variable *ref. A struct is assigned to the
+// struct held by the variable, field by field.
+func set(ref *Value, v Value) {
+	if d, ok := (*ref).(*Struct); ok {
+		if s, ok := v.(*Struct); ok && len(s.Fields) == len(d.Fields) {
+			for i, f := range s.Fields {
+				set(&d.Fields[i], f)
+			}
+			return
+		}
+	}
+	*ref = v
+}
+
+// A Pointer is a pointer value: a reference to a variable, slice
+// element or struct field. The zero value is the nil pointer.
+type Pointer struct {
+	Ref *Value
+}
+
+// A Map is a map value. Maps are references: the copies of a map
+// value share its entries. The entries are kept in increasing order of
+// their keys (see compareKeys), which is the order in which range loops
+// visit them. The zero value of a map type is a nil map, which has no
+// entries and cannot be assigned to.
+type Map struct {
+	Keys  []Value
+	Elems []Value
+	Zero  func() Value // zero value of the element type, which absent keys yield
+	Nil   bool         // m is a nil map
+}
+
+// find returns the index of the entry of m for key k, or the index at
+// which such an entry is inserted, and whether the entry exists.
+func (m *Map) find(k Value) (int, bool) {
+	i := sort.Search(len(m.Keys), func(i int) bool { return compareKeys(m.Keys[i], k) >= 0 })
+	return i, i < len(m.Keys) && compareKeys(m.Keys[i], k) == 0
+}
+
+// set sets the element of m for key k to v, adding an entry if needed.
+func (m *Map) set(k, v Value) {
+	i, ok := m.find(k)
+	if !ok {
+		m.Keys = append(m.Keys, nil)
+		m.Elems = append(m.Elems, nil)
+		copy(m.Keys[i+1:], m.Keys[i:])
+		copy(m.Elems[i+1:], m.Elems[i:])
+		m.Keys[i] = k
+	}
+	m.Elems[i] = v
+}
+
+// delete removes the entry of m for key k, if any.
+func (m *Map) delete(k Value) {
+	if i, ok := m.find(k); ok {
+		m.Keys = append(m.Keys[:i], m.Keys[i+1:]...)
+		m.Elems = append(m.Elems[:i], m.Elems[i+1:]...)
+	}
+}
+
+// compareKeys returns -1, 0 or +1 depending on whether the map key x is
+// less than, equal to or greater than the map key y of the same type.
+// Numbers and strings are ordered by value, false before true, pointers
+// by address, structs by their fields in order, and interface values by
+// their dynamic type (see compareTypes), then value, with nil first.
+func compareKeys(x, y Value) int {
+	switch x := x.(type) {
+	case int64:
+		return compare(x < y.(int64), x > y.(int64))
+	case float64:
+		return compare(x < y.(float64), x > y.(float64))
+	case string:
+		return compare(x < y.(string), x > y.(string))
+	case bool:
+		return compare(!x && y.(bool), x && !y.(bool))
+	case Pointer:
+		xa, ya := address(x), address(y.(Pointer))
+		return compare(xa < ya, xa > ya)
+	case *Struct:
+		y := y.(*Struct)
+		for i, f := range x.Fields {
+			if c := compareKeys(f, y.Fields[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case *Iface:
+		y, _ := y.(*Iface)
+		if y == nil {
+			return +1
+		}
+		if c := compareTypes(x.Type, y.Type); c != 0 {
+			return c
+		}
+		return compareKeys(x.Value, y.Value)
+	case nil:
+		return compare(y != nil, false)
+	}
+	return 0
+}
+
+// compareTypes orders the dynamic types x and y of interface values:
+// identical types are equal, and other types are ordered by their
+// string form, or if that is the same, by the address of the type.
+func compareTypes(x, y types.Type) int {
+	if types.Identical(x, y) {
+		return 0
+	}
+	if xs, ys := x.String(), y.String(); xs != ys {
+		return compare(xs < ys, xs > ys)
+	}
+	xa, ya := reflect.ValueOf(x).Pointer(), reflect.ValueOf(y).Pointer()
+	return compare(xa < ya, xa > ya)
+}
+
+// address returns the address of the variable that p points to,
+// or 0 if p is nil.
+func address(p Pointer) uintptr {
+	if p.Ref == nil {
+		return 0
+	}
+	return reflect.ValueOf(p.Ref).Pointer()
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return +1
+	}
+	return 0
+}
+
+// A Func is a function value backed by a function declaration.
+// If the declaration is a method, the value is a method value
+// bound to the receiver Recv. A method with a value receiver bound
+// to a pointer, the dynamic value of an interface, is called with
+// the value pointed to.
+type Func struct {
+	Decl *ast.FuncDecl
+	Recv Value
+}
+
+// A Closure is a function value backed by a function literal. The
+// literal's body is run in a new environment nested in Env, the
+// environment in which the literal was evaluated, so that the
+// variables of the enclosing function are shared with the closure.
+type Closure struct {
+	Lit *ast.FuncLit
+	Env *env
+}
+
+// An Iface is an interface value holding the value Value of the
+// dynamic type Type. Interface values are never modified; Value is
+// shared by their copies.
+type Iface struct {
+	Type  types.Type
+	Value Value
+}
+
+// A Builtin is a predeclared function implemented in Go.
+type Builtin struct {
+	Name string
+	fn   func(in *interpreter, pos position.Pos, args []Value) Value
+}
+
+// An env is a lexical environment mapping names to variables.
+// Variables are referred to through a pointer to their value so
+// that assignments are visible to every use of the variable.
+type env struct {
+	vars  map[string]*Value
+	outer *env
+}
+
+func newEnv(outer *env) *env {
+	return &env{vars: make(map[string]*Value), outer: outer}
+}
+
+// lookup returns the variable named name, or nil.
+func (e *env) lookup(name string) *Value {
+	for ; e != nil; e = e.outer {
+		if v, ok := e.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// copy returns a new environment with the same outer environment as
+// e and new variables initialized to the values of those of e.
+func (e *env) copy() *env {
+	c := newEnv(e.outer)
+	for name, v := range e.vars {
+		c.define(name, copyValue(*v))
+	}
+	return c
+}
+
+// define declares a new variable named name in e.
+func (e *env) define(name string, v Value) *Value {
+	ref := &v
+	if name != "_" {
+		e.vars[name] = ref
+	}
+	return ref
+}
+
+// zero returns the zero value for the type denoted by typ.
+func (in *interpreter) zero(typ ast.Expr) Value {
+	switch t := typ.(type) {
+	case nil:
+		return nil
+	case *ast.Name:
+		switch t.Value {
+		case "int", "rune":
+			return int64(0)
+		case "float":
+			return float64(0)
+		case "string":
+			return ""
+		case "bool":
+			return false
+		}
+		if d := in.types[t.Value]; d != nil {
+			return in.zero(d.Type)
+		}
+		in.errorf(t.GetPos(), "undefined type %s", t.Value)
+	case *ast.IndexExpr:
+		if d := in.types[in.typeKey(t)]; d != nil {
+			return in.zero(d.Type)
+		}
+		in.errorf(t.GetPos(), "undefined type %s", in.typeKey(t))
+	case *ast.SliceType:
+		return []Value(nil)
+	case *ast.MapType:
+		return &Map{Zero: func() Value { return in.zero(t.Value) }, Nil: true}
+	case *ast.Operation:
+		if t.Op == token.Mul && t.Y == nil {
+			return Pointer{}
+		}
+	case *ast.InterfaceType, *ast.FuncType:
+		return nil
+	case *ast.StructType:
+		s := &Struct{Names: fieldNames(t), Fields: make([]Value, len(t.FieldList))}
+		for i, f := range t.FieldList {
+			s.Fields[i] = in.zero(f.Type)
+		}
+		return s
+	case *ast.ParenExpr:
+		return in.zero(t.X)
+	}
+	in.errorf(typ.GetPos(), "invalid type")
+	return nil
+}
+
+// zeroOf returns the zero value for the type t recorded by the type
+// checker, such as the type of a nil value.
+func (in *interpreter) zeroOf(t types.Type) Value {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsInteger != 0:
+			return int64(0)
+		case t.Info()&types.IsFloat != 0:
+			return float64(0)
+		case t.Info()&types.IsString != 0:
+			return ""
+		case t.Info()&types.IsBoolean != 0:
+			return false
+		}
+	case *types.Slice:
+		return []Value(nil)
+	case *types.Map:
+		return &Map{Zero: func() Value { return in.zeroOf(t.Elem()) }, Nil: true}
+	case *types.Pointer:
+		return Pointer{}
+	case *types.Struct:
+		s := &Struct{Names: make([]string, t.NumFields()), Fields: make([]Value, t.NumFields())}
+		for i := range s.Fields {
+			f := t.Field(i)
+			s.Names[i] = f.Name()
+			s.Fields[i] = in.zeroOf(f.Type())
+		}
+		return s
+	}
+	return nil
+}
+
+// fieldNames returns the field names of the struct type t.
+func fieldNames(t *ast.StructType) []string {
+	names := make([]string, len(t.FieldList))
+	for i, f := range t.FieldList {
+		names[i] = f.Name.Value
+	}
+	return names
+}
+
+// underlying returns the slice or struct type denoted by the
+// type expression typ, following declared type names.
+func (in *interpreter) underlying(typ ast.Expr) ast.Expr {
+	for {
+		switch t := typ.(type) {
+		case *ast.Name:
+			d := in.types[t.Value]
+			if d == nil {
+				return t
+			}
+			typ = d.Type
+		case *ast.IndexExpr:
+			d := in.types[in.typeKey(t)]
+			if d == nil {
+				return t
+			}
+			typ = d.Type
+		case *ast.ParenExpr:
+			typ = t.X
+		default:
+			return t
+		}
+	}
+}
+
+// format returns the textual representation of v as printed by print.
+// A pointer to a struct, slice or map is printed as & followed by the
+// value it points to, other pointers, and pointers nested in the value,
+// as their address.
+func format(v Value) string {
+	if i, ok := v.(*Iface); ok {
+		v = i.Value
+	}
+	if p, ok := v.(Pointer); ok && p.Ref != nil {
+		switch (*p.Ref).(type) {
+		case *Struct, []Value, *Map:
+			return "&" + formatValue(*p.Ref)
+		}
+	}
+	return formatValue(v)
+}
+
+// formatValue is like format but prints all pointers as their address.
+func formatValue(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case []Value:
+		var b strings.Builder
+		b.WriteByte('[')
+		for i, x := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatValue(x))
+		}
+		b.WriteByte(']')
+		return b.String()
+	case *Map:
+		var b strings.Builder
+		b.WriteString("map[")
+		for i, k := range v.Keys {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatValue(k))
+			b.WriteByte(':')
+			b.WriteString(formatValue(v.Elems[i]))
+		}
+		b.WriteByte(']')
+		return b.String()
+	case Pointer:
+		if v.Ref == nil {
+			return "<nil>"
+		}
+		return fmt.Sprintf("%p", v.Ref)
+	case *Struct:
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, x := range v.Fields {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatValue(x))
+		}
+		b.WriteByte('}')
+		return b.String()
+	case *Func:
+		return "func " + v.Decl.Name.Value
+	case *Closure:
+		return "func literal"
+	case *Builtin:
+		return "builtin " + v.Name
+	case *Iface:
+		return formatValue(v.Value)
+	}
+	return "<?>"
+}
+
+// typeString returns the source form of the type expression typ.
+func typeString(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.Name:
+		return t.Value
+	case *ast.SliceType:
+		return "[]" + typeString(t.Elem)
+	case *ast.MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
+	case *ast.Operation:
+		return "*" + typeString(t.X)
+	case *ast.IndexExpr:
+		return typeString(t.X) + "[" + typeString(t.Index) + "]"
+	case *ast.ListExpr:
+		list := make([]string, len(t.ElemList))
+		for i, x := range t.ElemList {
+			list[i] = typeString(x)
+		}
+		return strings.Join(list, ", ")
+	case *ast.StructType:
+		return "struct{…}"
+	case *ast.InterfaceType:
+		return "interface{…}"
+	case *ast.FuncType:
+		return "func(…)"
+	case *ast.ParenExpr:
+		return "(" + typeString(t.X) + ")"
+	}
+	return "?"
+}
+
+// typeKey returns the type denoted by the type expression typ in the
+// form of types.Type.String, following alias declarations. It is the
+// name by which instances of generic types are declared.
+func (in *interpreter) typeKey(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.Name:
+		if d := in.types[t.Value]; d != nil && d.Alias {
+			return in.typeKey(d.Type)
+		}
+		return t.Value
+	case *ast.SliceType:
+		return "[]" + in.typeKey(t.Elem)
+	case *ast.MapType:
+		return "map[" + in.typeKey(t.Key) + "]" + in.typeKey(t.Value)
+	case *ast.Operation:
+		// a pointer type
+		return "*" + in.typeKey(t.X)
+	case *ast.IndexExpr:
+		// an instance of a generic type
+		var b strings.Builder
+		b.WriteString(in.typeKey(t.X))
+		b.WriteByte('[')
+		for i, x := range parser.UnpackList(t.Index) {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(in.typeKey(x))
+		}
+		b.WriteByte(']')
+		return b.String()
+	case *ast.StructType:
+		var b strings.Builder
+		b.WriteString("struct{")
+		for i, f := range t.FieldList {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			b.WriteString(f.Name.Value)
+			b.WriteByte(' ')
+			b.WriteString(in.typeKey(f.Type))
+		}
+		b.WriteByte('}')
+		return b.String()
+	case *ast.InterfaceType:
+		var b strings.Builder
+		b.WriteString("interface{")
+		for i, f := range t.MethodList {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			sig := f.Type.(*ast.FuncType)
+			b.WriteString(f.Name.Value)
+			b.WriteString(in.sigKey(sig.Param, sig.Return, true))
+		}
+		b.WriteByte('}')
+		return b.String()
+	case *ast.FuncType:
+		return "func" + in.sigKey(t.Param, t.Return, true)
+	case *ast.ListExpr:
+		// the results of a function with several results
+		list := make([]string, len(t.ElemList))
+		for i, x := range t.ElemList {
+			list[i] = in.typeKey(x)
+		}
+		return "(" + strings.Join(list, ", ") + ")"
+	case *ast.ParenExpr:
+		return in.typeKey(t.X)
+	}
+	return "?"
+}
+
+// sigKey returns the signature with the given parameters and result
+// in the form of types.Signature.String without the leading "func".
+// Parameter names are included if withNames is set.
+func (in *interpreter) sigKey(params []*ast.Field, result ast.Expr, withNames bool) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, p := range params {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if withNames && p.Name != nil {
+			b.WriteString(p.Name.Value)
+			b.WriteByte(' ')
+		}
+		b.WriteString(in.typeKey(p.Type))
+	}
+	b.WriteByte(')')
+	if result != nil {
+		b.WriteByte(' ')
+		b.WriteString(in.typeKey(result))
+	}
+	return b.String()
+}
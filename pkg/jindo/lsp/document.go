@@ -0,0 +1,173 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements the analysis of open documents and the
+// conversion between jindo and LSP positions.
+
+package lsp
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/types"
+	"strings"
+	"unicode/utf8"
+)
+
+// A document is an open text document and the result of its analysis.
+type document struct {
+	uri   string
+	text  string
+	lines []string // text split into lines, without line terminators
+
+	file *ast.File          // nil if the document could not be parsed
+	res  *parser.Resolution // nil if file is nil
+	info *types.Info        // nil if the document has syntax errors
+	errs []error            // syntax and type errors, in order
+}
+
+// analyze parses, resolves and type-checks text. Name resolution
+// is performed even in the presence of syntax errors so that
+// go-to-definition keeps working while the user is typing.
+func analyze(uri, text string) *document {
+	d := &document{
+		uri:   uri,
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+	errh := func(err error) { d.errs = append(d.errs, err) }
+
+	f, _ := parser.Parse(d.base(), strings.NewReader(text), errh, 0)
+	if f == nil {
+		return d
+	}
+	d.file = f
+	d.res, _ = parser.Resolve(f, func(error) {})
+	if len(d.errs) == 0 {
+		d.info, _ = types.Check(f, errh)
+	}
+	return d
+}
+
+func (d *document) base() *position.PosBase {
+	return position.NewFileBase(filename(d.uri))
+}
+
+// object returns the object denoted by n, or nil.
+func (d *document) object(n *ast.Name) types.Object {
+	if d.info == nil {
+		return nil
+	}
+	return d.info.ObjectOf(n)
+}
+
+// declOf returns the declaration of the entity denoted by n, or nil.
+func (d *document) declOf(n *ast.Name) ast.Node {
+	if d.res == nil {
+		return nil
+	}
+	return d.res.DeclOf(n)
+}
+
+// nameAt returns the name at the LSP position pos, or nil. The
+// position may be anywhere within the name or just after it.
+func (d *document) nameAt(pos lspPosition) *ast.Name {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return nil
+	}
+	line := uint(pos.Line + 1)
+	col := d.col(pos.Line, pos.Character)
+	match := func(n *ast.Name) bool {
+		p := n.GetPos()
+		return p.Line() == line && p.Col() <= col && col <= p.Col()+uint(len(n.Value))
+	}
+
+	if d.res != nil {
+		for _, m := range []map[*ast.Name]ast.Node{d.res.Defs, d.res.Uses} {
+			for n := range m {
+				if match(n) {
+					return n
+				}
+			}
+		}
+	}
+	if d.info != nil {
+		// predeclared names are only known to the type checker
+		for n := range d.info.Uses {
+			if match(n) {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// col returns the 1-based byte column for the 0-based UTF-16
+// offset char on the 0-based line.
+func (d *document) col(line, char int) uint {
+	s := d.lines[line]
+	i := 0
+	for n := 0; i < len(s) && n < char; {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		n += utf16Len(r)
+		i += size
+	}
+	return uint(i + 1)
+}
+
+// char returns the 0-based UTF-16 offset for the 1-based byte
+// column col on the 0-based line.
+func (d *document) char(line int, col uint) int {
+	if line < 0 || line >= len(d.lines) {
+		return 0
+	}
+	s := d.lines[line]
+	end := int(col) - 1
+	if end > len(s) {
+		end = len(s)
+	}
+	n := 0
+	for _, r := range s[:end] {
+		n += utf16Len(r)
+	}
+	return n
+}
+
+// span returns the range on the line of pos from the column of pos
+// up to the byte column end.
+func (d *document) span(pos position.Pos, end uint) span {
+	line := int(pos.Line()) - 1
+	if line < 0 {
+		return span{}
+	}
+	return span{
+		lspPosition{line, d.char(line, pos.Col())},
+		lspPosition{line, d.char(line, end)},
+	}
+}
+
+// nameSpan returns the range of name starting at pos. For an empty
+// name, the range is empty.
+func (d *document) nameSpan(pos position.Pos, name string) span {
+	return d.span(pos, pos.Col()+uint(len(name)))
+}
+
+// lineEnd returns the position at the end of the 0-based line.
+func (d *document) lineEnd(line int) lspPosition {
+	if line < 0 || line >= len(d.lines) {
+		return lspPosition{line, 0}
+	}
+	return lspPosition{line, d.char(line, uint(len(d.lines[line])+1))}
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
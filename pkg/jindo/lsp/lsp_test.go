@@ -0,0 +1,232 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///tmp/test.paw"
+
+const bad = `space main
+func main() {
+	println(1 2)
+}
+`
+
+const mistyped = `space main
+func main() {
+	var x int = "a"
+	println(x)
+}
+`
+
+const good = `space main
+
+// fib returns the n-th Fibonacci number.
+func fib(n int) int {
+  if n == 0 || n == 1 { return n }
+	return fib(n-1) + fib(n-2)
+}
+
+var limit = 10
+
+func main() { println(fib(limit)) }
+`
+
+// A script is a sequence of messages sent by a client.
+type script struct {
+	buf bytes.Buffer
+	id  int
+}
+
+func (s *script) send(method string, params interface{}) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		panic(err)
+	}
+	msg := &message{Method: method, Params: b}
+	if !strings.HasPrefix(method, "textDocument/did") && method != "initialized" && method != "exit" {
+		s.id++
+		msg.ID, _ = json.Marshal(s.id)
+	}
+	writeMessage(&s.buf, msg)
+}
+
+func at(line, char int) textDocumentPositionParams {
+	return textDocumentPositionParams{textDocumentIdentifier{uri}, lspPosition{line, char}}
+}
+
+func TestServe(t *testing.T) {
+	var s script
+	doc := documentParams{textDocumentIdentifier{uri}}
+	s.send("initialize", struct{}{})                                                      // 1
+	s.send("initialized", struct{}{})                                                     //
+	s.send("textDocument/didOpen", didOpenParams{textDocumentItem{uri, "jindo", 1, bad}}) //
+	for _, text := range []string{mistyped, good} {
+		s.send("textDocument/didChange", map[string]interface{}{
+			"textDocument":   doc.TextDocument,
+			"contentChanges": []map[string]string{{"text": text}},
+		})
+	}
+	s.send("textDocument/documentSymbol", doc)    // 2
+	s.send("textDocument/hover", at(10, 23))      // 3: fib in main
+	s.send("textDocument/hover", at(10, 28))      // 4: limit
+	s.send("textDocument/definition", at(5, 12))  // 5: n in fib(n-1)
+	s.send("textDocument/definition", at(10, 16)) // 6: println
+	s.send("textDocument/formatting", doc)        // 7
+	s.send("unknown/method", struct{}{})          // 8
+	s.send("shutdown", nil)                       // 9
+	s.send("exit", nil)
+
+	var out bytes.Buffer
+	if err := Serve(&s.buf, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// collect responses by ID and notifications in order
+	results := make(map[string]string)
+	var diags []string
+	r := bufio.NewReader(&out)
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg.Method == "textDocument/publishDiagnostics":
+			diags = append(diags, string(msg.Params))
+		case msg.Error != nil:
+			results[string(msg.ID)] = "error: " + msg.Error.Message
+		default:
+			results[string(msg.ID)] = string(msg.Result)
+		}
+	}
+
+	wantDiags := []string{
+		`{"uri":"file:///tmp/test.paw","diagnostics":[{"range":{"start":{"line":2,"character":11},"end":{"line":2,"character":11}},"severity":1,"source":"syntax","message":"syntax error: unexpected gotLiteral 2 in argument list; possibly missing comma or )"}]}`,
+		`{"uri":"file:///tmp/test.paw","diagnostics":[{"range":{"start":{"line":2,"character":13},"end":{"line":2,"character":13}},"severity":1,"source":"types","message":"cannot use \"a\" (untyped string constant) as int value in variable declaration"}]}`,
+		`{"uri":"file:///tmp/test.paw","diagnostics":[]}`,
+	}
+	if len(diags) != len(wantDiags) {
+		t.Fatalf("got diagnostics %q, want %q", diags, wantDiags)
+	}
+	for i, want := range wantDiags {
+		if diags[i] != want {
+			t.Errorf("diagnostics %d:\ngot  %s\nwant %s", i, diags[i], want)
+		}
+	}
+
+	want := map[string]string{
+		"1": `{"capabilities":{"textDocumentSync":1,"documentSymbolProvider":true,"hoverProvider":true,"definitionProvider":true,"documentFormattingProvider":true},"serverInfo":{"name":"jindo"}}`,
+		"2": `[{"name":"fib","detail":"func fib(n int) int","kind":12,"range":{"start":{"line":3,"character":0},"end":{"line":6,"character":1}},"selectionRange":{"start":{"line":3,"character":5},"end":{"line":3,"character":8}}},` +
+			`{"name":"limit","detail":"int","kind":13,"range":{"start":{"line":8,"character":0},"end":{"line":8,"character":14}},"selectionRange":{"start":{"line":8,"character":4},"end":{"line":8,"character":9}}},` +
+			`{"name":"main","detail":"func main()","kind":12,"range":{"start":{"line":10,"character":0},"end":{"line":10,"character":35}},"selectionRange":{"start":{"line":10,"character":5},"end":{"line":10,"character":9}}}]`,
+		"3": "{\"contents\":{\"kind\":\"markdown\",\"value\":\"```jindo\\nfunc fib(n int) int\\n```\"},\"range\":{\"start\":{\"line\":10,\"character\":22},\"end\":{\"line\":10,\"character\":25}}}",
+		"4": "{\"contents\":{\"kind\":\"markdown\",\"value\":\"```jindo\\nvar limit int\\n```\"},\"range\":{\"start\":{\"line\":10,\"character\":26},\"end\":{\"line\":10,\"character\":31}}}",
+		"5": `{"uri":"file:///tmp/test.paw","range":{"start":{"line":3,"character":9},"end":{"line":3,"character":10}}}`,
+		"6": `null`,
+		"7": `[{"range":{"start":{"line":0,"character":0},"end":{"line":11,"character":0}},"newText":` + mustJSON(formatted) + `}]`,
+		"8": `error: method not found: unknown/method`,
+		"9": `null`,
+	}
+	for id, w := range want {
+		if got := results[id]; got != w {
+			t.Errorf("response %s:\ngot  %s\nwant %s", id, got, w)
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("got %d responses, want %d", len(results), len(want))
+	}
+}
+
+const formatted = `space main
+
+// fib returns the n-th Fibonacci number.
+func fib(n int) int {
+	if n == 0 || n == 1 {
+		return n
+	}
+	return fib(n - 1) + fib(n - 2)
+}
+
+var limit = 10
+
+func main() {
+	println(fib(limit))
+}
+`
+
+func mustJSON(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	var s script
+	s.send("initialize", struct{}{})
+	s.send("exit", nil)
+	if err := Serve(&s.buf, io.Discard); err == nil {
+		t.Error("exit before shutdown succeeded")
+	}
+}
+
+const incomplete = `space main
+func main() {
+	x := 1 +
+}
+`
+
+func TestIncomplete(t *testing.T) {
+	// an incomplete expression is reported and not formatted
+	var s script
+	doc := documentParams{textDocumentIdentifier{uri}}
+	s.send("initialize", struct{}{})
+	s.send("textDocument/didOpen", didOpenParams{textDocumentItem{uri, "jindo", 1, incomplete}})
+	s.send("textDocument/formatting", doc) // 2
+	s.send("shutdown", nil)
+	s.send("exit", nil)
+
+	var out bytes.Buffer
+	if err := Serve(&s.buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	var diags, format string
+	r := bufio.NewReader(&out)
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg.Method == "textDocument/publishDiagnostics":
+			diags = string(msg.Params)
+		case string(msg.ID) == "2":
+			format = string(msg.Result)
+		}
+	}
+	if want := `{"uri":"file:///tmp/test.paw","diagnostics":[{"range":{"start":{"line":3,"character":0},"end":{"line":3,"character":0}},"severity":1,"source":"syntax","message":"syntax error: unexpected }, expecting expression"}]}`; diags != want {
+		t.Errorf("got diagnostics\n%s\nwant\n%s", diags, want)
+	}
+	if format != "null" {
+		t.Errorf("got formatting edits %s, want none", format)
+	}
+}
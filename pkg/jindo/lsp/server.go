@@ -0,0 +1,472 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// Package lsp implements a language server for jindo speaking the
+// Language Server Protocol over a byte stream, usually stdin and stdout.
+//
+// The server keeps the text of the open documents in memory; documents
+// are synchronized in full on every change. It publishes syntax and
+// type errors as diagnostics, and provides document symbols, hover
+// information, go-to-definition and formatting.
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/types"
+	"net/url"
+	"strings"
+)
+
+// Serve runs a language server reading requests from r and writing
+// responses and notifications to w. It returns when the client sends
+// the exit notification or closes r. Serve reports an error if r is
+// closed or the exit notification arrives before a shutdown request.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{
+		out:  w,
+		docs: make(map[string]*document),
+	}
+	in := bufio.NewReader(r)
+	for {
+		msg, err := readMessage(in)
+		if err != nil {
+			var rerr *rpcError
+			if errors.As(err, &rerr) {
+				// malformed JSON: report it and carry on
+				if err := s.reply(nil, nil, rerr); err != nil {
+					return err
+				}
+				continue
+			}
+			if err == io.EOF && s.shutdown {
+				return nil
+			}
+			if err == io.EOF {
+				return errors.New("connection closed before shutdown")
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+type server struct {
+	out      io.Writer
+	docs     map[string]*document // open documents by URI
+	shutdown bool                 // shutdown was requested
+}
+
+// handle handles a request or notification. It returns an error
+// only if the connection failed.
+func (s *server) handle(msg *message) (err error) {
+	var result interface{}
+	var rerr *rpcError
+	func() {
+		defer func() {
+			// don't let a bug in the analysis bring the server down
+			if p := recover(); p != nil {
+				rerr = &rpcError{codeInternalError, fmt.Sprintf("internal error: %v", p)}
+			}
+		}()
+		result, rerr = s.dispatch(msg)
+	}()
+
+	if msg.ID == nil {
+		return nil // notifications have no response
+	}
+	return s.reply(msg.ID, result, rerr)
+}
+
+func (s *server) reply(id json.RawMessage, result interface{}, rerr *rpcError) error {
+	resp := &message{ID: id, Error: rerr}
+	if id == nil {
+		resp.ID = json.RawMessage("null")
+	}
+	if rerr == nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = b
+	}
+	return writeMessage(s.out, resp)
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: b})
+}
+
+func (s *server) dispatch(msg *message) (interface{}, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var res initializeResult
+		res.Capabilities = serverCapabilities{
+			TextDocumentSync:           syncFull,
+			DocumentSymbolProvider:     true,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			DocumentFormattingProvider: true,
+		}
+		res.ServerInfo.Name = "jindo"
+		return res, nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			// with full synchronization, the last change holds the text
+			return nil, s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		if err := s.publish(p.TextDocument.URI, nil); err != nil {
+			return nil, &rpcError{codeInternalError, err.Error()}
+		}
+		return nil, nil
+
+	case "textDocument/documentSymbol":
+		var p documentParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.symbols(), nil
+
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.hover(p.Position), nil
+
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.definition(p.Position), nil
+
+	case "textDocument/formatting":
+		var p documentParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.format(), nil
+	}
+
+	if strings.HasPrefix(msg.Method, "$/") {
+		return nil, nil // optional notifications and requests may be ignored
+	}
+	return nil, &rpcError{codeMethodNotFound, "method not found: " + msg.Method}
+}
+
+func unmarshal(params json.RawMessage, v interface{}) *rpcError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (s *server) doc(uri string) (*document, *rpcError) {
+	d := s.docs[uri]
+	if d == nil {
+		return nil, &rpcError{codeInvalidParams, "unknown document " + uri}
+	}
+	return d, nil
+}
+
+// update sets the text of the document uri and publishes its diagnostics.
+func (s *server) update(uri, text string) *rpcError {
+	d := analyze(uri, text)
+	s.docs[uri] = d
+	if err := s.publish(uri, d.diagnostics()); err != nil {
+		return &rpcError{codeInternalError, err.Error()}
+	}
+	return nil
+}
+
+func (s *server) publish(uri string, diags []diagnostic) error {
+	if diags == nil {
+		diags = []diagnostic{} // an empty list clears the diagnostics
+	}
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diags})
+}
+
+// filename returns the file name for the document uri, which is
+// the path of file URIs and the uri itself otherwise.
+func filename(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return uri
+}
+
+// ----------------------------------------------------------------------------
+// Features
+
+func (d *document) diagnostics() []diagnostic {
+	var list []diagnostic
+	for _, err := range d.errs {
+		var diag diagnostic
+		switch err := err.(type) {
+		case parser.Error:
+			diag.Range = d.nameSpan(err.Pos, "")
+			diag.Source = "syntax"
+			diag.Message = err.Msg
+		case types.Error:
+			diag.Range = d.nameSpan(err.Pos, "")
+			diag.Source = "types"
+			diag.Message = err.Msg
+		default:
+			diag.Source = "jindo"
+			diag.Message = err.Error()
+		}
+		diag.Severity = severityError
+		list = append(list, diag)
+	}
+	return list
+}
+
+func (d *document) symbols() []documentSymbol {
+	list := []documentSymbol{}
+	if d.file == nil {
+		return list
+	}
+	for _, decl := range d.file.DeclList {
+		var sym documentSymbol
+		var name *ast.Name
+		var body *ast.BlockStmt
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name, body = decl.Name, decl.Body
+			sym.Kind = symbolFunction
+			if decl.Recv != nil {
+				sym.Kind = symbolMethod
+			}
+			sym.Detail = header(decl)
+		case *ast.OperDecl:
+			body = decl.Body
+			sym.Name = "oper " + decl.Oper.OperName()
+			sym.Kind = symbolOperator
+			sym.Detail = header(decl)
+			sym.SelectionRange = d.nameSpan(decl.GetPos(), "")
+		case *ast.ConstDecl:
+			name = decl.NameList
+			sym.Kind = symbolConstant
+			if obj := d.object(name); obj != nil {
+				sym.Detail = obj.Type().String()
+			}
+		case *ast.VarDecl:
+			name = decl.NameList
+			sym.Kind = symbolVariable
+			if obj := d.object(name); obj != nil {
+				sym.Detail = obj.Type().String()
+			}
+		case *ast.TypeDecl:
+			name = decl.Name
+			sym.Kind = symbolClass
+			if _, ok := decl.Type.(*ast.InterfaceType); ok {
+				sym.Kind = symbolInterface
+			}
+			sym.Detail = parser.String(decl.Type)
+		default:
+			continue
+		}
+		if name != nil {
+			sym.Name = name.Value
+			sym.SelectionRange = d.nameSpan(name.GetPos(), name.Value)
+		}
+
+		// a declaration extends from the start of its line to the
+		// closing brace of its body, or to the end of its line
+		sym.Range.Start = lspPosition{sym.SelectionRange.Start.Line, 0}
+		if body != nil && body.Rbrace.IsKnown() {
+			sym.Range.End = d.span(body.Rbrace, body.Rbrace.Col()+1).End
+		} else {
+			sym.Range.End = d.lineEnd(sym.SelectionRange.Start.Line)
+		}
+		list = append(list, sym)
+	}
+	return list
+}
+
+func (d *document) hover(pos lspPosition) *hover {
+	n := d.nameAt(pos)
+	if n == nil {
+		return nil
+	}
+	var text string
+	switch decl := d.declOf(n).(type) {
+	case *ast.FuncDecl, *ast.OperDecl:
+		text = header(decl)
+	case *ast.TypeDecl:
+		text = parser.String(decl)
+	default:
+		if fn, _ := d.object(n).(*types.Func); fn != nil && fn.Decl() != nil {
+			text = header(fn.Decl())
+		} else if obj := d.object(n); obj != nil {
+			text = types.ObjectString(obj)
+		} else if _, obj := types.Universe.LookupParent(n.Value); obj != nil {
+			text = types.ObjectString(obj)
+		} else if decl != nil {
+			text = parser.String(decl)
+		}
+	}
+	if text == "" {
+		return nil
+	}
+	r := d.nameSpan(n.GetPos(), n.Value)
+	return &hover{
+		Contents: markupContent{"markdown", "```jindo\n" + text + "\n```"},
+		Range:    &r,
+	}
+}
+
+func (d *document) definition(pos lspPosition) *location {
+	n := d.nameAt(pos)
+	if n == nil {
+		return nil
+	}
+	var name *ast.Name
+	switch decl := d.declOf(n).(type) {
+	case *ast.FuncDecl:
+		name = decl.Name
+	case *ast.ConstDecl:
+		name = decl.NameList
+	case *ast.VarDecl:
+		name = decl.NameList
+	case *ast.TypeDecl:
+		name = decl.Name
+	case *ast.Field:
+		name = decl.Name
+	case *ast.DefineStmt:
+		for _, x := range parser.UnpackList(decl.Lhs) {
+			if x, ok := x.(*ast.Name); ok && x.Value == n.Value {
+				name = x
+			}
+		}
+	case *ast.RangeClause:
+		for _, x := range parser.UnpackList(decl.Lhs) {
+			if x, ok := x.(*ast.Name); ok && x.Value == n.Value {
+				name = x
+			}
+		}
+	case *ast.TypeSwitchGuard:
+		name = decl.Lhs
+	case nil:
+		// selected fields and methods are only known to the type checker
+		switch obj := d.object(n).(type) {
+		case *types.Var:
+			if obj.IsField() {
+				return &location{d.uri, d.nameSpan(obj.Pos(), obj.Name())}
+			}
+		case *types.Func:
+			if decl := obj.Decl(); decl != nil {
+				name = decl.Name
+			} else {
+				// a method of an interface type
+				return &location{d.uri, d.nameSpan(obj.Pos(), obj.Name())}
+			}
+		}
+	}
+	if name == nil {
+		return nil
+	}
+	return &location{d.uri, d.nameSpan(name.GetPos(), name.Value)}
+}
+
+// format returns the edits that bring the document into canonical
+// form, or nil if the document has syntax errors.
+func (d *document) format() []textEdit {
+	f, err := parser.Parse(d.base(), strings.NewReader(d.text), nil, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if _, err := parser.Fprint(&buf, f, 0); err != nil {
+		return nil
+	}
+	if buf.String() == d.text {
+		return []textEdit{}
+	}
+	// replace the entire document
+	last := len(d.lines) - 1
+	return []textEdit{{
+		Range:   span{lspPosition{0, 0}, d.lineEnd(last)},
+		NewText: buf.String(),
+	}}
+}
+
+// header returns the signature of a function or operator declaration.
+func header(decl ast.Node) string {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		d := *decl
+		d.Body = nil
+		return parser.String(&d)
+	case *ast.OperDecl:
+		d := *decl
+		d.Body = nil
+		return parser.String(&d)
+	}
+	return parser.String(decl)
+}
@@ -13,6 +13,15 @@ import (
 	"os"
 )
 
+// Mode describes the parser mode.
+type Mode uint
+
+// Modes supported by the parser.
+const (
+	Trace         Mode = 1 << iota // print a trace of the parsed productions to standard output
+	ParseComments                  // attach comments to declarations and statements
+)
+
 // Parse parses a single Go source file from src and returns the corresponding
 // syntax tree. If there are errors, Parse will return the first error found,
 // and a possibly partially constructed syntax tree, or nil.
@@ -23,8 +32,8 @@ import (
 // If errh is nil, Parse will terminate immediately upon encountering the first
 // error, and the returned syntax tree is nil.
 //
-// If pragh != nil, it is called with each pragma encountered.
-func Parse(base *position.PosBase, src io.Reader, errh ErrorHandler) (_ *ast.File, first error) {
+// The mode parameter controls optional parser functionality; see Mode.
+func Parse(base *position.PosBase, src io.Reader, errh ErrorHandler, mode Mode) (_ *ast.File, first error) {
 	defer func() {
 		if p := recover(); p != nil {
 			if err, ok := p.(Error); ok {
@@ -36,13 +45,13 @@ func Parse(base *position.PosBase, src io.Reader, errh ErrorHandler) (_ *ast.Fil
 	}()
 
 	var p parser
-	p.init(base, src, errh)
+	p.init(base, src, errh, mode)
 	p.Next()
 	return p.fileOrNil(), p.first
 }
 
 // ParseFile behaves like Parse but it reads the source from the named file.
-func ParseFile(filename string, errh ErrorHandler) (*ast.File, error) {
+func ParseFile(filename string, errh ErrorHandler, mode Mode) (*ast.File, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		if errh != nil {
@@ -51,5 +60,5 @@ func ParseFile(filename string, errh ErrorHandler) (*ast.File, error) {
 		return nil, err
 	}
 	defer f.Close()
-	return Parse(position.NewFileBase(filename), f, errh)
+	return Parse(position.NewFileBase(filename), f, errh, mode)
 }
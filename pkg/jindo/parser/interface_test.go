@@ -13,11 +13,12 @@ import (
 	"jindo/pkg/jindo/ast"
 	"jindo/pkg/jindo/position"
 	"os"
+	"strings"
 	"testing"
 )
 
 const (
-	src_ = "/Users/seungyeoplee/Workspace/jindo/example/test.paw"
+	src_ = "../../../example/test.paw"
 )
 
 func testOut() io.Writer {
@@ -32,7 +33,7 @@ func TestDump(t *testing.T) {
 		t.Skip("skipping test in short mode")
 	}
 
-	parsed, _ := ParseFile(src_, func(err error) { t.Error(err) })
+	parsed, _ := ParseFile(src_, func(err error) { t.Error(err) }, 0)
 
 	if parsed != nil {
 		ast.Fdump(testOut(), parsed)
@@ -40,11 +41,11 @@ func TestDump(t *testing.T) {
 }
 
 func TestParse(t *testing.T) {
-	ParseFile(src_, func(err error) { t.Error(err) })
+	ParseFile(src_, func(err error) { t.Error(err) }, 0)
 }
 
 func TestVerify(t *testing.T) {
-	ast, err := ParseFile(src_, func(err error) { t.Error(err) })
+	ast, err := ParseFile(src_, func(err error) { t.Error(err) }, 0)
 	if err != nil {
 		return // error already reported
 	}
@@ -59,7 +60,7 @@ func verifyPrint(t *testing.T, filename string, ast1 *ast.File) {
 	}
 	bytes1 := buf1.Bytes()
 
-	ast2, err := Parse(position.NewFileBase(filename), &buf1, nil)
+	ast2, err := Parse(position.NewFileBase(filename), &buf1, nil, 0)
 	if err != nil {
 		panic(err)
 	}
@@ -83,3 +84,270 @@ func verifyPrint(t *testing.T, filename string, ast1 *ast.File) {
 		t.Error("printed syntax trees do not match")
 	}
 }
+
+func TestComments(t *testing.T) {
+	// src is in canonical form, so that printing
+	// its syntax tree must reproduce it exactly
+	const src = `// Copyright header.
+
+// Space doc.
+space main
+
+// count is a counter.
+var count int // trailing on var
+
+// Point is a point.
+type Point struct {
+	// doc of x and y
+	x, y int // trailing on x and y
+
+	name string /* trailing on name */
+	// final in struct
+}
+
+// Shape is a shape.
+type Shape interface {
+	// area returns the area.
+	area() int // trailing on area
+
+	// grow grows the shape by n.
+	grow(n int)
+	// final in interface
+}
+
+// group doc
+const (
+	// doc of A
+	A = iota // trailing on A
+
+	B
+	// final in group
+) // trailing on group
+
+/* block doc */
+func fib(n int) int {
+	// leading in body
+	if n < 2 {
+		return n // eol return
+	}
+
+	// after blank
+	return fib(n - 1) + fib(n - 2) /* inline */
+	// final in block
+}
+
+// final of file
+`
+
+	f, err := Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, ParseComments)
+	if err != nil {
+		return // error already reported
+	}
+	var buf bytes.Buffer
+	if _, err := Fprint(&buf, f, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != src {
+		t.Errorf("got:\n%s\nwant:\n%s", got, src)
+	}
+
+	// without ParseComments, no comments are attached
+	f, err = Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		return // error already reported
+	}
+	if c := f.Comments(); c != nil {
+		t.Errorf("got file comments %v, want none", c)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	// each source is formatted to want; want is in
+	// canonical form and must format to itself
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"space main", "space main\n"},
+		{"space main\nvar x int\nvar y = 1\n\n\n\nvar z int", "space main\n\nvar x int\nvar y = 1\n\nvar z int\n"},
+		{
+			"space main\n\n\n\nfunc f(a int, b []int) int {\nreturn a}\nfunc g() {}",
+			"space main\n\nfunc f(a int, b []int) int {\n\treturn a\n}\n\nfunc g() {}\n",
+		},
+		{
+			"space main\ntype V []int\noper (a V) add (b V) V { return a }\noper (a V) rmul (b int) V {\nreturn a}",
+			"space main\n\ntype V []int\n\noper (a V) add (b V) V {\n\treturn a\n}\n\noper (a V) rmul (b int) V {\n\treturn a\n}\n",
+		},
+		{
+			"space main\nfunc f() {\nx := []int{1,2}\n      while x[0] > 0 { x[0] -= 1; if x[1] == 2 { break } }\nfor i := 0; i < 3; i += 1 { break }\n}",
+			"space main\n\nfunc f() {\n\tx := []int{1, 2}\n\twhile x[0] > 0 {\n\t\tx[0] -= 1\n\t\tif x[1] == 2 {\n\t\t\tbreak\n\t\t}\n\t}\n\tfor i := 0; i < 3; i += 1 {\n\t\tbreak\n\t}\n}\n",
+		},
+		{
+			"space main\nconst (A = iota; B\nC int = (1 << 2) * 3)\nconst D = A\nfunc f() {\nconst x = 1\n}",
+			"space main\n\nconst (\n\tA = iota\n\tB\n\tC int = (1 << 2) * 3\n)\n\nconst D = A\n\nfunc f() {\n\tconst x = 1\n}\n",
+		},
+		{
+			"space main\ntype P struct {x, y int; name string}\nvar e struct{}\nfunc f() {\np := P{x: 1, y: 2,}\nls := []P{{1, 2, \"a\"}, {}}\np.x = ls[0].y\n}",
+			"space main\n\ntype P struct {\n\tx, y int\n\tname string\n}\n\nvar e struct{}\n\nfunc f() {\n\tp := P{x: 1, y: 2}\n\tls := []P{{1, 2, \"a\"}, {}}\n\tp.x = ls[0].y\n}\n",
+		},
+		{
+			"space main\nfunc (p P)norm() int { return p.x }\nfunc (P) zero(k int) P { return P{}.scale(k) }",
+			"space main\n\nfunc (p P) norm() int {\n\treturn p.x\n}\n\nfunc (P) zero(k int) P {\n\treturn P{}.scale(k)\n}\n",
+		},
+		{
+			"space main\nimport (\"fmt\"; \"os\")\ntype (T []int\nU = T)\nfunc f() {\nvar (x = 1; y T)\n}",
+			"space main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\ntype (\n\tT []int\n\tU = T\n)\n\nfunc f() {\n\tvar (\n\t\tx = 1\n\t\ty T\n\t)\n}\n",
+		},
+		{
+			"space main\ntype S interface {area() float; scale(k float, by S) S\n}\nvar e interface{}\nfunc f(s S) {\nc := s.(Circle)\nprintln(e.(S).area())\n}",
+			"space main\n\ntype S interface {\n\tarea() float\n\tscale(k float, by S) S\n}\n\nvar e interface{}\n\nfunc f(s S) {\n\tc := s.(Circle)\n\tprintln(e.(S).area())\n}\n",
+		},
+		{
+			"space main\ntype Pair[K comparable, V any] struct {key K; val V}\nfunc max[T ordered](a T, b T) T {\nreturn a\n}\nfunc (p Pair[K, V]) get() V { return p.val }\nfunc f() {\nprintln(max[int](1, 2), Pair[string, int]{\"a\", 1})\n}",
+			"space main\n\ntype Pair[K comparable, V any] struct {\n\tkey K\n\tval V\n}\n\nfunc max[T ordered](a T, b T) T {\n\treturn a\n}\n\nfunc (p Pair[K, V]) get() V {\n\treturn p.val\n}\n\nfunc f() {\n\tprintln(max[int](1, 2), Pair[string, int]{\"a\", 1})\n}\n",
+		},
+		{
+			"space main\nfunc f(a int) (int, string) {\nreturn a, \"x\"\n}\nfunc g() {\na, b := f(1)\na, b = b, a\nreturn\n}",
+			"space main\n\nfunc f(a int) (int, string) {\n\treturn a, \"x\"\n}\n\nfunc g() {\n\ta, b := f(1)\n\ta, b = b, a\n\treturn\n}\n",
+		},
+		{
+			"space main\ntype Op func(int, int) int\nfunc f(g func(x int) bool) {\nh := func(a int) int {\nreturn a}\nfunc() {}()\n}",
+			"space main\n\ntype Op func(int, int) int\n\nfunc f(g func(x int) bool) {\n\th := func(a int) int {\n\t\treturn a\n\t}\n\tfunc() {}()\n}\n",
+		},
+		{
+			"space main\nfunc f(x int, e interface{}) {\nswitch x {\ncase 1, 2: println(x)\n// other\ndefault:\n}\nswitch {}\nswitch v := e.(type) {\ncase int:\nreturn\ncase string, bool:\n}\n}",
+			"space main\n\nfunc f(x int, e interface{}) {\n\tswitch x {\n\tcase 1, 2:\n\t\tprintln(x)\n\t// other\n\tdefault:\n\t}\n\tswitch {}\n\tswitch v := e.(type) {\n\tcase int:\n\t\treturn\n\tcase string, bool:\n\t}\n}\n",
+		},
+		{
+			"space main\nfunc f(xs []int) {\nfor i, x := range xs { println(i, x) }\nfor i := range []int{1, 2} {}\nfor range \"abc\" {}\n}",
+			"space main\n\nfunc f(xs []int) {\n\tfor i, x := range xs {\n\t\tprintln(i, x)\n\t}\n\tfor i := range []int{1, 2} {}\n\tfor range \"abc\" {}\n}\n",
+		},
+		{
+			"space main\nfunc f() {\nouter: for {\nwhile true { if true { continue }; break outer }\ncontinue outer\n}\nend:\n}",
+			"space main\n\nfunc f() {\nouter:\n\tfor {\n\t\twhile true {\n\t\t\tif true {\n\t\t\t\tcontinue\n\t\t\t}\n\t\t\tbreak outer\n\t\t}\n\t\tcontinue outer\n\t}\nend:\n}\n",
+		},
+		{
+			"space main\ntype M map[string][]int\nfunc f(m map[P]int) {\nc := map[string]int{\"a\": 1, \"b\": 2,}\nv, ok := c[\"a\"]\ndelete(c, \"b\")\n}",
+			"space main\n\ntype M map[string][]int\n\nfunc f(m map[P]int) {\n\tc := map[string]int{\"a\": 1, \"b\": 2}\n\tv, ok := c[\"a\"]\n\tdelete(c, \"b\")\n}\n",
+		},
+		{
+			"space main\ntype L struct { next *L }\nfunc (l *L) f(p **int) {\n*p = &l.v\n(*l).next = &L{}\nx := -*(*p)\n}",
+			"space main\n\ntype L struct {\n\tnext *L\n}\n\nfunc (l *L) f(p **int) {\n\t*p = &l.v\n\t(*l).next = &L{}\n\tx := -*(*p)\n}\n",
+		},
+		{
+			"space main\nfunc f(\na int, // first\nb int, // second\n) int {\nreturn a + // plus\nb\n}",
+			"space main\n\nfunc f(\n\ta int, // first\n\tb int, // second\n) int {\n\treturn a + // plus\n\t\tb\n}\n",
+		},
+		{
+			"space main\nfunc f() {\nx := []int{\n1, // one\n2, // two\n}\nm := map[string]int{\"a\": 1,\n// alone\n\"b\": 2}\np := P{\nx: 1,\n\ny: 2,\n// last\n} // closing\n}",
+			"space main\n\nfunc f() {\n\tx := []int{\n\t\t1, // one\n\t\t2, // two\n\t}\n\tm := map[string]int{\n\t\t\"a\": 1,\n\t\t// alone\n\t\t\"b\": 2,\n\t}\n\tp := P{\n\t\tx: 1,\n\n\t\ty: 2,\n\t\t// last\n\t} // closing\n}\n",
+		},
+		{
+			"space main\nfunc f() {\ng(1, /* a */ 2)\ng(\n1, // a\n2)\nx := []int{1 /* one */, 2,\n}\n}",
+			"space main\n\nfunc f() {\n\tg(1 /* a */, 2)\n\tg(\n\t\t1, // a\n\t\t2,\n\t)\n\tx := []int{\n\t\t1, /* one */\n\t\t2,\n\t}\n}\n",
+		},
+	} {
+		for _, src := range []string{test.src, test.want} {
+			f, err := Parse(position.NewFileBase("test.paw"), strings.NewReader(src), nil, ParseComments)
+			if err != nil {
+				t.Errorf("%q: %s", src, err)
+				continue
+			}
+			var buf bytes.Buffer
+			if _, err := Fprint(&buf, f, 0); err != nil {
+				t.Errorf("%q: %s", src, err)
+				continue
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("%q:\ngot:\n%s\nwant:\n%s", src, got, test.want)
+			}
+		}
+	}
+}
+
+func TestFormatBadExpr(t *testing.T) {
+	// the tree of a file with syntax errors must not be printed
+	f, _ := Parse(position.NewFileBase("test.paw"), strings.NewReader("space main\nfunc f() {\nx := 1 +\n}"), func(error) {}, ParseComments)
+	var buf bytes.Buffer
+	n, err := Fprint(&buf, f, 0)
+	if want := "test.paw:4:1: cannot format invalid expression"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+	if n != 0 || buf.Len() != 0 {
+		t.Errorf("got output %q, want none", buf.String())
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	for _, test := range []struct {
+		src   string
+		errs  []string
+		decls int // number of declarations after recovery
+	}{
+		{"space main\nvar (\n\ta = 1 2\n\tb = 3\n)\nvar c = 4", []string{"3:8: syntax error: unexpected gotLiteral 2 in grouped declaration; possibly missing semicolon or newline or )"}, 3},
+		{"space main\nvar (\n\ta = 1\nfunc f() {}", []string{"4:1: syntax error: expected ), got func"}, 2},
+		{"space main\nimport (\n\t1\n\t\"os\"\n)", []string{"3:2: syntax error: import path must be a string"}, 2},
+		{"space main\nfunc f(a int, string) {}\nvar c = 4", []string{"2:8: syntax error: mixed named and unnamed parameters"}, 2},
+		{"space main\nvar (\n\ta\n\tb = 3\n\t1 = 2\n\tc = 4\n)", []string{"3:3: syntax error: unexpected newline, expecting type", "5:2: expecting name", "5:2: syntax error: unexpected gotLiteral 1, expecting type"}, 2},
+		{"space main\nvar a\nvar b = 3", []string{"2:6: syntax error: unexpected newline, expecting type"}, 1},
+	} {
+		var errs []string
+		f, _ := Parse(position.NewFileBase("test.paw"), strings.NewReader(test.src), func(err error) {
+			errs = append(errs, err.Error())
+		}, 0)
+		if len(errs) != len(test.errs) {
+			t.Errorf("%q: got errors %q, want %q", test.src, errs, test.errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.HasSuffix(err, test.errs[i]) {
+				t.Errorf("%q: got error %q, want %q", test.src, err, test.errs[i])
+			}
+		}
+		if len(f.DeclList) != test.decls {
+			t.Errorf("%q: got %d declarations, want %d", test.src, len(f.DeclList), test.decls)
+		}
+	}
+}
+
+func TestSwitchErrors(t *testing.T) {
+	for _, test := range []struct {
+		src string
+		err string
+	}{
+		{"switch x := 3; x {}", "2:21: syntax error: init statement not allowed in switch statement"},
+		{"switch x := 3; {}", "2:21: syntax error: init statement not allowed in switch statement"},
+	} {
+		var errs []string
+		Parse(position.NewFileBase("test.paw"), strings.NewReader("space main\nfunc f() { "+test.src+" }"), func(err error) {
+			errs = append(errs, err.Error())
+		}, 0)
+		if len(errs) != 1 || !strings.HasSuffix(errs[0], test.err) {
+			t.Errorf("%q: got errors %q, want %q", test.src, errs, test.err)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		errs []string
+	}{
+		{"x := 1 +\n", []string{"3:2: syntax error: unexpected }, expecting expression"}},
+		{"var y =\n", []string{"3:2: syntax error: unexpected }, expecting expression"}},
+		{"f(,)", []string{"2:14: syntax error: unexpected comma, expecting expression"}},
+	} {
+		var errs []string
+		Parse(position.NewFileBase("test.paw"), strings.NewReader("space main\nfunc f() { "+test.src+" }"), func(err error) {
+			errs = append(errs, err.Error())
+		}, 0)
+		if len(errs) != len(test.errs) {
+			t.Errorf("%q: got errors %q, want %q", test.src, errs, test.errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.HasSuffix(err, test.errs[i]) {
+				t.Errorf("%q: got error %q, want %q", test.src, err, test.errs[i])
+			}
+		}
+	}
+}
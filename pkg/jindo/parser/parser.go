@@ -13,7 +13,6 @@ import (
 	"jindo/pkg/jindo/position"
 	"jindo/pkg/jindo/scanner"
 	"jindo/pkg/jindo/token"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -28,6 +27,13 @@ type parser struct {
 	errcnt  int // number of errors encountered
 	verbose bool
 	fnest   int // function nesting level (for error handling)
+	xnest   int // expression nesting level (for complit ambiguity resolution)
+
+	lastLine uint // line on which the most recent token or comment ended
+
+	// comment collection (ParseComments mode)
+	comments bool
+	pending  []pendingComment // comments not yet attached to a node
 }
 
 // nil means error has occured
@@ -39,9 +45,9 @@ func (p *parser) fileOrNil() *ast.File {
 	// SourceFile = Space ";" { TopLevelDecl ";" } .
 	f := new(ast.File)
 	f.Pos = p.pos()
+	setComments(f, trimEmpty(p.leadComments()), nil)
 	if !p.got(token.Space) {
-		fmt.Println("expected space, got '" + p.Token().String() + "'")
-		os.Exit(-1)
+		p.syntaxError("space statement must be first")
 		return nil
 	}
 	f.SpaceName = p.name()
@@ -58,10 +64,20 @@ func (p *parser) fileOrNil() *ast.File {
 		}
 		prev = p.Token()
 
+		var doc []ast.Comment
+		if p.Token() != token.Semi {
+			doc = p.leadComments()
+		}
+		ndecls := len(f.DeclList)
+
 		switch p.Token() {
 		case token.Import:
 			p.Next()
 			f.DeclList = p.appendGroup(f.DeclList, p.importDecl)
+		case token.Const:
+			p.Next()
+			f.DeclList = p.appendGroup(f.DeclList, p.constDecl)
+
 		case token.Type:
 			p.Next()
 			f.DeclList = p.appendGroup(f.DeclList, p.typeDecl)
@@ -71,12 +87,17 @@ func (p *parser) fileOrNil() *ast.File {
 			f.DeclList = p.appendGroup(f.DeclList, p.varDecl)
 
 		case token.Func:
+			// function and operator declarations are never grouped
 			p.Next()
-			f.DeclList = p.appendGroup(f.DeclList, p.funcDeclOrNil)
+			if d := p.funcDeclOrNil(nil); d != nil {
+				f.DeclList = append(f.DeclList, d)
+			}
 
 		case token.Oper:
 			p.Next()
-			f.DeclList = p.appendGroup(f.DeclList, p.operDecl)
+			if d := p.operDecl(nil); d != nil {
+				f.DeclList = append(f.DeclList, d)
+			}
 
 		case token.Semi:
 			p.Next()
@@ -89,7 +110,21 @@ func (p *parser) fileOrNil() *ast.File {
 			p.errorAt(p.pos(), "ERROR: non-declaration statement outside function body: "+str)
 			p.Next()
 		}
+
+		if len(f.DeclList) > ndecls {
+			// the comments around a group belong to the group
+			var first, last commented = f.DeclList[ndecls], f.DeclList[len(f.DeclList)-1]
+			if _, g := groupFor(f.DeclList[ndecls]); g != nil {
+				first, last = g, g
+			}
+			setComments(first, doc, nil)
+			setComments(last, nil, p.trailComments())
+		}
 	}
+	if p.comments {
+		setFinal(f, p.takeComments())
+	}
+	f.EOF = p.pos()
 	return f
 }
 
@@ -134,6 +169,124 @@ func (p *parser) got(tok token.Token) bool {
 	return false
 }
 
+// ----------------------------------------------------------------------------
+// Comments
+
+// A pendingComment is a comment that has been read but not yet
+// attached to a node.
+type pendingComment struct {
+	ast.Comment
+	alone bool // comment is on a line of its own
+}
+
+// Next advances to the next token. It keeps track of the line on which
+// the current token ends, so that the comments read with the next token
+// can be placed relative to it, and so that line breaks before the next
+// token are known.
+func (p *parser) Next() {
+	p.lastLine = p.Line()
+	if p.Token() == token.Literal {
+		p.lastLine += uint(strings.Count(p.Literal(), "\n")) // raw strings may span lines
+	}
+	p.Scanner.Next()
+}
+
+// addComment records the comment text starting on the given line.
+func (p *parser) addComment(line uint, text string) {
+	alone := line > p.lastLine
+	if n := len(p.pending); n > 0 && p.pending[n-1].alone && line == p.lastLine {
+		alone = true // follows a comment on a line of its own
+	}
+	if alone && line > p.lastLine+1 && p.lastLine > 0 {
+		p.pending = append(p.pending, pendingComment{alone: true}) // empty line
+	}
+	p.pending = append(p.pending, pendingComment{ast.Comment{Text: text}, alone})
+	p.lastLine = line + uint(strings.Count(text, "\n"))
+}
+
+// takeComments returns all pending comments.
+func (p *parser) takeComments() []ast.Comment {
+	var list []ast.Comment
+	for _, c := range p.pending {
+		list = append(list, c.Comment)
+	}
+	p.pending = p.pending[:0]
+	return list
+}
+
+// leadComments returns the pending comments preceding a node that
+// starts at the current token, and an empty line if the node is
+// separated from the preceding token or comment by one.
+func (p *parser) leadComments() []ast.Comment {
+	if !p.comments {
+		return nil
+	}
+	list := p.takeComments()
+	if p.Line() > p.lastLine+1 && p.lastLine > 0 {
+		list = append(list, ast.Comment{})
+	}
+	return list
+}
+
+// trailComments returns the pending comments that follow
+// a node on the same line.
+func (p *parser) trailComments() []ast.Comment {
+	var list []ast.Comment
+	i := 0
+	for _, c := range p.pending {
+		if c.alone {
+			p.pending[i] = c
+			i++
+		} else {
+			list = append(list, c.Comment)
+		}
+	}
+	p.pending = p.pending[:i]
+	return list
+}
+
+// trimEmpty removes leading empty lines from list.
+func trimEmpty(list []ast.Comment) []ast.Comment {
+	for len(list) > 0 && list[0].Text == "" {
+		list = list[1:]
+	}
+	return list
+}
+
+// A commented value holds comments: a node or a declaration group.
+type commented interface {
+	Comments() *ast.Comments
+	SetComments(c *ast.Comments)
+}
+
+// setComments attaches the comments preceding and following n.
+func setComments(n commented, alone, after []ast.Comment) {
+	if n == nil || len(alone) == 0 && len(after) == 0 {
+		return
+	}
+	c := n.Comments()
+	if c == nil {
+		c = new(ast.Comments)
+		n.SetComments(c)
+	}
+	c.Alone = append(c.Alone, alone...)
+	c.After = append(c.After, after...)
+}
+
+// setFinal attaches the comments following the last
+// declaration or statement of n.
+func setFinal(n commented, final []ast.Comment) {
+	if n == nil || len(final) == 0 {
+		return
+	}
+	c := n.Comments()
+	if c == nil {
+		c = new(ast.Comments)
+		n.SetComments(c)
+	}
+	c.Final = append(c.Final, final...)
+}
+
 func commentText(s string) string {
 	if s[:2] == "/*" {
 		return s[2 : len(s)-2] // lop off /* and */
@@ -148,9 +301,16 @@ func commentText(s string) string {
 	return s[2:i] // lop off //, and \r at end, if any
 }
 
-func (p *parser) init(file *position.PosBase, r io.Reader, errh ErrorHandler) {
+func (p *parser) init(file *position.PosBase, r io.Reader, errh ErrorHandler, mode Mode) {
 	p.errh = errh
 	p.file = file
+	p.verbose = mode&Trace != 0
+	p.comments = mode&ParseComments != 0
+
+	scanmode := scanner.Directives
+	if p.comments {
+		scanmode = scanner.Comments
+	}
 	p.Scanner.Init(r,
 		func(line, col uint, msg string) {
 			if msg[0] != '/' {
@@ -175,21 +335,22 @@ func (p *parser) init(file *position.PosBase, r io.Reader, errh ErrorHandler) {
 					pos = position.MakePos(p.file, line, col+uint(len(msg)))
 				}
 				p.updateBase(pos, line, col+2+5, text[5:]) // +2 to skip over // or /*
-				return
 			}
 
 			//// go: directive (but be conservative and test)
 			//if pragh != nil && strings.HasPrefix(text, "go:") {
 			//	p.pragma = pragh(p.posAt(line, col+2), p.scanner.blank, text, p.pragma) // +2 to skip over // or /*
 			//}
+
+			if p.comments {
+				p.addComment(line, msg)
+			}
 		},
-		//func(line, col uint, msg string) {
-		//	p.errorAt(p.posAt(line, col), msg)
-		//
-		//},
+		scanmode,
 	)
 	p.base = file
 	p.fnest = 0
+	p.xnest = 0
 	p.indent = nil
 }
 
@@ -265,7 +426,16 @@ func (p *parser) syntaxErrorAt(pos position.Pos, msg string) {
 	p.errorAt(pos, "syntax error: unexpected "+tok+msg)
 }
 
-const stopset uint64 = 1<<token.If |
+// declset is the set of tokens starting a declaration.
+const declset uint64 = 1<<token.Import |
+	1<<token.Const |
+	1<<token.Type |
+	1<<token.Var |
+	1<<token.Func |
+	1<<token.Oper
+
+const stopset uint64 = 1<<token.Const |
+	1<<token.If |
 	1<<token.Var
 
 func (p *parser) gotAssign() bool {
@@ -282,13 +452,74 @@ func (p *parser) gotAssign() bool {
 
 // ----------------------------------------------------------------------------
 // Declarations
+
+// appendGroup parses a single declaration or a parenthesized group
+// of declarations using f and appends them to list. The declarations
+// of a group share the same *ast.Group.
 func (p *parser) appendGroup(list []ast.Decl, f func(group *ast.Group) ast.Decl) []ast.Decl {
+	if p.got(token.Lparen) {
+		g := new(ast.Group)
+		n := 0 // number of declarations in the group
+		for p.Token() != token.EOF && p.Token() != token.Rparen {
+			if token.Contains(declset, p.Token()) {
+				break // missing ")" before the next declaration
+			}
+			doc := p.leadComments()
+			if n == 0 {
+				doc = trimEmpty(doc)
+			}
+			x := f(g)
+			if x != nil {
+				setComments(x, doc, p.trailComments())
+				list = append(list, x)
+				n++
+			}
+			// the semicolon is optional before the closing ")"
+			if !p.got(token.Semi) && p.Token() != token.Rparen {
+				p.syntaxError("in grouped declaration; possibly missing semicolon or newline or )")
+				p.advance(token.Semi, token.Rparen)
+				if !p.got(token.Semi) && p.Token() != token.Rparen {
+					break // stopped at the start of a statement
+				}
+			}
+		}
+		if p.comments {
+			setFinal(g, p.takeComments())
+		}
+		p.want(token.Rparen)
+		return list
+	}
+
 	if x := f(nil); x != nil {
 		list = append(list, x)
 	}
 	return list
 }
 
+// ConstSpec = identifier [ [ Type ] "=" Expression ] .
+func (p *parser) constDecl(group *ast.Group) ast.Decl {
+	if p.verbose {
+		defer p.trace("constDecl")()
+	}
+
+	d := new(ast.ConstDecl)
+	d.Pos = p.pos()
+	d.Group = group
+
+	d.NameList = p.name()
+	p.print("id: " + d.NameList.Value)
+	if p.Token() != token.EOF && p.Token() != token.Semi && p.Token() != token.Rparen {
+		if p.Token() != token.Assign {
+			d.Type = p.typeOrNil()
+		}
+		if p.gotAssign() {
+			d.Values = p.expr()
+		}
+	}
+
+	return d
+}
+
 // TypeSpec = identifier [ TypeParams ] [ "=" ] Type .
 func (p *parser) typeDecl(group *ast.Group) ast.Decl {
 	if p.verbose {
@@ -300,15 +531,27 @@ func (p *parser) typeDecl(group *ast.Group) ast.Decl {
 	d.Group = group
 
 	d.Name = p.name()
-	d.Alias = p.gotAssign()
-	d.Type = p.typeOrNil()
+	if p.Token() == token.Lbrack {
+		// type T[P C] or type T []E
+		pos := p.pos()
+		p.Next()
+		if p.Token() == token.Rbrack {
+			d.Type = p.sliceTypeAfter(pos)
+		} else {
+			d.TParamList = p.typeParams()
+		}
+	}
+	if d.Type == nil {
+		d.Alias = p.gotAssign()
+		d.Type = p.typeOrNil()
+	}
 
 	if d.Type == nil {
 		d.Type = p.badExpr()
 		p.syntaxError("in type declaration")
 	} else if p.verbose {
 		p.print("id: " + d.Name.Value)
-		p.print("type: " + d.Type.(*ast.Name).Value)
+		p.print("type: " + String(d.Type))
 	}
 	return d
 }
@@ -328,14 +571,18 @@ func (p *parser) varDecl(group *ast.Group) ast.Decl {
 	if p.gotAssign() {
 		d.Values = p.expr()
 	} else {
-		if p.Token() != token.Name {
-			p.syntaxError("expecting name")
-			p.Next()
+		d.Type = p.typeOrNil()
+		if d.Type == nil {
+			// skip the rest of the declaration, but not the
+			// semicolon or ) ending it
+			p.syntaxError("expecting type")
+			p.advance(token.Semi, token.Rparen)
 			return nil
 		}
-
-		d.Type = p.name()
-		p.print("type: " + d.Type.(*ast.Name).Value)
+		p.print("type: " + String(d.Type))
+		if p.gotAssign() {
+			d.Values = p.expr()
+		}
 	}
 
 	return d
@@ -343,7 +590,7 @@ func (p *parser) varDecl(group *ast.Group) ast.Decl {
 
 // TypeDecl =
 
-// FuncDecl = "func" FuncName Signature FuncBody .
+// FuncDecl = "func" [ MethodReceiver ] FuncName [ TypeParams ] Signature FuncBody .
 // FuncName = identifier .
 func (p *parser) funcDeclOrNil(group *ast.Group) ast.Decl {
 	if p.verbose {
@@ -355,6 +602,14 @@ func (p *parser) funcDeclOrNil(group *ast.Group) ast.Decl {
 	d.Pos = p.pos()
 	d.Group = group
 
+	if p.Token() == token.Lparen {
+		d.Recv = p.receiver()
+		if d.Recv == nil {
+			return nil
+		}
+		p.print("receiver: " + String(d.Recv.Type))
+	}
+
 	if p.Token() != token.Name {
 		p.errorAt(p.pos(), "expecting name")
 		return nil
@@ -364,6 +619,10 @@ func (p *parser) funcDeclOrNil(group *ast.Group) ast.Decl {
 	d.Name = p.name()
 	p.print("id: " + d.Name.Value)
 
+	if p.got(token.Lbrack) {
+		d.TParamList = p.typeParams()
+	}
+
 	// Signature
 	d.Param, d.Return = p.funcType()
 
@@ -374,12 +633,49 @@ func (p *parser) funcDeclOrNil(group *ast.Group) ast.Decl {
 	return d
 }
 
+// MethodReceiver = "(" [ identifier ] Type ")" .
+func (p *parser) receiver() *ast.Field {
+	recv := new(ast.Field)
+	p.want(token.Lparen)
+	recv.Pos = p.pos()
+	if p.Token() == token.Name {
+		name := p.name()
+		switch p.Token() {
+		case token.Rparen:
+			// (T) is an unnamed receiver of type T
+			recv.Type = name
+		case token.Lbrack:
+			// (T[P]) is an unnamed receiver of generic type T,
+			// (r []T) a receiver r of slice type
+			pos := p.pos()
+			p.Next()
+			if p.Token() == token.Rbrack {
+				recv.Name = name
+				recv.Type = p.sliceTypeAfter(pos)
+			} else {
+				recv.Type = p.typeArgs(name, pos)
+			}
+		default:
+			recv.Name = name
+			recv.Type = p.typeOrNil()
+		}
+	} else {
+		recv.Type = p.typeOrNil()
+	}
+	if recv.Type == nil {
+		p.syntaxError("expecting receiver type")
+		return nil
+	}
+	p.want(token.Rparen)
+	return recv
+}
+
 // OperDecl = "oper" Receiver OperName OperOperand ReturnType OperBody .
 // Receiver = "(" Param ")" .
 // OperName =
 //
-//	"add" | "sub" | "mul" | "div" | "mod" |
-//	"radd" | "rsub" | "rmul" | "rdiv" | "rmod" .
+//	"not" | "add" | "sub" | "mul" | "div" | "rem" | "eql" | "gtr" |
+//	"rnot" | "radd" | "rsub" | "rmul" | "rdiv" | "rrem" | "reql" | "rgtr" .
 //
 // OperOperand = "(" Param ")" .
 // ReturnType = Type .
@@ -393,25 +689,35 @@ func (p *parser) operDecl(group *ast.Group) ast.Decl {
 	d.Pos = p.pos()
 	d.Group = group
 	d.TypeL = p.singleParam()
+	if d.TypeL == nil {
+		return nil
+	}
 
+	if p.Token() != token.Name {
+		p.syntaxError("expecting operator name")
+		return nil
+	}
+	pos := p.pos()
 	name := p.name()
 	op := token.OperOrNil(name.Value)
 	if !op.IsOperOverload() {
-		p.errorAt(p.pos(), "Unexpected Operator name")
+		p.errorAt(pos, "unexpected operator name "+name.Value)
 		return nil
 	}
 
 	d.Oper = op
-	p.Next()
-	p.print("oper type: " + d.Oper.String())
+	p.print("oper type: " + d.Oper.OperName())
 	d.TypeR = p.singleParam()
-	p.print("operands: " + d.TypeL.Name.Value + " " + d.TypeR.Name.Value)
-	if p.Token() != token.Name {
-		p.errorAt(p.pos(), "expecting type")
+	if d.TypeR == nil {
 		return nil
 	}
-	d.Return = p.name()
-	p.print("return type: " + d.Return.(*ast.Name).Value)
+	p.print("operands: " + String(d.TypeL.Type) + " " + String(d.TypeR.Type))
+	d.Return = p.typeOrNil()
+	if d.Return == nil {
+		p.syntaxError("expecting type")
+		return nil
+	}
+	p.print("return type: " + String(d.Return))
 	d.Body = p.funcBody()
 
 	return d
@@ -425,22 +731,143 @@ func (p *parser) funcBody() *ast.BlockStmt {
 	return body
 }
 
+// TypeParams    = "[" TypeParamDecl { "," TypeParamDecl } [ "," ] "]" .
+// TypeParamDecl = identifier TypeConstraint .
+//
+// typeParams parses a type parameter list after its opening "[".
+func (p *parser) typeParams() []*ast.Field {
+	if p.verbose {
+		defer p.trace("typeParams")()
+	}
+
+	var list []*ast.Field
+	for p.Token() != token.EOF && p.Token() != token.Rbrack {
+		f := new(ast.Field)
+		f.Pos = p.pos()
+		f.Name = p.name()
+		f.Type = p.typeOrNil()
+		if f.Type == nil {
+			f.Type = p.badExpr()
+			p.syntaxError("expecting type constraint")
+			p.advance(token.Comma, token.Rbrack)
+		}
+		list = append(list, f)
+		// the comma is optional before the closing "]"
+		if !p.got(token.Comma) && p.Token() != token.Rbrack {
+			p.syntaxError("in type parameter list; possibly missing comma or ]")
+			p.advance(token.Comma, token.Rbrack)
+			p.got(token.Comma)
+		}
+	}
+	if len(list) == 0 {
+		p.syntaxError("empty type parameter list")
+	}
+	p.want(token.Rbrack)
+	return list
+}
+
+// TypeArgs = "[" Type { "," Type } [ "," ] "]" .
+//
+// typeArgs parses the type arguments of the generic type or function x
+// after the opening "[" at pos.
+func (p *parser) typeArgs(x ast.Expr, pos position.Pos) ast.Expr {
+	if p.verbose {
+		defer p.trace("typeArgs")()
+	}
+
+	t := new(ast.IndexExpr)
+	t.Pos = pos
+	t.X = x
+	var list []ast.Expr
+	for p.Token() != token.EOF && p.Token() != token.Rbrack {
+		typ := p.typeOrNil()
+		if typ == nil {
+			typ = p.badExpr()
+			p.syntaxError("expecting type")
+			p.advance(token.Comma, token.Rbrack)
+		}
+		list = append(list, typ)
+		if !p.got(token.Comma) && p.Token() != token.Rbrack {
+			p.syntaxError("in type argument list; possibly missing comma or ]")
+			p.advance(token.Comma, token.Rbrack)
+			p.got(token.Comma)
+		}
+	}
+	p.want(token.Rbrack)
+	t.Index = packList(list)
+	if t.Index == nil {
+		t.Index = p.badExpr()
+		p.syntaxErrorAt(pos, "expecting type argument")
+	}
+	return t
+}
+
+// packList returns the single expression in list, a ListExpr
+// for several expressions, or nil if list is empty.
+func packList(list []ast.Expr) ast.Expr {
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	}
+	l := new(ast.ListExpr)
+	l.Pos = list[0].GetPos()
+	l.ElemList = list
+	return l
+}
+
 func (p *parser) funcType() ([]*ast.Field, ast.Expr) {
 	params := make([]*ast.Field, 0)
 	p.want(token.Lparen)
 	params = p.paramlist()
-	ftype := p.typeOrNil()
-	switch ftype.(type) {
-	case *ast.Name:
-		p.print("return type: " + ftype.(*ast.Name).Value)
-	case *ast.SliceType:
-		slice := ftype.(*ast.SliceType)
-
-		p.print("return type: slice of " + slice.Elem.(*ast.Name).Value)
+	var ftype ast.Expr
+	if p.Token() == token.Lparen {
+		ftype = p.resultList()
+	} else {
+		ftype = p.typeOrNil()
+	}
+	if ftype != nil {
+		p.print("return type: " + String(ftype))
 	}
 	return params, ftype
 }
 
+// Result = "(" Type { "," Type } [ "," ] ")" | Type .
+//
+// resultList parses a parenthesized list of result types, which
+// is a ListExpr if there are several.
+func (p *parser) resultList() ast.Expr {
+	if p.verbose {
+		defer p.trace("resultList")()
+	}
+
+	pos := p.pos()
+	p.want(token.Lparen)
+	var list []ast.Expr
+	for p.Token() != token.EOF && p.Token() != token.Rparen {
+		typ := p.typeOrNil()
+		if typ == nil {
+			typ = p.badExpr()
+			p.syntaxError("expecting type")
+			p.advance(token.Comma, token.Rparen)
+		}
+		list = append(list, typ)
+		if !p.got(token.Comma) && p.Token() != token.Rparen {
+			p.syntaxError("in result list; possibly missing comma or )")
+			p.advance(token.Comma, token.Rparen)
+			p.got(token.Comma)
+		}
+	}
+	p.want(token.Rparen)
+	res := packList(list)
+	if res == nil {
+		res = p.badExpr()
+		p.syntaxErrorAt(pos, "expecting result type")
+	}
+	return res
+}
+
 // ----------------------------------------------------------------------------
 // Statements
 
@@ -450,8 +877,13 @@ func (p *parser) simpleStmt(ls ast.Expr, keyword token.Token) ast.SimpleStmt {
 		defer p.trace("simpleStmt")()
 	}
 
+	if keyword == token.For && p.Token() == token.Range {
+		// for range X
+		return p.rangeClause(p.pos(), nil)
+	}
+
 	if ls == nil {
-		ls = p.expr()
+		ls = p.exprList()
 	}
 
 	pos := p.pos()
@@ -463,19 +895,43 @@ func (p *parser) simpleStmt(ls ast.Expr, keyword token.Token) ast.SimpleStmt {
 		op := p.Op()
 		if p.Token() == token.Assign {
 			op = token.NoneOp
+		} else if _, ok := ls.(*ast.ListExpr); ok {
+			p.syntaxError(fmt.Sprintf("unexpected %s=, expecting := or = or comma", op))
 		}
 		p.Next()
-		return p.assignStmt(pos, op, ls, p.expr())
+		if keyword == token.For && p.Token() == token.Range {
+			p.syntaxError("range clause requires :=")
+			return p.rangeClause(pos, ls)
+		}
+		return p.assignStmt(pos, op, ls, p.exprList())
 	case token.Define:
 		if p.verbose {
 			defer p.trace("shortVarDecl")()
 		}
 		p.Next()
-		return p.defineStmt(pos, ls, p.expr())
+		if keyword == token.For && p.Token() == token.Range {
+			return p.rangeClause(pos, ls)
+		}
+		rhs := p.exprList()
+		if x, ok := rhs.(*ast.TypeSwitchGuard); ok && keyword == token.Switch {
+			if lhs, ok := ls.(*ast.Name); ok {
+				// switch … lhs := rhs.(type)
+				x.Lhs = lhs
+				s := new(ast.ExprStmt)
+				s.Pos = x.GetPos()
+				s.X = x
+				return s
+			}
+			p.syntaxErrorAt(pos, fmt.Sprintf("invalid variable name %s in type switch", String(ls)))
+		}
+		return p.defineStmt(pos, ls, rhs)
 	default:
 		if p.verbose {
 			defer p.trace("exprStmt")()
 		}
+		if _, ok := ls.(*ast.ListExpr); ok {
+			p.syntaxError("expecting := or = or comma")
+		}
 		s := new(ast.ExprStmt)
 		s.Pos = ls.GetPos()
 		s.X = ls
@@ -484,6 +940,51 @@ func (p *parser) simpleStmt(ls ast.Expr, keyword token.Token) ast.SimpleStmt {
 
 }
 
+// LabeledStmt = Label ":" Statement .
+// Label       = identifier .
+func (p *parser) labeledStmtOrNil(label *ast.Name) ast.Stmt {
+	if p.verbose {
+		defer p.trace("labeledStmt")()
+	}
+
+	s := new(ast.LabeledStmt)
+	s.Pos = p.pos()
+	s.Label = label
+
+	p.want(token.Colon)
+
+	if p.Token() == token.Rbrace {
+		// a label at the end of a block labels an empty statement
+		e := new(ast.EmptyStmt)
+		e.Pos = p.pos()
+		s.Stmt = e
+		return s
+	}
+
+	s.Stmt = p.stmtOrNil()
+	if s.Stmt != nil {
+		return s
+	}
+
+	// report error at line of ':' token
+	p.syntaxErrorAt(s.Pos, "missing statement after label")
+	return nil // avoids follow-on errors
+}
+
+// RangeClause = [ ExpressionList ":=" ] "range" Expression .
+func (p *parser) rangeClause(pos position.Pos, lhs ast.Expr) *ast.RangeClause {
+	if p.verbose {
+		defer p.trace("rangeClause")()
+	}
+
+	r := new(ast.RangeClause)
+	r.Pos = pos
+	p.want(token.Range)
+	r.Lhs = lhs
+	r.X = p.expr()
+	return r
+}
+
 func (p *parser) declStmt(f func(*ast.Group) ast.Decl) *ast.DeclStmt {
 	if p.verbose {
 		defer p.trace("declStmt")()
@@ -530,6 +1031,9 @@ func (p *parser) blockStmt(context string) *ast.BlockStmt {
 		return nil
 	}
 	s.StmtList = p.stmtList()
+	if p.comments {
+		setFinal(s, p.takeComments())
+	}
 
 	s.Rbrace = p.pos()
 	p.want(token.Rbrace)
@@ -543,14 +1047,19 @@ func (p *parser) stmtList() (l []ast.Stmt) {
 		defer p.trace("stmtList")()
 	}
 
-	for p.Token() != token.EOF && p.Token() != token.Rbrace {
+	for p.Token() != token.EOF && p.Token() != token.Rbrace && p.Token() != token.Case && p.Token() != token.Default {
+		doc := p.leadComments()
+		if len(l) == 0 {
+			doc = trimEmpty(doc)
+		}
 		s := p.stmtOrNil()
 		if s == nil {
 			break
 		}
+		setComments(s, doc, p.trailComments())
 		l = append(l, s)
 		// ";" is optional before "}"
-		if !p.got(token.Semi) && p.Token() != token.Rbrace {
+		if !p.got(token.Semi) && p.Token() != token.Rbrace && p.Token() != token.Case && p.Token() != token.Default {
 			p.syntaxError("at end of statement")
 			p.got(token.Semi) // avoid spurious empty statement
 		}
@@ -560,8 +1069,8 @@ func (p *parser) stmtList() (l []ast.Stmt) {
 
 // Statement =
 //
-//	Declaration | ast.SimpleStmt | ReturnStmt | BreakStmt | ContinueStmt |
-//	Block | IfStmt | ForStmt .
+//	Declaration | LabeledStmt | ast.SimpleStmt | ReturnStmt | BreakStmt |
+//	ContinueStmt | Block | IfStmt | SwitchStmt | ForStmt .
 func (p *parser) stmtOrNil() ast.Stmt {
 	if p.verbose {
 		defer p.trace("stmt")()
@@ -569,15 +1078,20 @@ func (p *parser) stmtOrNil() ast.Stmt {
 
 	if p.Token() == token.Name {
 		p.print("lhs:")
-		lhs := p.expr()
+		lhs := p.exprList()
+		if label, ok := lhs.(*ast.Name); ok && p.Token() == token.Colon {
+			return p.labeledStmtOrNil(label)
+		}
 		return p.simpleStmt(lhs, 0)
 	}
 	switch p.Token() {
+	case token.Const:
+		return p.declStmt(p.constDecl)
 	case token.Var:
 		return p.declStmt(p.varDecl)
 	case token.Lbrace:
 		return p.blockStmt("")
-	case token.Literal, token.Name:
+	case token.Literal, token.Name, token.Func, token.Star, token.Lparen:
 		return p.simpleStmt(nil, 0)
 	case token.For:
 		return p.forStmt()
@@ -586,18 +1100,31 @@ func (p *parser) stmtOrNil() ast.Stmt {
 		return p.whileStmt()
 	case token.If:
 		return p.ifStmt()
+	case token.Switch:
+		return p.switchStmt()
 	case token.Return:
 		s := new(ast.ReturnStmt)
 		s.Pos = p.pos()
 		p.Next()
 		if p.Token() != token.Semi && p.Token() != token.Rbrace {
-			s.Result = p.expr()
+			s.Result = p.exprList()
 		}
 		return s
 	case token.Break:
 		s := new(ast.BreakStmt)
 		s.Pos = p.pos()
 		p.Next()
+		if p.Token() == token.Name {
+			s.Label = p.name()
+		}
+		return s
+	case token.Continue:
+		s := new(ast.ContinueStmt)
+		s.Pos = p.pos()
+		p.Next()
+		if p.Token() == token.Name {
+			s.Label = p.name()
+		}
 		return s
 	case token.Semi:
 		func() { defer p.trace("empty stmt")() }()
@@ -619,6 +1146,22 @@ func (p *parser) expr() ast.Expr {
 	return p.binaryExpr(0)
 }
 
+// ExpressionList = Expression { "," Expression } .
+//
+// exprList returns the single expression of the list, or a ListExpr
+// for several expressions.
+func (p *parser) exprList() ast.Expr {
+	if p.verbose {
+		defer p.trace("exprList")()
+	}
+
+	list := []ast.Expr{p.expr()}
+	for p.got(token.Comma) {
+		list = append(list, p.expr())
+	}
+	return packList(list)
+}
+
 // ast.Expr = UnaryExpr | ast.Expr binary_op ast.Expr .//a+b*x
 func (p *parser) binaryExpr(prec int) ast.Expr {
 	// don't p.verbose binaryExpr - only leads to overly nested p.verbose output
@@ -631,15 +1174,9 @@ func (p *parser) binaryExpr(prec int) ast.Expr {
 		tprec := p.Prec()
 		p.print("operator(" + t.Op.String() + ")")
 		p.Next()
+		setFinal(t, p.trailComments())
 		t.X = x
 		t.Y = p.binaryExpr(tprec)
-
-		switch t.Op {
-		case token.Lss:
-			t.Op = token.Gtr
-			t.X, t.Y = t.Y, t.X
-		}
-
 		x = t
 	}
 	return x
@@ -651,9 +1188,16 @@ func (p *parser) unaryExpr() ast.Expr {
 		defer p.trace("unaryExpr")()
 	}
 	switch p.Token() {
+	case token.Star:
+		x := new(ast.Operation)
+		x.Pos = p.pos()
+		x.Op = token.Mul
+		p.Next()
+		x.X = p.unaryExpr()
+		return x
 	case token.Op:
 		switch p.Op() {
-		case token.Mul, token.Add, token.Sub, token.Not: //, Xor:
+		case token.Add, token.Sub, token.Not: //, Xor:
 			x := new(ast.Operation)
 			x.Pos = p.pos()
 			x.Op = p.Op()
@@ -661,15 +1205,15 @@ func (p *parser) unaryExpr() ast.Expr {
 			x.X = p.unaryExpr()
 			return x
 
-			//case And:
-			//	x := new(Operation)
-			//	x.pos = p.pos()
-			//	x.Op = And
-			//	p.Next()
-			//	// unaryExpr may have returned a parenthesized composite gotLiteral
-			//	// (see comment in operand) - remove parentheses if any
-			//	x.X = Unparen(p.unaryExpr())
-			//	return x
+		case token.And:
+			x := new(ast.Operation)
+			x.Pos = p.pos()
+			x.Op = token.And
+			p.Next()
+			// unaryExpr may have returned a parenthesized composite literal
+			// (see comment in operand) - remove parentheses if any
+			x.X = Unparen(p.unaryExpr())
+			return x
 		}
 	}
 	return p.pexpr()
@@ -680,7 +1224,6 @@ func (p *parser) operand() (rtn ast.Expr) {
 		defer p.trace("operand")()
 	}
 
-	rtn = &ast.BadExpr{}
 	tok := p.Token().String()
 	switch p.Token() {
 	case token.Name:
@@ -690,10 +1233,52 @@ func (p *parser) operand() (rtn ast.Expr) {
 		rtn = p.sliceLit()
 		p.print(tok + "(" + ")")
 
+	case token.Struct:
+		rtn = p.structType()
+
+	case token.Map:
+		rtn = p.mapType()
+
+	case token.Interface:
+		rtn = p.interfaceType()
+
+	case token.Func:
+		// function type or literal
+		pos := p.pos()
+		p.Next()
+		t := p.funcTypeAfter(pos)
+		if p.Token() == token.Lbrace {
+			f := new(ast.FuncLit)
+			f.Pos = pos
+			f.Type = t
+			p.xnest++
+			f.Body = p.funcBody()
+			p.xnest--
+			rtn = f
+		} else {
+			rtn = t
+		}
+
 	case token.Literal:
 		lit := p.literal()
 		rtn = lit
 		p.print(tok + "(" + lit.Value + ")")
+
+	case token.Lparen:
+		// "(" Expression ")"
+		x := new(ast.ParenExpr)
+		x.Pos = p.pos()
+		p.Next()
+		p.xnest++
+		x.X = p.expr()
+		p.xnest--
+		p.want(token.Rparen)
+		rtn = x
+
+	default:
+		rtn = p.badExpr()
+		p.syntaxError("expecting expression")
+		p.advance(token.Semi, token.Comma, token.Rparen, token.Rbrack, token.Rbrace)
 	}
 	return
 }
@@ -701,10 +1286,12 @@ func (p *parser) operand() (rtn ast.Expr) {
 // PrimaryExpr =
 //
 //	Operand |
+//	CompositeLit |
 //	PrimaryExpr Selector |
 //	PrimaryExpr Call .
 //
 // Selector       = "." identifier .
+// TypeAssertion  = "." "(" Type ")" .
 // Call			  = "(" [ ast.ExprList ] ")" .
 func (p *parser) pexpr() ast.Expr {
 	if p.verbose {
@@ -727,26 +1314,79 @@ loop:
 				t.Sel = p.name()
 				x = t
 
+			case token.Lparen:
+				p.Next()
+				if p.got(token.Type) {
+					// pexpr '.' '(' 'type' ')'
+					t := new(ast.TypeSwitchGuard)
+					// t.Lhs is filled in by parser.simpleStmt
+					t.Pos = pos
+					t.X = x
+					x = t
+				} else {
+					// pexpr '.' '(' type ')'
+					t := new(ast.AssertExpr)
+					t.Pos = pos
+					t.X = x
+					t.Type = p.typeOrNil()
+					if t.Type == nil {
+						t.Type = p.badExpr()
+						p.syntaxError("expecting type")
+					}
+					x = t
+				}
+				p.want(token.Rparen)
+
 			default:
 				p.syntaxError("expecting name or (")
 			}
 		case token.Lbrack:
 			// pexpr '[' expr ']'
+			// pexpr '[' expr { ',' expr } ']' (type arguments)
 			t := new(ast.IndexExpr)
 			t.Pos = pos
 			t.X = x
 			p.Next()
-			t.Index = p.expr()
+			p.xnest++
+			list := []ast.Expr{p.expr()}
+			for p.got(token.Comma) && p.Token() != token.Rbrack {
+				list = append(list, p.expr())
+			}
+			p.xnest--
 			p.want(token.Rbrack)
+			t.Index = packList(list)
 			x = t
 		case token.Lparen:
 
 			t := new(ast.CallExpr)
 			t.Pos = pos
 			t.Func = x
-			t.ArgList = p.argList()
+			p.argList(t)
 			x = t
 
+		case token.Lbrace:
+			// determine if '{' belongs to a composite literal or a block statement
+			complit_ok := false
+			switch Unparen(x).(type) {
+			case *ast.Name, *ast.SelectorExpr, *ast.IndexExpr:
+				// x is possibly a composite literal type,
+				// unless we are in a statement header
+				complit_ok = p.xnest >= 0
+			case *ast.StructType, *ast.MapType:
+				// x is a composite literal type
+				complit_ok = true
+			}
+			if !complit_ok {
+				break loop
+			}
+			if Unparen(x) != x {
+				p.syntaxError("cannot parenthesize type in composite literal")
+				// already progressed, no need to advance
+			}
+			n := p.complitexpr()
+			n.Type = x
+			x = n
+
 		default:
 			break loop
 		}
@@ -760,13 +1400,56 @@ loop:
 func (p *parser) typeOrNil() ast.Expr {
 	switch p.Token() {
 	case token.Name:
-		return p.name()
+		name := p.name()
+		if p.Token() == token.Lbrack {
+			// instantiated generic type
+			pos := p.pos()
+			p.Next()
+			return p.typeArgs(name, pos)
+		}
+		return name
 	case token.Lbrack:
 		return p.sliceType()
+	case token.Struct:
+		return p.structType()
+	case token.Map:
+		return p.mapType()
+	case token.Interface:
+		return p.interfaceType()
+	case token.Func:
+		pos := p.pos()
+		p.Next()
+		return p.funcTypeAfter(pos)
+	case token.Star:
+		// pointer type
+		x := new(ast.Operation)
+		x.Pos = p.pos()
+		x.Op = token.Mul
+		p.Next()
+		x.X = p.typeOrNil()
+		if x.X == nil {
+			x.X = p.badExpr()
+			p.syntaxError("expecting type")
+		}
+		return x
 	}
 	return nil
 }
 
+// FunctionType = "func" Parameters [ Result ] .
+//
+// funcTypeAfter parses a function type after its "func" keyword at pos.
+func (p *parser) funcTypeAfter(pos position.Pos) *ast.FuncType {
+	if p.verbose {
+		defer p.trace("funcType")()
+	}
+
+	t := new(ast.FuncType)
+	t.Pos = pos
+	t.Param, t.Return = p.funcType()
+	return t
+}
+
 func (p *parser) literal() *ast.BasicLit {
 	if p.Token() == token.Literal {
 		b := new(ast.BasicLit)
@@ -788,7 +1471,7 @@ func (p *parser) singleParam() *ast.Field {
 	}
 	first := true
 recv:
-	if p.Token() != token.Name {
+	if p.Token() != token.Name && (first || p.Token() != token.Lbrack) {
 		str := "type"
 		if first {
 			str = "receiver"
@@ -796,75 +1479,132 @@ recv:
 		p.syntaxError("expecting " + str)
 		return nil
 	}
-	name := p.name()
 	if first {
-		param.Name = name
+		param.Pos = p.pos()
+		param.Name = p.name()
 		first = false
 		goto recv
 	}
-	param.Type = name
+	param.Type = p.typeOrNil()
 	p.want(token.Rparen)
 	return param
 }
 
+// Parameters     = "(" [ ParameterList [ "," ] ] ")" .
+// ParameterList  = ParameterDecl { "," ParameterDecl } .
+// ParameterDecl  = [ identifier ] Type .
+//
+// paramlist parses a parameter list after its opening "(". The
+// parameters are either all named or all anonymous, as in function
+// types like func(int) bool.
 func (p *parser) paramlist() []*ast.Field {
-	list := make([]*ast.Field, 0)
-	none := "none"
+	var list []*ast.Field
+	named := 0
 	str := " "
-redo:
-	param := new(ast.Field)
-	switch p.Token() {
-	case token.Name:
-		none = ""
-		param.Name = p.name()
-		if p.Token() == token.Name {
-			ptype := p.typeOrNil()
-			str += none + param.Name.Value + "(" + ptype.(*ast.Name).Value + ") "
-			param.Type = ptype
+	for p.Token() != token.EOF && p.Token() != token.Rparen {
+		doc := p.leadComments()
+		if list == nil {
+			doc = trimEmpty(doc)
+		}
+		param := p.paramOrNil()
+		if param == nil {
+			p.syntaxError("expecting parameter or ')'")
+			p.advance(token.Comma, token.Rparen)
+		} else {
 			list = append(list, param)
-			switch p.Token() {
-			case token.Comma:
-				p.Next()
-				goto redo
-			case token.Rparen:
-				p.Next()
-				p.print("params:" + str)
-				return list
-			default:
-				p.syntaxError("expecting comma or ')'")
-				p.Next()
-				return nil
+			if param.Name != nil {
+				named++
+				str += param.Name.Value
 			}
-		} else {
-			p.syntaxError("expecting type")
-			p.Next()
+			str += "(" + String(param.Type) + ") "
+		}
+		if !p.got(token.Comma) && p.Token() != token.Rparen {
+			p.syntaxError("expecting comma or ')'")
+			p.advance(token.Comma, token.Rparen)
+			p.got(token.Comma)
+		}
+		if param != nil {
+			setComments(param, doc, p.trailComments())
+		}
+	}
+	p.want(token.Rparen)
+	if named != 0 && named != len(list) {
+		p.syntaxErrorAt(list[0].Pos, "mixed named and unnamed parameters")
+	}
+	if len(list) > 0 {
+		p.print("params:" + str)
+	}
+	return list
+}
+
+// paramOrNil parses a parameter declaration, or returns nil
+// if there is none.
+func (p *parser) paramOrNil() *ast.Field {
+	f := new(ast.Field)
+	f.Pos = p.pos()
+	if p.Token() != token.Name {
+		f.Type = p.typeOrNil()
+		if f.Type == nil {
 			return nil
 		}
-	case token.Rparen:
+		return f
+	}
+
+	name := p.name()
+	switch p.Token() {
+	case token.Comma, token.Rparen:
+		// an anonymous parameter of type name
+		f.Type = name
+	case token.Lbrack:
+		// x []T is a parameter x of slice type,
+		// T[A] an anonymous parameter of generic type
+		pos := p.pos()
 		p.Next()
-		return nil
+		if p.Token() == token.Rbrack {
+			f.Name = name
+			f.Type = p.sliceTypeAfter(pos)
+		} else {
+			f.Type = p.typeArgs(name, pos)
+		}
 	default:
-		p.syntaxError("expecting parameter or ')'")
-		p.Next()
-		return nil
+		f.Name = name
+		f.Type = p.typeOrNil()
+		if f.Type == nil {
+			f.Type = p.badExpr()
+			p.syntaxError("expecting type")
+		}
 	}
+	return f
 }
 
-func (p *parser) argList() []ast.Expr {
+// Arguments = "(" [ ExpressionList [ "," ] ] ")" .
+//
+// argList parses the arguments of the call x.
+func (p *parser) argList(x *ast.CallExpr) {
 	if p.verbose {
 		defer p.trace("argList")()
 	}
-	list := make([]ast.Expr, 0)
+	x.ArgList = make([]ast.Expr, 0)
 	p.want(token.Lparen)
-	if !p.got(token.Rparen) {
-		list = append(list, p.expr())
-		for !p.got(token.Rparen) {
-			p.want(token.Comma)
-			list = append(list, p.expr())
+	p.xnest++
+	for p.Token() != token.EOF && p.Token() != token.Rparen {
+		doc := p.leadComments()
+		if len(x.ArgList) == 0 {
+			doc = trimEmpty(doc)
+		}
+		e := p.expr()
+		x.ArgList = append(x.ArgList, e)
+		// the comma is optional before the closing ")"
+		if !p.got(token.Comma) && p.Token() != token.Rparen {
+			p.syntaxError("in argument list; possibly missing comma or )")
+			p.advance(token.Comma, token.Rparen)
+			p.got(token.Comma)
 		}
+		setComments(e, doc, p.trailComments())
 	}
-
-	return list
+	setFinal(x, p.takeComments())
+	p.xnest--
+	p.want(token.Rparen)
 }
 
 // ----------------------------------------------------------------------------
@@ -920,12 +1660,20 @@ func (p *parser) header(keyword token.Token) (init ast.SimpleStmt, cond ast.Expr
 		return
 	}
 
+	outer := p.xnest
+	p.xnest = -1
+
 	if p.Token() != token.Semi {
 		// accept potential varDecl but complain
 		if p.got(token.Var) {
 			p.syntaxError(fmt.Sprintf("var declaration not allowed in %s initializer", tokstring(keyword)))
 		}
 		init = p.simpleStmt(nil, keyword)
+		// a range clause takes the place of all three for clauses
+		if _, ok := init.(*ast.RangeClause); ok {
+			p.xnest = outer
+			return
+		}
 	}
 	var condStmt ast.SimpleStmt
 	var semi struct {
@@ -982,6 +1730,7 @@ done:
 	default:
 		p.syntaxErrorAt(s.GetPos(), fmt.Sprintf("cannot use %s as value", s))
 	}
+	p.xnest = outer
 	return
 }
 
@@ -1012,13 +1761,72 @@ func (p *parser) ifStmt() *ast.IfStmt {
 	return s
 }
 
+// SwitchStmt = "switch" [ Expression | TypeSwitchGuard ] "{" { CaseClause } "}" .
+func (p *parser) switchStmt() *ast.SwitchStmt {
+	if p.verbose {
+		defer p.trace("switchStmt")()
+	}
+	s := new(ast.SwitchStmt)
+	s.Pos = p.pos()
+	init, tag, _ := p.header(token.Switch)
+	if init != nil {
+		p.syntaxErrorAt(init.GetPos(), "init statement not allowed in switch statement")
+	}
+	s.Tag = tag
+	if !p.got(token.Lbrace) {
+		p.syntaxError("missing { after switch clause")
+		p.advance(token.Case, token.Default, token.Rbrace)
+	}
+	for p.Token() != token.EOF && p.Token() != token.Rbrace {
+		doc := p.leadComments()
+		if len(s.Body) == 0 {
+			doc = trimEmpty(doc)
+		}
+		c := p.caseClause()
+		setComments(c, doc, nil)
+		s.Body = append(s.Body, c)
+	}
+	if p.comments {
+		setFinal(s, p.takeComments())
+	}
+	s.Rbrace = p.pos()
+	p.want(token.Rbrace)
+	return s
+}
+
+// CaseClause = ( "case" ExpressionList | "default" ) ":" StatementList .
+func (p *parser) caseClause() *ast.CaseClause {
+	if p.verbose {
+		defer p.trace("caseClause")()
+	}
+	c := new(ast.CaseClause)
+	c.Pos = p.pos()
+	switch p.Token() {
+	case token.Case:
+		p.Next()
+		c.Cases = p.exprList()
+	case token.Default:
+		p.Next()
+	default:
+		p.syntaxError("expecting case or default or }")
+		p.advance(token.Colon, token.Case, token.Default, token.Rbrace)
+	}
+	c.Colon = p.pos()
+	p.want(token.Colon)
+	c.Body = p.stmtList()
+	return c
+}
+
 func (p *parser) whileStmt() ast.Stmt {
 	if p.verbose {
 		defer p.trace("whileStmt")()
 	}
 	s := new(ast.WhileStmt)
 	s.Pos = p.pos()
+	outer := p.xnest
+	p.xnest = -1
 	s.Cond = p.expr()
+	p.xnest = outer
 	s.Body = p.blockStmt("While clause")
 	return s
 }
@@ -1027,9 +1835,16 @@ func (p *parser) sliceType() ast.Expr {
 	if p.verbose {
 		defer p.trace("sliceType")()
 	}
-	t := new(ast.SliceType)
-	t.Pos = p.pos()
+	pos := p.pos()
 	p.Next()
+	return p.sliceTypeAfter(pos)
+}
+
+// sliceTypeAfter parses the rest of a slice type
+// whose "[" at pos has been consumed.
+func (p *parser) sliceTypeAfter(pos position.Pos) ast.Expr {
+	t := new(ast.SliceType)
+	t.Pos = pos
 	p.want(token.Rbrack)
 	t.Elem = p.typeOrNil()
 	if t.Elem == nil {
@@ -1053,18 +1868,237 @@ func (p *parser) sliceLit() ast.Expr {
 		//elem = p.badExpr()
 		p.syntaxError("invalid element type in slice")
 	}
+	if p.Token() != token.Lbrace {
+		// a slice type used as an expression, as in
+		// a conversion or a type argument
+		t := new(ast.SliceType)
+		t.Pos = l.Pos
+		t.Elem = l.ElemType
+		return t
+	}
 	p.want(token.Lbrace)
+	p.xnest++
 	l.Elems = make([]ast.Expr, 0)
-	if !p.got(token.Rbrace) {
-		l.Elems = append(l.Elems, p.expr())
-		for !p.got(token.Rbrace) {
-			p.want(token.Comma)
-			l.Elems = append(l.Elems, p.expr())
+	for p.Token() != token.EOF && p.Token() != token.Rbrace {
+		doc := p.leadComments()
+		if len(l.Elems) == 0 {
+			doc = trimEmpty(doc)
 		}
+		e := p.bare_complitexpr()
+		l.Elems = append(l.Elems, e)
+		// the comma is optional before the closing "}"
+		if !p.got(token.Comma) && p.Token() != token.Rbrace {
+			p.syntaxError("in slice literal; possibly missing comma or }")
+			p.advance(token.Comma, token.Rbrace)
+			p.got(token.Comma)
+		}
+		setComments(e, doc, p.trailComments())
 	}
+	l.Lines = p.Line() > p.lastLine
+	setFinal(l, p.takeComments())
+	p.xnest--
+	p.want(token.Rbrace)
 	return l
 }
 
+// MapType = "map" "[" KeyType "]" ElementType .
+func (p *parser) mapType() *ast.MapType {
+	if p.verbose {
+		defer p.trace("mapType")()
+	}
+
+	typ := new(ast.MapType)
+	typ.Pos = p.pos()
+	p.want(token.Map)
+	p.want(token.Lbrack)
+	typ.Key = p.typeOrNil()
+	if typ.Key == nil {
+		typ.Key = p.badExpr()
+		p.syntaxError("invalid key type in map")
+	}
+	p.want(token.Rbrack)
+	typ.Value = p.typeOrNil()
+	if typ.Value == nil {
+		typ.Value = p.badExpr()
+		p.syntaxError("invalid element type in map")
+	}
+	return typ
+}
+
+// StructType = "struct" "{" { FieldDecl ";" } "}" .
+func (p *parser) structType() *ast.StructType {
+	if p.verbose {
+		defer p.trace("structType")()
+	}
+
+	typ := new(ast.StructType)
+	typ.Pos = p.pos()
+	p.want(token.Struct)
+	p.want(token.Lbrace)
+	for p.Token() != token.EOF && p.Token() != token.Rbrace {
+		doc := p.leadComments()
+		if len(typ.FieldList) == 0 {
+			doc = trimEmpty(doc)
+		}
+		// the comments of a field declaration belong to its first field
+		n := len(typ.FieldList)
+		p.fieldDecl(typ)
+		if n < len(typ.FieldList) {
+			setComments(typ.FieldList[n], doc, p.trailComments())
+		}
+		// the semicolon is optional before the closing "}"
+		if !p.got(token.Semi) && p.Token() != token.Rbrace {
+			p.syntaxError("in struct type; possibly missing semicolon or newline or }")
+			p.advance(token.Semi, token.Rbrace)
+			p.got(token.Semi)
+		}
+	}
+	if p.comments {
+		setFinal(typ, p.takeComments())
+	}
+	p.want(token.Rbrace)
+	return typ
+}
+
+// FieldDecl = IdentifierList Type .
+func (p *parser) fieldDecl(styp *ast.StructType) {
+	if p.verbose {
+		defer p.trace("fieldDecl")()
+	}
+
+	pos := p.pos()
+	names := p.nameList(p.name())
+	typ := p.typeOrNil()
+	if typ == nil {
+		typ = p.badExpr()
+		p.syntaxError("expecting field type")
+	}
+	// the fields of a list share the same type
+	for _, name := range names {
+		f := new(ast.Field)
+		f.Pos = pos
+		f.Name = name
+		f.Type = typ
+		styp.FieldList = append(styp.FieldList, f)
+	}
+}
+
+// InterfaceType = "interface" "{" { MethodSpec ";" } "}" .
+func (p *parser) interfaceType() *ast.InterfaceType {
+	if p.verbose {
+		defer p.trace("interfaceType")()
+	}
+
+	typ := new(ast.InterfaceType)
+	typ.Pos = p.pos()
+	p.want(token.Interface)
+	p.want(token.Lbrace)
+	for p.Token() != token.EOF && p.Token() != token.Rbrace {
+		doc := p.leadComments()
+		if len(typ.MethodList) == 0 {
+			doc = trimEmpty(doc)
+		}
+		if f := p.methodSpec(); f != nil {
+			setComments(f, doc, p.trailComments())
+			typ.MethodList = append(typ.MethodList, f)
+		}
+		// the semicolon is optional before the closing "}"
+		if !p.got(token.Semi) && p.Token() != token.Rbrace {
+			p.syntaxError("in interface type; possibly missing semicolon or newline or }")
+			p.advance(token.Semi, token.Rbrace)
+			p.got(token.Semi)
+		}
+	}
+	if p.comments {
+		setFinal(typ, p.takeComments())
+	}
+	p.want(token.Rbrace)
+	return typ
+}
+
+// MethodSpec = MethodName Parameters [ Result ] .
+func (p *parser) methodSpec() *ast.Field {
+	if p.verbose {
+		defer p.trace("methodSpec")()
+	}
+
+	if p.Token() != token.Name {
+		p.syntaxError("expecting method name")
+		return nil
+	}
+	f := new(ast.Field)
+	f.Pos = p.pos()
+	f.Name = p.name()
+	if p.Token() != token.Lparen {
+		p.syntaxError("expecting method signature")
+		return nil
+	}
+	t := new(ast.FuncType)
+	t.Pos = p.pos()
+	t.Param, t.Return = p.funcType()
+	f.Type = t
+	return f
+}
+
+// LiteralValue = "{" [ ElementList [ "," ] ] "}" .
+func (p *parser) complitexpr() *ast.CompositeLit {
+	if p.verbose {
+		defer p.trace("complitexpr")()
+	}
+
+	x := new(ast.CompositeLit)
+	x.Pos = p.pos()
+
+	p.want(token.Lbrace)
+	p.xnest++
+	for p.Token() != token.EOF && p.Token() != token.Rbrace {
+		doc := p.leadComments()
+		if len(x.ElemList) == 0 {
+			doc = trimEmpty(doc)
+		}
+		// value
+		e := p.bare_complitexpr()
+		if p.Token() == token.Colon {
+			// key ':' value
+			l := new(ast.KeyValueExpr)
+			l.Pos = p.pos()
+			p.Next()
+			l.Key = e
+			l.Value = p.bare_complitexpr()
+			e = l
+			x.NKeys++
+		}
+		x.ElemList = append(x.ElemList, e)
+		// the comma is optional before the closing "}"
+		if !p.got(token.Comma) && p.Token() != token.Rbrace {
+			p.syntaxError("in composite literal; possibly missing comma or }")
+			p.advance(token.Comma, token.Rbrace)
+			p.got(token.Comma)
+		}
+		setComments(e, doc, p.trailComments())
+	}
+	x.Lines = p.Line() > p.lastLine
+	setFinal(x, p.takeComments())
+	p.xnest--
+	p.want(token.Rbrace)
+
+	return x
+}
+
+// Element = Expression | LiteralValue .
+func (p *parser) bare_complitexpr() ast.Expr {
+	if p.verbose {
+		defer p.trace("bare_complitexpr")()
+	}
+
+	if p.Token() == token.Lbrace {
+		// '{' start_complit braced_keyval_list '}'
+		return p.complitexpr()
+	}
+
+	return p.expr()
+}
+
 func (p *parser) updateBase(pos position.Pos, tline, tcol uint, text string) {
 	i, n, ok := trailingDigits(text)
 	if i == 0 {
@@ -1111,6 +2145,8 @@ func (p *parser) updateBase(pos position.Pos, tline, tcol uint, text string) {
 
 func (p *parser) importDecl(group *ast.Group) ast.Decl {
 	decl := new(ast.ImportDecl)
+	decl.Pos = p.pos()
+	decl.Group = group
 
 	decl.Path = p.litOrNil()
 
@@ -1123,7 +2159,6 @@ func (p *parser) importDecl(group *ast.Group) ast.Decl {
 		p.syntaxErrorAt(decl.Path.GetPos(), "import path must be a string")
 		decl.Path.Bad = true
 	}
-	p.want(token.Semi)
 	return decl
 }
 
@@ -1176,6 +2211,18 @@ func Unparen(x ast.Expr) ast.Expr {
 	return x
 }
 
+// UnpackList returns the elements of the list expression x, x itself
+// as a list of one if it is not a *ast.ListExpr, or nil if x is nil.
+func UnpackList(x ast.Expr) []ast.Expr {
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case *ast.ListExpr:
+		return x.ElemList
+	}
+	return []ast.Expr{x}
+}
+
 const trace = false
 
 // advance consumes tokens until it finds a token of the stopset or followlist.
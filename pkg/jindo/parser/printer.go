@@ -25,7 +25,21 @@ const (
 
 // Fprint prints node x to w in the specified form.
 // It returns the number of bytes written, and whether there was an error.
+// In the default form, a tree with invalid expressions is not printed,
+// since they cannot be written back as source.
 func Fprint(w io.Writer, x ast.Node, form Form) (n int, err error) {
+	if form == 0 {
+		ast.Inspect(x, func(n ast.Node) bool {
+			if b, ok := n.(*ast.BadExpr); ok && err == nil {
+				err = Error{b.Pos, "cannot format invalid expression"}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	p := printer{
 		output:     w,
 		form:       form,
@@ -65,14 +79,14 @@ const (
 	newline
 	indent
 	outdent
-	// comment
+	comment
 	// eolComment
 )
 
 type whitespace struct {
 	last token.Token
 	kind ctrlSymbol
-	//text string // comment text (possibly ""); valid if kind == comment
+	text string // comment text (possibly ""); valid if kind == comment
 }
 
 type printer struct {
@@ -145,7 +159,7 @@ func lineComment(text string) bool {
 }
 
 func (p *printer) addWhitespace(kind ctrlSymbol, text string) {
-	p.pending = append(p.pending, whitespace{p.lastTok, kind /*text*/})
+	p.pending = append(p.pending, whitespace{p.lastTok, kind, text})
 	switch kind {
 	case semi:
 		p.lastTok = token.Semi
@@ -175,12 +189,12 @@ func (p *printer) flush(next token.Token) {
 			sawNewline = true
 		case blank, indent, outdent:
 			// nothing to do
-		// case comment:
-		// 	// A multi-line comment acts like a newline; and a ""
-		// 	// comment implies by definition at least one newline.
-		// 	if text := p.pending[i].text; strings.HasPrefix(text, "/*") && strings.ContainsRune(text, '\n') {
-		// 		sawNewline = true
-		// 	}
+		case comment:
+			// A multi-line comment acts like a newline; and a //-style
+			// comment is always followed by one.
+			if text := p.pending[i].text; lineComment(text) || strings.ContainsRune(text, '\n') {
+				sawNewline = true
+			}
 		// case eolComment:
 		// 	// TODO(gri) act depending on sawNewline
 		default:
@@ -219,13 +233,13 @@ func (p *printer) flush(next token.Token) {
 			if p.indent < 0 {
 				panic("negative indentation")
 			}
-		// case comment:
-		// 	if text := p.pending[i].text; text != "" {
-		// 		p.writeString(text)
-		// 		p.nlcount = 0
-		// 		prev = comment
-		// 	}
-		// 	// TODO(gri) should check that line comments are always followed by newline
+		case comment:
+			if text := p.pending[i].text; text != "" {
+				p.writeString(text)
+				p.nlcount = 0
+				prev = comment
+			}
+			// TODO(gri) should check that line comments are always followed by newline
 		default:
 			panic("unreachable")
 		}
@@ -300,7 +314,7 @@ func (p *printer) print(args ...interface{}) {
 
 		case ctrlSymbol:
 			switch x {
-			case none, semi /*, comment*/ :
+			case none, semi, comment:
 				panic("unreachable")
 			case newline:
 				// TODO(gri) need to handle mandatory newlines after a //-style comment
@@ -310,8 +324,8 @@ func (p *printer) print(args ...interface{}) {
 			}
 			p.addWhitespace(x, "")
 
-		// case *Comment: // comments are not ast.Nodes
-		// 	p.addWhitespace(comment, x.Text)
+		case *ast.Comment: // comments are not ast.Nodes
+			p.addWhitespace(comment, x.Text)
 
 		default:
 			panic(fmt.Sprintf("unexpected argument %v (%T)", x, x))
@@ -320,36 +334,65 @@ func (p *printer) print(args ...interface{}) {
 }
 
 func (p *printer) printNode(n ast.Node) {
-	// ncom := *n.Comments()
-	// if ncom != nil {
-	// 	// TODO(gri) in general we cannot make assumptions about whether
-	// 	// a comment is a /*- or a //-style comment since the syntax
-	// 	// tree may have been manipulated. Need to make sure the correct
-	// 	// whitespace is emitted.
-	// 	for _, c := range ncom.Alone {
-	// 		p.print(c, newline)
-	// 	}
-	// 	for _, c := range ncom.Before {
-	// 		if c.Text == "" || lineComment(c.Text) {
-	// 			panic("unexpected empty line or //-style 'before' comment")
-	// 		}
-	// 		p.print(c, blank)
-	// 	}
-	// }
-
+	// Comments are only printed if linebreaks are printed:
+	// a //-style comment must be followed by a newline.
+	// Comments following a node on the same line are printed
+	// by printTrailing, after the semicolon terminating the node.
+	if n == nil {
+		return // we should not reach here but don't crash
+	}
+	p.printLeading(n)
 	p.printRawNode(n)
+}
 
-	// if ncom != nil && len(ncom.After) > 0 {
-	// 	for i, c := range ncom.After {
-	// 		if i+1 < len(ncom.After) {
-	// 			if c.Text == "" || lineComment(c.Text) {
-	// 				panic("unexpected empty line or //-style non-final 'after' comment")
-	// 			}
-	// 		}
-	// 		p.print(blank, c)
-	// 	}
-	// 	//p.print(newline)
-	// }
+// printLeading prints the comments on lines of their own preceding n.
+func (p *printer) printLeading(n ast.Node) {
+	if ncom := n.Comments(); ncom != nil && p.linebreaks {
+		// TODO(gri) in general we cannot make assumptions about whether
+		// a comment is a /*- or a //-style comment since the syntax
+		// tree may have been manipulated. Need to make sure the correct
+		// whitespace is emitted.
+		for i := range ncom.Alone {
+			if ncom.Alone[i].Text != "" {
+				p.print(&ncom.Alone[i])
+			}
+			p.print(newline)
+		}
+	}
+}
+
+// printTrailing prints the comments following n on the same line.
+func (p *printer) printTrailing(n ast.Node) {
+	if ncom := n.Comments(); ncom != nil && p.linebreaks {
+		for i := range ncom.After {
+			p.print(blank, &ncom.After[i])
+		}
+	}
+}
+
+// printFinal prints the comments following the last declaration
+// or statement of n, on lines of their own. If there is a preceding
+// declaration or statement, after reports whether it was printed.
+func (p *printer) printFinal(n ast.Node, after bool) {
+	ncom := n.Comments()
+	if ncom == nil || !p.linebreaks {
+		return
+	}
+	for i := range ncom.Final {
+		if i > 0 || after {
+			p.print(newline)
+		}
+		if ncom.Final[i].Text != "" {
+			p.print(&ncom.Final[i])
+		}
+	}
+}
+
+// hasFinal reports whether n has comments following its last
+// declaration or statement that are printed.
+func (p *printer) hasFinal(n ast.Node) bool {
+	ncom := n.Comments()
+	return ncom != nil && len(ncom.Final) > 0 && p.linebreaks
 }
 
 func (p *printer) printRawNode(n ast.Node) {
@@ -376,9 +419,12 @@ func (p *printer) printRawNode(n ast.Node) {
 	case *ast.IndexExpr:
 		p.print(n.X, token.Lbrack, n.Index, token.Rbrack)
 
+	case *ast.ListExpr:
+		p.printExprList(n.ElemList)
+
 	case *ast.CallExpr:
 		p.print(n.Func, token.Lparen)
-		p.printExprList(n.ArgList)
+		p.printExprLines(n, n.ArgList, false)
 		p.print(token.Rparen)
 
 	case *ast.Operation:
@@ -393,12 +439,113 @@ func (p *printer) printRawNode(n ast.Node) {
 			// binary expr
 			// TODO(gri) eventually take precedence into account
 			// to control possibly missing parentheses
-			p.print(n.X, blank, n.Op, blank, n.Y)
+			p.print(n.X, blank, n.Op)
+			if p.hasFinal(n) {
+				// the comments following the operator; a //-style
+				// comment ends its line
+				ncom := n.Comments()
+				for i := range ncom.Final {
+					p.print(blank, &ncom.Final[i])
+				}
+				if lineComment(ncom.Final[len(ncom.Final)-1].Text) {
+					p.print(newline, indent, n.Y, outdent)
+					break
+				}
+			}
+			p.print(blank, n.Y)
 		}
 
 	case *ast.SliceType:
 		p.print(token.Lbrack, token.Rbrack, n.Elem)
 
+	case *ast.MapType:
+		p.print(token.Map, token.Lbrack, n.Key, token.Rbrack, n.Value)
+
+	case *ast.SliceLit:
+		p.print(token.Lbrack, token.Rbrack, n.ElemType, token.Lbrace)
+		p.printExprLines(n, n.Elems, n.Lines)
+		p.print(token.Rbrace)
+
+	case *ast.CompositeLit:
+		if n.Type != nil {
+			p.print(n.Type)
+		}
+		p.print(token.Lbrace)
+		p.printExprLines(n, n.ElemList, n.Lines)
+		p.print(token.Rbrace)
+
+	case *ast.KeyValueExpr:
+		p.print(n.Key, token.Colon, blank, n.Value)
+
+	case *ast.StructType:
+		p.print(token.Struct)
+		if (len(n.FieldList) > 0 || p.hasFinal(n)) && p.linebreaks {
+			p.print(blank)
+		}
+		p.print(token.Lbrace)
+		if len(n.FieldList) > 0 || p.hasFinal(n) {
+			if p.linebreaks {
+				p.print(newline, indent)
+				if len(n.FieldList) > 0 {
+					p.printFieldList(n.FieldList, nil, token.Semi)
+				}
+				p.printFinal(n, len(n.FieldList) > 0)
+				p.print(outdent, newline)
+			} else {
+				p.printFieldList(n.FieldList, nil, token.Semi)
+			}
+		}
+		p.print(token.Rbrace)
+
+	case *ast.InterfaceType:
+		p.print(token.Interface)
+		if (len(n.MethodList) > 0 || p.hasFinal(n)) && p.linebreaks {
+			p.print(blank)
+		}
+		p.print(token.Lbrace)
+		if len(n.MethodList) > 0 || p.hasFinal(n) {
+			if p.linebreaks {
+				p.print(newline, indent)
+				p.printMethodList(n.MethodList)
+				p.printFinal(n, len(n.MethodList) > 0)
+				p.print(outdent, newline)
+			} else {
+				p.printMethodList(n.MethodList)
+			}
+		}
+		p.print(token.Rbrace)
+
+	case *ast.FuncType:
+		p.print(token.Func)
+		p.printSignature(n.Param, n.Return)
+
+	case *ast.FuncLit:
+		p.print(n.Type, blank)
+		if p.form == ShortForm {
+			p.print(token.Lbrace)
+			if len(n.Body.StmtList) > 0 {
+				p.print(token.Name, "…")
+			}
+			p.print(token.Rbrace)
+		} else {
+			p.print(n.Body)
+		}
+
+	case *ast.AssertExpr:
+		p.print(n.X, token.Dot, token.Lparen, n.Type, token.Rparen)
+
+	case *ast.TypeSwitchGuard:
+		if n.Lhs != nil {
+			p.print(n.Lhs, blank, token.Define, blank)
+		}
+		p.print(n.X, token.Dot, token.Lparen, token.Type, token.Rparen)
+
+	case *ast.Field:
+		if n.Name != nil {
+			p.print(n.Name, blank)
+		}
+		p.print(n.Type)
+
 	// statements
 	case *ast.DeclStmt:
 		p.printDecl(n.DeclList)
@@ -409,6 +556,18 @@ func (p *printer) printRawNode(n ast.Node) {
 	case *ast.ExprStmt:
 		p.print(n.X)
 
+	case *ast.IncDecStmt:
+		p.print(n.X, n.Op, n.Op) // ++ or --
+
+	case *ast.DefineStmt:
+		p.print(n.Lhs, blank, token.Define, blank, n.Rhs)
+
+	case *ast.RangeClause:
+		if n.Lhs != nil {
+			p.print(n.Lhs, blank, token.Define, blank)
+		}
+		p.print(token.Range, blank, n.X)
+
 	case *ast.AssignStmt:
 		p.print(n.Lhs)
 		if n.Rhs == nil {
@@ -426,11 +585,31 @@ func (p *printer) printRawNode(n ast.Node) {
 			p.print(blank, n.Result)
 		}
 
+	case *ast.BreakStmt:
+		p.print(token.Break)
+		if n.Label != nil {
+			p.print(blank, n.Label)
+		}
+
+	case *ast.ContinueStmt:
+		p.print(token.Continue)
+		if n.Label != nil {
+			p.print(blank, n.Label)
+		}
+
+	case *ast.LabeledStmt:
+		// labels are outdented like in gofmt'ed Go code
+		p.print(outdent, n.Label, token.Colon, indent)
+		if _, ok := n.Stmt.(*ast.EmptyStmt); !ok {
+			p.print(newline, n.Stmt)
+		}
+
 	case *ast.BlockStmt:
 		p.print(token.Lbrace)
-		if len(n.StmtList) > 0 {
+		if len(n.StmtList) > 0 || p.hasFinal(n) {
 			p.print(newline, indent)
 			p.printStmtList(n.StmtList, true)
+			p.printFinal(n, len(n.StmtList) > 0)
 			p.print(outdent, newline)
 		}
 		p.print(token.Rbrace)
@@ -444,7 +623,9 @@ func (p *printer) printRawNode(n ast.Node) {
 
 	case *ast.ForStmt:
 		p.print(token.For, blank)
-		if n.Init == nil && n.Post == nil {
+		if r, ok := n.Init.(*ast.RangeClause); ok {
+			p.print(r, blank)
+		} else if n.Init == nil && n.Post == nil {
 			if n.Cond != nil {
 				p.print(n.Cond, blank)
 			}
@@ -463,17 +644,71 @@ func (p *printer) printRawNode(n ast.Node) {
 		}
 		p.print(n.Body)
 
+	case *ast.WhileStmt:
+		p.print(token.While, blank, n.Cond, blank, n.Body)
+
+	case *ast.SwitchStmt:
+		p.print(token.Switch, blank)
+		if n.Tag != nil {
+			p.print(n.Tag, blank)
+		}
+		p.print(token.Lbrace)
+		if len(n.Body) > 0 || p.hasFinal(n) {
+			p.print(newline)
+			for i, c := range n.Body {
+				p.print(c)
+				if i+1 < len(n.Body) {
+					p.print(newline)
+				}
+			}
+			if p.hasFinal(n) {
+				p.print(indent)
+				p.printFinal(n, len(n.Body) > 0)
+				p.print(outdent)
+			}
+			p.print(newline)
+		}
+		p.print(token.Rbrace)
+
+	case *ast.CaseClause:
+		if n.Cases != nil {
+			p.print(token.Case, blank, n.Cases)
+		} else {
+			p.print(token.Default)
+		}
+		p.print(token.Colon)
+		if len(n.Body) > 0 {
+			p.print(newline, indent)
+			p.printStmtList(n.Body, false)
+			p.print(outdent)
+		}
+
 	case *ast.ImportDecl:
 		if n.Group == nil {
 			p.print(token.Import, blank)
 		}
 		p.print(n.Path)
 
+	case *ast.ConstDecl:
+		if n.Group == nil {
+			p.print(token.Const, blank)
+		}
+		p.printNameList([]*ast.Name{n.NameList})
+		if n.Type != nil {
+			p.print(blank, n.Type)
+		}
+		if n.Values != nil {
+			p.print(blank, token.Assign, blank, n.Values)
+		}
+
 	case *ast.TypeDecl:
 		if n.Group == nil {
 			p.print(token.Type, blank)
 		}
 		p.print(n.Name)
+		if n.TParamList != nil {
+			p.printParameterList(n.TParamList, token.Type)
+		}
 		p.print(blank)
 		if n.Alias {
 			p.print(token.Assign, blank)
@@ -495,39 +730,63 @@ func (p *printer) printRawNode(n ast.Node) {
 	case *ast.FuncDecl:
 		p.print(token.Func, blank)
 
-		// receiver not implemented
-		//if r := n.Recv; r != nil {
-		//	p.print(token.Lparen)
-		//	if r.Name != nil {
-		//		p.print(r.Name, blank)
-		//	}
-		//	p.printNode(r.Type)
-		//	p.print(token.Rparen, blank)
-		//}
+		if r := n.Recv; r != nil {
+			p.print(token.Lparen)
+			if r.Name != nil {
+				p.print(r.Name, blank)
+			}
+			p.printNode(r.Type)
+			p.print(token.Rparen, blank)
+		}
 		p.print(n.Name)
-		p.printSignature(n)
+		if n.TParamList != nil {
+			p.printParameterList(n.TParamList, token.Func)
+		}
+		p.printSignature(n.Param, n.Return)
+		if n.Body != nil {
+			p.print(blank, n.Body)
+		}
+
+	case *ast.OperDecl:
+		p.print(token.Oper, blank)
+		p.printParameterList([]*ast.Field{n.TypeL}, 0)
+		p.print(blank, token.Name, n.Oper.OperName(), blank)
+		p.printParameterList([]*ast.Field{n.TypeR}, 0)
+		if n.Return != nil {
+			p.print(blank, n.Return)
+		}
 		if n.Body != nil {
 			p.print(blank, n.Body)
 		}
 
 	case *printGroup:
 		p.print(n.Tok, blank, token.Lparen)
-		if len(n.Decls) > 0 {
+		if len(n.Decls) > 0 || p.hasFinal(n) {
 			p.print(newline, indent)
-			for _, d := range n.Decls {
+			for i, d := range n.Decls {
+				if i > 0 {
+					p.print(newline)
+				}
 				p.printNode(d)
-				p.print(token.Semi, newline)
+				p.print(token.Semi)
+				p.printTrailing(d)
 			}
-			p.print(outdent)
+			p.printFinal(n, len(n.Decls) > 0)
+			p.print(outdent, newline)
 		}
 		p.print(token.Rparen)
 
 	// files
 	case *ast.File:
 		p.print(token.Space, blank, n.SpaceName)
-		if len(n.DeclList) > 0 {
+		if len(n.DeclList) > 0 || p.hasFinal(n) {
 			p.print(token.Semi, newline, newline)
 			p.printDeclList(n.DeclList)
+			p.printFinal(n, len(n.DeclList) > 0)
+		}
+		if p.linebreaks {
+			// source files end in a newline
+			p.print(newline)
 		}
 
 	default:
@@ -550,29 +809,73 @@ func (p *printer) printExprList(list []ast.Expr) {
 			p.print(token.Comma, blank)
 		}
 		p.printNode(x)
+		p.printTrailing(x)
 	}
 }
 
-func (p *printer) printExprLines(list []ast.Expr) {
-	if len(list) > 0 {
-		p.print(newline, indent)
-		for _, x := range list {
-			p.print(x, token.Comma, newline)
+// printExprLines prints the elements or arguments list of n. If lines
+// is set or comments require it, each element is printed on a line of
+// its own and followed by a comma.
+func (p *printer) printExprLines(n ast.Node, list []ast.Expr, lines bool) {
+	if !p.hasFinal(n) && (len(list) == 0 || !lines && !p.breakLines(list)) {
+		p.printExprList(list)
+		return
+	}
+	p.print(newline, indent)
+	for i, x := range list {
+		if i > 0 {
+			p.print(newline)
+		}
+		p.print(x, token.Comma)
+		p.printTrailing(x)
+	}
+	p.printFinal(n, len(list) > 0)
+	p.print(outdent, newline)
+}
+
+// breakLines reports whether a node in list has comments that require
+// it to be printed on a line of its own.
+func (p *printer) breakLines(list []ast.Expr) bool {
+	for _, x := range list {
+		if p.breaksLine(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// breaksLine reports whether n has comments on lines of their own or
+// a //-style comment following it, which must end its line.
+func (p *printer) breaksLine(n ast.Node) bool {
+	ncom := n.Comments()
+	if ncom == nil || !p.linebreaks {
+		return false
+	}
+	if len(ncom.Alone) > 0 {
+		return true
+	}
+	for _, c := range ncom.After {
+		if lineComment(c.Text) {
+			return true
 		}
-		p.print(outdent)
 	}
+	return false
 }
 
 func groupFor(d ast.Decl) (token.Token, *ast.Group) {
 	switch d := d.(type) {
 	case *ast.ImportDecl:
 		return token.Import, d.Group
+	case *ast.ConstDecl:
+		return token.Const, d.Group
 	case *ast.TypeDecl:
 		return token.Type, d.Group
 	case *ast.VarDecl:
 		return token.Var, d.Group
 	case *ast.FuncDecl:
 		return token.Func, nil
+	case *ast.OperDecl:
+		return token.Oper, nil
 	default:
 		panic("unreachable")
 	}
@@ -585,14 +888,20 @@ type printGroup struct {
 }
 
 func (p *printer) printDecl(list []ast.Decl) {
+	p.printNode(declNode(list))
+}
+
+// declNode returns the node to print for the declarations in list:
+// the declaration itself if it is not part of a group, or a printGroup
+// holding the group's comments.
+func declNode(list []ast.Decl) ast.Node {
 	tok, group := groupFor(list[0])
 
 	if group == nil {
 		if len(list) != 1 {
 			panic("unreachable")
 		}
-		p.printNode(list[0])
-		return
+		return list[0]
 	}
 
 	// if _, ok := list[0].(*EmptyDecl); ok {
@@ -605,12 +914,11 @@ func (p *printer) printDecl(list []ast.Decl) {
 	// }
 
 	// printGroup is here for consistent comment handling
-	// (this is not yet used)
-	var pg printGroup
-	// *pg.Comments() = *group.Comments()
+	pg := new(printGroup)
+	pg.SetComments(group.Comments())
 	pg.Tok = tok
 	pg.Decls = list
-	p.printNode(&pg)
+	return pg
 }
 
 func (p *printer) printDeclList(list []ast.Decl) {
@@ -620,11 +928,14 @@ func (p *printer) printDeclList(list []ast.Decl) {
 	for i, x := range list {
 		if s, g := groupFor(x); g == nil || g != group {
 			if i0 < i {
-				p.printDecl(list[i0:i])
-				p.print(token.Semi, newline)
+				d := declNode(list[i0:i])
+				p.printNode(d)
+				p.print(token.Semi)
+				p.printTrailing(d)
+				p.print(newline)
 				// print empty line between different declaration groups,
 				// different kinds of declarations, or between functions
-				if g != group || s != tok || s == token.Func {
+				if g != group || s != tok || s == token.Func || s == token.Oper {
 					p.print(newline)
 				}
 				i0 = i
@@ -632,26 +943,65 @@ func (p *printer) printDeclList(list []ast.Decl) {
 			tok, group = s, g
 		}
 	}
-	p.printDecl(list[i0:])
+	if i0 < len(list) {
+		d := declNode(list[i0:])
+		p.printNode(d)
+		p.printTrailing(d)
+	}
 }
 
-func (p *printer) printSignature(fn *ast.FuncDecl) {
-	p.printParameterList(fn.Param, 0)
-	p.printNode(fn.Return)
+func (p *printer) printSignature(params []*ast.Field, result ast.Expr) {
+	p.printParameterList(params, 0)
+	if list, ok := result.(*ast.ListExpr); ok {
+		p.print(blank, token.Lparen)
+		p.printExprList(list.ElemList)
+		p.print(token.Rparen)
+	} else if result != nil {
+		p.print(blank, result)
+	}
+}
+
+func (p *printer) printMethodList(methods []*ast.Field) {
+	for i, m := range methods {
+		if i > 0 {
+			p.print(newline)
+		}
+		p.printLeading(m)
+		p.printNode(m.Name)
+		t := m.Type.(*ast.FuncType)
+		p.printSignature(t.Param, t.Return)
+		p.print(token.Semi)
+		p.printTrailing(m)
+	}
 }
 
 // If tok != 0 print a type parameter list: tok == token.Type means
-// a type parameter list for a type, tok == _Func means a type
+// a type parameter list for a type, tok == token.Func means a type
 // parameter list for a func.
 func (p *printer) printParameterList(list []*ast.Field, tok token.Token) {
 	open, close := token.Lparen, token.Rparen
-
-	//if tok != 0 {
-	//	open, close = token.Lbrack, token.Rbrack
-	//}
-	// no generic support
+	if tok != 0 {
+		open, close = token.Lbrack, token.Rbrack
+	}
 
 	p.print(open)
+	if tok == 0 && p.breakFieldLines(list) {
+		// parameters with comments are printed on lines of their own
+		p.print(newline, indent)
+		for i, f := range list {
+			if i > 0 {
+				p.print(newline)
+			}
+			p.printLeading(f)
+			if f.Name != nil {
+				p.print(f.Name, blank)
+			}
+			p.print(Unparen(f.Type), token.Comma)
+			p.printTrailing(f)
+		}
+		p.print(outdent, newline, close)
+		return
+	}
 	for i, f := range list {
 		if i > 0 {
 			p.print(token.Comma, blank)
@@ -667,6 +1017,7 @@ func (p *printer) printParameterList(list []*ast.Field, tok token.Token) {
 			p.print(blank)
 		}
 		p.printNode(Unparen(f.Type)) // no need for (extra) parentheses around parameter types
+		p.printTrailing(f)
 	}
 	// A type parameter list [P T] where the name P and the type expression T syntactically
 	// combine to another valid (value) expression requires a trailing comma, as in [P *T,]
@@ -678,6 +1029,16 @@ func (p *printer) printParameterList(list []*ast.Field, tok token.Token) {
 	p.print(close)
 }
 
+// breakFieldLines is like breakLines for a list of fields.
+func (p *printer) breakFieldLines(list []*ast.Field) bool {
+	for _, f := range list {
+		if p.breaksLine(f) {
+			return true
+		}
+	}
+	return false
+}
+
 // combinesWithName reports whether a name followed by the expression x
 // syntactically combines to another valid (value) expression. For instance
 // using *T for x, "name *T" syntactically appears as the expression x*T.
@@ -703,6 +1064,7 @@ func combinesWithName(x ast.Expr) bool {
 func (p *printer) printStmtList(list []ast.Stmt, braces bool) {
 	for i, x := range list {
 		p.print(x, token.Semi)
+		p.printTrailing(x)
 		if i+1 < len(list) {
 			p.print(newline)
 		} else if braces {
@@ -736,18 +1098,27 @@ func (p *printer) printFields(fields []*ast.Field, tags []*ast.BasicLit, i, j in
 	}
 }
 
+// printFieldList prints the fields separated by sep. The fields of a
+// list sharing the same type are printed on one line, with the comments
+// of the first field of the list.
 func (p *printer) printFieldList(fields []*ast.Field, tags []*ast.BasicLit, sep token.Token) {
 	i0 := 0
 	var typ ast.Expr
 	for i, f := range fields {
 		if f.Name == nil || f.Type != typ {
 			if i0 < i {
+				p.printLeading(fields[i0])
 				p.printFields(fields, tags, i0, i)
-				p.print(sep, newline)
+				p.print(sep)
+				p.printTrailing(fields[i0])
+				p.print(newline)
 				i0 = i
 			}
 			typ = f.Type
 		}
 	}
+	p.printLeading(fields[i0])
 	p.printFields(fields, tags, i0, len(fields))
+	p.print(sep)
+	p.printTrailing(fields[i0])
 }
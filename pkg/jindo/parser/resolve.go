@@ -4,4 +4,518 @@
 // https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
 // located in the root directory of this source tree.
 
+// This file implements name resolution for syntax trees.
+
 package parser
+
+import (
+	"fmt"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+)
+
+// A Resolution records the declarations that the names of a
+// file resolve to. Declarations are FuncDecl, ConstDecl, VarDecl,
+// TypeDecl, parameter and type parameter Field, DefineStmt,
+// RangeClause and TypeSwitchGuard nodes.
+// The type parameters of a generic receiver type are declared by
+// the receiver Field. Names denoting predeclared objects such as
+// int or len are not recorded, and neither are labels.
+type Resolution struct {
+	// Defs maps declaring names to their declaration.
+	Defs map[*ast.Name]ast.Node
+
+	// Uses maps all other resolved names to the declaration
+	// of the entity they denote.
+	Uses map[*ast.Name]ast.Node
+}
+
+// DeclOf returns the declaration that the name n declares or
+// refers to, or nil if n was not resolved.
+func (r *Resolution) DeclOf(n *ast.Name) ast.Node {
+	if d := r.Defs[n]; d != nil {
+		return d
+	}
+	return r.Uses[n]
+}
+
+// predeclared lists the names declared in the universe scope.
+var predeclared = map[string]bool{
+	// types
+	"bool":   true,
+	"int":    true,
+	"float":  true,
+	"rune":   true,
+	"string": true,
+
+	// constraints
+	"any":        true,
+	"comparable": true,
+	"ordered":    true,
+
+	// constants
+	"true":  true,
+	"false": true,
+	"iota":  true,
+
+	// zero value
+	"nil": true,
+
+	// functions
+	"append":  true,
+	"delete":  true,
+	"len":     true,
+	"print":   true,
+	"println": true,
+}
+
+// Resolve resolves the names in f to their declarations.
+// Top-level declarations are visible in the entire file; local
+// declarations are visible from the end of their declaration to the
+// end of the innermost enclosing block. BlockStmt, IfStmt, ForStmt
+// and WhileStmt nodes and the case clauses of a SwitchStmt open a new
+// block, and so does each function body, which also contains the
+// function's parameters. The body of a function literal is nested in
+// the block of its enclosing function.
+//
+// Errors for undeclared and redeclared names are reported in the same
+// way as by Parse: if errh != nil, it is called with each error and
+// resolution continues; otherwise Resolve stops at the first error.
+// Resolve returns the first error found and the (possibly partial)
+// resolution.
+func Resolve(f *ast.File, errh ErrorHandler) (_ *Resolution, first error) {
+	r := &resolver{
+		errh: errh,
+		res: &Resolution{
+			Defs: make(map[*ast.Name]ast.Node),
+			Uses: make(map[*ast.Name]ast.Node),
+		},
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			if err, ok := p.(Error); ok {
+				first = err
+				return
+			}
+			panic(p)
+		}
+	}()
+	r.file(f)
+	return r.res, r.first
+}
+
+type resolver struct {
+	errh  ErrorHandler
+	first error
+	res   *Resolution
+	scope *scope
+}
+
+// A scope maps names to the declaring names and declarations
+// of a block.
+type scope struct {
+	outer *scope
+	elems map[string]binding
+}
+
+type binding struct {
+	name *ast.Name
+	decl ast.Node
+}
+
+func (r *resolver) errorf(pos position.Pos, format string, args ...interface{}) {
+	err := Error{pos, fmt.Sprintf(format, args...)}
+	if r.first == nil {
+		r.first = err
+	}
+	if r.errh == nil {
+		panic(err)
+	}
+	r.errh(err)
+}
+
+func (r *resolver) openScope() {
+	r.scope = &scope{outer: r.scope, elems: make(map[string]binding)}
+}
+
+func (r *resolver) closeScope() {
+	r.scope = r.scope.outer
+}
+
+// declare declares name in the current scope as denoting decl.
+func (r *resolver) declare(name *ast.Name, decl ast.Node) {
+	if name == nil || name.Value == "_" {
+		return
+	}
+	if alt, ok := r.scope.elems[name.Value]; ok {
+		r.errorf(name.GetPos(), "%s redeclared in this block\n\tother declaration of %s at %s", name.Value, name.Value, alt.name.GetPos())
+		return
+	}
+	r.scope.elems[name.Value] = binding{name, decl}
+	r.res.Defs[name] = decl
+}
+
+// use resolves the name n in the current scope.
+func (r *resolver) use(n *ast.Name) {
+	if n.Value == "_" {
+		return
+	}
+	for s := r.scope; s != nil; s = s.outer {
+		if b, ok := s.elems[n.Value]; ok {
+			r.res.Uses[n] = b.decl
+			return
+		}
+	}
+	if !predeclared[n.Value] {
+		r.errorf(n.GetPos(), "undefined: %s", n.Value)
+	}
+}
+
+// define declares the names on the left side of the short variable
+// declaration s. If there are several, the names already declared in
+// the current scope are reused, as long as at least one name is new.
+func (r *resolver) define(s *ast.DefineStmt) {
+	lhs := UnpackList(s.Lhs)
+	isNew := make([]bool, len(lhs))
+	anyNew := false
+	for i, x := range lhs {
+		if name, ok := x.(*ast.Name); ok && name.Value != "_" {
+			_, declared := r.scope.elems[name.Value]
+			isNew[i] = !declared
+			anyNew = anyNew || isNew[i]
+		}
+	}
+	for i, x := range lhs {
+		name, ok := x.(*ast.Name)
+		switch {
+		case !ok:
+			r.expr(x)
+		case isNew[i] || !anyNew:
+			r.declare(name, s)
+		default:
+			r.use(name)
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+func (r *resolver) file(f *ast.File) {
+	r.openScope()
+	defer r.closeScope()
+
+	// top-level declarations may be used before they are declared
+	for _, d := range f.DeclList {
+		switch d := d.(type) {
+		case *ast.ConstDecl:
+			r.declare(d.NameList, d)
+		case *ast.TypeDecl:
+			r.declare(d.Name, d)
+		case *ast.VarDecl:
+			r.declare(d.NameList, d)
+		case *ast.FuncDecl:
+			if d.Recv != nil {
+				// methods are selected through their receiver
+				// and not declared in the file block
+				r.res.Defs[d.Name] = d
+				continue
+			}
+			r.declare(d.Name, d)
+		}
+	}
+
+	for _, d := range f.DeclList {
+		switch d := d.(type) {
+		case *ast.TypeDecl:
+			r.openScope()
+			r.typeParams(d.TParamList)
+			r.expr(d.Type)
+			r.closeScope()
+		case *ast.ConstDecl, *ast.VarDecl:
+			r.valueDecl(d)
+		case *ast.FuncDecl:
+			r.openScope()
+			r.typeParams(d.TParamList)
+			r.funcBody(d.Recv, d.Param, d.Return, d.Body)
+			r.closeScope()
+		case *ast.OperDecl:
+			if d.TypeL != nil && d.TypeR != nil {
+				r.funcBody(nil, []*ast.Field{d.TypeL, d.TypeR}, d.Return, d.Body)
+			}
+		}
+	}
+}
+
+// valueDecl resolves the type and values of the constant or variable
+// declaration d and returns the declared name, or nil if d declares
+// neither.
+func (r *resolver) valueDecl(d ast.Decl) *ast.Name {
+	switch d := d.(type) {
+	case *ast.ConstDecl:
+		r.exprOrNil(d.Type)
+		r.exprOrNil(d.Values)
+		return d.NameList
+	case *ast.VarDecl:
+		r.exprOrNil(d.Type)
+		r.exprOrNil(d.Values)
+		return d.NameList
+	}
+	return nil
+}
+
+// typeParams declares the type parameters in list and resolves their
+// constraints, which may refer to any of the type parameters.
+func (r *resolver) typeParams(list []*ast.Field) {
+	for _, f := range list {
+		r.declare(f.Name, f)
+	}
+	for _, f := range list {
+		r.exprOrNil(f.Type)
+	}
+}
+
+// recvType resolves the type of the receiver recv. The type arguments
+// of a generic receiver type, as in (s Stack[T]) or (s *Stack[T]),
+// declare its type parameters for the method.
+func (r *resolver) recvType(recv *ast.Field) {
+	typ := recv.Type
+	if p, ok := typ.(*ast.Operation); ok && p.Op == token.Mul && p.Y == nil {
+		typ = p.X
+	}
+	x, ok := typ.(*ast.IndexExpr)
+	if !ok {
+		r.exprOrNil(recv.Type)
+		return
+	}
+	r.expr(x.X)
+	for _, e := range UnpackList(x.Index) {
+		if n, ok := e.(*ast.Name); ok {
+			r.declare(n, recv)
+		} else {
+			r.errorf(e.GetPos(), "receiver type parameter %s must be an identifier", String(e))
+		}
+	}
+}
+
+// funcBody resolves the receiver, parameters, result and body of a
+// function. The receiver, parameters and the top-level statements of
+// the body share a single block.
+func (r *resolver) funcBody(recv *ast.Field, params []*ast.Field, result ast.Expr, body *ast.BlockStmt) {
+	if recv != nil {
+		r.recvType(recv)
+	}
+	for _, p := range params {
+		r.exprOrNil(p.Type)
+	}
+	r.exprOrNil(result)
+	if body == nil {
+		return
+	}
+
+	r.openScope()
+	defer r.closeScope()
+	if recv != nil {
+		r.declare(recv.Name, recv)
+	}
+	for _, p := range params {
+		r.declare(p.Name, p)
+	}
+	r.stmtList(body.StmtList)
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+func (r *resolver) stmtList(list []ast.Stmt) {
+	for _, s := range list {
+		r.stmt(s)
+	}
+}
+
+func (r *resolver) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case nil, *ast.EmptyStmt, *ast.BreakStmt, *ast.ContinueStmt:
+		// nothing to do
+
+	case *ast.ExprStmt:
+		r.expr(s.X)
+
+	case *ast.IncDecStmt:
+		r.expr(s.X)
+
+	case *ast.ReturnStmt:
+		r.exprOrNil(s.Result)
+
+	case *ast.DeclStmt:
+		for _, d := range s.DeclList {
+			if name := r.valueDecl(d); name != nil {
+				// the scope of a local constant or variable
+				// starts after its declaration
+				r.declare(name, d)
+			}
+		}
+
+	case *ast.DefineStmt:
+		r.expr(s.Rhs)
+		r.define(s)
+
+	case *ast.AssignStmt:
+		r.expr(s.Lhs)
+		r.expr(s.Rhs)
+
+	case *ast.RangeClause:
+		r.expr(s.X)
+		for _, x := range UnpackList(s.Lhs) {
+			if name, ok := x.(*ast.Name); ok {
+				r.declare(name, s)
+			} else {
+				r.expr(x)
+			}
+		}
+
+	case *ast.LabeledStmt:
+		// labels are checked by the type checker
+		r.stmt(s.Stmt)
+
+	case *ast.BlockStmt:
+		r.openScope()
+		r.stmtList(s.StmtList)
+		r.closeScope()
+
+	case *ast.IfStmt:
+		r.openScope()
+		r.expr(s.Cond)
+		r.stmt(s.Block)
+		if s.Else != nil {
+			r.stmt(s.Else)
+		}
+		r.closeScope()
+
+	case *ast.ForStmt:
+		r.openScope()
+		r.stmt(s.Init)
+		r.exprOrNil(s.Cond)
+		r.stmt(s.Post)
+		r.stmt(s.Body)
+		r.closeScope()
+
+	case *ast.WhileStmt:
+		r.openScope()
+		r.expr(s.Cond)
+		r.stmt(s.Body)
+		r.closeScope()
+
+	case *ast.SwitchStmt:
+		g, _ := s.Tag.(*ast.TypeSwitchGuard)
+		if g != nil {
+			r.expr(g.X)
+		} else {
+			r.exprOrNil(s.Tag)
+		}
+		for _, c := range s.Body {
+			r.exprOrNil(c.Cases)
+			r.openScope()
+			if g != nil {
+				// the variable of a type switch is declared in each clause
+				r.declare(g.Lhs, g)
+			}
+			r.stmtList(c.Body)
+			r.closeScope()
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+func (r *resolver) exprOrNil(x ast.Expr) {
+	if x != nil {
+		r.expr(x)
+	}
+}
+
+func (r *resolver) expr(x ast.Expr) {
+	switch x := x.(type) {
+	case *ast.Name:
+		r.use(x)
+
+	case *ast.SliceLit:
+		r.exprOrNil(x.ElemType)
+		for _, e := range x.Elems {
+			r.expr(e)
+		}
+
+	case *ast.CompositeLit:
+		r.exprOrNil(x.Type)
+		for _, e := range x.ElemList {
+			r.expr(e)
+		}
+
+	case *ast.KeyValueExpr:
+		// a key naming a struct field, or a name used as a map key,
+		// is resolved by the type checker
+		if _, ok := x.Key.(*ast.Name); !ok {
+			r.expr(x.Key)
+		}
+		r.expr(x.Value)
+
+	case *ast.Operation:
+		r.expr(x.X)
+		r.exprOrNil(x.Y)
+
+	case *ast.ParenExpr:
+		r.expr(x.X)
+
+	case *ast.SliceType:
+		r.expr(x.Elem)
+
+	case *ast.MapType:
+		r.expr(x.Key)
+		r.expr(x.Value)
+
+	case *ast.StructType:
+		for _, f := range x.FieldList {
+			r.expr(f.Type)
+		}
+
+	case *ast.InterfaceType:
+		// method and parameter names are not resolved
+		for _, f := range x.MethodList {
+			r.expr(f.Type)
+		}
+
+	case *ast.FuncType:
+		for _, f := range x.Param {
+			r.expr(f.Type)
+		}
+		r.exprOrNil(x.Return)
+
+	case *ast.FuncLit:
+		r.funcBody(nil, x.Type.Param, x.Type.Return, x.Body)
+
+	case *ast.SelectorExpr:
+		// the selector is resolved by the type checker
+		r.expr(x.X)
+
+	case *ast.AssertExpr:
+		r.expr(x.X)
+		r.expr(x.Type)
+
+	case *ast.IndexExpr:
+		r.expr(x.X)
+		r.expr(x.Index)
+
+	case *ast.ListExpr:
+		for _, e := range x.ElemList {
+			r.expr(e)
+		}
+
+	case *ast.CallExpr:
+		r.expr(x.Func)
+		for _, a := range x.ArgList {
+			r.expr(a)
+		}
+	}
+}
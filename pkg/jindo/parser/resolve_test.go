@@ -0,0 +1,126 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package parser
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+	"strings"
+	"testing"
+)
+
+func TestResolveFile(t *testing.T) {
+	f, err := ParseFile(src_, func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		return // error already reported
+	}
+	Resolve(f, func(err error) { t.Error(err) })
+}
+
+func TestResolve(t *testing.T) {
+	const src = `space main
+type T int
+func f(a T) T {
+	b := a
+	if true {
+		b := b + a
+		return b
+	}
+	for i := 0; i < 3; i += 1 {
+		b += T(i)
+	}
+	return g(b)
+}
+func g(x T) T { return x }
+func h(m map[string]int) { delete(m, "a") }`
+
+	f, err := Parse(position.NewFileBase("test.paw"), strings.NewReader(src), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Resolve(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// map each name, by position, to the line:col of its declaring name
+	got := make(map[string]string)
+	for n, d := range res.Uses {
+		got[n.GetPos().String()] = declName(d).GetPos().String()
+	}
+
+	want := map[string]string{
+		"test.paw:3:10":  "test.paw:2:6",  // T in f's parameter
+		"test.paw:4:7":   "test.paw:3:8",  // a
+		"test.paw:6:8":   "test.paw:4:2",  // b in b + a refers to outer b
+		"test.paw:7:10":  "test.paw:6:3",  // inner b
+		"test.paw:10:3":  "test.paw:4:2",  // b +=
+		"test.paw:10:10": "test.paw:9:6",  // i
+		"test.paw:12:9":  "test.paw:14:6", // g, declared later
+	}
+	for pos, decl := range want {
+		if got[pos] != decl {
+			t.Errorf("%s: resolved to %q, want %s", pos, got[pos], decl)
+		}
+	}
+	for _, n := range []string{"int", "true", "delete"} {
+		for u := range res.Uses {
+			if u.Value == n {
+				t.Errorf("predeclared %s resolved to %v", n, res.Uses[u])
+			}
+		}
+	}
+}
+
+func declName(d ast.Node) *ast.Name {
+	switch d := d.(type) {
+	case *ast.TypeDecl:
+		return d.Name
+	case *ast.VarDecl:
+		return d.NameList
+	case *ast.FuncDecl:
+		return d.Name
+	case *ast.Field:
+		return d.Name
+	case *ast.DefineStmt:
+		return d.Lhs.(*ast.Name)
+	}
+	return nil
+}
+
+func TestResolveErrors(t *testing.T) {
+	const src = `space main
+var x = y
+func f(a int) {
+	a := 1
+	{ c := 1 }
+	println(c)
+}
+func f() {}`
+
+	f, err := Parse(position.NewFileBase("test.paw"), strings.NewReader(src), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	Resolve(f, func(err error) { errs = append(errs, err.Error()) })
+
+	want := []string{
+		"test.paw:8:6: f redeclared in this block\n\tother declaration of f at test.paw:3:6",
+		"test.paw:2:9: undefined: y",
+		"test.paw:4:2: a redeclared in this block\n\tother declaration of a at test.paw:3:8",
+		"test.paw:6:10: undefined: c",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors %q, want %d", len(errs), errs, len(want))
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("got error %q, want %q", errs[i], want[i])
+		}
+	}
+}
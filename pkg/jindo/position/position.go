@@ -48,10 +48,11 @@ func (b PosBase) Filename() string {
 
 // func (pos pos) IsKnown() bool  { return pos.line > 0 }
 
-func (p Pos) Pos() Pos      { return p }
-func (p Pos) Line() uint    { return p.line }
-func (p Pos) Col() uint     { return p.col }
-func (p Pos) IsKnown() bool { return p.line > 0 }
+func (p Pos) Pos() Pos       { return p }
+func (p Pos) Base() *PosBase { return p.base }
+func (p Pos) Line() uint     { return p.line }
+func (p Pos) Col() uint      { return p.col }
+func (p Pos) IsKnown() bool  { return p.line > 0 }
 
 func sat32(x uint) uint32 {
 	if x > PosMax {
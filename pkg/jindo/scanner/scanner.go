@@ -18,8 +18,8 @@ import (
 // by calling the error handler. If no flag is set, comments
 // are ignored.
 const (
-	comments   uint = 1 << iota // call handler for all comments
-	directives                  // call handler for directives only
+	Comments   uint = 1 << iota // call handler for all comments
+	Directives                  // call handler for directives only
 )
 
 type Scanner struct {
@@ -47,9 +47,9 @@ func (s *Scanner) Prec() int           { return s.prec }
 func (s *Scanner) Line() uint          { return s.line }
 func (s *Scanner) Col() uint           { return s.col }
 
-func (s *Scanner) Init(src io.Reader, errh func(line, col uint, msg string)) {
+func (s *Scanner) Init(src io.Reader, errh func(line, col uint, msg string), mode uint) {
 	s.source.init(src, errh)
-	//s.mode = mode
+	s.mode = mode
 	s.nlsemi = false
 }
 
@@ -701,14 +701,14 @@ func (s *Scanner) skipLine() {
 func (s *Scanner) lineComment() {
 	// opening has already been consumed
 
-	if s.mode&comments != 0 {
+	if s.mode&Comments != 0 {
 		s.skipLine()
 		s.comment(string(s.Segment()))
 		return
 	}
 
 	// are we saving directives? or is this definitely not a directive?
-	if s.mode&directives == 0 || (s.ch != 'g' && s.ch != 'l') {
+	if s.mode&Directives == 0 || (s.ch != 'g' && s.ch != 'l') {
 		s.stop()
 		s.skipLine()
 		return
@@ -751,14 +751,14 @@ func (s *Scanner) skipComment() bool {
 func (s *Scanner) fullComment() {
 	/* opening has already been consumed */
 
-	if s.mode&comments != 0 {
+	if s.mode&Comments != 0 {
 		if s.skipComment() {
 			s.comment(string(s.Segment()))
 		}
 		return
 	}
 
-	if s.mode&directives == 0 || s.ch != 'l' {
+	if s.mode&Directives == 0 || s.ch != 'l' {
 		s.stop()
 		s.skipComment()
 		return
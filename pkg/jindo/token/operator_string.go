@@ -20,19 +20,23 @@ var opString = [...]string{
 	Geq:    ">=",
 	Add:    "+",
 	Sub:    "-",
+	Or:     "|",
+	Xor:    "^",
 	Mul:    "*",
-	//Or:     "|",
-	//Xor:    "^",
-	//Mul:    "*",
-	//Div:    "/",
-	Rem: "%",
-	//And:    "&",
-	//AndNot: "&^",
-	//Shl:    "<<",
-	//Shr:    ">>",
+	Div:    "/",
+	Rem:    "%",
+	And:    "&",
+	AndNot: "&^",
+	Shl:    "<<",
+	Shr:    ">>",
 }
 
-func (op Operator) String() string { return opString[op] }
+func (op Operator) String() string {
+	if op.IsReversed() {
+		return op.OperName()
+	}
+	return opString[op]
+}
 
 // operator overload
 var opOverMap = map[string]Operator{
@@ -55,6 +59,8 @@ var opOverMap = map[string]Operator{
 	"rrem": Rem + Reverse,
 }
 
+// operOverload is the set of operators that may be overloaded,
+// as a bit set indexed by the non-reversed operator.
 const operOverload = 1<<Not |
 	1<<Add |
 	1<<Sub |
@@ -62,24 +68,51 @@ const operOverload = 1<<Not |
 	1<<Div |
 	1<<Eql |
 	1<<Gtr |
-	1<<Rem |
-	1<<Not + Reverse |
-	1<<Add + Reverse |
-	1<<Sub + Reverse |
-	1<<Mul + Reverse |
-	1<<Div + Reverse |
-	1<<Eql + Reverse |
-	1<<Gtr + Reverse |
-	Rem + Reverse
+	1<<Rem
 
+// OperOrNil returns the operator denoted by the overload name,
+// or NoneOp if name is not an operator overload name.
 func OperOrNil(name string) Operator {
+	if op, ok := opOverMap[name]; ok {
+		return op
+	}
+	return NoneOp
+}
+
+// OperName returns the overload name of op, such as "add" or "radd",
+// or the empty string if op cannot be overloaded.
+func (op Operator) OperName() string {
 	for s, t := range opOverMap {
-		if name == s {
-			return t
+		if op == t {
+			return s
 		}
 	}
-	return NoneOp
+	return ""
+}
+
+func (op Operator) IsOperOverload() bool {
+	if op.IsReversed() {
+		op -= Reverse
+	}
+	return op < Reverse && operOverload&(1<<op) != 0
+}
+
+func (op Operator) IsReversed() bool { return op > Reverse }
+
+// Overloaded returns the overloadable operator that implements op
+// and reports whether it applies to the operands of op in reverse
+// order: x < y is implemented by the overloads of > as y > x.
+func (op Operator) Overloaded() (Operator, bool) {
+	if op == Lss {
+		return Gtr, true
+	}
+	return op, false
 }
 
-func (op Operator) IsOperOverload() bool { return operOverload&op != 0 }
-func (op Operator) IsReversed() bool     { return op > Reverse }
+// Unreversed returns the operator op denotes without the Reverse flag.
+func (op Operator) Unreversed() Operator {
+	if op.IsReversed() {
+		return op - Reverse
+	}
+	return op
+}
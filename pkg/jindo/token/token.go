@@ -43,19 +43,26 @@ const (
 	// keywords
 	keyword_beg
 	Break    // break
+	Case     // case
 	Const    // const
 	Continue // continue
+	Default  // default
 	While
-	Else   // else
-	For    // for
-	Func   // func
-	If     // if
-	Import // import
-	Space  // space
-	Return // return
-	Type   // type
-	Var    // var
-	Oper   // oper
+	Else      // else
+	For       // for
+	Func      // func
+	If        // if
+	Import    // import
+	Map       // map
+	Space     // space
+	Range     // range
+	Return    // return
+	Struct    // struct
+	Switch    // switch
+	Interface // interface
+	Type      // type
+	Var       // var
+	Oper      // oper
 	keyword_end
 
 	tokenCount
@@ -35,20 +35,27 @@ var tokenString = map[Token]string{
 	Dot:       ".",
 	DotDotDot: "...",
 
-	Var:      "var",
-	Const:    "const",
-	Type:     "type",
-	Import:   "import",
-	If:       "if",
-	Else:     "else",
-	Space:    "space",
-	Oper:     "oper",
-	Func:     "func",
-	Return:   "return",
-	For:      "for",
-	While:    "while",
-	Break:    "break",
-	Continue: "continue",
+	Var:       "var",
+	Const:     "const",
+	Type:      "type",
+	Import:    "import",
+	If:        "if",
+	Else:      "else",
+	Space:     "space",
+	Oper:      "oper",
+	Func:      "func",
+	Return:    "return",
+	Struct:    "struct",
+	Interface: "interface",
+	For:       "for",
+	While:     "while",
+	Range:     "range",
+	Map:       "map",
+	Switch:    "switch",
+	Case:      "case",
+	Default:   "default",
+	Break:     "break",
+	Continue:  "continue",
 }
 
 func (t Token) String() string { return tokenString[t] }
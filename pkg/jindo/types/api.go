@@ -0,0 +1,175 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// Package types declares the data types and implements
+// the algorithms for type-checking of jindo spaces.
+//
+// Check walks an ast.File, builds its scopes, and assigns a
+// type to every expression. Errors are reported through a
+// parser.ErrorHandler in the same way as syntax errors.
+package types
+
+import (
+	"fmt"
+	"go/constant"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+)
+
+// Error describes a type-checking error. Error implements the error interface.
+type Error struct {
+	Pos position.Pos
+	Msg string
+}
+
+func (err Error) Error() string {
+	if !err.Pos.IsKnown() {
+		return err.Msg
+	}
+	return fmt.Sprintf("%s: %s", err.Pos, err.Msg)
+}
+
+var _ error = Error{} // verify that Error implements error
+
+// Info holds result type information for a type-checked file.
+type Info struct {
+	// Types maps expressions to their types. Untyped constant
+	// expressions are recorded with their final (typed) type;
+	// type expressions are recorded with the type they denote.
+	Types map[ast.Expr]Type
+
+	// Values maps constant expressions to their values. The values
+	// are exact; they are represented according to the type recorded
+	// for the expression when it is evaluated.
+	Values map[ast.Expr]constant.Value
+
+	// Defs maps names to the objects they define.
+	Defs map[*ast.Name]Object
+
+	// Uses maps names to the objects they denote.
+	Uses map[*ast.Name]Object
+
+	// Scopes maps the nodes opening a scope (File, FuncDecl, OperDecl,
+	// BlockStmt, IfStmt, ForStmt, WhileStmt and CaseClause, and TypeDecl
+	// of generic types) to their scope.
+	Scopes map[ast.Node]*Scope
+
+	// Implicits maps the case clauses of type switches declaring a
+	// variable, as in switch v := x.(type), to the variable implicitly
+	// declared in the clause. Each clause has a variable of its own.
+	Implicits map[ast.Node]Object
+
+	// Instances maps the names denoting generic functions or types in
+	// instantiations to their type arguments and instantiated type.
+	Instances map[*ast.Name]Instance
+
+	// Copies maps the declarations of generic functions and types, and
+	// of the methods of generic types, to the declarations of their
+	// instances, which are copies of the generic declarations with type
+	// arguments in place of the type parameters.
+	Copies map[ast.Decl][]ast.Decl
+
+	// Dynamic maps the expressions whose values are implicitly converted
+	// to an interface type to the types of the values, which become the
+	// dynamic types of the interface values.
+	Dynamic map[ast.Expr]Type
+
+	// Overloads maps the binary operations (Operation) and assignment
+	// operations (AssignStmt) resolved to an operator overload to the
+	// declaration of the overload.
+	Overloads map[ast.Node]*ast.OperDecl
+
+	// Methods maps the selectors denoting methods of defined types
+	// to the declarations of the methods.
+	Methods map[*ast.SelectorExpr]*ast.FuncDecl
+
+	// Specs maps the selectors denoting methods of interface types
+	// to the method specs of the interfaces.
+	Specs map[*ast.SelectorExpr]*ast.Field
+
+	// Targets maps the break and continue statements to the loop or
+	// switch statements they terminate or continue.
+	Targets map[ast.Stmt]ast.Stmt
+}
+
+// An Instance reports the type arguments and the instantiated type for
+// an instantiation of a generic function or type. For generic functions,
+// the type is the signature of the instance, and Decl is the declaration
+// of the instance unless a type argument is a type parameter.
+type Instance struct {
+	TypeArgs []Type
+	Type     Type
+	Decl     *ast.FuncDecl
+}
+
+// Instantiated returns the declarations in list with the declarations
+// of generic functions and types replaced by those of their instances.
+func (info *Info) Instantiated(list []ast.Decl) []ast.Decl {
+	var res []ast.Decl
+	for _, d := range list {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.IsGeneric() {
+				res = append(res, info.Copies[d]...)
+				continue
+			}
+		case *ast.TypeDecl:
+			if d.TParamList != nil {
+				res = append(res, info.Copies[d]...)
+				continue
+			}
+		}
+		res = append(res, d)
+	}
+	return res
+}
+
+// TypeOf returns the type of expression x, or nil if not found.
+func (info *Info) TypeOf(x ast.Expr) Type {
+	if t, ok := info.Types[x]; ok {
+		return t
+	}
+	if n, _ := x.(*ast.Name); n != nil {
+		if obj := info.ObjectOf(n); obj != nil {
+			return obj.Type()
+		}
+	}
+	return nil
+}
+
+// ObjectOf returns the object denoted by the name n, or nil if not found.
+func (info *Info) ObjectOf(n *ast.Name) Object {
+	if obj := info.Defs[n]; obj != nil {
+		return obj
+	}
+	return info.Uses[n]
+}
+
+// Check type-checks the file f and returns the collected type information.
+// If there are errors, Check returns the first error found, together with
+// the (partial) type information.
+//
+// If errh != nil, it is called with each error encountered, and Check
+// will process as much of the file as possible. If errh is nil, Check
+// terminates upon encountering the first error.
+func Check(f *ast.File, errh parser.ErrorHandler) (info *Info, first error) {
+	check := newChecker(errh)
+	info = check.info
+
+	defer func() {
+		if p := recover(); p != nil {
+			if err, ok := p.(Error); ok {
+				first = err
+				return
+			}
+			panic(p)
+		}
+	}()
+
+	check.file(f)
+	return info, check.first
+}
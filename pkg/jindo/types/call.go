@@ -0,0 +1,251 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+)
+
+func (check *checker) callExpr(x *operand, call *ast.CallExpr) {
+	// generic functions are instantiated by the call
+	check.genericExpr(x, call.Func, nil)
+	if sig, _ := x.typ.(*Signature); sig == nil || sig.tparams == nil {
+		check.nonGeneric(x)
+	}
+
+	switch x.mode {
+	case invalid:
+		check.use(call.ArgList...)
+		x.expr = call
+		return
+
+	case typexpr:
+		// conversion
+		T := x.typ
+		x.mode = invalid
+		switch n := len(call.ArgList); n {
+		case 0:
+			check.errorf(call.GetPos(), "missing argument in conversion to %s", T)
+		case 1:
+			check.expr(x, call.ArgList[0])
+			if x.mode != invalid {
+				check.conversion(x, T)
+			}
+		default:
+			check.use(call.ArgList...)
+			check.errorf(call.ArgList[n-1].GetPos(), "too many arguments in conversion to %s", T)
+		}
+		x.expr = call
+		return
+
+	case novalue:
+		check.errorf(call.Func.GetPos(), "%s (no value) used as value", ExprString(call.Func))
+		check.use(call.ArgList...)
+		x.mode = invalid
+		x.expr = call
+		return
+
+	case builtin:
+		id := x.id
+		if !check.builtin(x, call, id) {
+			x.mode = invalid
+		}
+		x.expr = call
+		return
+	}
+
+	// ordinary function call
+	sig, _ := x.typ.Underlying().(*Signature)
+	if sig == nil {
+		check.errorf(call.GetPos(), "invalid operation: cannot call non-function %s (%s)", ExprString(call.Func), x.description())
+		check.use(call.ArgList...)
+		x.mode = invalid
+		x.expr = call
+		return
+	}
+
+	sig = check.arguments(call, sig)
+	if sig == nil {
+		x.mode = invalid
+		x.expr = call
+		return
+	}
+
+	if sig.result == nil {
+		x.mode = novalue
+	} else {
+		x.mode = value
+		x.typ = sig.result
+	}
+	x.expr = call
+}
+
+// arguments checks the arguments of call against the parameters of sig
+// and returns sig, or the signature of the instance of sig for the type
+// arguments inferred from the arguments if sig is generic. It returns
+// nil if the instantiation fails.
+func (check *checker) arguments(call *ast.CallExpr, sig *Signature) *Signature {
+	nargs, npars := len(call.ArgList), len(sig.params)
+	if nargs != npars {
+		var at ast.Node = call
+		qualifier := "not enough"
+		if nargs > npars {
+			at = call.ArgList[npars]
+			qualifier = "too many"
+		}
+		check.errorf(at.GetPos(), "%s arguments in call to %s\n\thave %d\n\twant %s", qualifier, ExprString(call.Func), nargs, sig)
+		check.use(call.ArgList...)
+		return sig
+	}
+
+	args := make([]*operand, nargs)
+	for i, arg := range call.ArgList {
+		args[i] = new(operand)
+		check.expr(args[i], arg)
+	}
+
+	if sig.tparams != nil {
+		targs := check.infer(call, sig, args)
+		if targs == nil {
+			return nil
+		}
+		name, _ := parser.Unparen(call.Func).(*ast.Name)
+		if sig = check.funcInst(call.GetPos(), name, sig, targs); sig == nil {
+			return nil
+		}
+		check.recordType(call.Func, sig)
+	}
+
+	for i, x := range args {
+		check.assignment(x, sig.params[i].typ, "argument")
+	}
+	return sig
+}
+
+// builtin type-checks a call to the built-in specified by id and
+// reports whether the call is valid, with *x holding the result.
+func (check *checker) builtin(x *operand, call *ast.CallExpr, id builtinId) bool {
+	bin := predeclaredFuncs[id]
+	nargs := len(call.ArgList)
+
+	// check argument count
+	if nargs < bin.nargs || !bin.variadic && nargs > bin.nargs {
+		msg := "not enough"
+		if nargs > bin.nargs {
+			msg = "too many"
+		}
+		check.errorf(call.GetPos(), "%s arguments for %s (expected %d, found %d)", msg, ExprString(call), bin.nargs, nargs)
+		check.use(call.ArgList...)
+		return false
+	}
+
+	args := make([]*operand, nargs)
+	for i, arg := range call.ArgList {
+		a := new(operand)
+		check.expr(a, arg)
+		if a.mode == invalid {
+			return false
+		}
+		args[i] = a
+	}
+
+	switch id {
+	case _Append:
+		// append(s S, x ...E) S, where E is the element type of S
+		s, _ := args[0].typ.Underlying().(*Slice)
+		if s == nil {
+			check.errorf(args[0].expr.GetPos(), "invalid argument: %s (%s) is not a slice", ExprString(args[0].expr), args[0].description())
+			return false
+		}
+		for _, a := range args[1:] {
+			check.assignment(a, s.elem, "argument to append")
+		}
+		x.mode = value
+		x.typ = args[0].typ
+
+	case _Delete:
+		// delete(m M, k K), where K is the key type of M
+		m, _ := args[0].typ.Underlying().(*Map)
+		if m == nil {
+			check.errorf(args[0].expr.GetPos(), "invalid argument: %s (%s) is not a map", ExprString(args[0].expr), args[0].description())
+			return false
+		}
+		check.assignment(args[1], m.key, "argument to delete")
+		x.mode = novalue
+
+	case _Len:
+		// len(x) int
+		a := args[0]
+		switch t := a.typ.Underlying().(type) {
+		case *Basic:
+			if t.info&IsString == 0 {
+				check.errorf(a.expr.GetPos(), "invalid argument: %s (%s) for built-in len", ExprString(a.expr), a.description())
+				return false
+			}
+			check.defaultType(a, "argument to len")
+		case *Slice, *Map:
+			// ok
+		default:
+			check.errorf(a.expr.GetPos(), "invalid argument: %s (%s) for built-in len", ExprString(a.expr), a.description())
+			return false
+		}
+		x.mode = value
+		x.typ = Typ[Int]
+
+	case _Print, _Println:
+		// print(x, y, ...)
+		for _, a := range args {
+			check.defaultType(a, "argument to "+bin.name)
+		}
+		x.mode = novalue
+	}
+
+	return true
+}
+
+// conversion type-checks the conversion T(x).
+// The result is in x.
+func (check *checker) conversion(x *operand, T Type) {
+	constArg := x.mode == constant_
+
+	var ok bool
+	switch {
+	case constArg && isConstType(T):
+		// constant conversion
+		ok = constConvertible(x, T.Underlying().(*Basic))
+		if ok && isUntyped(x.typ) {
+			final := T
+			if !untypedConvertible(x.typ, T) {
+				final = Default(x.typ)
+				if isString(T) {
+					final = Typ[Rune]
+				}
+			}
+			check.updateExprType(x.expr, final)
+		}
+	case isUntyped(x.typ) && check.implicitType(x, T):
+		ok = true
+	case x.typ == Typ[UntypedNil]:
+		// not convertible
+	default:
+		check.defaultType(x, "conversion")
+		ok = ConvertibleTo(x.typ, T)
+	}
+
+	if !ok {
+		check.errorf(x.expr.GetPos(), "cannot convert %s (%s) to type %s", ExprString(x.expr), x.description(), T)
+		x.mode = invalid
+		return
+	}
+
+	check.box(x, T)
+	if !constArg || !isConstType(T) {
+		x.mode = value
+	}
+	x.typ = T
+}
@@ -0,0 +1,614 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import (
+	"fmt"
+	"go/constant"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+)
+
+type checker struct {
+	info  *Info
+	errh  parser.ErrorHandler
+	first error
+
+	space *Scope         // space-level scope
+	scope *Scope         // current scope
+	sig   *Signature     // signature of the function being checked, or nil
+	iota  constant.Value // value of iota in a constant declaration, or nil
+
+	decls   map[Object]ast.Decl            // declarations of space-level objects
+	consts  map[*ast.ConstDecl]constSpec   // specs of space-level constant declarations
+	color   map[Object]color               // declaration state of space-level objects
+	opers   map[token.Operator][]*overload // operator overloads, by operator
+	methods map[*TypeName][]*Func          // methods by receiver base type, until the type is declared
+	later   []func()                       // function bodies and map key types that remain to be checked
+
+	insts      map[*Named][]*Named              // instances of generic types
+	funcInsts  map[*Func][]instDecl             // declarations of instances of generic functions
+	tparamOps  map[ast.Node]*tparamOp           // operations using constraint methods
+	tparamSels map[*ast.SelectorExpr]*TypeParam // selectors of constraint methods named like operators
+	typeArgs   map[ast.Expr]Type                // type expressions for type arguments in instances
+	depth      int                              // nesting depth of the instance being checked
+}
+
+// A color records the progress of resolving a space-level object.
+type color uint8
+
+const (
+	white color = iota // not yet resolved
+	grey               // resolution in progress
+	black              // resolved
+)
+
+func newChecker(errh parser.ErrorHandler) *checker {
+	return &checker{
+		info: &Info{
+			Types:     make(map[ast.Expr]Type),
+			Values:    make(map[ast.Expr]constant.Value),
+			Defs:      make(map[*ast.Name]Object),
+			Uses:      make(map[*ast.Name]Object),
+			Scopes:    make(map[ast.Node]*Scope),
+			Implicits: make(map[ast.Node]Object),
+			Instances: make(map[*ast.Name]Instance),
+			Copies:    make(map[ast.Decl][]ast.Decl),
+			Dynamic:   make(map[ast.Expr]Type),
+			Overloads: make(map[ast.Node]*ast.OperDecl),
+			Methods:   make(map[*ast.SelectorExpr]*ast.FuncDecl),
+			Specs:     make(map[*ast.SelectorExpr]*ast.Field),
+			Targets:   make(map[ast.Stmt]ast.Stmt),
+		},
+		errh:    errh,
+		decls:   make(map[Object]ast.Decl),
+		color:   make(map[Object]color),
+		opers:   make(map[token.Operator][]*overload),
+		methods: make(map[*TypeName][]*Func),
+
+		insts:      make(map[*Named][]*Named),
+		funcInsts:  make(map[*Func][]instDecl),
+		tparamOps:  make(map[ast.Node]*tparamOp),
+		tparamSels: make(map[*ast.SelectorExpr]*TypeParam),
+		typeArgs:   make(map[ast.Expr]Type),
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Error handling
+
+func (check *checker) errorf(at position.Pos, format string, args ...interface{}) {
+	err := Error{at, fmt.Sprintf(format, args...)}
+	if check.first == nil {
+		check.first = err
+	}
+	if check.errh == nil {
+		panic(err)
+	}
+	check.errh(err)
+}
+
+// ----------------------------------------------------------------------------
+// Recording
+
+func (check *checker) recordType(x ast.Expr, typ Type) {
+	if x != nil && typ != nil {
+		check.info.Types[x] = typ
+	}
+}
+
+func (check *checker) recordValue(x ast.Expr, val constant.Value) {
+	check.info.Values[x] = val
+}
+
+func (check *checker) recordDef(n *ast.Name, obj Object) {
+	if n != nil {
+		check.info.Defs[n] = obj
+	}
+}
+
+func (check *checker) recordUse(n *ast.Name, obj Object) {
+	check.info.Uses[n] = obj
+}
+
+func (check *checker) recordScope(n ast.Node, s *Scope) {
+	check.info.Scopes[n] = s
+}
+
+// declare inserts obj into scope s and records its definition by n.
+// It reports an error if obj redeclares an object in s.
+func (check *checker) declare(s *Scope, n *ast.Name, obj Object) {
+	if obj.Name() != "_" {
+		if alt := s.Insert(obj); alt != nil {
+			check.errorf(obj.Pos(), "%s redeclared in this block", obj.Name())
+			if alt.Pos().IsKnown() {
+				check.errorf(alt.Pos(), "\tother declaration of %s", obj.Name())
+			}
+			return
+		}
+	}
+	check.recordDef(n, obj)
+}
+
+func (check *checker) openScope(n ast.Node) {
+	s := NewScope(check.scope)
+	check.recordScope(n, s)
+	check.scope = s
+}
+
+func (check *checker) closeScope() {
+	check.scope = check.scope.parent
+}
+
+// ----------------------------------------------------------------------------
+// Files
+
+func (check *checker) file(f *ast.File) {
+	check.space = NewScope(Universe)
+	check.scope = check.space
+	check.recordScope(f, check.space)
+
+	// collect space-level objects
+	check.consts = constSpecs(f.DeclList)
+	var objs []Object
+	var methods []*Func
+	var opers []*ast.OperDecl
+	for _, d := range f.DeclList {
+		var obj Object
+		var name *ast.Name
+		switch d := d.(type) {
+		case *ast.TypeDecl:
+			obj = NewTypeName(d.Name.GetPos(), d.Name.Value, nil)
+			name = d.Name
+		case *ast.ConstDecl:
+			obj = NewConst(d.NameList.GetPos(), d.NameList.Value, nil, nil)
+			name = d.NameList
+		case *ast.VarDecl:
+			obj = NewVar(d.NameList.GetPos(), d.NameList.Value, nil)
+			name = d.NameList
+		case *ast.FuncDecl:
+			fn := NewFunc(d.Name.GetPos(), d.Name.Value, nil)
+			fn.decl = d
+			if d.Recv != nil {
+				// methods are not declared in the space scope
+				check.recordDef(d.Name, fn)
+				check.decls[fn] = d
+				objs = append(objs, fn)
+				methods = append(methods, fn)
+				continue
+			}
+			obj = fn
+			name = d.Name
+		case *ast.OperDecl:
+			opers = append(opers, d)
+			continue
+		default:
+			check.otherDecl(d)
+			continue
+		}
+		check.declare(check.scope, name, obj)
+		check.decls[obj] = d
+		objs = append(objs, obj)
+	}
+
+	// associate methods with their receiver base types,
+	// which collect them once they are declared
+	for _, m := range methods {
+		check.collectMethod(m)
+	}
+
+	// collect operator overloads; they are referred to by operations
+	// rather than by name and may be used before their declaration,
+	// in any initialization expression
+	for _, d := range opers {
+		check.operDecl(d)
+	}
+
+	// resolve space-level objects in source order
+	for _, obj := range objs {
+		check.objDecl(obj)
+	}
+
+	// check function bodies once all signatures are known,
+	// and map key types once all types are set up
+	for i := 0; i < len(check.later); i++ {
+		check.later[i]()
+	}
+
+	check.finalize()
+}
+
+// otherDecl checks space-level declarations that do not declare objects.
+func (check *checker) otherDecl(d ast.Decl) {
+	switch d := d.(type) {
+	case *ast.ImportDecl:
+		if d.Path != nil {
+			check.errorf(d.Path.GetPos(), "imports are not supported: %s", d.Path.Value)
+		}
+	}
+}
+
+// objDecl resolves the type of the space-level object obj.
+func (check *checker) objDecl(obj Object) {
+	d := check.decls[obj]
+	if d == nil {
+		return // predeclared or local object
+	}
+
+	switch check.color[obj] {
+	case black:
+		return
+	case grey:
+		switch obj := obj.(type) {
+		case *TypeName:
+			if obj.typ != nil {
+				return // a defined type may refer to itself
+			}
+			check.errorf(obj.pos, "invalid recursive type %s", obj.name)
+			obj.typ = Typ[Invalid]
+		case *Const:
+			check.errorf(obj.pos, "initialization cycle: %s refers to itself", obj.name)
+			obj.typ = Typ[Invalid]
+			obj.val = constant.MakeUnknown()
+		case *Var:
+			check.errorf(obj.pos, "initialization cycle: %s refers to itself", obj.name)
+			obj.typ = Typ[Invalid]
+		}
+		return
+	}
+
+	check.color[obj] = grey
+	defer func() { check.color[obj] = black }()
+
+	// declarations are resolved in the space scope, independent
+	// of the context in which the object is referred to
+	scope, sig, iota := check.scope, check.sig, check.iota
+	check.scope, check.sig, check.iota = check.space, nil, nil
+	defer func() { check.scope, check.sig, check.iota = scope, sig, iota }()
+
+	switch obj := obj.(type) {
+	case *TypeName:
+		check.typeDecl(obj, d.(*ast.TypeDecl))
+	case *Const:
+		check.constDecl(obj, check.consts[d.(*ast.ConstDecl)])
+	case *Var:
+		check.varDecl(obj, d.(*ast.VarDecl))
+	case *Func:
+		check.funcDecl(obj, d.(*ast.FuncDecl))
+	}
+}
+
+func (check *checker) typeDecl(obj *TypeName, d *ast.TypeDecl) {
+	if d.Alias {
+		if d.TParamList != nil {
+			check.errorf(d.Name.GetPos(), "generic type cannot be alias")
+		}
+		obj.typ = check.typ(d.Type)
+		return
+	}
+	named := NewNamed(obj, nil)
+	if d.TParamList != nil {
+		check.openScope(d)
+		defer check.closeScope()
+		named.tparams = check.declareTypeParams(d.TParamList)
+	}
+	rhs := check.typ(d.Type)
+	named.underlying = rhs.Underlying()
+	if named.underlying == nil || contains(named.underlying, named) {
+		// rhs is a defined type whose declaration is still in progress,
+		// or a struct type containing named
+		check.errorf(obj.pos, "invalid recursive type %s", obj.name)
+		named.underlying = Typ[Invalid]
+	}
+	check.recordType(d.Name, named)
+	check.addMethods(named)
+
+	// expand the instances used in the declaration, including
+	// those added by the expansion
+	for i := 0; i < len(check.insts[named]); i++ {
+		check.expand(check.insts[named][i])
+	}
+}
+
+// A constSpec describes the type and initialization expression of a
+// constant declaration. A declaration without values repeats those of
+// the last declaration with values in its group, evaluated with iota
+// set to the declaration's index in the group.
+type constSpec struct {
+	typ, init ast.Expr
+	iota      constant.Value
+}
+
+// constSpecs returns the specs of the constant declarations in list.
+func constSpecs(list []ast.Decl) map[*ast.ConstDecl]constSpec {
+	specs := make(map[*ast.ConstDecl]constSpec)
+	var last *ast.ConstDecl // last declaration with values in the current group
+	index := 0
+	for _, d := range list {
+		c, _ := d.(*ast.ConstDecl)
+		if c == nil || c.Group == nil || last == nil || c.Group != last.Group {
+			last, index = nil, 0
+		}
+		if c == nil {
+			continue
+		}
+		if last == nil || c.Type != nil || c.Values != nil {
+			last = c
+		}
+		specs[c] = constSpec{last.Type, last.Values, constant.MakeInt64(int64(index))}
+		index++
+	}
+	return specs
+}
+
+func (check *checker) constDecl(obj *Const, spec constSpec) {
+	obj.typ, obj.val = Typ[Invalid], constant.MakeUnknown()
+
+	// iota is only defined within constant declarations
+	defer func(iota constant.Value) { check.iota = iota }(check.iota)
+	check.iota = spec.iota
+
+	var typ Type
+	if spec.typ != nil {
+		typ = check.typ(spec.typ)
+		if !isConstType(typ) {
+			if typ != Typ[Invalid] {
+				check.errorf(spec.typ.GetPos(), "invalid constant type %s", typ)
+			}
+			return
+		}
+	}
+	if spec.init == nil {
+		check.errorf(obj.pos, "missing init expr for const declaration")
+		return
+	}
+
+	var x operand
+	check.expr(&x, spec.init)
+	if x.mode == invalid {
+		return
+	}
+	if x.mode != constant_ {
+		check.errorf(x.expr.GetPos(), "%s (%s) is not constant", ExprString(x.expr), x.description())
+		return
+	}
+	if typ != nil {
+		check.assignment(&x, typ, "constant declaration")
+		if x.mode == invalid {
+			return
+		}
+	}
+	obj.typ, obj.val = x.typ, x.val
+}
+
+func (check *checker) varDecl(obj *Var, d *ast.VarDecl) {
+	var typ Type
+	if d.Type != nil {
+		typ = check.typ(d.Type)
+	}
+	if d.Values == nil {
+		if typ == nil {
+			typ = Typ[Invalid]
+		}
+		obj.typ = typ
+		return
+	}
+
+	var x operand
+	check.expr(&x, d.Values)
+	if typ == nil {
+		typ = check.defaultType(&x, "variable declaration")
+	} else {
+		check.assignment(&x, typ, "variable declaration")
+	}
+	obj.typ = typ
+}
+
+func (check *checker) funcDecl(obj *Func, d *ast.FuncDecl) {
+	// the type parameters are declared in the scope of the function
+	var tparams []*TypeParam
+	var scope *Scope
+	if d.IsGeneric() {
+		check.openScope(d)
+		defer check.closeScope()
+		scope = check.scope
+		if d.TParamList != nil {
+			tparams = check.declareTypeParams(d.TParamList)
+			if d.Recv != nil {
+				check.errorf(d.TParamList[0].GetPos(), "methods cannot have type parameters")
+				tparams = nil
+			}
+		}
+		if d.Recv != nil {
+			typ, _ := d.RecvType()
+			if x, ok := typ.(*ast.IndexExpr); ok {
+				check.recvTypeParams(x)
+			}
+		}
+	}
+	sig := check.funcType(d.Param, d.Return)
+	sig.tparams, sig.scope = tparams, scope
+	if d.Recv != nil {
+		sig.recv = check.recv(d.Recv)
+	}
+	obj.typ = sig
+	if d.Body != nil {
+		check.later = append(check.later, func() {
+			check.funcBody(d, d.Recv, d.Param, sig, d.Body)
+		})
+	}
+}
+
+// funcType returns the signature for the given parameters and result.
+func (check *checker) funcType(params []*ast.Field, result ast.Expr) *Signature {
+	var vars []*Var
+	for _, p := range params {
+		var name string
+		var pos position.Pos
+		if p.Name != nil {
+			name, pos = p.Name.Value, p.Name.GetPos()
+		}
+		vars = append(vars, NewVar(pos, name, check.typ(p.Type)))
+	}
+	var res Type
+	if list, ok := result.(*ast.ListExpr); ok {
+		var results []*Var
+		for _, x := range list.ElemList {
+			results = append(results, NewVar(x.GetPos(), "", check.typ(x)))
+		}
+		res = NewTuple(results...)
+	} else if result != nil {
+		res = check.typ(result)
+	}
+	return NewSignature(vars, res)
+}
+
+// funcBody checks the body of the function or operator declaration
+// or function literal d with the given receiver (nil for functions),
+// parameters and signature. The body of a function literal is checked
+// in the scope enclosing the literal, whose variables it may use.
+func (check *checker) funcBody(d ast.Node, recv *ast.Field, params []*ast.Field, sig *Signature, body *ast.BlockStmt) {
+	scope, outer := check.scope, check.sig
+	defer func() { check.scope, check.sig = scope, outer }()
+
+	if _, ok := d.(*ast.FuncLit); !ok {
+		check.scope = check.space
+	}
+	if sig.scope != nil {
+		// the body shares the scope of the type parameters
+		check.scope = sig.scope
+	} else {
+		check.openScope(d)
+	}
+	check.sig = sig
+	if recv != nil && recv.Name != nil {
+		check.declare(check.scope, recv.Name, sig.recv)
+	}
+	for i, p := range sig.params {
+		if params[i].Name != nil {
+			check.declare(check.scope, params[i].Name, p)
+		}
+	}
+
+	check.stmtList(body.StmtList)
+	check.labels(body)
+
+	if sig.result != nil && !check.isTerminatingList(body.StmtList) {
+		check.errorf(body.Rbrace, "missing return")
+	}
+}
+
+// finalize replaces the types of remaining untyped expressions
+// by their default types.
+func (check *checker) finalize() {
+	for x, t := range check.info.Types {
+		if isUntyped(t) {
+			check.info.Types[x] = Default(t)
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Type expressions
+
+// typ type-checks the type expression x and returns its type, or Typ[Invalid].
+func (check *checker) typ(x ast.Expr) Type {
+	typ := check.typInternal(x)
+	if t, _ := typ.Underlying().(*Interface); t != nil && !t.IsMethodSet() {
+		check.errorf(x.GetPos(), "cannot use type %s outside a type constraint: interface contains type constraints", ExprString(x))
+		typ = Typ[Invalid]
+	}
+	check.recordType(x, typ)
+	return typ
+}
+
+func (check *checker) typInternal(x ast.Expr) Type {
+	if t, ok := check.typeArgs[x]; ok {
+		return t
+	}
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		// error reported before
+
+	case *ast.Name:
+		_, obj := check.scope.LookupParent(x.Value)
+		if obj == nil {
+			if x.Value == "_" {
+				check.errorf(x.GetPos(), "cannot use _ as type")
+			} else {
+				check.errorf(x.GetPos(), "undefined: %s", x.Value)
+			}
+			break
+		}
+		check.recordUse(x, obj)
+		tname, ok := obj.(*TypeName)
+		if !ok {
+			check.errorf(x.GetPos(), "%s is not a type", x.Value)
+			break
+		}
+		check.objDecl(tname)
+		if tname.typ == nil {
+			break
+		}
+		if t, _ := tname.typ.(*Named); t != nil && t.tparams != nil && t.obj == tname {
+			check.errorf(x.GetPos(), "cannot use generic type %s without instantiation", x.Value)
+			break
+		}
+		return tname.typ
+
+	case *ast.IndexExpr:
+		return check.instantiatedType(x)
+
+	case *ast.SliceType:
+		return NewSlice(check.typ(x.Elem))
+
+	case *ast.MapType:
+		return check.mapType(x)
+
+	case *ast.StructType:
+		return check.structType(x)
+
+	case *ast.InterfaceType:
+		return check.interfaceType(x)
+
+	case *ast.FuncType:
+		return check.funcType(x.Param, x.Return)
+
+	case *ast.ParenExpr:
+		return check.typ(x.X)
+
+	case *ast.Operation:
+		if x.Op == token.Mul && x.Y == nil {
+			return NewPointer(check.typ(x.X))
+		}
+		check.errorf(x.GetPos(), "%s is not a type", ExprString(x))
+
+	default:
+		check.errorf(x.GetPos(), "%s is not a type", ExprString(x))
+	}
+	return Typ[Invalid]
+}
+
+// mapType type-checks the map type e and returns its type. The key
+// type must be comparable; as it may be a type that is still being
+// declared, that is checked once all types are set up.
+func (check *checker) mapType(e *ast.MapType) *Map {
+	typ := NewMap(check.typ(e.Key), check.typ(e.Value))
+	check.later = append(check.later, func() {
+		if typ.key == Typ[Invalid] || Comparable(typ.key) {
+			return
+		}
+		var cause string
+		if _, ok := typ.key.(*TypeParam); ok {
+			cause = " (missing comparable constraint)"
+		}
+		check.errorf(e.Key.GetPos(), "invalid map key type %s%s", typ.key, cause)
+	})
+	return typ
+}
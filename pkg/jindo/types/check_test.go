@@ -0,0 +1,806 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import (
+	"fmt"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const src_ = "../../../example/test.paw"
+
+func parse(t *testing.T, src string) *ast.File {
+	t.Helper()
+	f, err := parser.Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		t.FailNow()
+	}
+	return f
+}
+
+func TestCheckFile(t *testing.T) {
+	f, err := parser.ParseFile(src_, func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		return // error already reported
+	}
+	if _, err := Check(f, func(err error) { t.Error(err) }); err != nil {
+		return // error already reported
+	}
+}
+
+var errorTests = []struct {
+	src  string
+	errs []string // expected errors, in order, as "line:col: msg" substrings
+}{
+	{`space main
+	var x int = "s"`, []string{`2:14: cannot use "s" (untyped string constant) as int value in variable declaration`}},
+	{`space main
+	func f(a int, b int) int { return a + b }
+	func main() { f(1); f(1, 2, 3) }`, []string{"3:17: not enough arguments in call to f", "3:30: too many arguments in call to f"}},
+	{`space main
+	func main() { a := []int{1}; b := []int{2}; c := a + b }`, []string{"2:53: invalid operation: operator + not defined on a (variable of type []int)"}},
+	{`space main
+	type Vec int
+	var v Vec = 1
+	var w = v + 2
+	var i int = v`, []string{"5:14: cannot use v (variable of type Vec) as int value in variable declaration"}},
+	{`space main
+	func f() int { if true { return 1 } }`, []string{"2:38: missing return"}},
+	{`space main
+	func f() { return 1 }
+	func g() int { return }`, []string{"2:20: too many return values", "3:17: not enough return values"}},
+	{`space main
+	func main() { x := 1; x := 2; y = 3; "a" + 1 }`, []string{
+		"2:26: no new variables on left side of :=",
+		"2:32: undefined: y",
+		"2:43: invalid operation: \"a\" + 1 (mismatched types untyped string and untyped int)",
+	}},
+	{`space main
+	func main() { s := []int{1}; s[0] = "x"; s["a"] = 1; len(1); len }`, []string{
+		"2:38: cannot use \"x\" (untyped string constant) as int value in assignment",
+		"2:45: invalid argument: index \"a\" (untyped string constant) must be integer",
+		"2:59: invalid argument: 1 (untyped int constant) for built-in len",
+		"2:63: len (built-in) must be called",
+	}},
+	{`space main
+	var a = b
+	var b = a`, []string{"2:6: initialization cycle"}},
+	{`space main
+	func f() {}
+	func f() {}`, []string{"3:7: f redeclared in this block", "2:7: \tother declaration of f"}},
+	{`space main
+	func main() { while 1 { } ; if "x" { } }`, []string{"2:22: non-boolean condition in while loop", "2:33: non-boolean condition in if statement"}},
+	{`space main
+	type Vec []int
+	oper (a Vec) add (b int) Vec { return a }
+	oper (a Vec) add (b float) Vec { return a }
+	func f(v Vec) { v = v + 1; v = v + "s"; v = 1 + v }`, []string{
+		"5:24: invalid operation: v + 1 (ambiguous overload)",
+		"5:35: invalid operation: v + \"s\" (no overload of + for Vec and untyped string)",
+		"5:48: invalid operation: 1 + v (mismatched types untyped int and Vec)",
+	}},
+	{`space main
+	type Vec []int
+	oper (a Vec) add (b Vec) Vec { return a }
+	oper (x Vec) add (y Vec) Vec { return x }
+	oper (a int) sub (b int) int { return a }
+	oper (a Vec) mul (b Vec) int { }`, []string{
+		"4:7: operator add redeclared for (Vec, Vec)",
+		"3:7: \tother declaration of oper (Vec) add (Vec) Vec",
+		"5:7: invalid operator declaration oper (int) sub (int) int",
+		"6:33: missing return",
+	}},
+	{`space main
+	var x = iota
+	const a = x
+	const b int = 1 << 70
+	const c = 1 / 0
+	const d int = 1 << 62
+	const e = d * 4
+	const f
+	const g []int = nil`, []string{
+		"2:10: cannot use iota outside constant declaration",
+		"3:12: x (variable of type invalid type) is not constant",
+		"4:18: cannot use 1 << 70 (untyped int constant 1180591620717411303424) as int value in constant declaration",
+		"5:16: invalid operation: division by zero",
+		"7:14: constant 18446744073709551616 overflows int",
+		"8:8: missing init expr for const declaration",
+		"9:10: invalid constant type []int",
+	}},
+	{`space main
+	const (
+		a = b
+		b = a
+	)
+	func main() { const c = 1; c = 2 }`, []string{"3:3: initialization cycle", "6:29: cannot assign to c"}},
+	{`space main
+	type A struct { b B }
+	type B struct { a A }
+	type L struct { next []L; x, x int }
+	type P struct { x, y int }
+	func main() {
+		p := P{x: 1, z: 2, x: 3}
+		q := P{1}
+		r := P{1, 2, 3}
+		s := P{1, y: 2}
+		u := int{1}
+		println(p.w, q, r, s, u)
+		P{}.x = 1
+		var v struct{ f []int } = P{}
+	}`, []string{
+		"2:7: invalid recursive type A",
+		"4:31: x redeclared",
+		"4:28: \tother declaration of x",
+		"7:16: unknown field z in struct literal of type P",
+		"7:22: duplicate field name x in struct literal",
+		"8:9: too few values in struct literal of type P",
+		"9:16: too many values in struct literal of type P",
+		"10:10: mixture of field:value and value elements in struct literal",
+		"11:11: invalid composite literal type int",
+		"12:13: p.w undefined (type P has no field or method w)",
+		"13:6: cannot assign to P{…}.x",
+		"14:30: cannot use P{…} (value of type P) as struct{f []int} value in variable declaration",
+	}},
+	{`space main
+	type P struct { x int }
+	type A = P
+	type S = []int
+	func (p P) m() int { return p.x }
+	func (p P) m() int { return 0 }
+	func (p P) x() {}
+	func (i int) bad() {}
+	func (s S) bad2() {}
+	func (a A) n() int { return a.m() }
+	func (u Undef) bad3() {}
+	func main() {
+		p := P{}
+		println(p.n(), p.k())
+		p.m = 1
+		println(p.m(1))
+		m()
+	}`, []string{
+		"6:13: method P.m already declared",
+		"5:13: \tother declaration of m",
+		"7:13: field and method with the same name x",
+		"2:18: \tother declaration of x",
+		"8:10: cannot define new methods on non-local type int",
+		"9:10: invalid receiver type []int",
+		"11:10: undefined: Undef",
+		"14:20: p.k undefined (type P has no field or method k)",
+		"15:4: cannot assign to p.m",
+		"16:15: too many arguments in call to p.m\n\thave 1\n\twant func() int",
+		"17:3: undefined: m",
+	}},
+	{`space main
+	type Shape interface {
+		area() int
+		area() int
+		scale(k int) Shape
+	}
+	type Sq struct{ s int }
+	func (q Sq) area() int { return q.s * q.s }
+	type Named interface { name() string }
+	func (s Shape) bad() {}
+	type Num interface { add(b int) int }
+	type Sc interface { area() float }
+	var sc Sc = Sq{1}
+	type N int
+	oper (a N) add (b N) N { return a }
+	func main() {
+		var s Shape = Sq{2}
+		var n Named = s
+		var m Num = N(1)
+		x := 1
+		println(x.(int))
+		println(s.(int), n, m)
+		println(s.(Sq))
+		println(s.name())
+		var e interface{} = 1
+		_, e = e.(int)
+	}`, []string{
+		"4:3: duplicate method area",
+		"3:3: \tother declaration of area",
+		"10:10: invalid receiver type Shape (interface type)",
+		"13:16: cannot use Sq{…} (value of type Sq) as Sc value in variable declaration: Sq does not implement Sc (wrong type for method area)\n\t\thave area() int\n\t\twant area() float",
+		"17:19: cannot use Sq{…} (value of type Sq) as Shape value in variable declaration: Sq does not implement Shape (missing method scale)",
+		"18:17: cannot use s (variable of type Shape) as Named value in variable declaration: Shape does not implement Named (missing method name)",
+		"19:16: cannot use N(1) (constant 1 of type N) as Num value in variable declaration: N does not implement Num (missing method add)",
+		"21:11: invalid operation: x (variable of type int) is not an interface",
+		"22:14: impossible type assertion: s.(int)\n\tint does not implement Shape (missing method area)",
+		"23:14: impossible type assertion: s.(Sq)\n\tSq does not implement Shape (missing method scale)",
+		"24:13: s.name undefined (type Shape has no field or method name)",
+		"26:11: cannot use e.(int) (untyped bool value) as interface{} value in assignment (the second result of a map index or type assertion is not converted to interface types)",
+	}},
+	{`space main
+	type Stack[T any] struct{ items []T }
+	type Adder[T any] interface { add(b T) T }
+	func max[T ordered](a T, b T) T {
+		if a > b {
+			return a
+		}
+		return b
+	}
+	func eq[T comparable](a T, b T) bool { return a == b }
+	func bad[T any](a T, b T) T { return a + b }
+	func plus[T Adder[T]](a T, b T) T { return a + b }
+	func (s Stack[T]) m[U any]() {}
+	func (s Stack[T, U]) n() {}
+	type A[T any] = int
+	func none[T any]() T {
+		var x T
+		return x
+	}
+	func main() {
+		var s Stack
+		var o ordered
+		max(1, "a")
+		max([]int{1}, []int{2})
+		eq([]int{1}, []int{1})
+		plus(1, 2)
+		f := max
+		none()
+		max[int, int](1, 2)
+		x := Stack[int, int]{}
+		var y int = max(1.5, 2)
+	}`, []string{
+		"13:22: methods cannot have type parameters",
+		"14:16: got 2 type parameters, but receiver base type declares 1",
+		"15:7: generic type cannot be alias",
+		"11:41: invalid operation: operator + not defined on a (variable of type T)",
+		"21:9: cannot use generic type Stack without instantiation",
+		"22:9: cannot use type ordered outside a type constraint: interface contains type constraints",
+		"23:10: mismatched types untyped int and untyped string (cannot infer T)",
+		"24:6: []int does not satisfy ordered ([]int missing in int | float | rune | string)",
+		"25:5: []int does not satisfy comparable",
+		"26:7: int does not satisfy Adder[int] (missing method add)",
+		"27:8: cannot use generic function max without instantiation",
+		"28:7: in call to none, cannot infer T",
+		"29:6: got 2 type arguments but max has 1 type parameters",
+		"30:13: got 2 type arguments but Stack has 1 type parameters",
+		"31:18: cannot use max(1.5, 2) (value of type float) as int value in variable declaration",
+	}},
+	{`space main
+	func two() (int, int) { return 1, 2 }
+	func one() int { return 1, 2 }
+	func none() { return 1 }
+	func three() (int, int, int) { return two() }
+	func main() {
+		a, b := 1
+		c := two()
+		d, e, f := two()
+		var i interface{}
+		var j int
+		i, j = two()
+		println(two())
+		x := two() + 1
+		g, g := 1, 2
+		a, b := 3, 4
+		k, a := "s", 5
+		println(a, b, c, d, e, f, i, j, x, k)
+	}`, []string{
+		"3:26: too many return values\n\thave (untyped int, untyped int)\n\twant (int)",
+		"4:23: too many return values\n\thave (untyped int)\n\twant ()",
+		"5:43: not enough return values\n\thave (int, int)\n\twant (int, int, int)",
+		"7:11: assignment mismatch: 2 variables but 1 value",
+		"8:11: assignment mismatch: 1 variable but two() returns 2 values",
+		"9:17: assignment mismatch: 3 variables but two() returns 2 values",
+		"12:13: cannot use two() (result of type int) as interface{} value in assignment (results of multiple-value calls are not converted to interface types)",
+		"13:14: multiple-value two() (value of type (int, int)) in single-value context",
+		"14:11: multiple-value two() (value of type (int, int)) in single-value context",
+		"15:6: g repeated on left side of :=",
+		"16:8: no new variables on left side of :=",
+	}},
+	{`space main
+	func main() {
+		f := func(x int) int { if x > 0 { return 1 } }
+		var g func(string) int = f
+		x := 1
+		x(2)
+		println(f, g)
+	}`, []string{
+		"3:48: missing return",
+		"4:28: cannot use f (variable of type func(x int) int) as func(string) int value in variable declaration",
+		"6:4: invalid operation: cannot call non-function x (variable of type int)",
+	}},
+	{`space main
+	type I interface { m() }
+	type T struct{}
+	func f(x int, i I, e interface{}) int {
+		switch x {
+		case 1, 2:
+		case 2:
+		case "a":
+		default:
+		default:
+		}
+		switch {
+		case x:
+		}
+		switch []int{1} {
+		}
+		switch x.(type) {
+		}
+		switch v := i.(type) {
+		case T:
+		case I:
+			println(v)
+		case I:
+		}
+		switch e {
+		case 1:
+			return 1
+		}
+		y := e.(type)
+	}`, []string{
+		"10:3: multiple defaults (first at",
+		"7:8: duplicate case 2 in expression switch",
+		"6:11: \tprevious case",
+		"8:8: cannot use \"a\" (untyped string constant) as int value",
+		"13:8: invalid case x in switch (mismatched types int and bool)",
+		"15:10: cannot switch on []int{…} ([]int is not comparable)",
+		"17:10: x (variable of type int) is not an interface",
+		"20:8: impossible type switch case: T\n\ti (variable of type I) cannot have dynamic type T (missing method m)",
+		"23:8: duplicate case I in type switch",
+		"21:8: \tprevious case",
+		"29:9: use of .(type) outside type switch",
+		"30:2: missing return",
+	}},
+	{`space main
+	func f(e interface{}) {
+		switch v := e.(type) {
+		case nil:
+			var i interface{} = v
+		case int, nil:
+		}
+	}`, []string{
+		"6:13: multiple nil cases in type switch",
+		"4:8: \tprevious case",
+	}},
+	{`space main
+	func f(x int) int {
+		for i, v, w := range []int{} {
+		}
+		for i := range x {
+		}
+		for i, i := range "s" {
+		}
+		for i, s := range []string{} {
+			var t int = s
+		}
+		for range "x" {
+			return 1
+		}
+	}`, []string{
+		"3:13: range clause permits at most two iteration variables",
+		"5:18: cannot range over x (variable of type int)",
+		"7:10: i repeated on left side of :=",
+		"10:16: cannot use s (variable of type string) as int value in variable declaration",
+		"15:2: missing return",
+	}},
+	{`space main
+	func f(x int) int {
+		continue
+		break
+	L:
+		for {
+			switch x {
+			case 1:
+				continue
+			case 2:
+				break L
+			}
+			break M
+		}
+	S:
+		switch {
+		default:
+			continue S
+		}
+	B:
+		{
+			break B
+		}
+	L:
+		for {
+		}
+	U:
+		while x > 0 {
+		}
+		for {
+			break
+		}
+	}
+	func g() int {
+		for {
+			switch {
+			case true:
+				break
+			}
+		}
+	}`, []string{
+		"24:2: label L already declared",
+		"5:2: \tother declaration of L",
+		"3:3: continue is not in a loop",
+		"4:3: break is not in a loop or switch",
+		"13:10: label M not declared",
+		"18:13: invalid continue label S",
+		"22:10: invalid break label B",
+		"27:2: label U declared and not used",
+		"33:2: missing return",
+	}},
+	{`space main
+	type K struct { s []int }
+	func f[T any](m map[T]int) {}
+	func main() {
+		var a map[[]int]int
+		var b map[K]bool
+		m := map[string]int{"a": 1, "a": 2, 3}
+		m[1] = 2
+		v, ok := m["a"]
+		var s bool = v
+		var i any
+		v, i = m["b"]
+		delete(m)
+		delete(v, 1)
+		delete(m, 1)
+		x, y, z := m["a"]
+		for k, e := range m {
+			var n int = k
+		}
+	}`, []string{
+		"3:22: invalid map key type T (missing comparable constraint)",
+		"7:31: duplicate key \"a\" in map literal",
+		"7:39: missing key in map literal",
+		"8:5: cannot use 1 (untyped int constant) as string value in map index",
+		"10:16: cannot use v (variable of type int) as bool value in variable declaration",
+		"12:11: cannot use m[\"b\"] (untyped bool value) as interface{} value in assignment",
+		"13:9: not enough arguments for delete(m) (expected 2, found 1)",
+		"14:10: invalid argument: v (variable of type int) is not a map",
+		"15:13: cannot use 1 (untyped int constant) as string value in argument to delete",
+		"16:15: assignment mismatch: 3 variables but 1 value",
+		"18:16: cannot use k (variable of type string) as int value in variable declaration",
+		"5:13: invalid map key type []int",
+		"6:13: invalid map key type K",
+	}},
+	{`space main
+	type C struct{ n int }
+	type PC *C
+	func (c *C) incr() { c.n += 1 }
+	func (p PC) get() int { return 0 }
+	type I interface{ incr() }
+	func f() C { return C{} }
+	func main() {
+		x := nil
+		var i I = C{}
+		f().incr()
+		m := map[int]int{}
+		p := &m[1]
+		q := &f()
+		var s []int
+		println(s == nil, nil == nil)
+		y := *s
+		var k int = nil
+		var c *C = nil
+		c.incr()
+	}`, []string{
+		"5:10: invalid receiver type PC (pointer type)",
+		"9:8: use of untyped nil in assignment",
+		"10:14: cannot use C{…} (value of type C) as I value in variable declaration: C does not implement I (method incr has pointer receiver)",
+		"11:6: cannot call pointer method incr on C",
+		"13:8: invalid operation: cannot take address of m[1] (map index expression of type int)",
+		"14:8: invalid operation: cannot take address of f() (value of type C)",
+		"16:25: invalid operation: nil == nil (operator == not defined on nil)",
+		"17:8: invalid operation: cannot indirect s (variable of type []int)",
+		"18:15: cannot use nil (untyped nil value) as int value in variable declaration",
+	}},
+}
+
+func TestCheckErrors(t *testing.T) {
+	for _, test := range errorTests {
+		f := parse(t, test.src)
+		var errs []string
+		Check(f, func(err error) { errs = append(errs, err.Error()) })
+		if len(errs) != len(test.errs) {
+			t.Errorf("%s: got %d errors %q, want %d", test.src, len(errs), errs, len(test.errs))
+			continue
+		}
+		for i, want := range test.errs {
+			if !strings.Contains(errs[i], want) {
+				t.Errorf("%s: got error %q, want %q", test.src, errs[i], want)
+			}
+		}
+	}
+}
+
+func TestCheckFirstError(t *testing.T) {
+	f := parse(t, "space main\nvar x int = 1.5\nvar y string = 1")
+	_, err := Check(f, nil)
+	if err == nil || !strings.Contains(err.Error(), "2:13: cannot use 1.5") {
+		t.Errorf("got %v, want first error at 2:13", err)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	f := parse(t, `space main
+	var f float = 3
+	func g(s []int) bool { return len(s) > 2 && s[0] == 1 }`)
+	info, err := Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		return
+	}
+
+	want := map[string]string{
+		"3":                       "float",
+		"len(s)":                  "int",
+		"2":                       "int",
+		"len(s) > 2":              "bool",
+		"s[0]":                    "int",
+		"len(s) > 2 && s[0] == 1": "bool",
+		"s":                       "[]int",
+		"[]int":                   "[]int",
+	}
+	for x, typ := range info.Types {
+		s := ExprString(x)
+		if w, ok := want[s]; ok && typ.String() != w {
+			t.Errorf("%s: got type %s, want %s", s, typ, w)
+		}
+	}
+	for x, typ := range info.Types {
+		if isUntyped(typ) {
+			t.Errorf("%s: untyped type %s remains after checking", ExprString(x), typ)
+		}
+	}
+}
+
+func TestOverload(t *testing.T) {
+	f := parse(t, `space main
+type Vec []int
+oper (a Vec) add (b Vec) Vec { return a }
+oper (a Vec) rmul (k int) Vec { return a }
+oper (a Vec) gtr (b Vec) bool { return len(a) > len(b) }
+func f(v Vec) bool {
+	w := v + v
+	w = 2 * w
+	return v < w
+}`)
+	info, err := Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		return
+	}
+
+	want := map[string]string{
+		"v + v": "add",
+		"2 * w": "rmul",
+		"v < w": "gtr",
+	}
+	fn := f.DeclList[len(f.DeclList)-1].(*ast.FuncDecl)
+	for _, s := range fn.Body.StmtList {
+		var x ast.Expr
+		switch s := s.(type) {
+		case *ast.DefineStmt:
+			x = s.Rhs
+		case *ast.AssignStmt:
+			x = s.Rhs
+		case *ast.ReturnStmt:
+			x = s.Result
+		}
+		op := x.(*ast.Operation)
+		s := ExprString(op)
+		d := info.Overloads[op]
+		if d == nil {
+			t.Errorf("%s: no overload recorded", s)
+			continue
+		}
+		if got := d.Oper.OperName(); got != want[s] {
+			t.Errorf("%s: got overload %s, want %s", s, got, want[s])
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	f := parse(t, `space main
+const (
+	A = iota
+	B
+	C
+)
+const (
+	KB int = 1 << (10 * (iota + 1))
+	MB
+	_
+	TB
+)
+const half float = 1 / 2
+const ratio = 7 / 2.0
+const letter = string('A' + B)
+const greeting = "hello, " + "world"
+const truth = C > B && !false
+func main() {
+	const (
+		x = iota * 2
+		y
+	)
+}`)
+	info, err := Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		return
+	}
+
+	want := map[string]string{
+		"A":        "const A untyped int = 0",
+		"B":        "const B untyped int = 1",
+		"C":        "const C untyped int = 2",
+		"KB":       "const KB int = 1024",
+		"MB":       "const MB int = 1048576",
+		"TB":       "const TB int = 1099511627776",
+		"half":     "const half float = 0",
+		"ratio":    "const ratio untyped float = 3.5",
+		"letter":   `const letter string = "B"`,
+		"greeting": `const greeting untyped string = "hello, world"`,
+		"truth":    "const truth untyped bool = true",
+		"x":        "const x untyped int = 0",
+		"y":        "const y untyped int = 2",
+	}
+	for n, obj := range info.Defs {
+		if _, ok := obj.(*Const); !ok || n.Value == "_" {
+			continue
+		}
+		if got := ObjectString(obj); got != want[n.Value] {
+			t.Errorf("%s: got %s, want %s", n.Value, got, want[n.Value])
+		}
+		delete(want, n.Value)
+	}
+	for name := range want {
+		t.Errorf("%s: constant not declared", name)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	f := parse(t, `space main
+func (p Point) norm() float { return p.x * p.x + p.y * p.y }
+type Point struct { x, y float }
+type Alias = Point
+func (a Alias) scale(k float) Point { return Point{a.x * k, a.y * k} }
+oper (p Point) add (q Point) Point { return Point{p.x + q.x, p.y + q.y} }
+func (p Point) add(q Point) Point { return p + q }
+`)
+	info, err := Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		return
+	}
+
+	var named *Named
+	for n, obj := range info.Defs {
+		if n.Value == "Point" {
+			named = obj.Type().(*Named)
+		}
+	}
+	want := []string{
+		"func (Point).norm() float",
+		"func (Point).scale(k float) Point",
+		"func (Point).add(q Point) Point",
+	}
+	if named.NumMethods() != len(want) {
+		t.Fatalf("got %d methods, want %d", named.NumMethods(), len(want))
+	}
+	for i, w := range want {
+		if got := ObjectString(named.Method(i)); got != w {
+			t.Errorf("method %d: got %s, want %s", i, got, w)
+		}
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	f := parse(t, `space main
+type Shape interface { area() float }
+type Adder interface { add(b Vec) Vec }
+type Any interface {}
+type Circle struct { r float }
+func (c Circle) area() float { return 3 * c.r * c.r }
+type Vec []int
+oper (a Vec) add (b Vec) Vec { return a }
+type Square struct { s int }
+func (q Square) area() int { return q.s }
+`)
+	info, err := Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		return
+	}
+
+	byName := make(map[string]Type)
+	for n, obj := range info.Defs {
+		if _, ok := obj.(*TypeName); ok {
+			byName[n.Value] = obj.Type()
+		}
+	}
+	for _, test := range []struct {
+		V, T string
+		want bool
+	}{
+		{"Circle", "Shape", true},
+		{"Circle", "Any", true},
+		{"Circle", "Adder", false},
+		{"Vec", "Adder", true}, // satisfied by the overload of add
+		{"Square", "Shape", false},
+		{"Shape", "Any", true},
+		{"Any", "Shape", false},
+	} {
+		T := byName[test.T].Underlying().(*Interface)
+		if got := Implements(byName[test.V], T); got != test.want {
+			t.Errorf("Implements(%s, %s) = %v, want %v", test.V, test.T, got, test.want)
+		}
+	}
+}
+
+func TestInstances(t *testing.T) {
+	f := parse(t, `space main
+type Stack[T any] struct { items []T }
+func (s Stack[T]) top() T { return s.items[len(s.items)-1] }
+func max[T ordered](a T, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+func main() {
+	s := Stack[string]{[]string{"a"}}
+	println(max(1, 2), max(1.5, 2), max[int](3, 4), s.top())
+}
+`)
+	info, err := Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		return
+	}
+
+	var got []string
+	for n, inst := range info.Instances {
+		got = append(got, fmt.Sprintf("%s: %s %s", n.GetPos(), inst.TypeArgs, inst.Type))
+	}
+	sort.Strings(got)
+	want := []string{
+		"test.paw:11:7: [string] Stack[string]",
+		"test.paw:12:10: [int] func(a int, b int) int",
+		"test.paw:12:21: [float] func(a float, b float) float",
+		"test.paw:12:34: [int] func(a int, b int) int",
+		"test.paw:3:9: [T] Stack[T]",           // receiver of the generic method
+		"test.paw:3:9: [string] Stack[string]", // receiver of its instance
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got instances\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Each distinct instance is declared once, also when the file
+	// is checked again.
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			if info, err = Check(f, func(err error) { t.Error(err) }); err != nil {
+				return
+			}
+		}
+		for _, d := range f.DeclList {
+			insts := info.Copies[d]
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Name.Value == "max" && len(insts) != 2 {
+					t.Errorf("max: got %d instances, want 2", len(insts))
+				}
+				if d.Name.Value == "top" && len(insts) != 1 {
+					t.Errorf("top: got %d instances, want 1", len(insts))
+				}
+			case *ast.TypeDecl:
+				if len(insts) != 1 || insts[0].(*ast.TypeDecl).Name.Value != "Stack[string]" {
+					t.Errorf("Stack: got instances %v, want Stack[string]", insts)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements the evaluation of constant operands.
+
+package types
+
+import (
+	"go/constant"
+	gotoken "go/token"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"math"
+	"unicode"
+)
+
+// gotokens maps the operators of constant expressions
+// to the corresponding go/token operators.
+var gotokens = [...]gotoken.Token{
+	token.Not:    gotoken.NOT,
+	token.OrOr:   gotoken.LOR,
+	token.AndAnd: gotoken.LAND,
+	token.Eql:    gotoken.EQL,
+	token.Neq:    gotoken.NEQ,
+	token.Lss:    gotoken.LSS,
+	token.Leq:    gotoken.LEQ,
+	token.Gtr:    gotoken.GTR,
+	token.Geq:    gotoken.GEQ,
+	token.Add:    gotoken.ADD,
+	token.Sub:    gotoken.SUB,
+	token.Or:     gotoken.OR,
+	token.Xor:    gotoken.XOR,
+	token.Mul:    gotoken.MUL,
+	token.Div:    gotoken.QUO,
+	token.Rem:    gotoken.REM,
+	token.And:    gotoken.AND,
+	token.AndNot: gotoken.AND_NOT,
+	token.Shl:    gotoken.SHL,
+	token.Shr:    gotoken.SHR,
+}
+
+// golits maps literal kinds to the corresponding go/token tokens.
+var golits = [...]gotoken.Token{
+	token.IntLit:    gotoken.INT,
+	token.FloatLit:  gotoken.FLOAT,
+	token.ImagLit:   gotoken.IMAG,
+	token.RuneLit:   gotoken.CHAR,
+	token.StringLit: gotoken.STRING,
+}
+
+// maxShift is the largest permitted shift count of a constant shift.
+const maxShift = 1023
+
+// literal sets x to the constant value of the literal e.
+func (check *checker) literal(x *operand, e *ast.BasicLit) {
+	switch e.Kind {
+	case token.IntLit:
+		x.typ = Typ[UntypedInt]
+	case token.FloatLit:
+		x.typ = Typ[UntypedFloat]
+	case token.RuneLit:
+		x.typ = Typ[UntypedRune]
+	case token.StringLit:
+		x.typ = Typ[UntypedString]
+	default:
+		check.errorf(e.GetPos(), "unsupported literal %s", e.Value)
+		return
+	}
+	x.val = constant.MakeFromLiteral(e.Value, golits[e.Kind], 0)
+	if x.val.Kind() == constant.Unknown {
+		check.errorf(e.GetPos(), "malformed constant: %s", e.Value)
+		x.typ = Typ[Invalid]
+		return
+	}
+	x.mode = constant_
+}
+
+// overflow checks that the constant x is representable by its type.
+// For untyped constants, it checks that the value doesn't become
+// arbitrarily large.
+func (check *checker) overflow(x *operand, pos position.Pos) {
+	if x.val.Kind() == constant.Unknown {
+		check.errorf(pos, "constant result is not representable")
+		x.mode = invalid
+		return
+	}
+
+	// Typed constants must be representable in
+	// their type after each constant operation.
+	if !isUntyped(x.typ) {
+		if !representableConst(x.val, x.typ.Underlying().(*Basic), &x.val) {
+			check.errorf(pos, "constant %s overflows %s", x.val, x.typ)
+			x.mode = invalid
+		}
+		return
+	}
+
+	// Untyped integer values must not grow arbitrarily.
+	const prec = 512 // 512 is the constant precision
+	if x.val.Kind() == constant.Int && constant.BitLen(x.val) > prec {
+		check.errorf(pos, "constant overflow")
+		x.mode = invalid
+	}
+}
+
+// representableConst reports whether x can be represented as a value
+// of the basic type typ. If rounded is not nil, it is set to the value
+// of x rounded to typ.
+func representableConst(x constant.Value, typ *Basic, rounded *constant.Value) bool {
+	if x.Kind() == constant.Unknown {
+		return true // avoid follow-up errors
+	}
+
+	switch {
+	case isInteger(typ):
+		x := constant.ToInt(x)
+		if x.Kind() != constant.Int {
+			return false
+		}
+		if rounded != nil {
+			*rounded = x
+		}
+		if i, ok := constant.Int64Val(x); ok {
+			switch typ.kind {
+			case Int, UntypedInt, UntypedRune:
+				return true
+			case Rune:
+				return math.MinInt32 <= i && i <= math.MaxInt32
+			}
+		}
+		return typ.kind == UntypedInt || typ.kind == UntypedRune
+
+	case isFloat(typ):
+		x := constant.ToFloat(x)
+		if x.Kind() != constant.Float {
+			return false
+		}
+		if typ.kind == UntypedFloat {
+			if rounded != nil {
+				*rounded = x
+			}
+			return true
+		}
+		f, _ := constant.Float64Val(x)
+		if math.IsInf(f, 0) {
+			return false
+		}
+		if rounded != nil {
+			*rounded = constant.MakeFloat64(f)
+		}
+		return true
+
+	case isBoolean(typ):
+		return x.Kind() == constant.Bool
+
+	case isString(typ):
+		return x.Kind() == constant.String
+	}
+
+	return false
+}
+
+// constConvertible reports whether the constant x can be converted
+// to the basic type t, and sets x.val to the converted value if so.
+// Conversions of numeric constants to integer types truncate toward
+// zero, as they do for non-constant values.
+func constConvertible(x *operand, t *Basic) bool {
+	switch {
+	case representableConst(x.val, t, &x.val):
+		return true
+	case isFloat(x.typ) && isInteger(t):
+		f, _ := constant.Float64Val(constant.ToFloat(x.val))
+		v := constant.ToInt(constant.MakeFloat64(math.Trunc(f)))
+		if math.IsInf(f, 0) || !representableConst(v, t, nil) {
+			return false
+		}
+		x.val = v
+		return true
+	case isInteger(x.typ) && isString(t):
+		codepoint := unicode.ReplacementChar
+		if i, ok := constant.Uint64Val(x.val); ok && i <= unicode.MaxRune {
+			codepoint = rune(i)
+		}
+		x.val = constant.MakeString(string(codepoint))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,772 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import (
+	"go/constant"
+	gotoken "go/token"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+)
+
+// An operandMode specifies the (addressing) mode of an operand.
+type operandMode uint8
+
+const (
+	invalid   operandMode = iota // operand is invalid
+	novalue                      // operand represents no value (result of a function call w/o result)
+	builtin                      // operand is a built-in function
+	typexpr                      // operand is a type
+	constant_                    // operand is a constant; the operand's typ is a Basic type
+	value                        // operand is a computed value
+	variable                     // operand is an addressable variable
+	mapindex                     // operand is a map index expression (acts like a variable on lhs, commaok on rhs)
+	commaok                      // like value, but operand may be used in a comma,ok expression
+)
+
+// An operand represents an intermediate value during type checking.
+type operand struct {
+	mode operandMode
+	expr ast.Expr
+	typ  Type
+	val  constant.Value // constant value; valid if mode == constant_
+	id   builtinId      // valid if mode == builtin
+}
+
+// description returns a description of x for use in error messages.
+func (x *operand) description() string {
+	switch x.mode {
+	case novalue:
+		return "no value"
+	case builtin:
+		return "built-in"
+	case typexpr:
+		return "type"
+	case variable:
+		return "variable of type " + x.typ.String()
+	case mapindex:
+		return "map index expression of type " + x.typ.String()
+	case constant_:
+		// show the value if it differs from the expression
+		var val string
+		if s := x.val.String(); s != ExprString(x.expr) {
+			val = " " + s
+		}
+		if isUntyped(x.typ) {
+			return x.typ.String() + " constant" + val
+		}
+		return "constant" + val + " of type " + x.typ.String()
+	}
+	if x.typ == Typ[UntypedNil] {
+		return "untyped nil value"
+	}
+	return "value of type " + x.typ.String()
+}
+
+// rawExpr type-checks expression e and initializes x with the
+// expression value or type. The result mode may be any mode.
+// If hint != nil, it is the type of a composite literal element
+// whose literal type is elided.
+func (check *checker) rawExpr(x *operand, e ast.Expr, hint Type) {
+	check.genericExpr(x, e, hint)
+	check.nonGeneric(x)
+}
+
+// genericExpr is like rawExpr but x may also denote a generic function
+// or type, which the caller must instantiate.
+func (check *checker) genericExpr(x *operand, e ast.Expr, hint Type) {
+	check.exprInternal(x, e, hint)
+	x.expr = e
+	if x.mode != invalid && x.mode != novalue && x.mode != builtin {
+		check.recordType(e, x.typ)
+	}
+	if x.mode == constant_ {
+		check.recordValue(e, x.val)
+	}
+}
+
+// nonGeneric reports an error if x denotes a generic function or type,
+// or a type that may only be used as a constraint.
+func (check *checker) nonGeneric(x *operand) {
+	var what string
+	switch t := x.typ.(type) {
+	case *Signature:
+		if x.mode == value && t.tparams != nil {
+			what = "function"
+		}
+	case *Named:
+		if _, ok := parser.Unparen(x.expr).(*ast.Name); ok && x.mode == typexpr && t.tparams != nil {
+			what = "type"
+		}
+	}
+	if what != "" {
+		check.errorf(x.expr.GetPos(), "cannot use generic %s %s without instantiation", what, ExprString(x.expr))
+		x.mode = invalid
+		return
+	}
+	if t, _ := x.typ.Underlying().(*Interface); t != nil && x.mode == typexpr && !t.IsMethodSet() {
+		check.errorf(x.expr.GetPos(), "cannot use type %s outside a type constraint: interface contains type constraints", ExprString(x.expr))
+		x.mode = invalid
+	}
+}
+
+// expr type-checks expression e and initializes x with the expression
+// value. An error is reported if e does not denote a single value.
+func (check *checker) expr(x *operand, e ast.Expr) {
+	check.rawExpr(x, e, nil)
+	check.singleValue(x)
+}
+
+// exprWithHint is like expr but uses the type hint
+// for composite literals with elided types.
+func (check *checker) exprWithHint(x *operand, e ast.Expr, hint Type) {
+	check.rawExpr(x, e, hint)
+	check.singleValue(x)
+}
+
+func (check *checker) singleValue(x *operand) {
+	var msg string
+	switch x.mode {
+	case value:
+		if t, ok := x.typ.(*Tuple); ok {
+			check.errorf(x.expr.GetPos(), "multiple-value %s (value of type %s) in single-value context", ExprString(x.expr), t)
+			x.mode = invalid
+		}
+		return
+	case novalue:
+		msg = "%s (no value) used as value"
+	case builtin:
+		msg = "%s (built-in) must be called"
+	case typexpr:
+		msg = "%s (type) is not an expression"
+	default:
+		return
+	}
+	check.errorf(x.expr.GetPos(), msg, ExprString(x.expr))
+	x.mode = invalid
+}
+
+func (check *checker) exprInternal(x *operand, e ast.Expr, hint Type) {
+	x.mode = invalid
+	x.typ = Typ[Invalid]
+
+	if t, ok := check.typeArgs[e]; ok {
+		x.mode = typexpr
+		x.typ = t
+		return
+	}
+
+	switch e := e.(type) {
+	case nil:
+		panic("unreachable")
+
+	case *ast.BadExpr:
+		// error reported before
+
+	case *ast.Name:
+		check.ident(x, e)
+
+	case *ast.BasicLit:
+		if e.Bad {
+			return // error reported before
+		}
+		check.literal(x, e)
+
+	case *ast.SliceLit:
+		elem := check.typ(e.ElemType)
+		for _, v := range e.Elems {
+			var y operand
+			check.exprWithHint(&y, v, elem)
+			check.assignment(&y, elem, "slice literal")
+		}
+		x.mode = value
+		x.typ = NewSlice(elem)
+
+	case *ast.CompositeLit:
+		check.compositeLit(x, e, hint)
+
+	case *ast.ParenExpr:
+		check.genericExpr(x, e.X, nil)
+
+	case *ast.Operation:
+		if e.Y == nil {
+			check.unary(x, e)
+			return
+		}
+		check.binary(x, e)
+
+	case *ast.IndexExpr:
+		check.indexExpr(x, e)
+
+	case *ast.CallExpr:
+		check.callExpr(x, e)
+
+	case *ast.SelectorExpr:
+		check.selector(x, e)
+
+	case *ast.AssertExpr:
+		check.typeAssertion(x, e)
+
+	case *ast.TypeSwitchGuard:
+		check.errorf(e.GetPos(), "use of .(type) outside type switch")
+
+	case *ast.FuncLit:
+		sig := check.funcType(e.Type.Param, e.Type.Return)
+		check.recordType(e.Type, sig)
+		check.funcBody(e, nil, e.Type.Param, sig, e.Body)
+		x.mode = value
+		x.typ = sig
+
+	case *ast.SliceType, *ast.MapType, *ast.StructType, *ast.InterfaceType, *ast.FuncType:
+		x.mode = typexpr
+		x.typ = check.typ(e)
+
+	default:
+		check.errorf(e.GetPos(), "invalid expression %s", ExprString(e))
+	}
+}
+
+func (check *checker) ident(x *operand, e *ast.Name) {
+	if e.Value == "_" {
+		check.errorf(e.GetPos(), "cannot use _ as value")
+		return
+	}
+	_, obj := check.scope.LookupParent(e.Value)
+	if obj == nil {
+		check.errorf(e.GetPos(), "undefined: %s", e.Value)
+		return
+	}
+	check.recordUse(e, obj)
+	check.objDecl(obj)
+
+	switch obj := obj.(type) {
+	case *TypeName:
+		x.mode = typexpr
+	case *Var:
+		x.mode = variable
+	case *Const:
+		if obj.typ == Typ[Invalid] {
+			return // error reported before
+		}
+		x.mode = constant_
+		x.val = obj.val
+		if obj == universeIota {
+			if check.iota == nil {
+				check.errorf(e.GetPos(), "cannot use iota outside constant declaration")
+				x.mode = invalid
+				x.typ = Typ[Invalid]
+				return
+			}
+			x.val = check.iota
+		}
+	case *Func, *Nil:
+		x.mode = value
+	case *Builtin:
+		x.mode = builtin
+		x.id = obj.id
+	}
+	x.typ = obj.Type()
+	if x.typ == nil {
+		// object whose declaration is still in progress
+		check.errorf(e.GetPos(), "invalid use of %s in its own declaration", e.Value)
+		x.mode = invalid
+		x.typ = Typ[Invalid]
+	}
+}
+
+func (check *checker) indexExpr(x *operand, e *ast.IndexExpr) {
+	check.genericExpr(x, e.X, nil)
+	if x.mode == invalid {
+		check.use(parser.UnpackList(e.Index)...)
+		return
+	}
+
+	// instantiation
+	if x.mode == typexpr {
+		x.typ = check.instantiatedType(e)
+		if x.typ == Typ[Invalid] {
+			x.mode = invalid
+		}
+		return
+	}
+	if sig, _ := x.typ.(*Signature); sig != nil && sig.tparams != nil {
+		check.funcInstExpr(x, e, sig)
+		return
+	}
+	check.singleValue(x)
+	if x.mode == invalid {
+		check.use(parser.UnpackList(e.Index)...)
+		return
+	}
+
+	switch typ := x.typ.Underlying().(type) {
+	case *Slice:
+		x.mode = variable
+		x.typ = typ.elem
+	case *Map:
+		var k operand
+		check.expr(&k, e.Index)
+		check.assignment(&k, typ.key, "map index")
+		x.mode = mapindex
+		x.typ = typ.elem
+		return
+	case *Basic:
+		if typ.info&IsString == 0 {
+			goto Error
+		}
+		x.mode = value
+		x.typ = Typ[Int]
+	default:
+		goto Error
+	}
+	check.index(e.Index)
+	return
+
+Error:
+	check.errorf(e.GetPos(), "invalid operation: cannot index %s (%s)", ExprString(e.X), x.description())
+	check.use(e.Index)
+	x.mode = invalid
+}
+
+// index checks that the index expression e is an integer.
+func (check *checker) index(e ast.Expr) {
+	var x operand
+	check.expr(&x, e)
+	if x.mode == invalid {
+		return
+	}
+	if !isInteger(x.typ) {
+		check.errorf(e.GetPos(), "invalid argument: index %s (%s) must be integer", ExprString(e), x.description())
+		return
+	}
+	check.convertUntyped(&x, Typ[Int])
+}
+
+// use type-checks each of the expressions for side effects
+// after an error has been reported.
+func (check *checker) use(list ...ast.Expr) {
+	for _, e := range list {
+		var x operand
+		check.rawExpr(&x, e, nil)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Operators
+
+func (check *checker) unary(x *operand, e *ast.Operation) {
+	switch e.Op {
+	case token.And:
+		check.addressOf(x, e)
+		return
+	case token.Mul:
+		check.indirect(x, e)
+		return
+	}
+	check.expr(x, e.X)
+	if x.mode == invalid {
+		return
+	}
+
+	var ok bool
+	switch e.Op {
+	case token.Add, token.Sub:
+		ok = isNumeric(x.typ)
+	case token.Not:
+		ok = isBoolean(x.typ)
+	}
+	if !ok {
+		check.errorf(e.GetPos(), "invalid operation: operator %s not defined on %s (%s)", e.Op, ExprString(e.X), x.description())
+		x.mode = invalid
+		return
+	}
+
+	if x.mode == constant_ {
+		x.val = constant.UnaryOp(gotokens[e.Op], x.val, 0)
+		x.expr = e
+		check.overflow(x, e.GetPos())
+		return
+	}
+	x.mode = value
+}
+
+// addressOf type-checks the address operation &x of the operation e.
+// Its operand must be addressable or a composite literal.
+func (check *checker) addressOf(x *operand, e *ast.Operation) {
+	check.expr(x, e.X)
+	if x.mode == invalid {
+		return
+	}
+	if _, ok := e.X.(*ast.CompositeLit); !ok && x.mode != variable {
+		check.errorf(e.GetPos(), "invalid operation: cannot take address of %s (%s)", ExprString(e.X), x.description())
+		x.mode = invalid
+		return
+	}
+	x.mode = value
+	x.typ = NewPointer(x.typ)
+}
+
+// indirect type-checks the pointer indirection *x of the operation e,
+// which denotes a pointer type if x is a type.
+func (check *checker) indirect(x *operand, e *ast.Operation) {
+	check.rawExpr(x, e.X, nil)
+	if x.mode == typexpr {
+		x.typ = NewPointer(x.typ)
+		return
+	}
+	check.singleValue(x)
+	if x.mode == invalid {
+		return
+	}
+	p, _ := x.typ.Underlying().(*Pointer)
+	if p == nil {
+		if x.typ == Typ[UntypedNil] {
+			check.errorf(e.GetPos(), "invalid operation: cannot indirect nil")
+		} else {
+			check.errorf(e.GetPos(), "invalid operation: cannot indirect %s (%s)", ExprString(e.X), x.description())
+		}
+		x.mode = invalid
+		return
+	}
+	x.mode = variable
+	x.typ = p.base
+}
+
+func (check *checker) binary(x *operand, e *ast.Operation) {
+	var y operand
+	check.expr(x, e.X)
+	check.expr(&y, e.Y)
+	if x.mode == invalid {
+		return
+	}
+	if y.mode == invalid {
+		x.mode = invalid
+		return
+	}
+	if check.tparamOp(x, &y, e.Op, e) {
+		return
+	}
+	if d := check.overload(x, &y, e.Op, e.GetPos()); d != nil || x.mode == invalid {
+		if d != nil {
+			check.info.Overloads[e] = d
+		}
+		return
+	}
+	check.binaryOp(x, &y, e.Op, e.GetPos())
+}
+
+// binaryOp checks the binary operation x op y and leaves the result in x.
+func (check *checker) binaryOp(x, y *operand, op token.Operator, pos position.Pos) {
+	check.matchTypes(x, y)
+	if x.mode == invalid {
+		check.errorf(pos, "invalid operation: %s %s %s (mismatched types %s and %s)", ExprString(x.expr), op, ExprString(y.expr), x.typ, y.typ)
+		return
+	}
+
+	if isComparison(op) {
+		check.comparison(x, y, op, pos, false)
+		return
+	}
+
+	if !Identical(x.typ, y.typ) {
+		check.errorf(pos, "invalid operation: %s %s %s (mismatched types %s and %s)", ExprString(x.expr), op, ExprString(y.expr), x.typ, y.typ)
+		x.mode = invalid
+		return
+	}
+
+	var ok bool
+	switch op {
+	case token.Add:
+		ok = isBasic(x.typ, IsNumeric|IsString)
+	case token.Sub, token.Mul, token.Div:
+		ok = isNumeric(x.typ)
+	case token.Rem, token.And, token.Or, token.Xor, token.AndNot, token.Shl, token.Shr:
+		ok = isInteger(x.typ)
+	case token.AndAnd, token.OrOr:
+		ok = isBoolean(x.typ)
+	}
+	if !ok {
+		check.errorf(pos, "invalid operation: operator %s not defined on %s (%s)", op, ExprString(x.expr), x.description())
+		x.mode = invalid
+		return
+	}
+
+	if (op == token.Div || op == token.Rem) && (x.mode == constant_ || isInteger(x.typ)) && y.mode == constant_ && constant.Sign(y.val) == 0 {
+		check.errorf(y.expr.GetPos(), "invalid operation: division by zero")
+		x.mode = invalid
+		return
+	}
+
+	if x.mode == constant_ && y.mode == constant_ {
+		switch {
+		case op == token.Shl || op == token.Shr:
+			s, ok := constant.Uint64Val(y.val)
+			if !ok || s > maxShift {
+				check.errorf(y.expr.GetPos(), "invalid shift count %s", ExprString(y.expr))
+				x.mode = invalid
+				return
+			}
+			x.val = constant.Shift(x.val, gotokens[op], uint(s))
+		case op == token.Div && isInteger(x.typ):
+			// force integer division for integer operands
+			x.val = constant.BinaryOp(x.val, gotoken.QUO_ASSIGN, y.val)
+		default:
+			x.val = constant.BinaryOp(x.val, gotokens[op], y.val)
+		}
+		check.overflow(x, pos)
+		return
+	}
+	x.mode = value
+}
+
+// matchTypes attempts to convert untyped operands x and y such that
+// they have matching types. If that is not possible, x.mode is set
+// to invalid.
+func (check *checker) matchTypes(x, y *operand) {
+	switch {
+	case isUntyped(x.typ) && isUntyped(y.typ):
+		if t := largerUntyped(x.typ, y.typ); t != nil {
+			x.typ, y.typ = t, t
+			return
+		}
+		x.mode = invalid
+	case isUntyped(x.typ):
+		if !check.implicitType(x, y.typ) {
+			x.mode = invalid
+		}
+	case isUntyped(y.typ):
+		if !check.implicitType(y, x.typ) {
+			x.mode = invalid
+		}
+	}
+}
+
+// largerUntyped returns the larger of the two untyped types x and y,
+// or nil if they are not compatible.
+func largerUntyped(x, y Type) Type {
+	xk, yk := x.(*Basic).kind, y.(*Basic).kind
+	if xk == yk {
+		return x
+	}
+	if isNumeric(x) && isNumeric(y) {
+		// UntypedInt < UntypedRune < UntypedFloat
+		if xk > yk {
+			return x
+		}
+		return y
+	}
+	return nil
+}
+
+// comparison checks the comparison x op y and leaves the result in x.
+// If switchCase is set, x is a case value and y the tag of a switch.
+func (check *checker) comparison(x, y *operand, op token.Operator, pos position.Pos, switchCase bool) {
+	// a value may be compared with an interface value if it
+	// is assignable to the interface type
+	mixed := false
+	if !Identical(x.typ, y.typ) && (op == token.Eql || op == token.Neq) {
+		switch {
+		case IsInterface(x.typ) && !IsInterface(y.typ) && AssignableTo(y.typ, x.typ):
+			check.box(y, x.typ)
+			mixed = true
+		case IsInterface(y.typ) && !IsInterface(x.typ) && AssignableTo(x.typ, y.typ):
+			check.box(x, y.typ)
+			mixed = true
+		}
+	}
+
+	var cause string
+	switch {
+	case mixed:
+		// ok
+	case !Identical(x.typ, y.typ):
+		cause = "mismatched types " + x.typ.String() + " and " + y.typ.String()
+	case op == token.Eql || op == token.Neq:
+		switch {
+		case x.typ == Typ[UntypedNil]:
+			cause = "operator " + op.String() + " not defined on nil"
+		case check.isNil(x) || check.isNil(y):
+			// slices, maps and functions may be compared with nil
+		case !Comparable(x.typ):
+			cause = "operator " + op.String() + " not defined on " + x.typ.String()
+		}
+	default:
+		if !isOrdered(x.typ) {
+			cause = "operator " + op.String() + " not defined on " + x.typ.String()
+		}
+	}
+	if cause != "" {
+		if switchCase {
+			check.errorf(pos, "invalid case %s in switch on %s (%s)", ExprString(x.expr), ExprString(y.expr), cause)
+		} else {
+			check.errorf(pos, "invalid operation: %s %s %s (%s)", ExprString(x.expr), op, ExprString(y.expr), cause)
+		}
+		x.mode = invalid
+		return
+	}
+
+	// operands of a comparison are never converted implicitly
+	// to the comparison result; give them their default type
+	check.defaultType(x, "comparison")
+	check.defaultType(y, "comparison")
+
+	if x.mode == constant_ && y.mode == constant_ {
+		x.val = constant.MakeBool(constant.Compare(x.val, gotokens[op], y.val))
+	} else {
+		x.mode = value
+	}
+	x.typ = Typ[UntypedBool]
+}
+
+// isNil reports whether x denotes the predeclared nil, possibly
+// converted to a typed nil.
+func (check *checker) isNil(x *operand) bool {
+	name, _ := parser.Unparen(x.expr).(*ast.Name)
+	return name != nil && check.info.Uses[name] == universeNil
+}
+
+func isComparison(op token.Operator) bool {
+	switch op {
+	case token.Eql, token.Neq, token.Lss, token.Leq, token.Gtr, token.Geq:
+		return true
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// Untyped values and assignments
+
+// implicitType converts the untyped operand x to the type target,
+// if possible, and records the new type. It reports whether the
+// conversion succeeded. If target is an interface type, x is
+// converted to its default type, which must implement target.
+func (check *checker) implicitType(x *operand, target Type) bool {
+	if x.typ == Typ[UntypedNil] {
+		if !hasNil(target) {
+			return false
+		}
+		x.typ = target
+		check.updateExprType(x.expr, target)
+		return true
+	}
+	if t, _ := target.Underlying().(*Interface); t != nil {
+		if !Implements(Default(x.typ), t) {
+			return false
+		}
+		target = Default(x.typ)
+	}
+	if !untypedConvertible(x.typ, target) {
+		return false
+	}
+	if t, _ := target.(*TypeParam); t != nil {
+		// the constant must be representable by all types of the type set
+		if x.mode == constant_ && !allTerms(t, func(u Type) bool { return representableConst(x.val, u.(*Basic), nil) }) {
+			return false
+		}
+	} else if x.mode == constant_ && !isUntyped(target) && !representableConst(x.val, target.Underlying().(*Basic), &x.val) {
+		return false
+	}
+	x.typ = target
+	check.updateExprType(x.expr, target)
+	return true
+}
+
+// untypedConvertible reports whether a value of the untyped type V
+// can be converted implicitly to type T.
+func untypedConvertible(V, T Type) bool {
+	if V == Typ[UntypedNil] {
+		return hasNil(T)
+	}
+	if t, ok := T.Underlying().(*Interface); ok {
+		return Implements(Default(V), t)
+	}
+	if t, ok := T.(*TypeParam); ok {
+		return allTerms(t, func(u Type) bool { return untypedConvertible(V, u) })
+	}
+	t, ok := T.Underlying().(*Basic)
+	if !ok {
+		return false
+	}
+	switch V.(*Basic).kind {
+	case UntypedBool:
+		return t.info&IsBoolean != 0
+	case UntypedInt, UntypedRune:
+		return t.info&IsNumeric != 0
+	case UntypedFloat:
+		return t.info&IsFloat != 0
+	case UntypedString:
+		return t.info&IsString != 0
+	}
+	return false
+}
+
+// convertUntyped is like implicitType but reports an error if
+// the conversion is not possible.
+func (check *checker) convertUntyped(x *operand, target Type) {
+	if x.mode == invalid || !isUntyped(x.typ) || isUntyped(target) {
+		return
+	}
+	if !check.implicitType(x, target) {
+		check.errorf(x.expr.GetPos(), "cannot use %s (%s) as %s value", ExprString(x.expr), x.description(), target)
+		x.mode = invalid
+	}
+}
+
+// updateExprType updates the recorded type of the untyped expression
+// x, and of its untyped operands, to typ.
+func (check *checker) updateExprType(x ast.Expr, typ Type) {
+	old, ok := check.info.Types[x]
+	if !ok || !isUntyped(old) {
+		return
+	}
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		check.updateExprType(x.X, typ)
+	case *ast.Operation:
+		if x.Y == nil {
+			check.updateExprType(x.X, typ)
+		} else if !isComparison(x.Op) {
+			check.updateExprType(x.X, typ)
+			check.updateExprType(x.Y, typ)
+		}
+	}
+	check.info.Types[x] = typ
+}
+
+// defaultType converts an untyped operand x to its default type
+// and returns the resulting type.
+func (check *checker) defaultType(x *operand, context string) Type {
+	if x.mode == invalid {
+		return Typ[Invalid]
+	}
+	if x.typ == Typ[UntypedNil] {
+		check.errorf(x.expr.GetPos(), "use of untyped nil in %s", context)
+		x.mode = invalid
+		return Typ[Invalid]
+	}
+	if isUntyped(x.typ) {
+		check.convertUntyped(x, Default(x.typ))
+	}
+	return x.typ
+}
+
+// assignment reports whether x can be assigned to a variable of type T,
+// converting untyped values as needed. The context describes the
+// assignment for error messages.
+func (check *checker) assignment(x *operand, T Type, context string) {
+	if x.mode == invalid || T == Typ[Invalid] {
+		return
+	}
+	if isUntyped(x.typ) && !check.implicitType(x, T) || !AssignableTo(x.typ, T) {
+		check.errorf(x.expr.GetPos(), "cannot use %s (%s) as %s value in %s%s", ExprString(x.expr), x.description(), T, context, implementsCause(x.typ, T))
+		x.mode = invalid
+		return
+	}
+	check.box(x, T)
+}
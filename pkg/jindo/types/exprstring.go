@@ -0,0 +1,176 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements printing of expressions for error messages.
+
+package types
+
+import (
+	"bytes"
+	"jindo/pkg/jindo/ast"
+)
+
+// ExprString returns the (possibly shortened) string representation for x.
+// Shortened representations are suitable for user interfaces but may not
+// necessarily follow jindo syntax.
+func ExprString(x ast.Expr) string {
+	var buf bytes.Buffer
+	WriteExpr(&buf, x)
+	return buf.String()
+}
+
+// WriteExpr writes the (possibly shortened) string representation for x to buf.
+func WriteExpr(buf *bytes.Buffer, x ast.Expr) {
+	switch x := x.(type) {
+	default:
+		buf.WriteString("(bad expr)") // nil, ast.BadExpr, ast.Field
+
+	case *ast.Name:
+		buf.WriteString(x.Value)
+
+	case *ast.BasicLit:
+		buf.WriteString(x.Value)
+
+	case *ast.SliceLit:
+		buf.WriteString("[]")
+		WriteExpr(buf, x.ElemType)
+		buf.WriteString("{…}")
+
+	case *ast.CompositeLit:
+		WriteExpr(buf, x.Type) // shortened; nil Type writes nothing
+		buf.WriteString("{…}")
+
+	case *ast.KeyValueExpr:
+		WriteExpr(buf, x.Key)
+		buf.WriteString(": ")
+		WriteExpr(buf, x.Value)
+
+	case *ast.ParenExpr:
+		buf.WriteByte('(')
+		WriteExpr(buf, x.X)
+		buf.WriteByte(')')
+
+	case *ast.SelectorExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteByte('.')
+		buf.WriteString(x.Sel.Value)
+
+	case *ast.IndexExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteByte('[')
+		WriteExpr(buf, x.Index)
+		buf.WriteByte(']')
+
+	case *ast.ListExpr:
+		writeExprList(buf, x.ElemList)
+
+	case *ast.CallExpr:
+		WriteExpr(buf, x.Func)
+		buf.WriteByte('(')
+		writeExprList(buf, x.ArgList)
+		buf.WriteByte(')')
+
+	case *ast.Operation:
+		if x.Y == nil {
+			buf.WriteString(x.Op.String())
+			WriteExpr(buf, x.X)
+		} else {
+			WriteExpr(buf, x.X)
+			buf.WriteByte(' ')
+			buf.WriteString(x.Op.String())
+			buf.WriteByte(' ')
+			WriteExpr(buf, x.Y)
+		}
+
+	case *ast.SliceType:
+		buf.WriteString("[]")
+		WriteExpr(buf, x.Elem)
+
+	case *ast.MapType:
+		buf.WriteString("map[")
+		WriteExpr(buf, x.Key)
+		buf.WriteByte(']')
+		WriteExpr(buf, x.Value)
+
+	case *ast.StructType:
+		buf.WriteString("struct{")
+		for i, f := range x.FieldList {
+			if i > 0 {
+				buf.WriteString("; ")
+			}
+			buf.WriteString(f.Name.Value)
+			buf.WriteByte(' ')
+			WriteExpr(buf, f.Type)
+		}
+		buf.WriteByte('}')
+
+	case *ast.InterfaceType:
+		buf.WriteString("interface{")
+		for i, f := range x.MethodList {
+			if i > 0 {
+				buf.WriteString("; ")
+			}
+			buf.WriteString(f.Name.Value)
+			writeSignature(buf, f.Type.(*ast.FuncType))
+		}
+		buf.WriteByte('}')
+
+	case *ast.FuncType:
+		buf.WriteString("func")
+		writeSignature(buf, x)
+
+	case *ast.FuncLit:
+		buf.WriteString("(func")
+		writeSignature(buf, x.Type)
+		buf.WriteString(" literal)")
+
+	case *ast.AssertExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteString(".(")
+		WriteExpr(buf, x.Type)
+		buf.WriteByte(')')
+
+	case *ast.TypeSwitchGuard:
+		if x.Lhs != nil {
+			WriteExpr(buf, x.Lhs)
+			buf.WriteString(" := ")
+		}
+		WriteExpr(buf, x.X)
+		buf.WriteString(".(type)")
+	}
+}
+
+func writeSignature(buf *bytes.Buffer, t *ast.FuncType) {
+	buf.WriteByte('(')
+	for i, f := range t.Param {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if f.Name != nil {
+			buf.WriteString(f.Name.Value)
+			buf.WriteByte(' ')
+		}
+		WriteExpr(buf, f.Type)
+	}
+	buf.WriteByte(')')
+	if list, ok := t.Return.(*ast.ListExpr); ok {
+		buf.WriteString(" (")
+		writeExprList(buf, list.ElemList)
+		buf.WriteByte(')')
+	} else if t.Return != nil {
+		buf.WriteByte(' ')
+		WriteExpr(buf, t.Return)
+	}
+}
+
+func writeExprList(buf *bytes.Buffer, list []ast.Expr) {
+	for i, x := range list {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		WriteExpr(buf, x)
+	}
+}
@@ -0,0 +1,542 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements type-checking of type parameters,
+// instantiation and type argument inference.
+//
+// A generic function or type declares type parameters, each with a
+// constraint, an interface:
+//
+//	func max[T ordered](a T, b T) T { ... }
+//	type Stack[T any] struct { items []T }
+//
+// The body of a generic function is checked once, permitting only the
+// operations that the constraints of its type parameters provide. The
+// predeclared operators apply to operands of type parameter type if
+// they apply to all types of the type set, as < does for ordered. The
+// methods of a constraint that are named like an operator provide
+// that operator, as the method add provides + in
+//
+//	type Adder[T any] interface { add(b T) T }
+//
+//	func sum[T Adder[T]](a T, b T) T { return a + b }
+//
+// with a.add(b) for the operation a + b or, for a method radd, b.radd(a).
+// A type argument for T then satisfies Adder[T] with a method add or
+// an operator overload of add (see interface.go).
+//
+// Generic functions are instantiated with explicit type arguments, as
+// in max[int], or with the type arguments inferred from the arguments
+// of a call. Generic types are always instantiated explicitly, as in
+// Stack[int]. The type arguments must satisfy the constraints.
+
+package types
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"strings"
+)
+
+// maxInstDepth limits the nesting of instantiations, which only grows
+// without bound if generic declarations instantiate each other with
+// ever larger type arguments.
+const maxInstDepth = 32
+
+// declareTypeParams declares the type parameters in list in the current
+// scope and returns them. The constraints are resolved once all type
+// parameters are declared, so that they may refer to each other.
+func (check *checker) declareTypeParams(list []*ast.Field) []*TypeParam {
+	tparams := make([]*TypeParam, len(list))
+	for i, f := range list {
+		tparams[i] = NewTypeParam(NewTypeName(f.Name.GetPos(), f.Name.Value, nil), i, nil)
+		check.declare(check.scope, f.Name, tparams[i].obj)
+	}
+	for i, f := range list {
+		tparams[i].bound = check.bound(f.Type)
+	}
+	return tparams
+}
+
+// bound type-checks the constraint x of a type parameter and returns it.
+// Invalid constraints are reported and replaced by interface{}.
+func (check *checker) bound(x ast.Expr) Type {
+	t := check.typInternal(x)
+	check.recordType(x, t)
+	if !IsInterface(t) {
+		if t != Typ[Invalid] {
+			check.errorf(x.GetPos(), "cannot use %s as constraint: %s is not an interface", ExprString(x), t)
+		}
+		return emptyInterface
+	}
+	return t
+}
+
+// recvTypeParams declares the names in the generic receiver type of a
+// method, such as E in (s *Stack[E]), as names for the type parameters
+// of the receiver base type.
+func (check *checker) recvTypeParams(x *ast.IndexExpr) {
+	var tparams []*TypeParam
+	if base, _ := x.X.(*ast.Name); base != nil {
+		if obj, _ := check.space.Lookup(base.Value).(*TypeName); obj != nil {
+			check.objDecl(obj)
+			if t, _ := obj.typ.(*Named); t != nil {
+				tparams = t.tparams
+			}
+		}
+	}
+	names := parser.UnpackList(x.Index)
+	if tparams != nil && len(names) != len(tparams) {
+		check.errorf(x.Index.GetPos(), "got %d type parameters, but receiver base type declares %d", len(names), len(tparams))
+		tparams = nil
+	}
+	for i, e := range names {
+		name, _ := e.(*ast.Name)
+		if name == nil {
+			check.errorf(e.GetPos(), "receiver type parameter %s must be an identifier", ExprString(e))
+			continue
+		}
+		// invalid receiver types are reported by recv
+		var typ Type = Typ[Invalid]
+		if tparams != nil {
+			typ = tparams[i]
+		}
+		check.declare(check.scope, name, NewTypeName(name.GetPos(), name.Value, typ))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Instantiation
+
+// typeList type-checks the type arguments x, a single type or a list,
+// and returns their types, or nil if a type is invalid.
+func (check *checker) typeList(x ast.Expr) []Type {
+	var list []Type
+	for _, e := range parser.UnpackList(x) {
+		t := check.typ(e)
+		if t == Typ[Invalid] {
+			return nil
+		}
+		list = append(list, t)
+	}
+	return list
+}
+
+// instantiatedType type-checks the instantiation x of a generic type
+// and returns the instance.
+func (check *checker) instantiatedType(x *ast.IndexExpr) Type {
+	name, _ := parser.Unparen(x.X).(*ast.Name)
+	if name == nil {
+		check.errorf(x.X.GetPos(), "%s is not a generic type", ExprString(x.X))
+		return Typ[Invalid]
+	}
+	_, obj := check.scope.LookupParent(name.Value)
+	if obj == nil {
+		check.errorf(name.GetPos(), "undefined: %s", name.Value)
+		return Typ[Invalid]
+	}
+	check.recordUse(name, obj)
+	tname, _ := obj.(*TypeName)
+	if tname == nil {
+		check.errorf(name.GetPos(), "%s is not a type", name.Value)
+		return Typ[Invalid]
+	}
+	check.objDecl(tname)
+	orig, _ := tname.typ.(*Named)
+	if orig == nil || orig.tparams == nil || orig.obj != tname {
+		if tname.typ != nil && tname.typ != Typ[Invalid] {
+			check.errorf(name.GetPos(), "%s is not a generic type", name.Value)
+		}
+		return Typ[Invalid]
+	}
+
+	targs := check.typeList(x.Index)
+	if targs == nil {
+		return Typ[Invalid]
+	}
+	if len(targs) != len(orig.tparams) {
+		check.errorf(x.GetPos(), "got %d type arguments but %s has %d type parameters", len(targs), name.Value, len(orig.tparams))
+		return Typ[Invalid]
+	}
+	if !check.verify(x.GetPos(), orig.tparams, targs) {
+		return Typ[Invalid]
+	}
+	inst := check.instance(orig, targs)
+	check.recordInstance(name, targs, inst, nil)
+	return inst
+}
+
+// funcInstExpr type-checks the explicit instantiation e of the generic
+// function x with signature sig. All type arguments must be given.
+func (check *checker) funcInstExpr(x *operand, e *ast.IndexExpr, sig *Signature) {
+	targs := check.typeList(e.Index)
+	if targs == nil {
+		x.mode = invalid
+		return
+	}
+	if len(targs) != len(sig.tparams) {
+		check.errorf(e.GetPos(), "got %d type arguments but %s has %d type parameters", len(targs), ExprString(e.X), len(sig.tparams))
+		x.mode = invalid
+		return
+	}
+	name, _ := parser.Unparen(e.X).(*ast.Name)
+	inst := check.funcInst(e.GetPos(), name, sig, targs)
+	if inst == nil {
+		x.mode = invalid
+		return
+	}
+	x.mode = value
+	x.typ = inst
+}
+
+// funcInst instantiates the generic function with signature sig,
+// denoted by name, with the type arguments targs. It returns the
+// signature of the instance, or nil if the type arguments do not
+// satisfy the constraints.
+func (check *checker) funcInst(pos position.Pos, name *ast.Name, sig *Signature, targs []Type) *Signature {
+	if !check.verify(pos, sig.tparams, targs) {
+		return nil
+	}
+	inst := check.subst(NewSignature(sig.params, sig.result), makeSubstMap(sig.tparams, targs)).(*Signature)
+	if name != nil {
+		var decl *ast.FuncDecl
+		if fn, _ := check.info.Uses[name].(*Func); fn != nil && fn.decl != nil && !isParameterizedList(targs) {
+			decl = check.funcInstance(fn, targs)
+		}
+		check.recordInstance(name, targs, inst, decl)
+		check.recordType(name, inst)
+	}
+	return inst
+}
+
+// verify reports whether the type arguments targs satisfy the
+// constraints of the type parameters tparams. If not, it reports
+// an error at pos.
+func (check *checker) verify(pos position.Pos, tparams []*TypeParam, targs []Type) bool {
+	smap := makeSubstMap(tparams, targs)
+	for i, tpar := range tparams {
+		if tpar.bound == nil {
+			continue // constraint still being resolved
+		}
+		bound := check.subst(tpar.bound, smap)
+		if ok, cause := satisfies(targs[i], bound); !ok {
+			if cause != "" {
+				cause = " (" + cause + ")"
+			}
+			check.errorf(pos, "%s does not satisfy %s%s", targs[i], bound, cause)
+			return false
+		}
+	}
+	return true
+}
+
+// satisfies reports whether the type V satisfies the constraint bound
+// and, if not, why not, unless that is evident.
+func satisfies(V, bound Type) (ok bool, cause string) {
+	t, _ := bound.Underlying().(*Interface)
+	if t == nil || V == Typ[Invalid] {
+		return true, "" // error reported before
+	}
+	if t.comparable && !Comparable(V) {
+		return false, ""
+	}
+	if t.terms != nil && !inTerms(V, t.terms) {
+		list := make([]string, len(t.terms))
+		for i, term := range t.terms {
+			list[i] = term.String()
+		}
+		return false, V.String() + " missing in " + strings.Join(list, " | ")
+	}
+	if m, wrongType := MissingMethod(V, t); m != nil {
+		if wrongType {
+			return false, "wrong type for method " + m.name
+		}
+		return false, "missing method " + m.name
+	}
+	return true, ""
+}
+
+// inTerms reports whether the underlying type of V is that of one of
+// the types in terms; for a type parameter V, all types of its type
+// set must be.
+func inTerms(V Type, terms []Type) bool {
+	if t, _ := V.(*TypeParam); t != nil {
+		return allTerms(t, func(u Type) bool { return inTerms(u, terms) })
+	}
+	for _, term := range terms {
+		if Identical(V.Underlying(), term.Underlying()) {
+			return true
+		}
+	}
+	return false
+}
+
+// instance returns the instance of the generic type orig for the type
+// arguments targs. There is a single instance for identical type
+// arguments. An instance for the type parameters of orig themselves,
+// as Stack[T] in the methods of Stack, is orig.
+func (check *checker) instance(orig *Named, targs []Type) *Named {
+	identity := true
+	for i, targ := range targs {
+		if targ != orig.tparams[i] {
+			identity = false
+		}
+	}
+	if identity {
+		return orig
+	}
+	for _, inst := range check.insts[orig] {
+		if identicalList(inst.targs, targs) {
+			return inst
+		}
+	}
+	inst := &Named{obj: orig.obj, orig: orig, targs: targs}
+	check.insts[orig] = append(check.insts[orig], inst)
+	// the instances of a type still being declared are
+	// expanded once its declaration is complete
+	if check.color[orig.obj] == black {
+		check.expand(inst)
+	}
+	return inst
+}
+
+// expand sets the underlying type and the methods of the instance inst
+// from those of its generic type.
+func (check *checker) expand(inst *Named) {
+	orig := inst.orig
+	if check.depth >= maxInstDepth {
+		check.errorf(orig.obj.pos, "instantiation cycle in %s", inst)
+		inst.underlying = Typ[Invalid]
+		return
+	}
+	check.depth++
+	defer func() { check.depth-- }()
+
+	smap := makeSubstMap(orig.tparams, inst.targs)
+	inst.underlying = check.subst(orig.underlying, smap)
+	for _, m := range orig.methods {
+		sig := m.typ.(*Signature)
+		isig := check.subst(NewSignature(sig.params, sig.result), smap).(*Signature)
+		if isig == sig {
+			isig = NewSignature(sig.params, sig.result)
+		}
+		var recv Type = inst
+		if _, ok := sig.recv.typ.(*Pointer); ok {
+			recv = NewPointer(inst)
+		}
+		isig.recv = NewVar(sig.recv.pos, sig.recv.name, recv)
+		im := NewFunc(m.pos, m.name, isig)
+		im.decl = m.decl
+		inst.methods = append(inst.methods, im)
+	}
+	if !isParameterizedList(inst.targs) {
+		check.typeInstance(inst, smap)
+	}
+}
+
+// recordInstance records the instantiation of the generic function
+// or type denoted by name.
+func (check *checker) recordInstance(name *ast.Name, targs []Type, typ Type, decl *ast.FuncDecl) {
+	check.info.Instances[name] = Instance{targs, typ, decl}
+}
+
+// ----------------------------------------------------------------------------
+// Inference
+
+// infer infers the type arguments for the generic function sig from the
+// arguments args of call. It reports an error and returns nil if that
+// is not possible. Typed arguments are considered first; a type
+// parameter that remains is inferred from the untyped arguments of its
+// type as the default type of the largest of them.
+func (check *checker) infer(call *ast.CallExpr, sig *Signature, args []*operand) []Type {
+	targs := make([]Type, len(sig.tparams))
+	for i, a := range args {
+		if a.mode == invalid {
+			return nil
+		}
+		if isUntyped(a.typ) {
+			continue
+		}
+		par := sig.params[i].typ
+		if !unify(sig.tparams, targs, par, a.typ) {
+			check.errorf(a.expr.GetPos(), "type %s of %s does not match %s (cannot infer %s)", a.typ, ExprString(a.expr), par, tparamList(sig.tparams))
+			return nil
+		}
+	}
+
+	untyped := make([]Type, len(targs))
+	for i, a := range args {
+		tpar, _ := sig.params[i].typ.(*TypeParam)
+		if tpar == nil || !isUntyped(a.typ) || !isTypeParam(sig.tparams, tpar) || targs[tpar.index] != nil {
+			continue
+		}
+		j := tpar.index
+		if untyped[j] == nil {
+			untyped[j] = a.typ
+			continue
+		}
+		t := largerUntyped(untyped[j], a.typ)
+		if t == nil {
+			check.errorf(a.expr.GetPos(), "mismatched types %s and %s (cannot infer %s)", untyped[j], a.typ, tpar)
+			return nil
+		}
+		untyped[j] = t
+	}
+	for j, t := range untyped {
+		if t != nil {
+			targs[j] = Default(t)
+		}
+	}
+
+	for j, targ := range targs {
+		if targ == nil {
+			check.errorf(call.GetPos(), "in call to %s, cannot infer %s", ExprString(call.Func), sig.tparams[j])
+			return nil
+		}
+	}
+	return targs
+}
+
+// unify matches the parameter type par against the argument type arg
+// and records the types that the type parameters tparams in par
+// correspond to in targs. It reports whether the types match;
+// other mismatches are reported by the assignment of the argument.
+func unify(tparams []*TypeParam, targs []Type, par, arg Type) bool {
+	switch p := par.(type) {
+	case *TypeParam:
+		if isTypeParam(tparams, p) {
+			if targs[p.index] == nil {
+				targs[p.index] = arg
+				return true
+			}
+			return Identical(targs[p.index], arg)
+		}
+	case *Slice:
+		if a, ok := arg.Underlying().(*Slice); ok {
+			return unify(tparams, targs, p.elem, a.elem)
+		}
+	case *Map:
+		if a, ok := arg.Underlying().(*Map); ok {
+			return unify(tparams, targs, p.key, a.key) && unify(tparams, targs, p.elem, a.elem)
+		}
+	case *Pointer:
+		if a, ok := arg.Underlying().(*Pointer); ok {
+			return unify(tparams, targs, p.base, a.base)
+		}
+	case *Named:
+		if a, ok := arg.(*Named); ok && p.orig != nil && a.orig == p.orig {
+			for i, targ := range p.targs {
+				if !unify(tparams, targs, targ, a.targs[i]) {
+					return false
+				}
+			}
+		}
+	case *Struct:
+		if a, ok := arg.Underlying().(*Struct); ok && len(a.fields) == len(p.fields) {
+			for i, f := range p.fields {
+				if !unify(tparams, targs, f.typ, a.fields[i].typ) {
+					return false
+				}
+			}
+		}
+	case *Tuple:
+		if a, ok := arg.(*Tuple); ok && len(a.vars) == len(p.vars) {
+			for i, v := range p.vars {
+				if !unify(tparams, targs, v.typ, a.vars[i].typ) {
+					return false
+				}
+			}
+		}
+	case *Signature:
+		if a, ok := arg.(*Signature); ok && len(a.params) == len(p.params) {
+			for i, v := range p.params {
+				if !unify(tparams, targs, v.typ, a.params[i].typ) {
+					return false
+				}
+			}
+			if p.result != nil && a.result != nil {
+				return unify(tparams, targs, p.result, a.result)
+			}
+		}
+	}
+	return true
+}
+
+// isTypeParam reports whether t is one of the type parameters tparams.
+func isTypeParam(tparams []*TypeParam, t *TypeParam) bool {
+	return t.index < len(tparams) && tparams[t.index] == t
+}
+
+// tparamList returns the names of the type parameters tparams.
+func tparamList(tparams []*TypeParam) string {
+	list := make([]string, len(tparams))
+	for i, t := range tparams {
+		list[i] = t.obj.name
+	}
+	return strings.Join(list, ", ")
+}
+
+// ----------------------------------------------------------------------------
+// Operators
+
+// A tparamOp records an operation or method call on an operand of type
+// parameter type that the constraint provides through a method named
+// like an operator. Depending on the type argument, an instance of the
+// operation calls the method or is an operation.
+type tparamOp struct {
+	recv, arg ast.Expr // operands; the method is called on recv
+	name      string   // name of the method
+	typ       Type     // type of recv
+}
+
+// tparamMethod returns the method with the given name of the
+// constraint of t, if t is a type parameter, or nil.
+func tparamMethod(t Type, name string) *Func {
+	if t, _ := t.(*TypeParam); t != nil {
+		if m := t.iface().method(name); m != nil && len(m.typ.(*Signature).params) == 1 {
+			return m
+		}
+	}
+	return nil
+}
+
+// tparamOp resolves the binary operation x op y of the node e to a method
+// of the constraint of a type parameter named like the operator, unless
+// the predeclared operator applies: x.add(y) for x + y or, if the type
+// of x provides no method add, y.radd(x). It reports whether the
+// operation was resolved; the result is left in x.
+func (check *checker) tparamOp(x, y *operand, op token.Operator, e ast.Node) bool {
+	oop, swapped := op.Overloaded()
+	if !oop.IsOperOverload() || oop.IsReversed() || hasPredeclaredOp(oop, x.typ, y.typ) {
+		return false
+	}
+	l, r := x, y
+	if swapped {
+		l, r = y, x // x < y is y.gtr(x)
+	}
+	recv, arg, name := l, r, oop.OperName()
+	m := tparamMethod(l.typ, name)
+	if m == nil {
+		recv, arg, name = r, l, (oop + token.Reverse).OperName()
+		m = tparamMethod(r.typ, name)
+	}
+	if m == nil {
+		return false
+	}
+
+	sig := m.typ.(*Signature)
+	check.assignment(arg, sig.params[0].typ, "operator "+name)
+	check.tparamOps[e] = &tparamOp{recv.expr, arg.expr, name, recv.typ}
+	if sig.result == nil {
+		x.mode = novalue
+	} else {
+		x.mode = value
+		x.typ = sig.result
+	}
+	return true
+}
@@ -0,0 +1,587 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements substitution of type arguments for type
+// parameters and the declarations of instances.
+//
+// For each instance whose type arguments do not mention type
+// parameters, the checker records a copy of the generic declaration in
+// Info.Copies, in which the type parameters are replaced by
+// type expressions for the type arguments, and checks the copy like an
+// ordinary declaration once the generic declaration is checked. The
+// copy of max for max[int] is named max[int], that of Stack for
+// Stack[int] is named Stack[int], and so are the receiver types of the
+// copies of the methods of Stack. Through the copies, instances have
+// operator overloads, methods and dynamic types recorded like any other
+// code, so that interpreters and code generators need not know about
+// type parameters.
+//
+// An operation that uses a constraint method named like an operator
+// (see generic.go) becomes a call of the method if the type argument
+// has the method; otherwise the operation remains and is resolved to
+// the operator overload of the type argument. Conversely, a call of
+// such a constraint method becomes an operation if the type argument
+// provides the operator as an overload rather than a method.
+
+package types
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"reflect"
+	"strings"
+)
+
+// A substMap maps type parameters to their type arguments.
+type substMap map[*TypeParam]Type
+
+func makeSubstMap(tparams []*TypeParam, targs []Type) substMap {
+	smap := make(substMap, len(tparams))
+	for i, tpar := range tparams {
+		smap[tpar] = targs[i]
+	}
+	return smap
+}
+
+// subst returns the type t with the type parameters in smap replaced by
+// their type arguments. Types that do not change are returned as is.
+func (check *checker) subst(t Type, smap substMap) Type {
+	switch t := t.(type) {
+	case *TypeParam:
+		if u, ok := smap[t]; ok {
+			return u
+		}
+
+	case *Slice:
+		if elem := check.subst(t.elem, smap); elem != t.elem {
+			return NewSlice(elem)
+		}
+
+	case *Map:
+		key, elem := check.subst(t.key, smap), check.subst(t.elem, smap)
+		if key != t.key || elem != t.elem {
+			return NewMap(key, elem)
+		}
+
+	case *Pointer:
+		if base := check.subst(t.base, smap); base != t.base {
+			return NewPointer(base)
+		}
+
+	case *Struct:
+		if fields, changed := check.substVars(t.fields, smap); changed {
+			return NewStruct(fields)
+		}
+
+	case *Interface:
+		var methods []*Func
+		changed := false
+		for _, m := range t.methods {
+			sig := check.subst(m.typ, smap)
+			if sig != m.typ {
+				changed = true
+				m = &Func{object{nil, m.pos, m.name, sig}, m.decl, m.spec}
+			}
+			methods = append(methods, m)
+		}
+		if changed {
+			return &Interface{methods, t.terms, t.comparable}
+		}
+
+	case *Tuple:
+		if vars, changed := check.substVars(t.vars, smap); changed {
+			return NewTuple(vars...)
+		}
+
+	case *Signature:
+		params, changed := check.substVars(t.params, smap)
+		var result Type
+		if t.result != nil {
+			result = check.subst(t.result, smap)
+		}
+		if changed || result != t.result {
+			sig := NewSignature(params, result)
+			sig.recv = t.recv
+			return sig
+		}
+
+	case *Named:
+		// a generic type in its own declaration denotes
+		// its instance for its own type parameters
+		targs := t.targs
+		orig := t.orig
+		if orig == nil {
+			if t.tparams == nil {
+				break
+			}
+			orig = t
+			targs = make([]Type, len(t.tparams))
+			for i, tpar := range t.tparams {
+				targs[i] = tpar
+			}
+		}
+		if list, changed := check.substList(targs, smap); changed {
+			return check.instance(orig, list)
+		}
+	}
+	return t
+}
+
+// substVars is like subst for the types of the variables in list.
+// If a type changes, it returns new variables.
+func (check *checker) substVars(list []*Var, smap substMap) ([]*Var, bool) {
+	var res []*Var
+	changed := false
+	for _, v := range list {
+		if typ := check.subst(v.typ, smap); typ != v.typ {
+			changed = true
+			v = &Var{object{nil, v.pos, v.name, typ}, v.isField}
+		}
+		res = append(res, v)
+	}
+	return res, changed
+}
+
+// substList is like subst for the types in list.
+func (check *checker) substList(list []Type, smap substMap) ([]Type, bool) {
+	res := make([]Type, len(list))
+	changed := false
+	for i, t := range list {
+		res[i] = check.subst(t, smap)
+		if res[i] != t {
+			changed = true
+		}
+	}
+	return res, changed
+}
+
+// isParameterized reports whether t mentions type parameters.
+func isParameterized(t Type) bool {
+	switch t := t.(type) {
+	case *TypeParam:
+		return true
+	case *Slice:
+		return isParameterized(t.elem)
+	case *Map:
+		return isParameterized(t.key) || isParameterized(t.elem)
+	case *Pointer:
+		return isParameterized(t.base)
+	case *Struct:
+		for _, f := range t.fields {
+			if isParameterized(f.typ) {
+				return true
+			}
+		}
+	case *Interface:
+		for _, m := range t.methods {
+			if isParameterized(m.typ) {
+				return true
+			}
+		}
+	case *Signature:
+		for _, p := range t.params {
+			if isParameterized(p.typ) {
+				return true
+			}
+		}
+		return t.result != nil && isParameterized(t.result)
+	case *Tuple:
+		for _, v := range t.vars {
+			if isParameterized(v.typ) {
+				return true
+			}
+		}
+	case *Named:
+		return t.tparams != nil || isParameterizedList(t.targs)
+	}
+	return false
+}
+
+// isParameterizedList reports whether any type in list mentions
+// type parameters.
+func isParameterizedList(list []Type) bool {
+	for _, t := range list {
+		if isParameterized(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// identicalList reports whether the types in x and y are identical.
+func identicalList(x, y []Type) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, t := range x {
+		if !Identical(t, y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// instName returns the name of the instance of the generic function
+// or type name for the type arguments targs, as max[int].
+func instName(name string, targs []Type) string {
+	list := make([]string, len(targs))
+	for i, t := range targs {
+		list[i] = t.String()
+	}
+	return name + "[" + strings.Join(list, ", ") + "]"
+}
+
+// ----------------------------------------------------------------------------
+// Instances
+
+// An instDecl is the declaration of an instance of a generic function.
+type instDecl struct {
+	targs []Type
+	decl  *ast.FuncDecl
+}
+
+// funcInstance returns the declaration of the instance of the generic
+// function fn for the type arguments targs, which must not mention type
+// parameters. The declaration is completed once fn is checked.
+func (check *checker) funcInstance(fn *Func, targs []Type) *ast.FuncDecl {
+	for _, inst := range check.funcInsts[fn] {
+		if identicalList(inst.targs, targs) {
+			return inst.decl
+		}
+	}
+	decl := new(ast.FuncDecl)
+	check.funcInsts[fn] = append(check.funcInsts[fn], instDecl{targs, decl})
+	if check.depth >= maxInstDepth {
+		check.errorf(fn.pos, "instantiation cycle in %s", instName(fn.name, targs))
+		return decl
+	}
+	orig := fn.decl
+	check.info.Copies[orig] = append(check.info.Copies[orig], decl)
+
+	smap := makeSubstMap(fn.typ.(*Signature).tparams, targs)
+	depth := check.depth + 1
+	check.later = append(check.later, func() {
+		if check.first != nil {
+			return // instances of erroneous code are not needed
+		}
+		defer func(d int) { check.depth = d }(check.depth)
+		check.depth = depth
+
+		*decl = *check.copyDecl(orig, smap).(*ast.FuncDecl)
+		decl.Name.Value = instName(fn.name, targs)
+		obj := NewFunc(decl.Name.GetPos(), decl.Name.Value, nil)
+		obj.decl = decl
+		check.recordDef(decl.Name, obj)
+		sig := check.funcType(decl.Param, decl.Return)
+		obj.typ = sig
+		check.funcBody(decl, nil, decl.Param, sig, decl.Body)
+	})
+	return decl
+}
+
+// typeInstance adds the declarations of the instance inst of a generic
+// type and of its methods, which are completed once the generic type
+// and its methods are checked.
+func (check *checker) typeInstance(inst *Named, smap substMap) {
+	orig := inst.orig
+	d, _ := check.decls[orig.obj].(*ast.TypeDecl)
+	if d == nil {
+		return
+	}
+	decl := new(ast.TypeDecl)
+	check.info.Copies[d] = append(check.info.Copies[d], decl)
+	methods := make([]*ast.FuncDecl, len(inst.methods))
+	for i, m := range inst.methods {
+		methods[i] = new(ast.FuncDecl)
+		md := orig.methods[i].decl
+		check.info.Copies[md] = append(check.info.Copies[md], methods[i])
+		m.decl = methods[i]
+	}
+
+	depth := check.depth + 1
+	check.later = append(check.later, func() {
+		if check.first != nil {
+			return // instances of erroneous code are not needed
+		}
+		defer func(d int, scope *Scope) { check.depth, check.scope = d, scope }(check.depth, check.scope)
+		check.depth, check.scope = depth, check.space
+
+		*decl = *check.copyDecl(d, smap).(*ast.TypeDecl)
+		decl.Name.Value = inst.String()
+		check.recordDef(decl.Name, NewTypeName(decl.Name.GetPos(), decl.Name.Value, inst))
+		check.recordType(decl.Name, inst)
+		check.typ(decl.Type)
+
+		for i, m := range inst.methods {
+			md := methods[i]
+			*md = *check.copyDecl(orig.methods[i].decl, smap).(*ast.FuncDecl)
+			check.recordDef(md.Name, m)
+			sig := check.funcType(md.Param, md.Return)
+			sig.recv = check.recv(md.Recv)
+			check.funcBody(md, md.Recv, md.Param, sig, md.Body)
+		}
+	})
+}
+
+// ----------------------------------------------------------------------------
+// Copies
+
+// copyDecl returns a copy of the generic declaration d in which the
+// type parameters are replaced according to smap. The copy has no type
+// parameters.
+func (check *checker) copyDecl(d ast.Decl, smap substMap) ast.Decl {
+	c := &copier{check, smap, make(map[ast.Node]ast.Node)}
+	switch d := c.copy(d).(type) {
+	case *ast.FuncDecl:
+		d.TParamList = nil
+		return d
+	case *ast.TypeDecl:
+		d.TParamList = nil
+		return d
+	}
+	panic("unreachable")
+}
+
+// A copier copies the syntax tree of a generic declaration.
+type copier struct {
+	check *checker
+	smap  substMap
+	seen  map[ast.Node]ast.Node // copies of the nodes copied so far
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// copy returns a copy of the syntax tree n. Nodes that occur in the
+// tree several times, such as the types shared by fields declared in
+// a list, are copied once.
+func (c *copier) copy(n ast.Node) ast.Node {
+	if n == nil || reflect.ValueOf(n).IsNil() {
+		return n
+	}
+	if x, ok := c.seen[n]; ok {
+		return x
+	}
+	x := c.rewrite(n)
+	if x == nil {
+		v := reflect.ValueOf(n).Elem()
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		c.copyFields(p.Elem())
+		x = p.Interface().(ast.Node)
+	}
+	c.seen[n] = x
+	return x
+}
+
+// copyFields replaces the children of the struct v by their copies.
+func (c *copier) copyFields(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch {
+		case f.Type().Implements(nodeType):
+			if !f.IsNil() {
+				f.Set(reflect.ValueOf(c.copy(f.Interface().(ast.Node))))
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem().Implements(nodeType) && !f.IsNil():
+			s := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			for j := 0; j < f.Len(); j++ {
+				if e := f.Index(j); !e.IsNil() {
+					s.Index(j).Set(reflect.ValueOf(c.copy(e.Interface().(ast.Node))))
+				}
+			}
+			f.Set(s)
+		}
+	}
+}
+
+// rewrite returns the copy of n if it differs from n by more than its
+// children: names of type parameters become type expressions for their
+// type arguments, and operations and calls using constraint methods
+// named like operators are rewritten according to the type arguments.
+// Otherwise rewrite returns nil.
+func (c *copier) rewrite(n ast.Node) ast.Node {
+	switch n := n.(type) {
+	case *ast.Name:
+		obj, _ := c.check.info.Uses[n].(*TypeName) // not the declarations of the type parameters
+		if obj == nil {
+			break
+		}
+		if tpar, _ := obj.typ.(*TypeParam); tpar != nil {
+			if t, ok := c.smap[tpar]; ok {
+				return c.check.typeExpr(t, n.GetPos())
+			}
+		}
+
+	case *ast.Operation:
+		if op := c.check.tparamOps[n]; op != nil && c.hasMethod(op) {
+			return c.call(op, c.copy(op.recv).(ast.Expr), c.copy(op.arg).(ast.Expr), n.GetPos())
+		}
+
+	case *ast.AssignStmt:
+		// x op= y becomes x = x.m(y), or x = y.rm(x); the copies of x
+		// as operand are separate from the assigned variable
+		if op := c.check.tparamOps[n]; op != nil && c.hasMethod(op) {
+			operand := func(x ast.Expr) ast.Expr {
+				if x == n.Lhs {
+					return (&copier{c.check, c.smap, make(map[ast.Node]ast.Node)}).copy(x).(ast.Expr)
+				}
+				return c.copy(x).(ast.Expr)
+			}
+			s := new(ast.AssignStmt)
+			s.Pos = n.Pos
+			s.Lhs = c.copy(n.Lhs).(ast.Expr)
+			s.Op = token.NoneOp
+			s.Rhs = c.call(op, operand(op.recv), operand(op.arg), n.GetPos())
+			return s
+		}
+
+	case *ast.CallExpr:
+		// x.add(y) becomes x + y and x.radd(y) becomes y + x
+		sel, _ := parser.Unparen(n.Func).(*ast.SelectorExpr)
+		if sel == nil || len(n.ArgList) != 1 {
+			break
+		}
+		t, ok := c.check.tparamSels[sel]
+		if !ok {
+			break
+		}
+		recv := c.check.subst(t, c.smap)
+		if lookupMethod(recv, sel.Sel.Value) != nil {
+			break
+		}
+		op := token.OperOrNil(sel.Sel.Value)
+		x, y := c.copy(sel.X).(ast.Expr), c.copy(n.ArgList[0]).(ast.Expr)
+		if op.IsReversed() {
+			op -= token.Reverse
+			x, y = y, x
+		}
+		e := new(ast.Operation)
+		e.Pos = n.Pos
+		e.Op, e.X, e.Y = op, x, y
+		return e
+	}
+	return nil
+}
+
+// hasMethod reports whether the type argument for the receiver of the
+// operation op has the method, rather than an operator overload.
+func (c *copier) hasMethod(op *tparamOp) bool {
+	return lookupMethod(c.check.subst(op.typ, c.smap), op.name) != nil
+}
+
+// call returns the call of the method of op on recv with argument arg.
+func (c *copier) call(op *tparamOp, recv, arg ast.Expr, pos position.Pos) *ast.CallExpr {
+	sel := new(ast.SelectorExpr)
+	sel.Pos = pos
+	sel.X = recv
+	sel.Sel = ast.NewName(pos, op.name)
+	call := new(ast.CallExpr)
+	call.Pos = pos
+	call.Func = sel
+	call.ArgList = []ast.Expr{arg}
+	return call
+}
+
+// typeExpr returns a type expression at pos that denotes the type t.
+// The type is recorded for the expression and used by the checker as is,
+// independent of the scope in which the expression occurs.
+func (check *checker) typeExpr(t Type, pos position.Pos) ast.Expr {
+	var x ast.Expr
+	switch t := t.(type) {
+	case *Basic:
+		name := ast.NewName(pos, t.name)
+		check.recordUse(name, Universe.Lookup(t.name))
+		x = name
+	case *Named:
+		name := ast.NewName(pos, t.obj.name)
+		check.recordUse(name, t.obj)
+		if t.targs == nil {
+			x = name
+			break
+		}
+		var list []ast.Expr
+		for _, targ := range t.targs {
+			list = append(list, check.typeExpr(targ, pos))
+		}
+		ix := new(ast.IndexExpr)
+		ix.X = name
+		ix.Index = list[0]
+		if len(list) > 1 {
+			l := new(ast.ListExpr)
+			l.ElemList = list
+			l.Pos = pos
+			ix.Index = l
+		}
+		x = ix
+	case *TypeParam:
+		x = ast.NewName(pos, t.obj.name)
+	case *Slice:
+		s := new(ast.SliceType)
+		s.Elem = check.typeExpr(t.elem, pos)
+		x = s
+	case *Map:
+		m := new(ast.MapType)
+		m.Key = check.typeExpr(t.key, pos)
+		m.Value = check.typeExpr(t.elem, pos)
+		x = m
+	case *Pointer:
+		p := new(ast.Operation)
+		p.Op = token.Mul
+		p.X = check.typeExpr(t.base, pos)
+		x = p
+	case *Struct:
+		s := new(ast.StructType)
+		for _, f := range t.fields {
+			s.FieldList = append(s.FieldList, check.field(ast.NewName(pos, f.name), f.typ, pos))
+		}
+		x = s
+	case *Interface:
+		it := new(ast.InterfaceType)
+		for _, m := range t.methods {
+			it.MethodList = append(it.MethodList, check.field(ast.NewName(pos, m.name), m.typ, pos))
+		}
+		x = it
+	case *Signature:
+		ft := new(ast.FuncType)
+		for _, p := range t.params {
+			var name *ast.Name
+			if p.name != "" {
+				name = ast.NewName(pos, p.name)
+			}
+			ft.Param = append(ft.Param, check.field(name, p.typ, pos))
+		}
+		if t.result != nil {
+			ft.Return = check.typeExpr(t.result, pos)
+		}
+		x = ft
+	case *Tuple:
+		l := new(ast.ListExpr)
+		for _, v := range t.vars {
+			l.ElemList = append(l.ElemList, check.typeExpr(v.typ, pos))
+		}
+		x = l
+	default:
+		panic("unreachable")
+	}
+	x.SetPos(pos)
+	check.typeArgs[x] = t
+	check.recordType(x, t)
+	return x
+}
+
+// field returns a field at pos with the given name and type.
+func (check *checker) field(name *ast.Name, t Type, pos position.Pos) *ast.Field {
+	f := new(ast.Field)
+	f.Pos = pos
+	f.Name = name
+	f.Type = check.typeExpr(t, pos)
+	return f
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements type-checking of interface types,
+// interface satisfaction and type assertions.
+//
+// A type implements an interface if it has all methods of the
+// interface. The methods of a defined type include its operator
+// overloads, under the name of their operator: given
+//
+//	type Adder interface { add(b Vec) Vec }
+//
+// a type Vec implements Adder if it has a method add(b Vec) Vec or,
+// lacking a method named add, an overload
+//
+//	oper (a Vec) add (b Vec) Vec { ... }
+//
+// This way, interfaces express which operators a type supports.
+// A value of a non-interface type that is converted to an interface
+// type, implicitly or explicitly, is recorded with its dynamic type
+// (see ast.Expr.Dynamic).
+
+package types
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+)
+
+// interfaceType type-checks the interface type e and returns its type.
+func (check *checker) interfaceType(e *ast.InterfaceType) *Interface {
+	var methods []*Func
+	seen := make(map[string]position.Pos)
+	for _, f := range e.MethodList {
+		t := f.Type.(*ast.FuncType)
+		m := NewFunc(f.Name.GetPos(), f.Name.Value, check.funcType(t.Param, t.Return))
+		m.spec = f
+		check.recordDef(f.Name, m)
+		check.recordType(t, m.typ)
+		name := f.Name.Value
+		if name == "_" {
+			check.errorf(m.pos, "methods must have a unique non-blank name")
+			continue
+		}
+		if alt, ok := seen[name]; ok {
+			check.errorf(m.pos, "duplicate method %s", name)
+			check.errorf(alt, "\tother declaration of %s", name)
+			continue
+		}
+		seen[name] = m.pos
+		methods = append(methods, m)
+	}
+	return NewInterface(methods)
+}
+
+// Implements reports whether type V implements the interface T.
+func Implements(V Type, T *Interface) bool {
+	m, _ := MissingMethod(V, T)
+	return m == nil
+}
+
+// MissingMethod returns a method of T that V does not have, or nil
+// if V implements T. If V has a method of that name with a different
+// signature, wrongType is set.
+func MissingMethod(V Type, T *Interface) (m *Func, wrongType bool) {
+	for _, m := range T.methods {
+		switch sig := lookupMethod(V, m.name); {
+		case sig == nil:
+			if !hasOper(V, m) {
+				return m, false
+			}
+		case !Identical(sig, m.typ):
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// lookupMethod returns the signature of the method of V with the given
+// name, or nil. Operator overloads are not included, nor are methods
+// with a pointer receiver unless V is a pointer.
+func lookupMethod(V Type, name string) *Signature {
+	switch t := V.(type) {
+	case *Named:
+		if m := t.method(name); m != nil && !ptrRecv(m) {
+			sig, _ := m.typ.(*Signature)
+			return sig
+		}
+	case *Pointer:
+		if t, _ := t.base.(*Named); t != nil {
+			if m := t.method(name); m != nil {
+				sig, _ := m.typ.(*Signature)
+				return sig
+			}
+		}
+	case *TypeParam:
+		if m := t.iface().method(name); m != nil {
+			return m.typ.(*Signature)
+		}
+	}
+	if t, _ := V.Underlying().(*Interface); t != nil {
+		if m := t.method(name); m != nil {
+			return m.typ.(*Signature)
+		}
+	}
+	return nil
+}
+
+// hasOper reports whether the defined type V has an overload of the
+// operator named like the method m, with the same operand and result.
+func hasOper(V Type, m *Func) bool {
+	t, _ := V.(*Named)
+	return t != nil && t.oper(m) != nil
+}
+
+// oper returns the overload of the operator named like the method m
+// with receiver t and the same operand and result as m, or nil.
+func (t *Named) oper(m *Func) *overload {
+	sig := m.typ.(*Signature)
+	for _, o := range t.opers {
+		if o.decl.Oper.OperName() == m.name && Identical(NewSignature(o.sig.params[1:], o.sig.result), sig) {
+			return o
+		}
+	}
+	return nil
+}
+
+// MethodDecl returns the declaration that implements the interface
+// method m for an interface value of the dynamic type V: the method
+// of V named like m, which is a *ast.FuncDecl, or lacking that, the
+// operator overload of V that provides m, which is a *ast.OperDecl.
+// The methods of a pointer type *T include those of T. MethodDecl
+// returns nil if V has neither.
+func MethodDecl(V Type, m *Func) ast.Decl {
+	t, _ := V.(*Named)
+	if p, _ := V.(*Pointer); p != nil {
+		t, _ = p.base.(*Named)
+	}
+	if t == nil {
+		return nil
+	}
+	if f := t.method(m.name); f != nil && f.decl != nil {
+		return f.decl
+	}
+	if V == t {
+		if o := t.oper(m); o != nil {
+			return o.decl
+		}
+	}
+	return nil
+}
+
+// hasPtrMethod reports whether the defined type V has a method with
+// the given name and a pointer receiver.
+func hasPtrMethod(V Type, name string) bool {
+	t, _ := V.(*Named)
+	if t == nil {
+		return false
+	}
+	m := t.method(name)
+	return m != nil && ptrRecv(m)
+}
+
+// implementsCause returns the reason why V does not implement T,
+// for use in error messages, or "".
+func implementsCause(V, T Type) string {
+	t, _ := T.Underlying().(*Interface)
+	if t == nil {
+		return ""
+	}
+	V = Default(V) // untyped values are converted to their default type
+	m, wrongType := MissingMethod(V, t)
+	switch {
+	case m == nil:
+		return ""
+	case wrongType:
+		have := lookupMethod(V, m.name)
+		return ": " + V.String() + " does not implement " + T.String() + " (wrong type for method " + m.name + ")\n\t\thave " +
+			m.name + have.String()[len("func"):] + "\n\t\twant " + m.name + m.typ.String()[len("func"):]
+	case hasPtrMethod(V, m.name):
+		return ": " + V.String() + " does not implement " + T.String() + " (method " + m.name + " has pointer receiver)"
+	}
+	return ": " + V.String() + " does not implement " + T.String() + " (missing method " + m.name + ")"
+}
+
+// box records the conversion of the value x to the interface type T
+// with x's type as dynamic type, unless x has an interface type itself.
+func (check *checker) box(x *operand, T Type) {
+	if x.mode != invalid && IsInterface(T) && !IsInterface(x.typ) {
+		check.info.Dynamic[x.expr] = x.typ
+	}
+}
+
+// typeAssertion type-checks the type assertion e.
+func (check *checker) typeAssertion(x *operand, e *ast.AssertExpr) {
+	check.expr(x, e.X)
+	T := check.typ(e.Type)
+	if x.mode == invalid {
+		return
+	}
+	t, _ := x.typ.Underlying().(*Interface)
+	if t == nil {
+		check.errorf(e.X.GetPos(), "invalid operation: %s (%s) is not an interface", ExprString(e.X), x.description())
+		x.mode = invalid
+		return
+	}
+	if T == Typ[Invalid] {
+		x.mode = invalid
+		return
+	}
+	// a non-interface type T must implement the interface
+	if !IsInterface(T) {
+		if cause := assertCause(T, t); cause != "" {
+			check.errorf(e.Type.GetPos(), "impossible type assertion: %s\n\t%s does not implement %s (%s)", ExprString(e), T, x.typ, cause)
+			x.mode = invalid
+			return
+		}
+	}
+	x.mode = commaok
+	x.typ = T
+}
+
+// assertCause returns the reason why a value of the interface type V
+// cannot hold a value of the non-interface type T, or "".
+func assertCause(T Type, V *Interface) string {
+	m, wrongType := MissingMethod(T, V)
+	switch {
+	case m == nil:
+		return ""
+	case wrongType:
+		return "wrong type for method " + m.name
+	case hasPtrMethod(T, m.name):
+		return "method " + m.name + " has pointer receiver"
+	}
+	return "missing method " + m.name
+}
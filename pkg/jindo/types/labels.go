@@ -0,0 +1,158 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements the checks of labels and of break and
+// continue statements.
+//
+// Labels have a name space of their own: they are declared in the
+// body of the function they occur in, but not in any of its blocks.
+// A label may be referred to by the break and continue statements
+// of the statement it labels; a break statement terminates a loop or
+// switch statement, and a continue statement continues a loop.
+
+package types
+
+import "jindo/pkg/jindo/ast"
+
+// A target is a loop or switch statement enclosing a break or
+// continue statement, with its label if it is labeled.
+type target struct {
+	stmt  ast.Stmt
+	label *ast.LabeledStmt // or nil
+}
+
+// labels checks the labels and the break and continue statements of
+// the function body, and records the statement each break or continue
+// statement refers to as its Target. The bodies of function literals
+// are checked on their own.
+func (check *checker) labels(body *ast.BlockStmt) {
+	var labels []*ast.LabeledStmt // in source order
+	all := make(map[string]*ast.LabeledStmt)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.LabeledStmt:
+			name := n.Label.Value
+			if alt := all[name]; alt != nil {
+				check.errorf(n.Label.GetPos(), "label %s already declared", name)
+				check.errorf(alt.Label.GetPos(), "\tother declaration of %s", name)
+				break
+			}
+			all[name] = n
+			labels = append(labels, n)
+		}
+		return true
+	})
+
+	used := make(map[*ast.LabeledStmt]bool)
+	var enclosing []target // innermost last
+
+	// find returns the innermost enclosing statement labeled label,
+	// or if label is nil the innermost enclosing loop or, unless
+	// loop is set, switch statement. The result is nil if there is
+	// no such statement, or if a labeled statement is not a loop
+	// but loop is set.
+	find := func(label *ast.Name, loop bool) ast.Stmt {
+		for i := len(enclosing) - 1; i >= 0; i-- {
+			t := enclosing[i]
+			_, isSwitch := t.stmt.(*ast.SwitchStmt)
+			if label == nil {
+				if !loop || !isSwitch {
+					return t.stmt
+				}
+				continue
+			}
+			if t.label != nil && t.label.Label.Value == label.Value {
+				if loop && isSwitch {
+					return nil
+				}
+				return t.stmt
+			}
+		}
+		return nil
+	}
+
+	// branch returns the statement that the break or continue
+	// statement kind with the given label refers to, or nil.
+	branch := func(s ast.Stmt, label *ast.Name, kind string) ast.Stmt {
+		loop := kind == "continue"
+		if label == nil {
+			t := find(nil, loop)
+			if t == nil {
+				if loop {
+					check.errorf(s.GetPos(), "continue is not in a loop")
+				} else {
+					check.errorf(s.GetPos(), "break is not in a loop or switch")
+				}
+			}
+			return t
+		}
+		l := all[label.Value]
+		if l == nil {
+			check.errorf(label.GetPos(), "label %s not declared", label.Value)
+			return nil
+		}
+		used[l] = true
+		t := find(label, loop)
+		if t == nil {
+			check.errorf(label.GetPos(), "invalid %s label %s", kind, label.Value)
+		}
+		return t
+	}
+
+	var stmt func(s ast.Stmt, label *ast.LabeledStmt)
+	stmtList := func(list []ast.Stmt) {
+		for _, s := range list {
+			stmt(s, nil)
+		}
+	}
+	stmt = func(s ast.Stmt, label *ast.LabeledStmt) {
+		switch s := s.(type) {
+		case *ast.LabeledStmt:
+			stmt(s.Stmt, s)
+
+		case *ast.BlockStmt:
+			stmtList(s.StmtList)
+
+		case *ast.IfStmt:
+			stmtList(s.Block.StmtList)
+			if s.Else != nil {
+				stmt(s.Else, nil)
+			}
+
+		case *ast.ForStmt:
+			enclosing = append(enclosing, target{s, label})
+			stmtList(s.Body.StmtList)
+			enclosing = enclosing[:len(enclosing)-1]
+
+		case *ast.WhileStmt:
+			enclosing = append(enclosing, target{s, label})
+			stmtList(s.Body.StmtList)
+			enclosing = enclosing[:len(enclosing)-1]
+
+		case *ast.SwitchStmt:
+			enclosing = append(enclosing, target{s, label})
+			for _, c := range s.Body {
+				stmtList(c.Body)
+			}
+			enclosing = enclosing[:len(enclosing)-1]
+
+		case *ast.BreakStmt:
+			check.info.Targets[s] = branch(s, s.Label, "break")
+
+		case *ast.ContinueStmt:
+			check.info.Targets[s] = branch(s, s.Label, "continue")
+		}
+	}
+	stmtList(body.StmtList)
+
+	for _, l := range labels {
+		if !used[l] {
+			check.errorf(l.Label.GetPos(), "label %s declared and not used", l.Label.Value)
+		}
+	}
+}
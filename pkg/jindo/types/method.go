@@ -0,0 +1,143 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements type-checking of method declarations.
+//
+// A method declaration
+//
+//	func (p Point) norm() float { ... }
+//
+// adds the method norm to the method set of the defined type Point.
+// Methods are associated with the type name of their receiver before
+// any space-level declaration is resolved, and added to the type once
+// its declaration is resolved, so that they may be declared anywhere
+// in the file. Operator overloads are not methods: a type may have
+// both a method add and an overload of the operator add.
+
+package types
+
+import (
+	"jindo/pkg/jindo/ast"
+)
+
+// collectMethod associates the method m with the type name of its
+// receiver, following alias declarations. Invalid receivers are
+// reported when the signature of m is resolved.
+func (check *checker) collectMethod(m *Func) {
+	typ, _ := m.decl.RecvType()
+	if x, ok := typ.(*ast.IndexExpr); ok {
+		typ = x.X // generic receiver type
+	}
+	seen := make(map[*TypeName]bool)
+	for {
+		name, _ := typ.(*ast.Name)
+		if name == nil {
+			return
+		}
+		obj, _ := check.space.Lookup(name.Value).(*TypeName)
+		if obj == nil || seen[obj] {
+			return
+		}
+		seen[obj] = true
+		d, _ := check.decls[obj].(*ast.TypeDecl)
+		if d == nil {
+			return
+		}
+		if !d.Alias {
+			check.methods[obj] = append(check.methods[obj], m)
+			return
+		}
+		typ = d.Type
+	}
+}
+
+// addMethods adds the methods collected for the type name of t to t.
+func (check *checker) addMethods(t *Named) {
+	list := check.methods[t.obj]
+	delete(check.methods, t.obj)
+
+	s, _ := t.underlying.(*Struct)
+	for _, m := range list {
+		if alt := t.method(m.name); alt != nil {
+			check.errorf(m.pos, "method %s.%s already declared", t.obj.name, m.name)
+			check.errorf(alt.pos, "\tother declaration of %s", m.name)
+			continue
+		}
+		if s != nil {
+			if i := s.fieldIndex(m.name); i >= 0 {
+				check.errorf(m.pos, "field and method with the same name %s", m.name)
+				check.errorf(s.fields[i].pos, "\tother declaration of %s", m.name)
+				continue
+			}
+		}
+		t.methods = append(t.methods, m)
+	}
+
+	// resolve the method signatures, which are
+	// needed to check interface satisfaction
+	for _, m := range t.methods {
+		check.objDecl(m)
+	}
+}
+
+// recv type-checks the receiver f of a method declaration.
+// The receiver type must be a type defined in the same file,
+// or a pointer to such a type.
+func (check *checker) recv(f *ast.Field) *Var {
+	var name string
+	pos := f.Type.GetPos()
+	if f.Name != nil {
+		name, pos = f.Name.Value, f.Name.GetPos()
+	}
+	typ := check.typ(f.Type)
+
+	base := typ
+	if p, _ := typ.(*Pointer); p != nil {
+		base = p.base
+	}
+	switch t := base.(type) {
+	case *Named:
+		if check.decls[t.obj] == nil {
+			check.errorf(f.Type.GetPos(), "cannot define new methods on non-local type %s", t)
+		} else if _, ok := t.underlying.(*Pointer); ok {
+			check.errorf(f.Type.GetPos(), "invalid receiver type %s (pointer type)", t)
+		} else if IsInterface(t) {
+			check.errorf(f.Type.GetPos(), "invalid receiver type %s (interface type)", t)
+		}
+	case *Basic:
+		if t != Typ[Invalid] {
+			check.errorf(f.Type.GetPos(), "cannot define new methods on non-local type %s", t)
+		}
+	default:
+		check.errorf(f.Type.GetPos(), "invalid receiver type %s", t)
+	}
+	return NewVar(pos, name, typ)
+}
+
+// ptrRecv reports whether the method m has a pointer receiver.
+// Methods with a pointer receiver are in the method set of the
+// pointer type only.
+func ptrRecv(m *Func) bool {
+	if sig, _ := m.typ.(*Signature); sig != nil && sig.recv != nil {
+		_, ok := sig.recv.typ.(*Pointer)
+		return ok
+	}
+	_, ptr := m.decl.RecvType()
+	return ptr
+}
+
+// method type-checks the selection of the method m of the operand x
+// in the selector e. The result is a method value whose signature is
+// that of m without the receiver.
+func (check *checker) method(x *operand, e *ast.SelectorExpr, m *Func) {
+	check.objDecl(m) // the signature of m may not be resolved yet
+	check.recordUse(e.Sel, m)
+	check.info.Methods[e] = m.decl
+
+	sig := m.typ.(*Signature)
+	x.mode = value
+	x.typ = NewSignature(sig.params, sig.result)
+}
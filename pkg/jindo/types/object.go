@@ -0,0 +1,141 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import (
+	"go/constant"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+)
+
+// An Object describes a named language entity such as
+// a type, variable, function or builtin.
+type Object interface {
+	Name() string      // object name
+	Type() Type        // object type
+	Pos() position.Pos // position of object identifier in declaration
+	Parent() *Scope    // scope in which this object is declared; nil for fields and parameters of signatures
+
+	setParent(*Scope)
+}
+
+// An object implements the common parts of an Object.
+type object struct {
+	parent *Scope
+	pos    position.Pos
+	name   string
+	typ    Type
+}
+
+func (obj *object) Name() string            { return obj.name }
+func (obj *object) Type() Type              { return obj.typ }
+func (obj *object) Pos() position.Pos       { return obj.pos }
+func (obj *object) Parent() *Scope          { return obj.parent }
+func (obj *object) setParent(parent *Scope) { obj.parent = parent }
+
+// A TypeName represents a name for a (defined or alias) type.
+type TypeName struct {
+	object
+}
+
+// NewTypeName returns a new type name denoting the given typ.
+func NewTypeName(pos position.Pos, name string, typ Type) *TypeName {
+	return &TypeName{object{nil, pos, name, typ}}
+}
+
+// A Var represents a declared variable (including function parameters
+// and struct fields).
+type Var struct {
+	object
+	isField bool // var is a struct field
+}
+
+// NewVar returns a new variable.
+func NewVar(pos position.Pos, name string, typ Type) *Var {
+	return &Var{object: object{nil, pos, name, typ}}
+}
+
+// NewField returns a new struct field.
+func NewField(pos position.Pos, name string, typ Type) *Var {
+	return &Var{object: object{nil, pos, name, typ}, isField: true}
+}
+
+// IsField reports whether the variable is a struct field.
+func (obj *Var) IsField() bool { return obj.isField }
+
+// A Const represents a declared constant.
+type Const struct {
+	object
+	val constant.Value
+}
+
+// NewConst returns a new constant with value val.
+func NewConst(pos position.Pos, name string, typ Type, val constant.Value) *Const {
+	return &Const{object{nil, pos, name, typ}, val}
+}
+
+// Val returns the constant's value.
+func (obj *Const) Val() constant.Value { return obj.val }
+
+// A Func represents a declared function or method, or
+// a method of an interface type.
+type Func struct {
+	object
+	decl *ast.FuncDecl
+	spec *ast.Field // method spec of an interface method, or nil
+}
+
+// NewFunc returns a new function with the given signature.
+func NewFunc(pos position.Pos, name string, sig *Signature) *Func {
+	var typ Type
+	if sig != nil {
+		typ = sig
+	}
+	return &Func{object: object{nil, pos, name, typ}}
+}
+
+// Decl returns the declaration of function obj, or nil.
+func (obj *Func) Decl() *ast.FuncDecl { return obj.decl }
+
+// Nil represents the predeclared value nil.
+type Nil struct {
+	object
+}
+
+// A Builtin represents a built-in function.
+// Builtins don't have a valid type.
+type Builtin struct {
+	object
+	id builtinId
+}
+
+// ObjectString returns the string form of obj as used in error messages.
+func ObjectString(obj Object) string {
+	switch obj := obj.(type) {
+	case *TypeName:
+		return "type " + obj.name
+	case *Var:
+		if obj.isField {
+			return "field " + obj.name + " " + obj.typ.String()
+		}
+		return "var " + obj.name + " " + obj.typ.String()
+	case *Const:
+		return "const " + obj.name + " " + obj.typ.String() + " = " + obj.val.String()
+	case *Func:
+		if obj.typ == nil {
+			return "func " + obj.name
+		}
+		name := obj.name
+		if recv := obj.typ.(*Signature).recv; recv != nil {
+			name = "(" + recv.typ.String() + ")." + name
+		}
+		return "func " + name + obj.typ.String()[len("func"):]
+	case *Builtin:
+		return "builtin " + obj.name
+	}
+	return obj.Name()
+}
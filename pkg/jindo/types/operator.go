@@ -0,0 +1,209 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements operator overload resolution.
+//
+// An operator declaration
+//
+//	oper (a T) add (b U) R { ... }
+//
+// defines the operation x + y for operands x of type T and y of
+// type U. The reversed form
+//
+//	oper (a T) radd (b U) R { ... }
+//
+// defines the operation y + x, with the receiver a on the right.
+// For a binary operation x op y, the checker first looks for an
+// overload of op whose receiver matches x, and falls back to the
+// reversed overload whose receiver matches y.
+
+package types
+
+import (
+	"fmt"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"strings"
+)
+
+// An overload describes an operator declaration.
+type overload struct {
+	decl *ast.OperDecl
+	sig  *Signature // receiver and operand as parameters
+}
+
+func (o *overload) String() string {
+	return fmt.Sprintf("oper (%s) %s (%s) %s", o.sig.params[0].typ, o.decl.Oper.OperName(), o.sig.params[1].typ, o.sig.result)
+}
+
+// operDecl type-checks the operator declaration d and records
+// it as an overload of its operator.
+func (check *checker) operDecl(d *ast.OperDecl) {
+	sig := check.funcType([]*ast.Field{d.TypeL, d.TypeR}, d.Return)
+	o := &overload{d, sig}
+
+	recv, operand := sig.params[0].typ, sig.params[1].typ
+	if recv == Typ[Invalid] || operand == Typ[Invalid] {
+		return // error reported before
+	}
+	if _, ok := recv.(*Basic); ok {
+		if _, ok := operand.(*Basic); ok {
+			check.errorf(d.GetPos(), "invalid operator declaration %s: receiver or operand must have a defined or composite type", o)
+			return
+		}
+	}
+
+	for _, alt := range check.opers[d.Oper] {
+		if Identical(alt.sig.params[0].typ, recv) && Identical(alt.sig.params[1].typ, operand) {
+			check.errorf(d.GetPos(), "operator %s redeclared for (%s, %s)", d.Oper.OperName(), recv, operand)
+			check.errorf(alt.decl.GetPos(), "\tother declaration of %s", alt)
+			return
+		}
+	}
+	check.opers[d.Oper] = append(check.opers[d.Oper], o)
+	if t, _ := recv.(*Named); t != nil {
+		t.opers = append(t.opers, o)
+	}
+
+	check.later = append(check.later, func() {
+		check.funcBody(d, nil, []*ast.Field{d.TypeL, d.TypeR}, sig, d.Body)
+	})
+}
+
+// overload resolves the binary operation x op y to an operator
+// overload. If there is exactly one matching overload, the operands
+// are converted to its parameter types, the result is left in x, and
+// the overload's declaration is returned. Otherwise overload returns
+// nil; if resolution failed with an error, x.mode is set to invalid.
+// The operation x < y resolves to an overload of > for y > x.
+func (check *checker) overload(x, y *operand, op token.Operator, pos position.Pos) *ast.OperDecl {
+	oop, swapped := op.Overloaded()
+	if !oop.IsOperOverload() || oop.IsReversed() {
+		return nil
+	}
+	l, r := x, y
+	if swapped {
+		l, r = y, x
+	}
+
+	reversed := false
+	cands := check.matchOverloads(oop, l.typ, r.typ)
+	if len(cands) == 0 {
+		reversed = true
+		cands = check.matchOverloads(oop+token.Reverse, r.typ, l.typ)
+	}
+
+	switch len(cands) {
+	case 0:
+		// No overload applies. Defer to the predeclared operator,
+		// unless that is not defined either while overloads of op
+		// exist for the operand types.
+		if related := check.relatedOverloads(oop, l.typ, r.typ); len(related) > 0 && !hasPredeclaredOp(oop, l.typ, r.typ) {
+			check.errorf(pos, "invalid operation: %s %s %s (no overload of %s for %s and %s)%s", ExprString(x.expr), op, ExprString(y.expr), oop, l.typ, r.typ, candidates(related))
+			x.mode = invalid
+		}
+		return nil
+	case 1:
+		// ok
+	default:
+		check.errorf(pos, "invalid operation: %s %s %s (ambiguous overload)%s", ExprString(x.expr), op, ExprString(y.expr), candidates(cands))
+		x.mode = invalid
+		return nil
+	}
+
+	o := cands[0]
+	recv, operand := l, r
+	if reversed {
+		recv, operand = r, l
+	}
+	check.assignment(recv, o.sig.params[0].typ, "operator "+o.decl.Oper.OperName())
+	check.assignment(operand, o.sig.params[1].typ, "operator "+o.decl.Oper.OperName())
+
+	if o.sig.result == nil {
+		x.mode = novalue
+	} else {
+		x.mode = value
+		x.typ = o.sig.result
+	}
+	return o.decl
+}
+
+// matchOverloads returns the overloads of op whose receiver accepts
+// an operand of type recv and whose operand accepts type operand.
+func (check *checker) matchOverloads(op token.Operator, recv, operand Type) []*overload {
+	var list []*overload
+	for _, o := range check.opers[op] {
+		if accepts(o.sig.params[0].typ, recv) && accepts(o.sig.params[1].typ, operand) {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// relatedOverloads returns the overloads of op that apply to
+// the left operand type x, or in reversed form to the right
+// operand type y.
+func (check *checker) relatedOverloads(op token.Operator, x, y Type) []*overload {
+	var list []*overload
+	for _, o := range check.opers[op] {
+		if Identical(o.sig.params[0].typ, x) {
+			list = append(list, o)
+		}
+	}
+	for _, o := range check.opers[op+token.Reverse] {
+		if Identical(o.sig.params[0].typ, y) {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// accepts reports whether a parameter of type T accepts an
+// operand of type V without reporting errors.
+func accepts(T, V Type) bool {
+	if isUntyped(V) {
+		return untypedConvertible(V, T)
+	}
+	return AssignableTo(V, T)
+}
+
+// hasPredeclaredOp reports whether the predeclared operator op may
+// apply to operands of type x and y.
+func hasPredeclaredOp(op token.Operator, x, y Type) bool {
+	switch {
+	case isUntyped(x) && isUntyped(y):
+		return true // checked by binaryOp
+	case isUntyped(x):
+		x = y
+	case isUntyped(y):
+		y = x
+	}
+	if !Identical(x, y) {
+		return false
+	}
+	switch op {
+	case token.Add:
+		return isBasic(x, IsNumeric|IsString)
+	case token.Sub, token.Mul, token.Div:
+		return isNumeric(x)
+	case token.Rem:
+		return isInteger(x)
+	case token.Eql:
+		return Comparable(x)
+	case token.Gtr:
+		return isOrdered(x)
+	}
+	return false
+}
+
+func candidates(list []*overload) string {
+	var buf strings.Builder
+	for _, o := range list {
+		fmt.Fprintf(&buf, "\n\tcandidate %s at %s", o, o.decl.GetPos())
+	}
+	return buf.String()
+}
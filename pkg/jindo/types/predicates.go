@@ -0,0 +1,241 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements commonly used type predicates.
+
+package types
+
+// isBasic reports whether t is a basic type with one of the properties
+// in info. For a type parameter, all types of its type set must be.
+func isBasic(t Type, info BasicInfo) bool {
+	if t, _ := t.(*TypeParam); t != nil {
+		return allTerms(t, func(u Type) bool { return isBasic(u, info) })
+	}
+	u, _ := t.Underlying().(*Basic)
+	return u != nil && u.info&info != 0
+}
+
+// allTerms reports whether f holds for all types in the type set of
+// the type parameter t. It is false for type sets that are not
+// restricted to a list of types.
+func allTerms(t *TypeParam, f func(Type) bool) bool {
+	terms := t.iface().terms
+	for _, term := range terms {
+		if !f(term) {
+			return false
+		}
+	}
+	return len(terms) > 0
+}
+
+func isBoolean(t Type) bool { return isBasic(t, IsBoolean) }
+func isInteger(t Type) bool { return isBasic(t, IsInteger) }
+func isFloat(t Type) bool   { return isBasic(t, IsFloat) }
+func isString(t Type) bool  { return isBasic(t, IsString) }
+func isNumeric(t Type) bool { return isBasic(t, IsNumeric) }
+func isOrdered(t Type) bool { return isBasic(t, IsOrdered) }
+
+// isConstType reports whether t is a type of constants.
+// Values of type parameter type are never constant.
+func isConstType(t Type) bool {
+	_, ok := t.Underlying().(*Basic)
+	return ok && isBasic(t, IsConstType)
+}
+
+// isUntyped reports whether t is the type of an untyped value.
+func isUntyped(t Type) bool {
+	b, _ := t.(*Basic)
+	return b != nil && b.info&IsUntyped != 0
+}
+
+// IsInterface reports whether t is an interface type.
+func IsInterface(t Type) bool {
+	_, ok := t.Underlying().(*Interface)
+	return ok
+}
+
+// hasNil reports whether nil is a value of type t.
+func hasNil(t Type) bool {
+	switch u := t.Underlying().(type) {
+	case *Pointer, *Slice, *Map, *Signature:
+		return true
+	case *Interface:
+		return u.IsMethodSet()
+	}
+	return false
+}
+
+// Comparable reports whether values of type T are comparable.
+func Comparable(T Type) bool {
+	switch t := T.Underlying().(type) {
+	case *TypeParam:
+		return t.iface().comparable || allTerms(t, Comparable)
+	case *Basic:
+		return t.kind != Invalid
+	case *Interface, *Pointer:
+		return true
+	case *Struct:
+		for _, f := range t.fields {
+			if !Comparable(f.typ) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Identical reports whether x and y are identical types.
+func Identical(x, y Type) bool {
+	if x == y {
+		return true
+	}
+	switch x := x.(type) {
+	case *Slice:
+		if y, ok := y.(*Slice); ok {
+			return Identical(x.elem, y.elem)
+		}
+	case *Map:
+		if y, ok := y.(*Map); ok {
+			return Identical(x.key, y.key) && Identical(x.elem, y.elem)
+		}
+	case *Pointer:
+		if y, ok := y.(*Pointer); ok {
+			return Identical(x.base, y.base)
+		}
+	case *Struct:
+		if y, ok := y.(*Struct); ok && len(x.fields) == len(y.fields) {
+			for i, f := range x.fields {
+				g := y.fields[i]
+				if f.name != g.name || !Identical(f.typ, g.typ) {
+					return false
+				}
+			}
+			return true
+		}
+	case *Interface:
+		if y, ok := y.(*Interface); ok && len(x.methods) == len(y.methods) {
+			for _, m := range x.methods {
+				n := y.method(m.name)
+				if n == nil || !Identical(m.typ, n.typ) {
+					return false
+				}
+			}
+			return true
+		}
+	case *Named:
+		// instances of the same generic type with
+		// identical type arguments are identical
+		if y, ok := y.(*Named); ok && x.orig != nil && x.orig == y.orig {
+			for i, targ := range x.targs {
+				if !Identical(targ, y.targs[i]) {
+					return false
+				}
+			}
+			return true
+		}
+	case *Tuple:
+		if y, ok := y.(*Tuple); ok && len(x.vars) == len(y.vars) {
+			for i, v := range x.vars {
+				if !Identical(v.typ, y.vars[i].typ) {
+					return false
+				}
+			}
+			return true
+		}
+	case *Signature:
+		if y, ok := y.(*Signature); ok {
+			if len(x.params) != len(y.params) {
+				return false
+			}
+			for i := range x.params {
+				if !Identical(x.params[i].typ, y.params[i].typ) {
+					return false
+				}
+			}
+			if x.result == nil || y.result == nil {
+				return x.result == y.result
+			}
+			return Identical(x.result, y.result)
+		}
+	}
+	return false
+}
+
+// AssignableTo reports whether a value of type V is assignable
+// to a variable of type T.
+func AssignableTo(V, T Type) bool {
+	if Identical(V, T) {
+		return true
+	}
+	if V == Typ[Invalid] || T == Typ[Invalid] {
+		return true // avoid follow-up errors
+	}
+	// T is an interface type and V implements T
+	if t, ok := T.Underlying().(*Interface); ok {
+		return Implements(V, t)
+	}
+	// V and T have identical underlying types
+	// and at least one of V or T is not a named type
+	return (!hasName(V) || !hasName(T)) && Identical(V.Underlying(), T.Underlying())
+}
+
+// hasName reports whether t has a name. This includes
+// predeclared types and defined types.
+func hasName(t Type) bool {
+	switch t.(type) {
+	case *Basic, *Named, *TypeParam:
+		return true
+	}
+	return false
+}
+
+// ConvertibleTo reports whether a value of type V is convertible
+// to a value of type T.
+func ConvertibleTo(V, T Type) bool {
+	if AssignableTo(V, T) {
+		return true
+	}
+	// for type parameters, the conversion must be valid
+	// for all types of their type sets
+	if t, _ := V.(*TypeParam); t != nil {
+		return allTerms(t, func(u Type) bool { return ConvertibleTo(u, T) })
+	}
+	if t, _ := T.(*TypeParam); t != nil {
+		return allTerms(t, func(u Type) bool { return ConvertibleTo(V, u) })
+	}
+	Vu, Tu := V.Underlying(), T.Underlying()
+	if Identical(Vu, Tu) {
+		return true
+	}
+	if isNumeric(Vu) && isNumeric(Tu) {
+		return true
+	}
+	if isInteger(Vu) && isString(Tu) {
+		return true
+	}
+	return false
+}
+
+// Default returns the default "typed" type for an "untyped" type;
+// it returns the incoming type for all other types.
+func Default(t Type) Type {
+	if t, ok := t.(*Basic); ok {
+		switch t.kind {
+		case UntypedBool:
+			return Typ[Bool]
+		case UntypedInt:
+			return Typ[Int]
+		case UntypedRune:
+			return Typ[Rune]
+		case UntypedFloat:
+			return Typ[Float]
+		case UntypedString:
+			return Typ[String]
+		}
+	}
+	return t
+}
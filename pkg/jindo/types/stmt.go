@@ -0,0 +1,805 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import (
+	"go/constant"
+	gotoken "go/token"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"strconv"
+	"strings"
+)
+
+func (check *checker) stmtList(list []ast.Stmt) {
+	for _, s := range list {
+		check.stmt(s)
+	}
+}
+
+func (check *checker) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case nil, *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.ExprStmt:
+		var x operand
+		check.rawExpr(&x, s.X, nil)
+		switch x.mode {
+		case invalid, novalue:
+			// ok or error reported before
+		case builtin:
+			check.errorf(s.GetPos(), "%s (built-in) must be called", ExprString(s.X))
+		case typexpr:
+			check.errorf(s.GetPos(), "%s (type) is not an expression", ExprString(s.X))
+		default:
+			if _, ok := parser.Unparen(s.X).(*ast.CallExpr); !ok {
+				check.errorf(s.GetPos(), "%s (%s) is not used", ExprString(s.X), x.description())
+			}
+		}
+
+	case *ast.DeclStmt:
+		check.declStmt(s.DeclList)
+
+	case *ast.DefineStmt:
+		check.defineStmt(s)
+
+	case *ast.AssignStmt:
+		check.assignStmt(s)
+
+	case *ast.RangeClause:
+		check.rangeClause(s)
+
+	case *ast.IncDecStmt:
+		var x operand
+		check.expr(&x, s.X)
+		if x.mode == invalid {
+			return
+		}
+		if !isNumeric(x.typ) {
+			check.errorf(s.GetPos(), "invalid operation: %s%s (non-numeric type %s)", ExprString(s.X), s.Op.String()+s.Op.String(), x.typ)
+			return
+		}
+		check.assignable(&x)
+
+	case *ast.ReturnStmt:
+		check.returnStmt(s)
+
+	case *ast.BreakStmt, *ast.ContinueStmt:
+		// checked with the labels of the function body
+
+	case *ast.LabeledStmt:
+		check.stmt(s.Stmt)
+
+	case *ast.BlockStmt:
+		check.openScope(s)
+		check.stmtList(s.StmtList)
+		check.closeScope()
+
+	case *ast.IfStmt:
+		check.openScope(s)
+		check.cond(s.Cond, "if statement")
+		check.stmt(s.Block)
+		if s.Else != nil {
+			check.stmt(s.Else)
+		}
+		check.closeScope()
+
+	case *ast.ForStmt:
+		check.openScope(s)
+		check.simpleStmt(s.Init)
+		if s.Cond != nil {
+			check.cond(s.Cond, "for loop")
+		}
+		check.simpleStmt(s.Post)
+		check.stmt(s.Body)
+		check.closeScope()
+
+	case *ast.WhileStmt:
+		check.openScope(s)
+		check.cond(s.Cond, "while loop")
+		check.stmt(s.Body)
+		check.closeScope()
+
+	case *ast.SwitchStmt:
+		check.multipleDefaults(s.Body)
+		if g, ok := s.Tag.(*ast.TypeSwitchGuard); ok {
+			check.typeSwitchStmt(s, g)
+		} else {
+			check.switchStmt(s)
+		}
+
+	default:
+		check.errorf(s.GetPos(), "invalid statement")
+	}
+}
+
+func (check *checker) simpleStmt(s ast.SimpleStmt) {
+	if s != nil {
+		check.stmt(s)
+	}
+}
+
+// cond checks that x is a boolean condition.
+func (check *checker) cond(x ast.Expr, context string) {
+	var y operand
+	check.expr(&y, x)
+	if y.mode != invalid && !isBoolean(y.typ) {
+		check.errorf(x.GetPos(), "non-boolean condition in %s", context)
+	}
+}
+
+func (check *checker) declStmt(list []ast.Decl) {
+	consts := constSpecs(list)
+	for _, d := range list {
+		switch d := d.(type) {
+		case *ast.ConstDecl:
+			obj := NewConst(d.NameList.GetPos(), d.NameList.Value, nil, nil)
+			check.constDecl(obj, consts[d])
+			// the scope of a local constant starts after its declaration
+			check.declare(check.scope, d.NameList, obj)
+		case *ast.VarDecl:
+			obj := NewVar(d.NameList.GetPos(), d.NameList.Value, nil)
+			check.varDecl(obj, d)
+			// the scope of a local variable starts after its declaration
+			check.declare(check.scope, d.NameList, obj)
+		default:
+			check.errorf(d.GetPos(), "invalid declaration in function body")
+		}
+	}
+}
+
+// exprList type-checks the expressions in list and returns an operand
+// for each value. A single call of a function with several results
+// provides an operand for each result, all with the call as expression;
+// tuple reports whether that is the case.
+func (check *checker) exprList(list []ast.Expr) (xs []*operand, tuple bool) {
+	if len(list) == 1 {
+		x := new(operand)
+		check.rawExpr(x, list[0], nil)
+		if t, ok := x.typ.(*Tuple); ok && x.mode == value {
+			for _, v := range t.vars {
+				xs = append(xs, &operand{mode: value, expr: x.expr, typ: v.typ})
+			}
+			return xs, true
+		}
+		check.singleValue(x)
+		return []*operand{x}, false
+	}
+	for _, e := range list {
+		x := new(operand)
+		check.expr(x, e)
+		xs = append(xs, x)
+	}
+	return xs, false
+}
+
+// assignMismatch reports an error if the number of values xs
+// for the expression list rhs differs from the number of variables n.
+func (check *checker) assignMismatch(n int, rhs ast.Expr, xs []*operand, tuple bool) bool {
+	if len(xs) == n {
+		return false
+	}
+	if len(xs) == 1 && xs[0].mode == invalid {
+		return true // error reported before
+	}
+	vars := plural(n, "variable")
+	if tuple {
+		check.errorf(rhs.GetPos(), "assignment mismatch: %s but %s returns %s", vars, ExprString(rhs), plural(len(xs), "value"))
+	} else {
+		check.errorf(rhs.GetPos(), "assignment mismatch: %s but %s", vars, plural(len(xs), "value"))
+	}
+	return true
+}
+
+func plural(n int, what string) string {
+	if n == 1 {
+		return "1 " + what
+	}
+	return strconv.Itoa(n) + " " + what + "s"
+}
+
+// tupleAssignment is like assignment for the value x of one of the
+// results of a function call. Such values are not converted to
+// interface types: there is no expression to record the conversion.
+func (check *checker) tupleAssignment(x *operand, T Type, context string) {
+	if x.mode != invalid && IsInterface(T) && !IsInterface(x.typ) {
+		check.errorf(x.expr.GetPos(), "cannot use %s (result of type %s) as %s value in %s (results of multiple-value calls are not converted to interface types)", ExprString(x.expr), x.typ, T, context)
+		x.mode = invalid
+		return
+	}
+	check.assignment(x, T, context)
+}
+
+// isCommaOk reports whether xs is the single operand of a map index
+// expression or type assertion, which provides two values when assigned
+// to two variables.
+func isCommaOk(xs []*operand) bool {
+	return len(xs) == 1 && (xs[0].mode == mapindex || xs[0].mode == commaok)
+}
+
+// commaOk returns the operands for the two values of the map index
+// expression or type assertion x: the element and whether the key is
+// present, or the asserted value and whether the assertion holds.
+func commaOk(x *operand) []*operand {
+	return []*operand{x, {mode: value, expr: x.expr, typ: Typ[UntypedBool]}}
+}
+
+// okAssignment is like assignment for the second value x of a map
+// index expression or type assertion. It is not converted to interface
+// types: the conversion recorded for the expression applies to the
+// first value.
+func (check *checker) okAssignment(x *operand, T Type, context string) {
+	if x.mode != invalid && IsInterface(T) {
+		check.errorf(x.expr.GetPos(), "cannot use %s (untyped bool value) as %s value in %s (the second result of a map index or type assertion is not converted to interface types)", ExprString(x.expr), T, context)
+		x.mode = invalid
+		return
+	}
+	check.assignment(x, T, context)
+}
+
+func (check *checker) defineStmt(s *ast.DefineStmt) {
+	lhs := parser.UnpackList(s.Lhs)
+	xs, tuple := check.exprList(parser.UnpackList(s.Rhs))
+	commaok := len(lhs) == 2 && isCommaOk(xs)
+	if commaok {
+		xs = commaOk(xs[0])
+	}
+	mismatch := check.assignMismatch(len(lhs), s.Rhs, xs, tuple)
+
+	// the scope of the new variables starts after the statement
+	var names []*ast.Name
+	var vars []*Var
+	seen := make(map[string]bool)
+	for i, e := range lhs {
+		name, _ := e.(*ast.Name)
+		if name == nil {
+			check.errorf(e.GetPos(), "non-name %s on left side of :=", ExprString(e))
+			continue
+		}
+		if seen[name.Value] {
+			check.errorf(name.GetPos(), "%s repeated on left side of :=", name.Value)
+			continue
+		}
+		seen[name.Value] = true
+
+		x := &operand{mode: invalid, typ: Typ[Invalid]}
+		if !mismatch {
+			x = xs[i]
+		}
+		if name.Value == "_" {
+			check.recordDef(name, nil)
+			check.defaultType(x, "assignment")
+			continue
+		}
+		if obj, _ := check.scope.Lookup(name.Value).(*Var); obj != nil && len(lhs) > 1 {
+			// redeclaration: assign to the variable of the same scope
+			check.recordUse(name, obj)
+			switch {
+			case tuple:
+				check.tupleAssignment(x, obj.typ, "assignment")
+			case commaok && i == 1:
+				check.okAssignment(x, obj.typ, "assignment")
+			default:
+				check.assignment(x, obj.typ, "assignment")
+			}
+			continue
+		} else if check.scope.Lookup(name.Value) != nil {
+			if len(lhs) > 1 {
+				check.errorf(name.GetPos(), "cannot assign to %s", name.Value)
+			}
+			continue // for a single name, there are no new variables
+		}
+		names = append(names, name)
+		vars = append(vars, NewVar(name.GetPos(), name.Value, check.defaultType(x, "assignment")))
+	}
+	if len(vars) == 0 && len(seen) == len(lhs) {
+		check.errorf(s.GetPos(), "no new variables on left side of :=")
+		return
+	}
+	for i, name := range names {
+		check.declare(check.scope, name, vars[i])
+	}
+}
+
+func (check *checker) assignStmt(s *ast.AssignStmt) {
+	if _, ok := s.Lhs.(*ast.ListExpr); ok {
+		check.assignList(s)
+		return
+	}
+	if _, ok := s.Rhs.(*ast.ListExpr); ok {
+		check.assignList(s)
+		return
+	}
+
+	var y operand
+	check.expr(&y, s.Rhs)
+
+	// blank identifier
+	if name, _ := s.Lhs.(*ast.Name); name != nil && name.Value == "_" && s.Op == token.NoneOp {
+		check.recordDef(name, nil)
+		check.defaultType(&y, "assignment")
+		return
+	}
+
+	var z operand
+	check.expr(&z, s.Lhs)
+	if z.mode == invalid || y.mode == invalid {
+		return
+	}
+	if !check.assignable(&z) {
+		return
+	}
+
+	if s.Op != token.NoneOp {
+		x := z
+		overload := false
+		if check.tparamOp(&x, &y, s.Op, s) {
+			// ok
+		} else if d := check.overload(&x, &y, s.Op, s.GetPos()); d != nil {
+			check.info.Overloads[s] = d
+			overload = true
+		} else if x.mode != invalid {
+			check.binaryOp(&x, &y, s.Op, s.GetPos())
+		}
+		if x.mode == invalid {
+			return
+		}
+		// there is no expression whose conversion to an interface
+		// type could be recorded for the result of an overload
+		if overload && IsInterface(z.typ) && !IsInterface(x.typ) {
+			check.errorf(s.GetPos(), "invalid operation: %s %s= %s (result of type %s is not converted to interface type %s)", ExprString(s.Lhs), s.Op, ExprString(s.Rhs), x.typ, z.typ)
+			return
+		}
+		y = x
+	}
+	check.assignment(&y, z.typ, "assignment")
+}
+
+// assignList checks the assignment s of several values, or of a
+// function result to several variables.
+func (check *checker) assignList(s *ast.AssignStmt) {
+	if s.Op != token.NoneOp {
+		check.errorf(s.GetPos(), "assignment operation %s= requires single-valued expressions", s.Op)
+		return
+	}
+	lhs := parser.UnpackList(s.Lhs)
+	xs, tuple := check.exprList(parser.UnpackList(s.Rhs))
+	commaok := len(lhs) == 2 && isCommaOk(xs)
+	if commaok {
+		xs = commaOk(xs[0])
+	}
+	mismatch := check.assignMismatch(len(lhs), s.Rhs, xs, tuple)
+	for i, e := range lhs {
+		x := &operand{mode: invalid, typ: Typ[Invalid]}
+		if !mismatch {
+			x = xs[i]
+		}
+		if name, _ := e.(*ast.Name); name != nil && name.Value == "_" {
+			check.recordDef(name, nil)
+			check.defaultType(x, "assignment")
+			continue
+		}
+		var z operand
+		check.expr(&z, e)
+		if z.mode == invalid || !check.assignable(&z) {
+			continue
+		}
+		switch {
+		case tuple:
+			check.tupleAssignment(x, z.typ, "assignment")
+		case commaok && i == 1:
+			check.okAssignment(x, z.typ, "assignment")
+		default:
+			check.assignment(x, z.typ, "assignment")
+		}
+	}
+}
+
+// assignable reports whether z denotes an assignable location,
+// and reports an error if not.
+func (check *checker) assignable(z *operand) bool {
+	if z.mode == variable || z.mode == mapindex {
+		return true
+	}
+	check.errorf(z.expr.GetPos(), "cannot assign to %s", ExprString(z.expr))
+	return false
+}
+
+func (check *checker) returnStmt(s *ast.ReturnStmt) {
+	var want []Type
+	switch res := check.sig.result.(type) {
+	case nil:
+		// no results
+	case *Tuple:
+		for _, v := range res.vars {
+			want = append(want, v.typ)
+		}
+	default:
+		want = []Type{res}
+	}
+
+	xs, tuple := check.exprList(parser.UnpackList(s.Result))
+	for _, x := range xs {
+		if x.mode == invalid {
+			return
+		}
+	}
+	if len(xs) != len(want) {
+		pos := s.GetPos()
+		if s.Result != nil {
+			pos = s.Result.GetPos()
+		}
+		msg := "not enough return values"
+		if len(xs) > len(want) {
+			msg = "too many return values"
+		}
+		have := make([]Type, len(xs))
+		for i, x := range xs {
+			have[i] = x.typ
+		}
+		check.errorf(pos, "%s\n\thave %s\n\twant %s", msg, typeList(have), typeList(want))
+		return
+	}
+	for i, x := range xs {
+		if tuple {
+			check.tupleAssignment(x, want[i], "return statement")
+		} else {
+			check.assignment(x, want[i], "return statement")
+		}
+	}
+}
+
+// typeList returns the parenthesized list of the types in list.
+func typeList(list []Type) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, t := range list {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(t.String())
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// ----------------------------------------------------------------------------
+// Range clauses
+
+// rangeClause checks the range clause s of a for loop and declares
+// its iteration variables in the scope of the loop. A slice yields
+// its indices and elements, a string the byte offsets and values of
+// its runes, and a map its keys and elements.
+func (check *checker) rangeClause(s *ast.RangeClause) {
+	var x operand
+	check.expr(&x, s.X)
+	var key, val Type = Typ[Invalid], Typ[Invalid]
+	if x.mode != invalid {
+		check.defaultType(&x, "range clause")
+		if t, _ := x.typ.Underlying().(*Slice); t != nil {
+			key, val = Typ[Int], t.elem
+		} else if t, _ := x.typ.Underlying().(*Map); t != nil {
+			key, val = t.key, t.elem
+		} else if isString(x.typ) {
+			key, val = Typ[Int], Typ[Rune]
+		} else {
+			check.errorf(s.X.GetPos(), "cannot range over %s (%s)", ExprString(s.X), x.description())
+		}
+	}
+
+	lhs := parser.UnpackList(s.Lhs)
+	if len(lhs) > 2 {
+		check.errorf(lhs[2].GetPos(), "range clause permits at most two iteration variables")
+		lhs = lhs[:2]
+	}
+	// the scope of the iteration variables starts at the loop body
+	var names []*ast.Name
+	var vars []*Var
+	seen := make(map[string]bool)
+	for i, e := range lhs {
+		name, _ := e.(*ast.Name)
+		if name == nil {
+			check.errorf(e.GetPos(), "non-name %s on left side of :=", ExprString(e))
+			continue
+		}
+		if seen[name.Value] && name.Value != "_" {
+			check.errorf(name.GetPos(), "%s repeated on left side of :=", name.Value)
+			continue
+		}
+		seen[name.Value] = true
+		if name.Value == "_" {
+			check.recordDef(name, nil)
+			continue
+		}
+		typ := key
+		if i == 1 {
+			typ = val
+		}
+		names = append(names, name)
+		vars = append(vars, NewVar(name.GetPos(), name.Value, typ))
+	}
+	for i, name := range names {
+		check.declare(check.scope, name, vars[i])
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Switch statements
+
+// multipleDefaults reports an error for each default clause
+// in list after the first one.
+func (check *checker) multipleDefaults(list []*ast.CaseClause) {
+	var first *ast.CaseClause
+	for _, c := range list {
+		if c.Cases != nil {
+			continue
+		}
+		if first != nil {
+			check.errorf(c.GetPos(), "multiple defaults (first at %s)", first.GetPos())
+			continue
+		}
+		first = c
+	}
+}
+
+// caseBody checks the statements of the case clause c in a scope of
+// their own. If v is not nil, it is declared in that scope.
+func (check *checker) caseBody(c *ast.CaseClause, v *Var) {
+	check.openScope(c)
+	if v != nil {
+		check.declare(check.scope, nil, v)
+		check.info.Implicits[c] = v
+	}
+	check.stmtList(c.Body)
+	check.closeScope()
+}
+
+// A caseValue is a constant case value of an expression switch.
+type caseValue struct {
+	pos position.Pos
+	val constant.Value
+	typ Type
+}
+
+// switchStmt checks the expression switch s. The case values are
+// compared with the tag like the operands of ==; in a switch without
+// tag, they must be boolean.
+func (check *checker) switchStmt(s *ast.SwitchStmt) {
+	var x operand
+	if s.Tag != nil {
+		check.expr(&x, s.Tag)
+		check.defaultType(&x, "switch expression")
+		if x.mode != invalid && !Comparable(x.typ) {
+			check.errorf(s.Tag.GetPos(), "cannot switch on %s (%s is not comparable)", ExprString(s.Tag), x.typ)
+			x.mode = invalid
+		}
+	}
+
+	var seen []caseValue
+	var concrete []ast.Expr // case values of non-interface types
+	for _, c := range s.Body {
+		for _, e := range parser.UnpackList(c.Cases) {
+			var v operand
+			check.expr(&v, e)
+			if v.mode == invalid {
+				continue
+			}
+			if s.Tag == nil {
+				if !isBoolean(v.typ) {
+					check.errorf(e.GetPos(), "invalid case %s in switch (mismatched types %s and bool)", ExprString(e), v.typ)
+					continue
+				}
+				check.convertUntyped(&v, Typ[Bool])
+			} else {
+				if x.mode == invalid {
+					continue
+				}
+				check.convertUntyped(&v, x.typ)
+				if v.mode == invalid {
+					continue
+				}
+				res, y := v, x
+				if check.comparison(&res, &y, token.Eql, e.GetPos(), true); res.mode == invalid {
+					continue
+				}
+				if !IsInterface(v.typ) {
+					concrete = append(concrete, e)
+				}
+			}
+			if v.mode != constant_ {
+				continue
+			}
+			dup := false
+			for _, prev := range seen {
+				if Identical(v.typ, prev.typ) && constant.Compare(v.val, gotoken.EQL, prev.val) {
+					check.errorf(e.GetPos(), "duplicate case %s in expression switch", ExprString(e))
+					check.errorf(prev.pos, "\tprevious case")
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				seen = append(seen, caseValue{e.GetPos(), v.val, v.typ})
+			}
+		}
+		check.caseBody(c, nil)
+	}
+
+	// If a case value of an interface type converted the tag to
+	// that type, all case values are compared as interface values.
+	if s.Tag != nil && check.info.Dynamic[s.Tag] != nil {
+		for _, e := range concrete {
+			if check.info.Dynamic[e] == nil {
+				check.info.Dynamic[e] = check.info.TypeOf(e)
+			}
+		}
+	}
+}
+
+// typeSwitchStmt checks the type switch s with the guard g. The case
+// values are types, or nil for an interface value without dynamic type,
+// and the variable declared by the guard, if any, has the type of the
+// case in clauses listing exactly one type other than nil, and the type
+// of g.X otherwise.
+func (check *checker) typeSwitchStmt(s *ast.SwitchStmt, g *ast.TypeSwitchGuard) {
+	var x operand
+	check.expr(&x, g.X)
+	var iface *Interface
+	if x.mode != invalid {
+		if iface, _ = x.typ.Underlying().(*Interface); iface == nil {
+			check.errorf(g.X.GetPos(), "%s (%s) is not an interface", ExprString(g.X), x.description())
+			x.mode = invalid
+		}
+	}
+	if g.Lhs != nil && g.Lhs.Value == "_" {
+		check.errorf(g.Lhs.GetPos(), "no new variable on left side of :=")
+	}
+
+	type caseType struct {
+		pos position.Pos
+		typ Type
+	}
+	var seen []caseType
+	var nilCase ast.Expr // the nil case, if any
+	for _, c := range s.Body {
+		list := parser.UnpackList(c.Cases)
+		single := x.typ // type of the variable in the clause
+		for _, e := range list {
+			if check.isNilCase(e) {
+				if nilCase != nil {
+					check.errorf(e.GetPos(), "multiple nil cases in type switch")
+					check.errorf(nilCase.GetPos(), "\tprevious case")
+				} else {
+					nilCase = e
+				}
+				continue
+			}
+			T := check.typ(e)
+			if len(list) == 1 {
+				single = T
+			}
+			if T == Typ[Invalid] {
+				continue
+			}
+			dup := false
+			for _, prev := range seen {
+				if Identical(T, prev.typ) {
+					check.errorf(e.GetPos(), "duplicate case %s in type switch", T)
+					check.errorf(prev.pos, "\tprevious case")
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				seen = append(seen, caseType{e.GetPos(), T})
+			}
+			if x.mode != invalid && !IsInterface(T) {
+				if cause := assertCause(T, iface); cause != "" {
+					check.errorf(e.GetPos(), "impossible type switch case: %s\n\t%s (%s) cannot have dynamic type %s (%s)", ExprString(e), ExprString(g.X), x.description(), T, cause)
+				}
+			}
+		}
+		var v *Var
+		if g.Lhs != nil {
+			v = NewVar(g.Lhs.GetPos(), g.Lhs.Value, single)
+		}
+		check.caseBody(c, v)
+	}
+}
+
+// isNilCase reports whether the case e of a type switch is the
+// predeclared nil, and records it as an expression if so.
+func (check *checker) isNilCase(e ast.Expr) bool {
+	name, _ := parser.Unparen(e).(*ast.Name)
+	if name == nil {
+		return false
+	}
+	if _, obj := check.scope.LookupParent(name.Value); obj != universeNil {
+		return false
+	}
+	var x operand
+	check.expr(&x, e)
+	return true
+}
+
+// ----------------------------------------------------------------------------
+// Terminating statements
+
+// isTerminatingList reports whether the statement list ends in
+// a terminating statement.
+func (check *checker) isTerminatingList(list []ast.Stmt) bool {
+	// trailing empty statements are permitted - skip them
+	for i := len(list) - 1; i >= 0; i-- {
+		if _, ok := list[i].(*ast.EmptyStmt); !ok {
+			return check.isTerminating(list[i])
+		}
+	}
+	return false
+}
+
+// isTerminating reports whether s is a terminating statement.
+func (check *checker) isTerminating(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStmt:
+		return true
+
+	case *ast.BlockStmt:
+		return check.isTerminatingList(s.StmtList)
+
+	case *ast.IfStmt:
+		return s.Else != nil && check.isTerminating(s.Block) && check.isTerminating(s.Else)
+
+	case *ast.ForStmt:
+		if _, ok := s.Init.(*ast.RangeClause); ok {
+			return false
+		}
+		return s.Cond == nil && !check.hasBreakList(s.Body.StmtList, s)
+
+	case *ast.WhileStmt:
+		if n, ok := parser.Unparen(s.Cond).(*ast.Name); ok && n.Value == "true" {
+			return !check.hasBreakList(s.Body.StmtList, s)
+		}
+
+	case *ast.LabeledStmt:
+		return check.isTerminating(s.Stmt)
+
+	case *ast.SwitchStmt:
+		hasDefault := false
+		for _, c := range s.Body {
+			if c.Cases == nil {
+				hasDefault = true
+			}
+			if !check.isTerminatingList(c.Body) || check.hasBreakList(c.Body, s) {
+				return false
+			}
+		}
+		return hasDefault
+	}
+	return false
+}
+
+// hasBreakList reports whether the statement list contains a break
+// statement referring to the loop or switch statement s. It relies on
+// the targets of the break statements recorded by labels.
+func (check *checker) hasBreakList(list []ast.Stmt, s ast.Stmt) bool {
+	found := false
+	for _, x := range list {
+		ast.Inspect(x, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.BreakStmt:
+				found = found || check.info.Targets[n] == s
+			}
+			return !found
+		})
+	}
+	return found
+}
@@ -0,0 +1,261 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements type-checking of struct types,
+// composite literals and selectors.
+
+package types
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+)
+
+// structType type-checks the struct type e and returns its type.
+func (check *checker) structType(e *ast.StructType) *Struct {
+	var fields []*Var
+	seen := make(map[string]position.Pos)
+
+	var prev ast.Expr // type expression of the previous field
+	var typ Type
+	for _, f := range e.FieldList {
+		// fields declared in a list share the same type expression
+		if f.Type != prev {
+			typ = check.typ(f.Type)
+			prev = f.Type
+		}
+		fld := NewField(f.Name.GetPos(), f.Name.Value, typ)
+		if name := f.Name.Value; name != "_" {
+			if alt, ok := seen[name]; ok {
+				check.errorf(fld.pos, "%s redeclared", name)
+				check.errorf(alt, "\tother declaration of %s", name)
+				continue
+			}
+			seen[name] = fld.pos
+		}
+		check.recordDef(f.Name, fld)
+		fields = append(fields, fld)
+	}
+	return NewStruct(fields)
+}
+
+// contains reports whether the struct type t contains a field of type
+// named, directly or through the fields of nested struct types.
+func contains(t Type, named *Named) bool {
+	s, _ := t.(*Struct)
+	if s == nil {
+		return false
+	}
+	for _, f := range s.fields {
+		if f.typ == named {
+			return true
+		}
+		// the underlying type of a type still being declared is nil
+		if u := f.typ.Underlying(); u != nil && contains(u, named) {
+			return true
+		}
+	}
+	return false
+}
+
+// compositeLit type-checks the composite literal e. If e has no literal
+// type, its type is hint, the element type of the enclosing literal.
+func (check *checker) compositeLit(x *operand, e *ast.CompositeLit, hint Type) {
+	var typ Type
+	switch {
+	case e.Type != nil:
+		typ = check.typ(e.Type)
+	case hint != nil:
+		typ = hint
+	default:
+		check.errorf(e.GetPos(), "invalid composite literal type: missing type")
+		check.useElems(e.ElemList)
+		return
+	}
+
+	switch utyp := typ.Underlying().(type) {
+	case *Struct:
+		if e.NKeys > 0 {
+			// all elements must have keys
+			visited := make([]bool, len(utyp.fields))
+			for _, elem := range e.ElemList {
+				kv, _ := elem.(*ast.KeyValueExpr)
+				if kv == nil {
+					check.errorf(elem.GetPos(), "mixture of field:value and value elements in struct literal")
+					check.use(elem)
+					continue
+				}
+				key, _ := kv.Key.(*ast.Name)
+				if key == nil {
+					check.errorf(kv.Key.GetPos(), "invalid field name %s in struct literal", ExprString(kv.Key))
+					check.use(kv.Value)
+					continue
+				}
+				i := utyp.fieldIndex(key.Value)
+				if i < 0 {
+					check.errorf(key.GetPos(), "unknown field %s in struct literal of type %s", key.Value, typ)
+					check.use(kv.Value)
+					continue
+				}
+				fld := utyp.fields[i]
+				check.recordUse(key, fld)
+				if visited[i] {
+					check.errorf(key.GetPos(), "duplicate field name %s in struct literal", key.Value)
+				}
+				visited[i] = true
+				var y operand
+				check.exprWithHint(&y, kv.Value, fld.typ)
+				check.assignment(&y, fld.typ, "struct literal")
+			}
+		} else if len(e.ElemList) > 0 {
+			// no element must have a key
+			for i, elem := range e.ElemList {
+				if i >= len(utyp.fields) {
+					check.errorf(elem.GetPos(), "too many values in struct literal of type %s", typ)
+					check.useElems(e.ElemList[i:])
+					break
+				}
+				fld := utyp.fields[i]
+				var y operand
+				check.exprWithHint(&y, elem, fld.typ)
+				check.assignment(&y, fld.typ, "struct literal")
+			}
+			if len(e.ElemList) < len(utyp.fields) {
+				check.errorf(e.GetPos(), "too few values in struct literal of type %s", typ)
+			}
+		}
+
+	case *Slice:
+		for _, elem := range e.ElemList {
+			if kv, _ := elem.(*ast.KeyValueExpr); kv != nil {
+				check.errorf(kv.GetPos(), "invalid key in slice literal")
+				check.use(kv.Value)
+				continue
+			}
+			var y operand
+			check.exprWithHint(&y, elem, utyp.elem)
+			check.assignment(&y, utyp.elem, "slice literal")
+		}
+
+	case *Map:
+		seen := make(map[string]bool) // values of constant keys
+		for _, elem := range e.ElemList {
+			kv, _ := elem.(*ast.KeyValueExpr)
+			if kv == nil {
+				check.errorf(elem.GetPos(), "missing key in map literal")
+				check.use(elem)
+				continue
+			}
+			var k operand
+			check.exprWithHint(&k, kv.Key, utyp.key)
+			check.assignment(&k, utyp.key, "map literal")
+			if k.mode == constant_ && !IsInterface(utyp.key) {
+				val := k.val.ExactString()
+				if seen[val] {
+					check.errorf(kv.Key.GetPos(), "duplicate key %s in map literal", ExprString(kv.Key))
+				}
+				seen[val] = true
+			}
+			var y operand
+			check.exprWithHint(&y, kv.Value, utyp.elem)
+			check.assignment(&y, utyp.elem, "map literal")
+		}
+
+	default:
+		if utyp != Typ[Invalid] {
+			check.errorf(e.GetPos(), "invalid composite literal type %s", typ)
+		}
+		check.useElems(e.ElemList)
+		return
+	}
+
+	x.mode = value
+	x.typ = typ
+}
+
+// useElems is like use for the elements of a composite literal,
+// whose keys are not expressions.
+func (check *checker) useElems(list []ast.Expr) {
+	for _, elem := range list {
+		if kv, _ := elem.(*ast.KeyValueExpr); kv != nil {
+			elem = kv.Value
+		}
+		if lit, _ := elem.(*ast.CompositeLit); lit != nil && lit.Type == nil {
+			check.useElems(lit.ElemList)
+			continue
+		}
+		check.use(elem)
+	}
+}
+
+// selector type-checks the selector e, which denotes a field or a method,
+// possibly of an interface.
+func (check *checker) selector(x *operand, e *ast.SelectorExpr) {
+	check.expr(x, e.X)
+	if x.mode == invalid {
+		return
+	}
+	// the fields and methods of the base type of a pointer
+	// are selected through the pointer
+	typ := x.typ
+	if p, _ := x.typ.Underlying().(*Pointer); p != nil {
+		typ = p.base
+	}
+	if s, _ := typ.Underlying().(*Struct); s != nil {
+		if i := s.fieldIndex(e.Sel.Value); i >= 0 {
+			fld := s.fields[i]
+			check.recordUse(e.Sel, fld)
+			// a field of a variable, or of the variable
+			// a pointer points to, is a variable
+			if typ != x.typ {
+				x.mode = variable
+			} else if x.mode != variable {
+				x.mode = value
+			}
+			x.typ = fld.typ
+			return
+		}
+	}
+	if _, ok := x.typ.(*Pointer); ok || typ == x.typ {
+		if t, _ := typ.(*Named); t != nil {
+			if m := t.method(e.Sel.Value); m != nil {
+				check.objDecl(m) // the receiver of m may not be resolved yet
+				if typ == x.typ && x.mode != variable && ptrRecv(m) {
+					check.errorf(e.GetPos(), "cannot call pointer method %s on %s", e.Sel.Value, x.typ)
+					x.mode = invalid
+					return
+				}
+				check.method(x, e, m)
+				return
+			}
+		}
+	}
+	if t, _ := x.typ.(*TypeParam); t != nil {
+		// a method of the constraint; those named like operators
+		// may be provided by operator overloads of type arguments
+		if m := t.iface().method(e.Sel.Value); m != nil {
+			check.recordUse(e.Sel, m)
+			if token.OperOrNil(m.name) != token.NoneOp {
+				check.tparamSels[e] = t
+			}
+			x.mode = value
+			x.typ = m.typ
+			return
+		}
+	}
+	if t, _ := x.typ.Underlying().(*Interface); t != nil {
+		if m := t.method(e.Sel.Value); m != nil {
+			check.recordUse(e.Sel, m)
+			check.info.Specs[e] = m.spec
+			x.mode = value
+			x.typ = m.typ
+			return
+		}
+	}
+	check.errorf(e.Sel.GetPos(), "%s undefined (type %s has no field or method %s)", ExprString(e), x.typ, e.Sel.Value)
+	x.mode = invalid
+}
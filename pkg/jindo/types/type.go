@@ -0,0 +1,439 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import "strings"
+
+// A Type represents a type of jindo.
+// All types implement the Type interface.
+type Type interface {
+	// Underlying returns the underlying type of a type.
+	Underlying() Type
+
+	// String returns a string representation of a type.
+	String() string
+}
+
+// BasicKind describes the kind of basic type.
+type BasicKind int
+
+const (
+	Invalid BasicKind = iota // type is invalid
+
+	// predeclared types
+	Bool
+	Int
+	Float
+	Rune
+	String
+
+	// types for untyped values
+	UntypedBool
+	UntypedInt
+	UntypedRune
+	UntypedFloat
+	UntypedString
+	UntypedNil
+)
+
+// BasicInfo is a set of flags describing properties of a basic type.
+type BasicInfo int
+
+// Properties of basic types.
+const (
+	IsBoolean BasicInfo = 1 << iota
+	IsInteger
+	IsFloat
+	IsString
+	IsUntyped
+
+	IsOrdered   = IsInteger | IsFloat | IsString
+	IsNumeric   = IsInteger | IsFloat
+	IsConstType = IsBoolean | IsNumeric | IsString
+)
+
+// A Basic represents a basic type.
+type Basic struct {
+	kind BasicKind
+	info BasicInfo
+	name string
+}
+
+// Kind returns the kind of basic type b.
+func (b *Basic) Kind() BasicKind { return b.kind }
+
+// Info returns information about properties of basic type b.
+func (b *Basic) Info() BasicInfo { return b.info }
+
+// Name returns the name of basic type b.
+func (b *Basic) Name() string { return b.name }
+
+// A Slice represents a slice type.
+type Slice struct {
+	elem Type
+}
+
+// NewSlice returns a new slice type for the given element type.
+func NewSlice(elem Type) *Slice { return &Slice{elem: elem} }
+
+// Elem returns the element type of slice s.
+func (s *Slice) Elem() Type { return s.elem }
+
+// A Map represents a map type.
+type Map struct {
+	key, elem Type
+}
+
+// NewMap returns a new map type for the given key and element types.
+func NewMap(key, elem Type) *Map { return &Map{key: key, elem: elem} }
+
+// Key returns the key type of map m.
+func (m *Map) Key() Type { return m.key }
+
+// Elem returns the element type of map m.
+func (m *Map) Elem() Type { return m.elem }
+
+// A Pointer represents a pointer type.
+type Pointer struct {
+	base Type
+}
+
+// NewPointer returns a new pointer type for the given base type.
+func NewPointer(base Type) *Pointer { return &Pointer{base: base} }
+
+// Elem returns the element type of pointer p.
+func (p *Pointer) Elem() Type { return p.base }
+
+// A Struct represents a struct type.
+type Struct struct {
+	fields []*Var
+}
+
+// NewStruct returns a new struct type with the given fields.
+func NewStruct(fields []*Var) *Struct { return &Struct{fields: fields} }
+
+// NumFields returns the number of fields in the struct s.
+func (s *Struct) NumFields() int { return len(s.fields) }
+
+// Field returns the i'th field of struct s for 0 <= i < NumFields().
+func (s *Struct) Field(i int) *Var { return s.fields[i] }
+
+// fieldIndex returns the index of the field named name, or -1.
+func (s *Struct) fieldIndex(name string) int {
+	if name != "_" {
+		for i, f := range s.fields {
+			if f.name == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// An Interface represents an interface type.
+type Interface struct {
+	methods []*Func // methods in source order
+
+	// The predeclared constraints comparable and ordered restrict their
+	// type sets further; such interfaces may only be used as constraints.
+	terms      []Type // types whose underlying type is in the type set; nil means all types
+	comparable bool   // the type set contains comparable types only
+}
+
+// NewInterface returns a new interface type for the given methods.
+func NewInterface(methods []*Func) *Interface { return &Interface{methods: methods} }
+
+// NumMethods returns the number of methods of interface t.
+func (t *Interface) NumMethods() int { return len(t.methods) }
+
+// Method returns the i'th method of interface t for 0 <= i < t.NumMethods().
+func (t *Interface) Method(i int) *Func { return t.methods[i] }
+
+// IsMethodSet reports whether the interface t is fully described by its
+// method set; otherwise t may only be used as a type constraint.
+func (t *Interface) IsMethodSet() bool { return t.terms == nil && !t.comparable }
+
+// method returns the method of t with the given name, or nil.
+func (t *Interface) method(name string) *Func {
+	for _, m := range t.methods {
+		if m.name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// A Tuple represents the ordered list of result types of a function
+// with several results. It is not a type of the language: it is only
+// the type of a call of such a function.
+type Tuple struct {
+	vars []*Var
+}
+
+// NewTuple returns a new tuple for the given variables.
+func NewTuple(vars ...*Var) *Tuple { return &Tuple{vars: vars} }
+
+// Len returns the number of variables of tuple t.
+func (t *Tuple) Len() int { return len(t.vars) }
+
+// At returns the i'th variable of tuple t.
+func (t *Tuple) At(i int) *Var { return t.vars[i] }
+
+// A Signature represents a function type.
+type Signature struct {
+	recv    *Var         // nil means not a method
+	tparams []*TypeParam // type parameters of a generic function, or nil
+	params  []*Var
+	result  Type   // nil means no result; a *Tuple for several results
+	scope   *Scope // scope of the type parameters of a generic function or method, or nil
+}
+
+// NewSignature returns a new function type for the given parameters and result.
+func NewSignature(params []*Var, result Type) *Signature {
+	return &Signature{params: params, result: result}
+}
+
+// Recv returns the receiver of signature s, or nil if s is not
+// the signature of a method.
+func (s *Signature) Recv() *Var { return s.recv }
+
+// TypeParams returns the type parameters of signature s, or nil.
+func (s *Signature) TypeParams() []*TypeParam { return s.tparams }
+
+// Params returns the parameters of signature s, or nil.
+func (s *Signature) Params() []*Var { return s.params }
+
+// Result returns the result type of signature s, or nil.
+func (s *Signature) Result() Type { return s.result }
+
+// A Named represents a declared type, or an instance of a generic
+// declared type.
+type Named struct {
+	obj        *TypeName    // corresponding declared object
+	underlying Type         // possibly a *Named during setup; never a *Named once set up completely
+	methods    []*Func      // methods declared for this type, in source order
+	opers      []*overload  // operator overloads with this type as receiver
+	tparams    []*TypeParam // type parameters of a generic type, or nil
+	orig       *Named       // generic type of an instance, or nil
+	targs      []Type       // type arguments of an instance, or nil
+}
+
+// NewNamed returns a new named type for the given type name and underlying type.
+func NewNamed(obj *TypeName, underlying Type) *Named {
+	t := &Named{obj: obj, underlying: underlying}
+	if obj.typ == nil {
+		obj.typ = t
+	}
+	return t
+}
+
+// Obj returns the type name for the declaration defining the named type t.
+func (t *Named) Obj() *TypeName { return t.obj }
+
+// TypeParams returns the type parameters of the generic type t, or nil.
+func (t *Named) TypeParams() []*TypeParam { return t.tparams }
+
+// Origin returns the generic type of which t is an instance,
+// or t itself if t is not an instance.
+func (t *Named) Origin() *Named {
+	if t.orig != nil {
+		return t.orig
+	}
+	return t
+}
+
+// TypeArgs returns the type arguments of the instance t, or nil.
+func (t *Named) TypeArgs() []Type { return t.targs }
+
+// NumMethods returns the number of methods declared for t.
+func (t *Named) NumMethods() int { return len(t.methods) }
+
+// Method returns the i'th method of t for 0 <= i < t.NumMethods().
+func (t *Named) Method(i int) *Func { return t.methods[i] }
+
+// method returns the method of t with the given name, or nil.
+func (t *Named) method(name string) *Func {
+	if name != "_" {
+		for _, m := range t.methods {
+			if m.name == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// A TypeParam represents a type parameter of a generic function or type.
+type TypeParam struct {
+	obj   *TypeName // corresponding type name
+	index int       // index in the type parameter list
+	bound Type      // constraint; an interface type, possibly defined; nil during setup
+}
+
+// NewTypeParam returns a new type parameter with the given index
+// and constraint.
+func NewTypeParam(obj *TypeName, index int, bound Type) *TypeParam {
+	t := &TypeParam{obj: obj, index: index, bound: bound}
+	if obj.typ == nil {
+		obj.typ = t
+	}
+	return t
+}
+
+// Obj returns the type name of the type parameter t.
+func (t *TypeParam) Obj() *TypeName { return t.obj }
+
+// Index returns the index of t in its type parameter list.
+func (t *TypeParam) Index() int { return t.index }
+
+// Constraint returns the constraint of t.
+func (t *TypeParam) Constraint() Type { return t.bound }
+
+// iface returns the constraint interface of t.
+func (t *TypeParam) iface() *Interface {
+	if t.bound != nil {
+		if u, _ := t.bound.Underlying().(*Interface); u != nil {
+			return u
+		}
+	}
+	return emptyInterface
+}
+
+// Implementations for Type methods.
+
+func (b *Basic) Underlying() Type     { return b }
+func (s *Slice) Underlying() Type     { return s }
+func (m *Map) Underlying() Type       { return m }
+func (p *Pointer) Underlying() Type   { return p }
+func (s *Struct) Underlying() Type    { return s }
+func (t *Interface) Underlying() Type { return t }
+func (s *Signature) Underlying() Type { return s }
+func (t *Named) Underlying() Type     { return t.underlying }
+func (t *TypeParam) Underlying() Type { return t }
+func (t *Tuple) Underlying() Type     { return t }
+
+func (b *Basic) String() string     { return b.name }
+func (s *Slice) String() string     { return "[]" + s.elem.String() }
+func (m *Map) String() string       { return "map[" + m.key.String() + "]" + m.elem.String() }
+func (p *Pointer) String() string   { return "*" + p.base.String() }
+func (t *TypeParam) String() string { return t.obj.name }
+
+func (t *Named) String() string {
+	if t.targs == nil && t.tparams == nil {
+		return t.obj.name
+	}
+	var b strings.Builder
+	b.WriteString(t.obj.name)
+	b.WriteByte('[')
+	if t.targs != nil {
+		for i, targ := range t.targs {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(targ.String())
+		}
+	} else {
+		for i, tpar := range t.tparams {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(tpar.obj.name)
+		}
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func (t *Tuple) String() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, v := range t.vars {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(v.typ.String())
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func (s *Struct) String() string {
+	var b strings.Builder
+	b.WriteString("struct{")
+	for i, f := range s.fields {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(f.name)
+		b.WriteByte(' ')
+		b.WriteString(f.typ.String())
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (t *Interface) String() string {
+	var b strings.Builder
+	b.WriteString("interface{")
+	var sep string
+	if t.comparable {
+		b.WriteString("comparable")
+		sep = "; "
+	}
+	if t.terms != nil {
+		b.WriteString(sep)
+		for i, term := range t.terms {
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(term.String())
+		}
+		sep = "; "
+	}
+	for _, m := range t.methods {
+		b.WriteString(sep)
+		sep = "; "
+		b.WriteString(m.name)
+		b.WriteString(m.typ.String()[len("func"):])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (s *Signature) String() string {
+	var b strings.Builder
+	b.WriteString("func")
+	if s.tparams != nil {
+		b.WriteByte('[')
+		for i, tpar := range s.tparams {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(tpar.obj.name)
+			b.WriteByte(' ')
+			b.WriteString(tpar.bound.String())
+		}
+		b.WriteByte(']')
+	}
+	b.WriteByte('(')
+	for i, p := range s.params {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if p.name != "" {
+			b.WriteString(p.name)
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.typ.String())
+	}
+	b.WriteByte(')')
+	if s.result != nil {
+		b.WriteByte(' ')
+		b.WriteString(s.result.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package types
+
+import (
+	"go/constant"
+	"jindo/pkg/jindo/position"
+)
+
+// Universe is the scope holding the predeclared types, constants
+// and functions. It is the parent of every space scope.
+var Universe *Scope
+
+// universeIota is the predeclared iota.
+var universeIota *Const
+
+// universeNil is the predeclared nil.
+var universeNil *Nil
+
+// emptyInterface is the type interface{}, which the predeclared any
+// denotes. It is the constraint of type parameters without methods.
+var emptyInterface = NewInterface(nil)
+
+// Typ contains the predeclared *Basic types indexed by their
+// corresponding BasicKind.
+var Typ = [...]*Basic{
+	Invalid: {Invalid, 0, "invalid type"},
+
+	Bool:   {Bool, IsBoolean, "bool"},
+	Int:    {Int, IsInteger, "int"},
+	Float:  {Float, IsFloat, "float"},
+	Rune:   {Rune, IsInteger, "rune"},
+	String: {String, IsString, "string"},
+
+	UntypedBool:   {UntypedBool, IsBoolean | IsUntyped, "untyped bool"},
+	UntypedInt:    {UntypedInt, IsInteger | IsUntyped, "untyped int"},
+	UntypedRune:   {UntypedRune, IsInteger | IsUntyped, "untyped rune"},
+	UntypedFloat:  {UntypedFloat, IsFloat | IsUntyped, "untyped float"},
+	UntypedString: {UntypedString, IsString | IsUntyped, "untyped string"},
+	UntypedNil:    {UntypedNil, IsUntyped, "untyped nil"},
+}
+
+type builtinId int
+
+const (
+	_Append builtinId = iota
+	_Delete
+	_Len
+	_Print
+	_Println
+)
+
+var predeclaredFuncs = [...]struct {
+	name     string
+	nargs    int
+	variadic bool
+}{
+	_Append:  {"append", 1, true},
+	_Delete:  {"delete", 2, false},
+	_Len:     {"len", 1, false},
+	_Print:   {"print", 0, true},
+	_Println: {"println", 0, true},
+}
+
+func init() {
+	Universe = NewScope(nil)
+
+	for _, t := range Typ {
+		if t.info&IsUntyped == 0 && t.kind != Invalid {
+			Universe.Insert(NewTypeName(position.Pos{}, t.name, t))
+		}
+	}
+
+	// any is an alias for interface{}; comparable and ordered are
+	// constraints: comparable is satisfied by comparable types, and
+	// ordered by the types whose underlying type is int, float, rune
+	// or string
+	Universe.Insert(NewTypeName(position.Pos{}, "any", emptyInterface))
+	for _, c := range []struct {
+		name string
+		typ  *Interface
+	}{
+		{"comparable", &Interface{comparable: true}},
+		{"ordered", &Interface{terms: []Type{Typ[Int], Typ[Float], Typ[Rune], Typ[String]}}},
+	} {
+		obj := NewTypeName(position.Pos{}, c.name, nil)
+		NewNamed(obj, c.typ)
+		Universe.Insert(obj)
+	}
+
+	for _, c := range []struct {
+		name string
+		val  bool
+	}{{"true", true}, {"false", false}} {
+		Universe.Insert(NewConst(position.Pos{}, c.name, Typ[UntypedBool], constant.MakeBool(c.val)))
+	}
+
+	// iota has a value only within constant declarations
+	universeIota = NewConst(position.Pos{}, "iota", Typ[UntypedInt], constant.MakeInt64(0))
+	Universe.Insert(universeIota)
+
+	universeNil = &Nil{object{nil, position.Pos{}, "nil", Typ[UntypedNil]}}
+	Universe.Insert(universeNil)
+
+	for id, f := range predeclaredFuncs {
+		Universe.Insert(&Builtin{object{nil, position.Pos{}, f.name, Typ[Invalid]}, builtinId(id)})
+	}
+}
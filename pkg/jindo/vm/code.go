@@ -0,0 +1,373 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file defines the bytecode format and its disassembler.
+
+package vm
+
+import (
+	"fmt"
+	"io"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"jindo/pkg/jindo/types"
+	"sort"
+	"strconv"
+)
+
+// An Opcode identifies a bytecode instruction. An instruction is
+// an opcode byte followed by an operand of 0, 1 or 2 bytes; two-byte
+// operands are stored in big-endian order.
+//
+// In the descriptions below, the stack grows to the right.
+type Opcode byte
+
+const (
+	_ Opcode = iota
+
+	// stack manipulation
+	OpConst // k: push Consts[k]
+	OpNil   // push nil
+	OpPop   // x: pop x
+	OpDup   // x: push x
+	OpDup2  // x y: push x, y
+	OpSwap  // x y: replace with y x
+
+	// variables
+	OpLoadLocal   // n: push local n
+	OpStoreLocal  // n: x: pop x into local n
+	OpLoadGlobal  // n: push global n
+	OpStoreGlobal // n: x: pop x into global n
+	OpCell        // x: replace x with a new cell holding x
+	OpLoadCell    // n: push the value of the cell in local n
+	OpStoreCell   // n: x: pop x into the cell in local n
+	OpAddrGlobal  // n: push a pointer to global n
+	OpAddrCell    // n: push a pointer to the variable held by the cell in local n
+
+	// functions
+	OpFunc    // n: push Funcs[n]
+	OpClosure // n: c1 ... ck: replace the cells c1, ..., ck with a closure of Funcs[n] over them, where k is Funcs[n].Free
+	OpBuiltin // n: push builtin n
+	OpMethod  // f x: replace with the method value f bound to receiver x
+	OpCall    // n: f a1 ... an: replace with the result of f(a1, ..., an)
+	OpOper    // n: x y: replace with the result of overload Funcs[n](x, y)
+	OpReturn  // x: return x from the current function
+	OpTuple   // n: x1 ... xn: replace with the tuple of the results x1, ..., xn
+	OpUnpack  // n: t: replace the tuple t of n results with its results
+
+	// operations
+	OpUnary   // op: x: replace with op x
+	OpBinary  // op: x y: replace with x op y
+	OpConvert // kind: x: replace with x converted to types.Typ[kind]
+
+	// slices
+	OpSlice    // n: x1 ... xn: replace with []Value{x1, ..., xn}
+	OpNilSlice // push a nil slice
+	OpIndex    // x i: replace with x[i]
+	OpSetIndex // x i v: pop all and set x[i] = v, where x is a slice or map
+
+	// maps
+	OpMap    // n: k1 v1 ... kn vn: replace with a map with the elements v1, ..., vn for the keys k1, ..., kn
+	OpLookup // x k z: replace with the element of map x for key k, or z if there is none, and whether there is one
+	OpIter   // x: replace the map x with an iterator over its entries
+
+	// structs
+	OpStruct   // n: x1 ... xn: replace with a struct with fields x1, ..., xn
+	OpField    // n: x: replace with field n of the struct x, or of the struct x points to
+	OpSetField // n: x v: pop both and set field n of the struct x, or of the struct x points to, to v
+	OpCopy     // x: replace with a copy of the struct x
+
+	// pointers
+	OpNew       // x: replace with a pointer to a new variable holding x
+	OpAddrIndex // x i: replace with a pointer to the element x[i] of the slice x
+	OpAddrField // n: x: replace with a pointer to field n of the struct x, or of the struct x points to
+	OpLoad      // p: replace with the value of the variable p points to
+	OpStore     // p x: pop both and set the variable p points to to x
+
+	// interfaces
+	OpBox       // t: x: replace with an interface value holding x of dynamic type Types[t]
+	OpDynMethod // k: x: replace with the method value for interface method Methods[k] of interface value x
+	OpAssert    // t: x: replace with the interface value x asserted to type Types[t]
+	OpAssertOk  // t: x z: replace with the interface value x asserted to type Types[t], or z if the assertion fails, and whether it holds
+	OpIsType    // t: x: replace with whether the interface value x holds a value of type Types[t]
+
+	// control flow
+	OpJump      // pc: continue at pc
+	OpJumpFalse // pc: x: pop x and continue at pc if x is false
+	OpJumpTrue  // pc: x: pop x and continue at pc if x is true
+	OpNext      // pc: x i: replace with the element of x at index i and the index of the next element, or pop both and continue at pc if i is at the end of x; the elements of a string are its runes
+	OpNextEntry // pc: it: replace the map iterator it with the element and key of its next entry, or pop it and continue at pc if there is none
+)
+
+var opcodeNames = [...]string{
+	OpConst:       "CONST",
+	OpNil:         "NIL",
+	OpPop:         "POP",
+	OpDup:         "DUP",
+	OpDup2:        "DUP2",
+	OpSwap:        "SWAP",
+	OpLoadLocal:   "LOAD_LOCAL",
+	OpStoreLocal:  "STORE_LOCAL",
+	OpLoadGlobal:  "LOAD_GLOBAL",
+	OpStoreGlobal: "STORE_GLOBAL",
+	OpCell:        "CELL",
+	OpLoadCell:    "LOAD_CELL",
+	OpStoreCell:   "STORE_CELL",
+	OpAddrGlobal:  "ADDR_GLOBAL",
+	OpAddrCell:    "ADDR_CELL",
+	OpFunc:        "FUNC",
+	OpClosure:     "CLOSURE",
+	OpBuiltin:     "BUILTIN",
+	OpMethod:      "METHOD",
+	OpCall:        "CALL",
+	OpOper:        "OPER",
+	OpReturn:      "RETURN",
+	OpTuple:       "TUPLE",
+	OpUnpack:      "UNPACK",
+	OpUnary:       "UNARY",
+	OpBinary:      "BINARY",
+	OpConvert:     "CONVERT",
+	OpSlice:       "SLICE",
+	OpNilSlice:    "NIL_SLICE",
+	OpIndex:       "INDEX",
+	OpSetIndex:    "SET_INDEX",
+	OpMap:         "MAP",
+	OpLookup:      "LOOKUP",
+	OpIter:        "ITER",
+	OpStruct:      "STRUCT",
+	OpField:       "FIELD",
+	OpSetField:    "SET_FIELD",
+	OpCopy:        "COPY",
+	OpNew:         "NEW",
+	OpAddrIndex:   "ADDR_INDEX",
+	OpAddrField:   "ADDR_FIELD",
+	OpLoad:        "LOAD",
+	OpStore:       "STORE",
+	OpBox:         "BOX",
+	OpDynMethod:   "DYN_METHOD",
+	OpAssert:      "ASSERT",
+	OpAssertOk:    "ASSERT_OK",
+	OpIsType:      "IS_TYPE",
+	OpJump:        "JUMP",
+	OpJumpFalse:   "JUMP_FALSE",
+	OpJumpTrue:    "JUMP_TRUE",
+	OpNext:        "NEXT",
+	OpNextEntry:   "NEXT_ENTRY",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) && opcodeNames[op] != "" {
+		return opcodeNames[op]
+	}
+	return "Opcode(" + strconv.Itoa(int(op)) + ")"
+}
+
+// operandSize holds the operand size in bytes of each opcode.
+var operandSize = [...]int{
+	OpConst:       2,
+	OpLoadLocal:   2,
+	OpStoreLocal:  2,
+	OpLoadGlobal:  2,
+	OpStoreGlobal: 2,
+	OpLoadCell:    2,
+	OpStoreCell:   2,
+	OpAddrGlobal:  2,
+	OpAddrCell:    2,
+	OpFunc:        2,
+	OpClosure:     2,
+	OpBuiltin:     1,
+	OpCall:        1,
+	OpOper:        2,
+	OpTuple:       1,
+	OpUnpack:      1,
+	OpUnary:       1,
+	OpBinary:      1,
+	OpConvert:     1,
+	OpSlice:       2,
+	OpMap:         2,
+	OpStruct:      2,
+	OpField:       2,
+	OpSetField:    2,
+	OpAddrField:   2,
+	OpBox:         2,
+	OpDynMethod:   2,
+	OpAssert:      2,
+	OpAssertOk:    2,
+	OpIsType:      2,
+	OpJump:        2,
+	OpJumpFalse:   2,
+	OpJumpTrue:    2,
+	OpNext:        2,
+	OpNextEntry:   2,
+}
+
+// Size returns the size in bytes of an instruction with opcode op.
+func (op Opcode) Size() int {
+	if int(op) < len(operandSize) {
+		return 1 + operandSize[op]
+	}
+	return 1
+}
+
+// A Program is a compiled jindo file.
+type Program struct {
+	Consts  []Value // constant pool
+	Funcs   []*Func // functions and operator overloads
+	Globals int     // number of global variables
+	Init    *Func   // initializes the global variables
+	Main    *Func   // the main function
+
+	Types   []*Type  // dynamic types of interface values and types of type assertions
+	Methods []string // names of the interface methods called, indexed by method index
+}
+
+// A Type is the dynamic type of interface values or the type of a type
+// assertion. No two types of a program are identical, so that types are
+// compared by their address.
+type Type struct {
+	Name string // in the form of types.Type.String
+
+	// Methods holds the methods and overloads of a dynamic type that
+	// implement interface methods, by method index, as method values
+	// without receiver.
+	Methods map[int]*Method
+
+	Iface bool // the type is an interface type
+
+	// Missing holds for an interface type the name of a method that
+	// a dynamic type lacks, for each dynamic type not implementing it.
+	Missing map[*Type]string
+}
+
+// A Func is a compiled function, function literal or operator overload.
+type Func struct {
+	Name   string
+	Params int // number of parameters
+	Free   int // number of free variables of a function literal, held in the locals following the parameters
+	Locals int // number of local variables, including parameters and free variables
+	Code   []byte
+	lines  []line // in increasing pc order
+}
+
+// A line maps the instructions starting at pc to a source position.
+type line struct {
+	pc  int
+	pos position.Pos
+}
+
+// Pos returns the source position of the instruction at pc.
+func (fn *Func) Pos(pc int) position.Pos {
+	i := sort.Search(len(fn.lines), func(i int) bool { return fn.lines[i].pc > pc })
+	if i == 0 {
+		return position.Pos{}
+	}
+	return fn.lines[i-1].pos
+}
+
+// operand returns the operand of the instruction at pc.
+func (fn *Func) operand(pc int) int {
+	switch Opcode(fn.Code[pc]).Size() {
+	case 2:
+		return int(fn.Code[pc+1])
+	case 3:
+		return int(fn.Code[pc+1])<<8 | int(fn.Code[pc+2])
+	}
+	return 0
+}
+
+// Disassemble writes a human-readable listing of the code of prog to w.
+// Each instruction is listed with its offset, and with its source line
+// where that line changes.
+func Disassemble(w io.Writer, prog *Program) error {
+	pr := &printer{w: w, prog: prog}
+	for i, k := range prog.Consts {
+		pr.printf("const %d = %s\n", i, quote(k))
+	}
+	if len(prog.Consts) > 0 {
+		pr.printf("\n")
+	}
+	pr.fn(prog.Init)
+	for _, fn := range prog.Funcs {
+		pr.printf("\n")
+		pr.fn(fn)
+	}
+	return pr.err
+}
+
+type printer struct {
+	w    io.Writer
+	prog *Program
+	err  error
+}
+
+func (pr *printer) printf(format string, args ...interface{}) {
+	if pr.err == nil {
+		_, pr.err = fmt.Fprintf(pr.w, format, args...)
+	}
+}
+
+func (pr *printer) fn(fn *Func) {
+	if fn.Free > 0 {
+		pr.printf("func %s (params %d, free %d, locals %d)\n", fn.Name, fn.Params, fn.Free, fn.Locals)
+	} else {
+		pr.printf("func %s (params %d, locals %d)\n", fn.Name, fn.Params, fn.Locals)
+	}
+	var lastLine uint
+	for pc := 0; pc < len(fn.Code); pc += Opcode(fn.Code[pc]).Size() {
+		ln := ""
+		if pos := fn.Pos(pc); pos.IsKnown() && pos.Line() != lastLine {
+			lastLine = pos.Line()
+			ln = strconv.FormatUint(uint64(lastLine), 10)
+		}
+		op := Opcode(fn.Code[pc])
+		pr.printf("%6s %04d  %s", ln, pc, op)
+		if op.Size() > 1 {
+			pr.printf(" %s", pr.operand(op, fn.operand(pc)))
+		}
+		pr.printf("\n")
+	}
+}
+
+// operand formats the operand x of an instruction with opcode op.
+func (pr *printer) operand(op Opcode, x int) string {
+	switch op {
+	case OpConst:
+		if x < len(pr.prog.Consts) {
+			return fmt.Sprintf("%d (%s)", x, quote(pr.prog.Consts[x]))
+		}
+	case OpBox, OpAssert, OpAssertOk, OpIsType:
+		if x < len(pr.prog.Types) {
+			return fmt.Sprintf("%d (%s)", x, pr.prog.Types[x].Name)
+		}
+	case OpDynMethod:
+		if x < len(pr.prog.Methods) {
+			return fmt.Sprintf("%d (%s)", x, pr.prog.Methods[x])
+		}
+	case OpFunc, OpClosure, OpOper:
+		if x < len(pr.prog.Funcs) {
+			return fmt.Sprintf("%d (%s)", x, pr.prog.Funcs[x].Name)
+		}
+	case OpBuiltin:
+		if x < len(builtins) {
+			return fmt.Sprintf("%d (%s)", x, builtins[x].Name)
+		}
+	case OpUnary, OpBinary:
+		return token.Operator(x).String()
+	case OpConvert:
+		if x < len(types.Typ) {
+			return types.Typ[x].Name()
+		}
+	}
+	return strconv.Itoa(x)
+}
+
+// quote returns the source form of the constant k.
+func quote(k Value) string {
+	if s, ok := k.(string); ok {
+		return strconv.Quote(s)
+	}
+	return format(k)
+}
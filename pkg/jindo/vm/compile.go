@@ -0,0 +1,1500 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// This file implements the compiler from syntax trees to bytecode.
+
+package vm
+
+import (
+	"fmt"
+	"go/constant"
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"jindo/pkg/jindo/types"
+)
+
+// Compile compiles the file f to a program. The file must have
+// been type-checked without errors, and info must hold the type
+// information collected by types.Check. Compile returns the first
+// error encountered, if any.
+func Compile(f *ast.File, info *types.Info) (prog *Program, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if e, ok := p.(Error); ok {
+				err = e
+				return
+			}
+			panic(p)
+		}
+	}()
+
+	c := &compiler{
+		info:     info,
+		prog:     new(Program),
+		consts:   make(map[Value]int),
+		globals:  make(map[*types.Var]int),
+		funcs:    make(map[types.Object]int),
+		opers:    make(map[*ast.OperDecl]int),
+		captured: make(map[*types.Var]bool),
+		free:     make(map[*ast.FuncLit][]*types.Var),
+		nlits:    make(map[*Func]int),
+	}
+	c.file(f)
+	return c.prog, nil
+}
+
+type compiler struct {
+	info    *types.Info
+	prog    *Program
+	consts  map[Value]int         // index of constants in prog.Consts
+	globals map[*types.Var]int    // index of global variables
+	funcs   map[types.Object]int  // index of functions in prog.Funcs
+	opers   map[*ast.OperDecl]int // index of operator overloads in prog.Funcs
+	types   []types.Type          // types of prog.Types, by index
+	ifaceMs []*types.Func         // interface methods of prog.Methods, by index
+
+	captured map[*types.Var]bool           // local variables used by function literals or whose address is taken, held in cells
+	free     map[*ast.FuncLit][]*types.Var // free variables of function literals, in order of first use
+	nlits    map[*Func]int                 // number of function literals compiled per enclosing function
+
+	// current function
+	fn     *Func
+	locals map[*types.Var]int // index of local variables
+	loops  []*loop            // enclosing loops, innermost last
+}
+
+// A loop records the jumps out of a loop or switch statement that
+// are patched once their targets are known.
+type loop struct {
+	stmt      ast.Stmt // loop or switch statement
+	breaks    []int
+	continues []int
+}
+
+func (c *compiler) errorf(pos position.Pos, format string, args ...interface{}) {
+	panic(Error{pos, fmt.Sprintf(format, args...)})
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+func (c *compiler) file(f *ast.File) {
+	// allocate functions and globals first, since they
+	// may be used before they are declared; generic
+	// functions are compiled as their instances
+	var funcs []ast.Decl
+	for _, d := range c.info.Instantiated(f.DeclList) {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Value
+			if d.Recv != nil {
+				name = c.typeOf(d.Recv.Type).String() + "." + name
+			}
+			c.funcs[c.info.Defs[d.Name]] = len(c.prog.Funcs)
+			c.prog.Funcs = append(c.prog.Funcs, &Func{Name: name})
+			funcs = append(funcs, d)
+		case *ast.OperDecl:
+			name := fmt.Sprintf("operator %s(%s, %s)", d.Oper.OperName(), c.typeOf(d.TypeL.Type), c.typeOf(d.TypeR.Type))
+			c.opers[d] = len(c.prog.Funcs)
+			c.prog.Funcs = append(c.prog.Funcs, &Func{Name: name})
+			funcs = append(funcs, d)
+		case *ast.VarDecl:
+			if v, _ := c.info.Defs[d.NameList].(*types.Var); v != nil {
+				c.globals[v] = c.prog.Globals
+				c.prog.Globals++
+			}
+		}
+	}
+
+	c.closures(f)
+	c.addressed(f)
+
+	// global variables are initialized in source order
+	c.prog.Init = &Func{Name: "init"}
+	c.begin(c.prog.Init, nil, nil)
+	for _, d := range f.DeclList {
+		if d, ok := d.(*ast.VarDecl); ok {
+			c.varValue(d)
+			if v, _ := c.info.Defs[d.NameList].(*types.Var); v != nil {
+				c.emit(d.GetPos(), OpStoreGlobal, c.globals[v])
+			} else {
+				c.emit(d.GetPos(), OpPop, 0)
+			}
+		}
+	}
+	c.emit(f.EOF, OpNil, 0)
+	c.emit(f.EOF, OpReturn, 0)
+
+	for i, d := range funcs {
+		fn := c.prog.Funcs[i]
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil {
+				// the receiver is the first parameter
+				c.funcBody(fn, append([]*ast.Field{d.Recv}, d.Param...), nil, d.Return, d.Body)
+				break
+			}
+			c.funcBody(fn, d.Param, nil, d.Return, d.Body)
+			if d.Name.Value == "main" {
+				if len(d.Param) != 0 || d.Return != nil {
+					c.errorf(d.GetPos(), "func main must have no arguments and no return values")
+				}
+				c.prog.Main = fn
+			}
+		case *ast.OperDecl:
+			c.funcBody(fn, []*ast.Field{d.TypeL, d.TypeR}, nil, d.Return, d.Body)
+		}
+	}
+	c.dynamic()
+}
+
+// typeIndex returns the index in prog.Types of the type T, adding the
+// type if no identical type is there yet.
+func (c *compiler) typeIndex(pos position.Pos, T types.Type) int {
+	for i, t := range c.types {
+		if types.Identical(t, T) {
+			return i
+		}
+	}
+	n := len(c.prog.Types)
+	if n > 0xffff {
+		c.errorf(pos, "too many types")
+	}
+	_, iface := T.Underlying().(*types.Interface)
+	c.types = append(c.types, T)
+	c.prog.Types = append(c.prog.Types, &Type{Name: T.String(), Iface: iface})
+	return n
+}
+
+// methodIndex returns the index in prog.Methods of the interface
+// method m, adding the method if no method of the same name and an
+// identical signature is there yet.
+func (c *compiler) methodIndex(pos position.Pos, m *types.Func) int {
+	for i, im := range c.ifaceMs {
+		if im.Name() == m.Name() && types.Identical(im.Type(), m.Type()) {
+			return i
+		}
+	}
+	n := len(c.prog.Methods)
+	if n > 0xffff {
+		c.errorf(pos, "too many interface methods")
+	}
+	c.ifaceMs = append(c.ifaceMs, m)
+	c.prog.Methods = append(c.prog.Methods, m.Name())
+	return n
+}
+
+// dynamic completes the types of the program once all code is compiled:
+// dynamic types get the methods and overloads that implement the
+// interface methods called, and interface types the methods that each
+// dynamic type lacks.
+func (c *compiler) dynamic() {
+	for i, T := range c.types {
+		t := c.prog.Types[i]
+		if iface, ok := T.Underlying().(*types.Interface); ok {
+			for j, V := range c.types {
+				if m, _ := types.MissingMethod(V, iface); m != nil && !c.prog.Types[j].Iface {
+					if t.Missing == nil {
+						t.Missing = make(map[*Type]string)
+					}
+					t.Missing[c.prog.Types[j]] = m.Name()
+				}
+			}
+			continue
+		}
+		for k, m := range c.ifaceMs {
+			var fn *Method
+			switch d := types.MethodDecl(T, m).(type) {
+			case *ast.FuncDecl:
+				// a method with a value receiver of a pointer
+				// type gets the value pointed to
+				_, ptr := d.RecvType()
+				fn = &Method{Fn: c.prog.Funcs[c.funcs[c.info.Defs[d.Name]]], Deref: isPointer(T) && !ptr}
+			case *ast.OperDecl:
+				fn = &Method{Fn: c.prog.Funcs[c.opers[d]]}
+			default:
+				continue
+			}
+			if t.Methods == nil {
+				t.Methods = make(map[int]*Method)
+			}
+			t.Methods[k] = fn
+		}
+	}
+}
+
+// begin starts the compilation of the function fn with the given
+// parameters and, for a function literal, free variables. Captured
+// parameters are moved to cells.
+func (c *compiler) begin(fn *Func, params []*ast.Field, free []*types.Var) {
+	c.fn = fn
+	c.locals = make(map[*types.Var]int)
+	c.loops = nil
+	vars := make([]*types.Var, len(params))
+	for i, p := range params {
+		if p.Name != nil {
+			vars[i], _ = c.info.Defs[p.Name].(*types.Var)
+		}
+		c.newLocal(vars[i])
+	}
+	fn.Params = len(params)
+	for _, v := range free {
+		c.newLocal(v)
+	}
+	fn.Free = len(free)
+	for i, v := range vars {
+		if c.captured[v] {
+			pos := params[i].GetPos()
+			c.emit(pos, OpLoadLocal, i)
+			c.emit(pos, OpCell, 0)
+			c.emit(pos, OpStoreLocal, i)
+		}
+	}
+}
+
+func (c *compiler) funcBody(fn *Func, params []*ast.Field, free []*types.Var, result ast.Expr, body *ast.BlockStmt) {
+	c.begin(fn, params, free)
+	if body == nil {
+		c.errorf(fn.Pos(0), "missing function body for %s", fn.Name)
+	}
+	c.stmtList(body.StmtList)
+	if result == nil {
+		c.emit(body.Rbrace, OpNil, 0)
+		c.emit(body.Rbrace, OpReturn, 0)
+	}
+	// Functions with a result end in a terminating statement,
+	// which the type checker has verified.
+}
+
+// newLocal allocates a local variable for v, which may be nil
+// for unnamed or blank variables.
+func (c *compiler) newLocal(v *types.Var) int {
+	n := c.fn.Locals
+	if n > 0xffff {
+		c.errorf(position.Pos{}, "too many local variables in %s", c.fn.Name)
+	}
+	c.fn.Locals++
+	if v != nil {
+		c.locals[v] = n
+	}
+	return n
+}
+
+// declare allocates a local variable for v and stores the value
+// on the stack in it. Captured variables are stored in a new cell.
+func (c *compiler) declare(pos position.Pos, v *types.Var) {
+	n := c.newLocal(v)
+	if c.captured[v] {
+		c.emit(pos, OpCell, 0)
+	}
+	c.emit(pos, OpStoreLocal, n)
+}
+
+// closures records the free variables of the function literals in f
+// and marks them as captured. The free variables of a literal are the
+// local variables it uses that are declared outside of it.
+func (c *compiler) closures(f *ast.File) {
+	for _, d := range c.info.Instantiated(f.DeclList) {
+		ast.Inspect(d, func(n ast.Node) bool {
+			if x, ok := n.(*ast.FuncLit); ok {
+				c.free[x] = c.freeVars(x)
+				for _, v := range c.free[x] {
+					c.captured[v] = true
+				}
+			}
+			return true
+		})
+	}
+}
+
+// addressed marks the local variables in f whose address is taken as
+// captured: the variables, and the variables holding the struct fields,
+// that are operands of & or receivers of pointer methods.
+func (c *compiler) addressed(f *ast.File) {
+	for _, d := range c.info.Instantiated(f.DeclList) {
+		ast.Inspect(d, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.Operation:
+				if x.Op == token.And && x.Y == nil {
+					c.markRoot(x.X)
+				}
+			case *ast.SelectorExpr:
+				if c.ptrMethod(x) && !isPointer(c.info.TypeOf(x.X)) {
+					c.markRoot(x.X)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// markRoot marks the variable holding the location x as captured, if
+// x is a variable or a field of a struct variable.
+func (c *compiler) markRoot(x ast.Expr) {
+	switch x := unparen(x).(type) {
+	case *ast.Name:
+		if v, ok := c.info.Uses[x].(*types.Var); ok {
+			c.captured[v] = true
+		}
+	case *ast.SelectorExpr:
+		if !isPointer(c.info.TypeOf(x.X)) {
+			c.markRoot(x.X)
+		}
+	}
+}
+
+// freeVars returns the free variables of the function literal x.
+func (c *compiler) freeVars(x *ast.FuncLit) []*types.Var {
+	local := make(map[*types.Var]bool) // variables declared in x
+	ast.Inspect(x, func(n ast.Node) bool {
+		if name, ok := n.(*ast.Name); ok {
+			if v, ok := c.info.Defs[name].(*types.Var); ok {
+				local[v] = true
+			}
+		}
+		if v, ok := c.info.Implicits[n].(*types.Var); ok {
+			local[v] = true // declared by a clause of a type switch
+		}
+		return true
+	})
+	var free []*types.Var
+	ast.Inspect(x.Body, func(n ast.Node) bool {
+		if name, ok := n.(*ast.Name); ok {
+			v, _ := c.info.Uses[name].(*types.Var)
+			if _, global := c.globals[v]; v != nil && !v.IsField() && !global && !local[v] {
+				local[v] = true // listed once
+				free = append(free, v)
+			}
+		}
+		return true
+	})
+	return free
+}
+
+// varValue pushes the initial value of the variable declared by d.
+func (c *compiler) varValue(d *ast.VarDecl) {
+	if d.Values != nil {
+		c.expr(d.Values)
+	} else {
+		c.zero(d.GetPos(), c.typeOf(d.Type))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+func (c *compiler) stmtList(list []ast.Stmt) {
+	for _, s := range list {
+		c.stmt(s)
+	}
+}
+
+func (c *compiler) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case nil, *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.ExprStmt:
+		c.expr(s.X)
+		c.emit(s.GetPos(), OpPop, 0)
+
+	case *ast.DeclStmt:
+		for _, d := range s.DeclList {
+			if d, ok := d.(*ast.VarDecl); ok {
+				c.varValue(d)
+				v, _ := c.info.Defs[d.NameList].(*types.Var)
+				c.declare(d.GetPos(), v)
+			}
+		}
+
+	case *ast.DefineStmt:
+		lhs := parser.UnpackList(s.Lhs)
+		c.values(s.Rhs, len(lhs))
+		for i := len(lhs) - 1; i >= 0; i-- {
+			name, ok := lhs[i].(*ast.Name)
+			if !ok {
+				c.errorf(s.GetPos(), "non-name on left side of :=")
+			}
+			if v, ok := c.info.Defs[name].(*types.Var); ok {
+				c.declare(s.GetPos(), v)
+			} else if name.Value == "_" {
+				c.emit(s.GetPos(), OpPop, 0)
+			} else {
+				// redeclared in the same block
+				_, store, n := c.variable(name)
+				c.emit(s.GetPos(), store, n)
+			}
+		}
+
+	case *ast.AssignStmt:
+		if _, ok := s.Lhs.(*ast.ListExpr); ok {
+			c.assignList(s)
+			break
+		}
+		c.assign(s.GetPos(), s.Lhs, func() {
+			c.expr(s.Rhs)
+		}, s.Op, c.info.Overloads[s])
+
+	case *ast.IncDecStmt:
+		c.assign(s.GetPos(), s.X, func() {
+			var one Value = int64(1)
+			if isFloat(c.typeOf(s.X)) {
+				one = float64(1)
+			}
+			c.emit(s.GetPos(), OpConst, c.constant(s.GetPos(), one))
+		}, s.Op, nil)
+
+	case *ast.BlockStmt:
+		c.stmtList(s.StmtList)
+
+	case *ast.IfStmt:
+		c.expr(s.Cond)
+		jf := c.emit(s.GetPos(), OpJumpFalse, 0)
+		c.stmtList(s.Block.StmtList)
+		if s.Else == nil {
+			c.patch(jf)
+			break
+		}
+		j := c.emit(s.GetPos(), OpJump, 0)
+		c.patch(jf)
+		c.stmt(s.Else)
+		c.patch(j)
+
+	case *ast.ForStmt:
+		if r, ok := s.Init.(*ast.RangeClause); ok {
+			c.rangeStmt(s, r)
+			break
+		}
+		c.stmt(s.Init)
+		top := len(c.fn.Code)
+		exit := -1
+		if s.Cond != nil {
+			c.expr(s.Cond)
+			exit = c.emit(s.GetPos(), OpJumpFalse, 0)
+		}
+		l := c.loop(s, s.Body)
+		c.patchAll(l.continues)
+		c.renew(s.Init)
+		c.stmt(s.Post)
+		c.emit(s.GetPos(), OpJump, top)
+		if exit >= 0 {
+			c.patch(exit)
+		}
+		c.patchAll(l.breaks)
+
+	case *ast.WhileStmt:
+		top := len(c.fn.Code)
+		c.expr(s.Cond)
+		exit := c.emit(s.GetPos(), OpJumpFalse, 0)
+		l := c.loop(s, s.Body)
+		c.patchAll(l.continues)
+		c.emit(s.GetPos(), OpJump, top)
+		c.patch(exit)
+		c.patchAll(l.breaks)
+
+	case *ast.SwitchStmt:
+		c.switchStmt(s)
+
+	case *ast.ReturnStmt:
+		if list, ok := s.Result.(*ast.ListExpr); ok {
+			c.values(list, len(list.ElemList))
+			c.emit(s.GetPos(), OpTuple, len(list.ElemList))
+		} else if s.Result != nil {
+			c.expr(s.Result)
+		} else {
+			c.emit(s.GetPos(), OpNil, 0)
+		}
+		c.emit(s.GetPos(), OpReturn, 0)
+
+	case *ast.LabeledStmt:
+		c.stmt(s.Stmt)
+
+	case *ast.BreakStmt:
+		l := c.target(s.GetPos(), c.info.Targets[s])
+		l.breaks = append(l.breaks, c.emit(s.GetPos(), OpJump, 0))
+
+	case *ast.ContinueStmt:
+		l := c.target(s.GetPos(), c.info.Targets[s])
+		l.continues = append(l.continues, c.emit(s.GetPos(), OpJump, 0))
+
+	default:
+		c.errorf(s.GetPos(), "unexpected statement %T", s)
+	}
+}
+
+// renew emits new cells for the next iteration of a for loop for the
+// variables declared by its init statement that are captured, so that
+// the closures created by each iteration refer to its own copy of them.
+func (c *compiler) renew(init ast.SimpleStmt) {
+	s, ok := init.(*ast.DefineStmt)
+	if !ok {
+		return
+	}
+	for _, x := range parser.UnpackList(s.Lhs) {
+		name, _ := x.(*ast.Name)
+		if v, ok := c.info.Defs[name].(*types.Var); ok && c.captured[v] {
+			n := c.locals[v]
+			c.emit(s.GetPos(), OpLoadCell, n)
+			if isStruct(v.Type()) {
+				c.emit(s.GetPos(), OpCopy, 0)
+			}
+			c.emit(s.GetPos(), OpCell, 0)
+			c.emit(s.GetPos(), OpStoreLocal, n)
+		}
+	}
+}
+
+// rangeStmt compiles the range loop s with range clause r. The range
+// value and the index of its next element, or the iterator over the
+// entries of a map, are held in local variables; the iteration
+// variables are declared anew by each iteration.
+func (c *compiler) rangeStmt(s *ast.ForStmt, r *ast.RangeClause) {
+	pos := s.GetPos()
+	c.expr(r.X)
+	var top, exit int
+	if _, ok := c.typeOf(r.X).Underlying().(*types.Map); ok {
+		c.emit(pos, OpIter, 0)
+		it := c.newLocal(nil)
+		c.emit(pos, OpStoreLocal, it)
+
+		top = len(c.fn.Code)
+		c.emit(pos, OpLoadLocal, it)
+		exit = c.emit(pos, OpNextEntry, 0)
+	} else {
+		x := c.newLocal(nil)
+		c.emit(pos, OpStoreLocal, x)
+		c.emit(pos, OpConst, c.constant(pos, int64(0)))
+		next := c.newLocal(nil)
+		c.emit(pos, OpStoreLocal, next)
+
+		top = len(c.fn.Code)
+		c.emit(pos, OpLoadLocal, x)
+		c.emit(pos, OpLoadLocal, next)
+		exit = c.emit(pos, OpNext, 0)
+		c.emit(pos, OpLoadLocal, next)
+		c.emit(pos, OpSwap, 0)
+		c.emit(pos, OpStoreLocal, next)
+	}
+	// the stack holds the element and its index or key
+	lhs := parser.UnpackList(r.Lhs)
+	for i := 0; i < 2; i++ {
+		var v *types.Var
+		if i < len(lhs) {
+			v, _ = c.info.Defs[lhs[i].(*ast.Name)].(*types.Var)
+		}
+		switch {
+		case v == nil:
+			c.emit(pos, OpPop, 0)
+		case isStruct(v.Type()):
+			c.emit(pos, OpCopy, 0)
+			fallthrough
+		default:
+			c.declare(pos, v)
+		}
+	}
+	l := c.loop(s, s.Body)
+	c.patchAll(l.continues)
+	c.emit(pos, OpJump, top)
+	c.patch(exit)
+	c.patchAll(l.breaks)
+}
+
+// loop compiles the body of the loop s and returns the
+// unresolved break and continue jumps of the body.
+func (c *compiler) loop(s ast.Stmt, body *ast.BlockStmt) *loop {
+	l := &loop{stmt: s}
+	c.loops = append(c.loops, l)
+	c.stmtList(body.StmtList)
+	c.loops = c.loops[:len(c.loops)-1]
+	return l
+}
+
+// target returns the enclosing loop or switch statement target that a
+// break or continue statement refers to, as recorded by the type checker.
+func (c *compiler) target(pos position.Pos, target ast.Stmt) *loop {
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		if l := c.loops[i]; l.stmt == target {
+			return l
+		}
+	}
+	c.errorf(pos, "invalid break or continue statement")
+	return nil
+}
+
+// switchStmt compiles the switch statement s. The tag is held in a
+// local variable while it is compared with the cases in order; the
+// first case that matches jumps to the body of its clause.
+func (c *compiler) switchStmt(s *ast.SwitchStmt) {
+	g, _ := s.Tag.(*ast.TypeSwitchGuard)
+	tag := -1
+	switch {
+	case g != nil:
+		c.expr(g.X)
+		tag = c.newLocal(nil)
+		c.emit(s.GetPos(), OpStoreLocal, tag)
+	case s.Tag != nil:
+		c.expr(s.Tag)
+		tag = c.newLocal(nil)
+		c.emit(s.GetPos(), OpStoreLocal, tag)
+	}
+
+	bodies := make([][]int, len(s.Body)) // jumps to the clause bodies
+	def := -1
+	for i, cl := range s.Body {
+		if cl.Cases == nil {
+			def = i
+			continue
+		}
+		for _, x := range parser.UnpackList(cl.Cases) {
+			pos := x.GetPos()
+			switch {
+			case g != nil && c.isNil(x):
+				// the interface value has no dynamic type
+				c.emit(pos, OpLoadLocal, tag)
+				c.emit(pos, OpNil, 0)
+				c.emit(pos, OpBinary, int(token.Eql))
+			case g != nil:
+				c.emit(pos, OpLoadLocal, tag)
+				c.emit(pos, OpIsType, c.typeIndex(pos, c.typeOf(x)))
+			case tag >= 0:
+				c.expr(x)
+				c.emit(pos, OpLoadLocal, tag)
+				c.emit(pos, OpBinary, int(token.Eql))
+			default:
+				c.expr(x) // a boolean case of a switch without tag
+			}
+			bodies[i] = append(bodies[i], c.emit(pos, OpJumpTrue, 0))
+		}
+	}
+	other := c.emit(s.GetPos(), OpJump, 0) // to the default clause or the end
+
+	l := &loop{stmt: s}
+	c.loops = append(c.loops, l)
+	for i, cl := range s.Body {
+		if i == def {
+			c.patch(other)
+		}
+		c.patchAll(bodies[i])
+		if v, ok := c.info.Implicits[cl].(*types.Var); ok {
+			// the variable of a clause listing a single non-interface
+			// type holds the dynamic value of the tag
+			c.emit(cl.GetPos(), OpLoadLocal, tag)
+			if !types.IsInterface(v.Type()) {
+				c.emit(cl.GetPos(), OpAssert, c.typeIndex(cl.GetPos(), v.Type()))
+			}
+			c.declare(cl.GetPos(), v)
+		}
+		c.stmtList(cl.Body)
+		l.breaks = append(l.breaks, c.emit(cl.GetPos(), OpJump, 0))
+	}
+	c.loops = c.loops[:len(c.loops)-1]
+	if def < 0 {
+		c.patch(other)
+	}
+	c.patchAll(l.breaks)
+}
+
+// assign compiles the assignment lhs op= rhs, where the function
+// rhs emits the code for the right-hand side. If op is NoneOp, the
+// assignment is a plain assignment; if overload is not nil, the
+// operation is a call of the operator overload.
+func (c *compiler) assign(pos position.Pos, lhs ast.Expr, rhs func(), op token.Operator, overload *ast.OperDecl) {
+	operate := func() {
+		switch {
+		case overload != nil:
+			c.oper(pos, overload, op)
+		case op != token.NoneOp:
+			c.emit(pos, OpBinary, int(op))
+		}
+	}
+
+	switch x := unparen(lhs).(type) {
+	case *ast.Name:
+		if x.Value == "_" {
+			rhs()
+			c.emit(pos, OpPop, 0)
+			return
+		}
+		load, store, n := c.variable(x)
+		if op != token.NoneOp {
+			c.emit(x.GetPos(), load, n)
+			c.box(lhs)
+		}
+		rhs()
+		operate()
+		c.emit(pos, store, n)
+
+	case *ast.IndexExpr:
+		c.load(x.X)
+		c.expr(x.Index)
+		if op != token.NoneOp {
+			c.emit(pos, OpDup2, 0)
+			c.index(x)
+			c.box(lhs)
+		}
+		rhs()
+		operate()
+		c.emit(x.Index.GetPos(), OpSetIndex, 0)
+
+	case *ast.SelectorExpr:
+		n := c.field(x)
+		c.load(x.X)
+		if op != token.NoneOp {
+			c.emit(pos, OpDup, 0)
+			c.emit(x.GetPos(), OpField, n)
+			c.box(lhs)
+		}
+		rhs()
+		operate()
+		c.emit(x.GetPos(), OpSetField, n)
+
+	case *ast.Operation:
+		if x.Op != token.Mul || x.Y != nil {
+			c.errorf(lhs.GetPos(), "cannot assign to expression")
+		}
+		c.expr(x.X)
+		if op != token.NoneOp {
+			c.emit(pos, OpDup, 0)
+			c.emit(x.GetPos(), OpLoad, 0)
+			c.box(lhs)
+		}
+		rhs()
+		operate()
+		c.emit(x.GetPos(), OpStore, 0)
+
+	default:
+		c.errorf(lhs.GetPos(), "cannot assign to expression")
+	}
+}
+
+// assignList compiles the assignment s of several values. The operands
+// of the locations on the left are evaluated before the values on the
+// right, and all are kept in temporaries until the values are assigned
+// from left to right.
+func (c *compiler) assignList(s *ast.AssignStmt) {
+	lhs := parser.UnpackList(s.Lhs)
+	locs := make([][]int, len(lhs)) // temporaries holding the operands of the locations
+	for i, x := range lhs {
+		switch x := unparen(x).(type) {
+		case *ast.IndexExpr:
+			c.load(x.X)
+			c.expr(x.Index)
+			locs[i] = []int{c.newLocal(nil), c.newLocal(nil)}
+			c.emit(x.GetPos(), OpStoreLocal, locs[i][1])
+			c.emit(x.GetPos(), OpStoreLocal, locs[i][0])
+		case *ast.SelectorExpr:
+			c.load(x.X)
+			locs[i] = []int{c.newLocal(nil)}
+			c.emit(x.GetPos(), OpStoreLocal, locs[i][0])
+		case *ast.Operation:
+			c.expr(x.X)
+			locs[i] = []int{c.newLocal(nil)}
+			c.emit(x.GetPos(), OpStoreLocal, locs[i][0])
+		}
+	}
+
+	c.values(s.Rhs, len(lhs))
+	vals := make([]int, len(lhs))
+	for i := len(lhs) - 1; i >= 0; i-- {
+		vals[i] = c.newLocal(nil)
+		c.emit(s.GetPos(), OpStoreLocal, vals[i])
+	}
+
+	for i, x := range lhs {
+		switch x := unparen(x).(type) {
+		case *ast.Name:
+			if x.Value != "_" {
+				_, store, n := c.variable(x)
+				c.emit(s.GetPos(), OpLoadLocal, vals[i])
+				c.emit(s.GetPos(), store, n)
+			}
+		case *ast.IndexExpr:
+			c.emit(s.GetPos(), OpLoadLocal, locs[i][0])
+			c.emit(s.GetPos(), OpLoadLocal, locs[i][1])
+			c.emit(s.GetPos(), OpLoadLocal, vals[i])
+			c.emit(x.Index.GetPos(), OpSetIndex, 0)
+		case *ast.SelectorExpr:
+			c.emit(s.GetPos(), OpLoadLocal, locs[i][0])
+			c.emit(s.GetPos(), OpLoadLocal, vals[i])
+			c.emit(x.GetPos(), OpSetField, c.field(x))
+		case *ast.Operation:
+			c.emit(s.GetPos(), OpLoadLocal, locs[i][0])
+			c.emit(s.GetPos(), OpLoadLocal, vals[i])
+			c.emit(x.GetPos(), OpStore, 0)
+		default:
+			c.errorf(x.GetPos(), "cannot assign to expression")
+		}
+	}
+}
+
+// values pushes the n values of the expression list x: either n
+// expressions, a single call of a function with n results, a single
+// map index expression providing the element and whether the key is
+// present, or a single type assertion providing the asserted value, or
+// the zero value of the type, and whether the assertion holds.
+func (c *compiler) values(x ast.Expr, n int) {
+	list := parser.UnpackList(x)
+	if a, ok := unparen(x).(*ast.AssertExpr); ok && n == 2 {
+		pos := a.GetPos()
+		T := c.typeOf(a.Type)
+		c.expr(a.X)
+		c.zero(pos, T)
+		c.emit(pos, OpAssertOk, c.typeIndex(pos, T))
+		if c.info.Dynamic[x] != nil {
+			c.emit(pos, OpSwap, 0)
+			c.box(x)
+			c.emit(pos, OpSwap, 0)
+		}
+		return
+	}
+	if ix, ok := unparen(x).(*ast.IndexExpr); ok && n == 2 {
+		t, ok := c.typeOf(ix.X).Underlying().(*types.Map)
+		if !ok {
+			c.errorf(x.GetPos(), "assignment mismatch: %d values expected", n)
+		}
+		pos := ix.Index.GetPos()
+		c.load(ix.X)
+		c.expr(ix.Index)
+		c.zero(pos, t.Elem())
+		c.emit(pos, OpLookup, 0)
+		if isStruct(t.Elem()) || c.info.Dynamic[ix] != nil {
+			c.emit(pos, OpSwap, 0)
+			if isStruct(t.Elem()) {
+				c.emit(pos, OpCopy, 0)
+			}
+			c.box(ix)
+			c.emit(pos, OpSwap, 0)
+		}
+		return
+	}
+	if len(list) == 1 && n > 1 {
+		c.expr(list[0])
+		c.emit(x.GetPos(), OpUnpack, n)
+		return
+	}
+	for _, x := range list {
+		c.expr(x)
+	}
+}
+
+// variable returns the instructions to load and store the
+// variable denoted by x and their operand.
+func (c *compiler) variable(x *ast.Name) (load, store Opcode, n int) {
+	v, _ := c.info.Uses[x].(*types.Var)
+	if n, ok := c.locals[v]; ok {
+		if c.captured[v] {
+			return OpLoadCell, OpStoreCell, n
+		}
+		return OpLoadLocal, OpStoreLocal, n
+	}
+	if n, ok := c.globals[v]; ok {
+		return OpLoadGlobal, OpStoreGlobal, n
+	}
+	c.errorf(x.GetPos(), "undefined: %s", x.Value)
+	return
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+func (c *compiler) expr(x ast.Expr) {
+	c.load(x)
+
+	// struct values are copied when they are read from a
+	// variable, slice element or field, or through a pointer
+	switch u := unparen(x).(type) {
+	case *ast.Name, *ast.IndexExpr, *ast.SelectorExpr:
+		if isStruct(c.info.TypeOf(x)) {
+			c.emit(x.GetPos(), OpCopy, 0)
+		}
+	case *ast.Operation:
+		if u.Op == token.Mul && u.Y == nil && isStruct(c.info.TypeOf(x)) {
+			c.emit(x.GetPos(), OpCopy, 0)
+		}
+	}
+	c.box(x)
+}
+
+// box emits the conversion of the value of x on the stack to an
+// interface value, if the type checker recorded one.
+func (c *compiler) box(x ast.Expr) {
+	if T := c.info.Dynamic[x]; T != nil {
+		c.emit(x.GetPos(), OpBox, c.typeIndex(x.GetPos(), T))
+	}
+}
+
+// load compiles the expression x like expr, but without copying the
+// struct value of a variable, slice element or field, so that fields
+// of the struct can be selected and assigned in place.
+func (c *compiler) load(x ast.Expr) {
+	// constant expressions are evaluated by the type checker
+	if v, ok := c.info.Values[x]; ok {
+		pos := x.GetPos()
+		c.emit(pos, OpConst, c.constant(pos, c.constValue(pos, v, c.info.TypeOf(x))))
+		return
+	}
+
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		c.errorf(x.GetPos(), "invalid expression")
+
+	case *ast.Name:
+		if d := c.info.Instances[x].Decl; d != nil {
+			c.emit(x.GetPos(), OpFunc, c.funcs[c.info.Defs[d.Name]])
+			break
+		}
+		switch obj := c.info.Uses[x].(type) {
+		case *types.Nil:
+			c.zero(x.GetPos(), c.typeOf(x))
+		case *types.Var:
+			load, _, n := c.variable(x)
+			c.emit(x.GetPos(), load, n)
+		case *types.Func:
+			n, ok := c.funcs[obj]
+			if !ok {
+				c.errorf(x.GetPos(), "undefined: %s", x.Value)
+			}
+			c.emit(x.GetPos(), OpFunc, n)
+		case *types.Builtin:
+			c.emit(x.GetPos(), OpBuiltin, builtinIndex(x.Value))
+		default:
+			c.errorf(x.GetPos(), "undefined: %s", x.Value)
+		}
+
+	case *ast.BasicLit:
+		c.errorf(x.GetPos(), "invalid literal %s", x.Value)
+
+	case *ast.ParenExpr:
+		c.load(x.X)
+
+	case *ast.SliceLit:
+		for _, elem := range x.Elems {
+			c.expr(elem)
+		}
+		c.emit(x.GetPos(), OpSlice, len(x.Elems))
+
+	case *ast.CompositeLit:
+		c.compositeLit(x)
+
+	case *ast.Operation:
+		if x.Y == nil {
+			switch x.Op {
+			case token.And:
+				c.addr(x.X)
+			case token.Mul:
+				c.expr(x.X)
+				c.emit(x.GetPos(), OpLoad, 0)
+			default:
+				c.expr(x.X)
+				c.emit(x.GetPos(), OpUnary, int(x.Op))
+			}
+			break
+		}
+		switch x.Op {
+		case token.AndAnd, token.OrOr:
+			// x && y: if x is false, the result is x, otherwise y
+			jump := OpJumpFalse
+			if x.Op == token.OrOr {
+				jump = OpJumpTrue
+			}
+			c.expr(x.X)
+			c.emit(x.GetPos(), OpDup, 0)
+			j := c.emit(x.GetPos(), jump, 0)
+			c.emit(x.GetPos(), OpPop, 0)
+			c.expr(x.Y)
+			c.patch(j)
+			return
+		}
+		c.expr(x.X)
+		c.expr(x.Y)
+		if d := c.info.Overloads[x]; d != nil {
+			c.oper(x.GetPos(), d, x.Op)
+		} else {
+			c.emit(x.GetPos(), OpBinary, int(x.Op))
+		}
+
+	case *ast.IndexExpr:
+		if name, ok := x.X.(*ast.Name); ok && c.info.Instances[name].Decl != nil {
+			c.load(name) // instantiation
+			break
+		}
+		c.load(x.X)
+		c.expr(x.Index)
+		c.index(x)
+
+	case *ast.SelectorExpr:
+		if c.info.Specs[x] != nil {
+			c.expr(x.X)
+			m, _ := c.info.Uses[x.Sel].(*types.Func)
+			c.emit(x.Sel.GetPos(), OpDynMethod, c.methodIndex(x.Sel.GetPos(), m))
+			break
+		}
+		if n := c.method(x); n >= 0 {
+			c.emit(x.Sel.GetPos(), OpFunc, n)
+			c.recv(x)
+			c.emit(x.Sel.GetPos(), OpMethod, 0)
+			break
+		}
+		n := c.field(x)
+		c.load(x.X)
+		c.emit(x.GetPos(), OpField, n)
+
+	case *ast.AssertExpr:
+		c.expr(x.X)
+		c.emit(x.GetPos(), OpAssert, c.typeIndex(x.GetPos(), c.typeOf(x.Type)))
+
+	case *ast.FuncLit:
+		c.funcLit(x)
+
+	case *ast.CallExpr:
+		if c.isType(x.Func) {
+			if len(x.ArgList) != 1 {
+				c.errorf(x.GetPos(), "wrong number of arguments in conversion to %s", types.ExprString(x.Func))
+			}
+			c.expr(x.ArgList[0])
+			c.convert(x.GetPos(), c.typeOf(x.Func))
+			break
+		}
+		nargs := len(x.ArgList)
+		if sel, ok := unparen(x.Func).(*ast.SelectorExpr); ok && c.method(sel) >= 0 {
+			// a method is called with its receiver as first argument
+			c.emit(sel.Sel.GetPos(), OpFunc, c.method(sel))
+			c.recv(sel)
+			nargs++
+		} else {
+			c.expr(x.Func)
+		}
+		for _, arg := range x.ArgList {
+			c.expr(arg)
+		}
+		if nargs > 0xff {
+			c.errorf(x.GetPos(), "too many arguments in call to %s", types.ExprString(x.Func))
+		}
+		c.emit(x.GetPos(), OpCall, nargs)
+
+	default:
+		c.errorf(x.GetPos(), "unexpected expression %T", x)
+	}
+}
+
+// addr emits a pointer to the location denoted by x: a variable, a
+// slice element, a struct field or the variable a pointer points to.
+// The location of a composite literal is a new variable.
+func (c *compiler) addr(x ast.Expr) {
+	pos := x.GetPos()
+	switch x := unparen(x).(type) {
+	case *ast.Name:
+		v, _ := c.info.Uses[x].(*types.Var)
+		if n, ok := c.locals[v]; ok && c.captured[v] {
+			c.emit(pos, OpAddrCell, n)
+			return
+		}
+		if n, ok := c.globals[v]; ok {
+			c.emit(pos, OpAddrGlobal, n)
+			return
+		}
+	case *ast.IndexExpr:
+		if _, ok := c.typeOf(x.X).Underlying().(*types.Slice); ok {
+			c.load(x.X)
+			c.expr(x.Index)
+			c.emit(x.Index.GetPos(), OpAddrIndex, 0)
+			return
+		}
+	case *ast.SelectorExpr:
+		if c.method(x) < 0 && c.info.Specs[x] == nil {
+			n := c.field(x)
+			c.load(x.X)
+			c.emit(x.GetPos(), OpAddrField, n)
+			return
+		}
+	case *ast.Operation:
+		if x.Op == token.Mul && x.Y == nil {
+			// the pointer is dereferenced to check that it is not nil
+			c.expr(x.X)
+			c.emit(pos, OpDup, 0)
+			c.emit(pos, OpLoad, 0)
+			c.emit(pos, OpPop, 0)
+			return
+		}
+	case *ast.CompositeLit:
+		c.expr(x)
+		c.emit(pos, OpNew, 0)
+		return
+	}
+	c.errorf(pos, "cannot take address of %s", types.ExprString(x))
+}
+
+// recv emits the receiver of the method selected by x. A method with
+// a pointer receiver selected on a variable rather than a pointer gets
+// the address of the variable, and a method with a value receiver
+// selected on a pointer gets the value pointed to.
+func (c *compiler) recv(x *ast.SelectorExpr) {
+	ptr := isPointer(c.typeOf(x.X))
+	switch {
+	case c.ptrMethod(x) && !ptr:
+		c.addr(x.X)
+	case !c.ptrMethod(x) && ptr:
+		c.expr(x.X)
+		c.emit(x.GetPos(), OpLoad, 0)
+		if isStruct(c.typeOf(x.X).Underlying().(*types.Pointer).Elem()) {
+			c.emit(x.GetPos(), OpCopy, 0)
+		}
+	default:
+		c.expr(x.X)
+	}
+}
+
+// ptrMethod reports whether x selects a method with a pointer receiver.
+func (c *compiler) ptrMethod(x *ast.SelectorExpr) bool {
+	d := c.info.Methods[x]
+	if d == nil {
+		return false
+	}
+	_, ptr := d.RecvType()
+	return ptr
+}
+
+// index emits the element of the slice, string or map x.X at the
+// index x.Index, both of which are on the stack. The element of a map
+// for an absent key is the zero value of the element type.
+func (c *compiler) index(x *ast.IndexExpr) {
+	pos := x.Index.GetPos()
+	if t, ok := c.typeOf(x.X).Underlying().(*types.Map); ok {
+		c.zero(pos, t.Elem())
+		c.emit(pos, OpLookup, 0)
+		c.emit(pos, OpPop, 0)
+		return
+	}
+	c.emit(pos, OpIndex, 0)
+}
+
+// funcLit emits a closure of the function literal x over the cells
+// of its free variables. The literal is compiled to a function of
+// its own, named after the enclosing function.
+func (c *compiler) funcLit(x *ast.FuncLit) {
+	free := c.free[x]
+	for _, v := range free {
+		n, ok := c.locals[v]
+		if !ok {
+			c.errorf(x.GetPos(), "undefined: %s", v.Name())
+		}
+		c.emit(x.GetPos(), OpLoadLocal, n) // the cell
+	}
+
+	c.nlits[c.fn]++
+	fn := &Func{Name: fmt.Sprintf("%s.func%d", c.fn.Name, c.nlits[c.fn])}
+	k := len(c.prog.Funcs)
+	c.prog.Funcs = append(c.prog.Funcs, fn)
+
+	outer, locals, loops := c.fn, c.locals, c.loops
+	c.funcBody(fn, x.Type.Param, free, x.Type.Return, x.Body)
+	c.fn, c.locals, c.loops = outer, locals, loops
+
+	c.emit(x.GetPos(), OpClosure, k)
+}
+
+// compositeLit emits the value of the composite literal x. The
+// fields of a struct literal are pushed in declaration order, with
+// zero values for the fields that are not present.
+func (c *compiler) compositeLit(x *ast.CompositeLit) {
+	switch t := c.typeOf(x).Underlying().(type) {
+	case *types.Struct:
+		elems := make([]ast.Expr, t.NumFields())
+		for i, elem := range x.ElemList {
+			if kv, ok := elem.(*ast.KeyValueExpr); ok {
+				key, _ := kv.Key.(*ast.Name)
+				if key == nil {
+					c.errorf(kv.GetPos(), "invalid field name in struct literal")
+				}
+				i = fieldIndex(t, c.info.Uses[key])
+				elem = kv.Value
+			}
+			if i < 0 || i >= len(elems) {
+				c.errorf(elem.GetPos(), "invalid element in struct literal")
+			}
+			elems[i] = elem
+		}
+		for i, elem := range elems {
+			if elem != nil {
+				c.expr(elem)
+			} else {
+				c.zero(x.GetPos(), t.Field(i).Type())
+			}
+		}
+		if len(elems) > 0xffff {
+			c.errorf(x.GetPos(), "too many fields in struct literal")
+		}
+		c.emit(x.GetPos(), OpStruct, len(elems))
+
+	case *types.Slice:
+		for _, elem := range x.ElemList {
+			c.expr(elem)
+		}
+		c.emit(x.GetPos(), OpSlice, len(x.ElemList))
+
+	case *types.Map:
+		for _, elem := range x.ElemList {
+			kv, ok := elem.(*ast.KeyValueExpr)
+			if !ok {
+				c.errorf(elem.GetPos(), "missing key in map literal")
+			}
+			c.expr(kv.Key)
+			c.expr(kv.Value)
+		}
+		if len(x.ElemList) > 0xffff {
+			c.errorf(x.GetPos(), "too many elements in map literal")
+		}
+		c.emit(x.GetPos(), OpMap, len(x.ElemList))
+
+	default:
+		c.errorf(x.GetPos(), "invalid composite literal type %s", t)
+	}
+}
+
+// field returns the index of the field selected by x, possibly
+// through a pointer.
+func (c *compiler) field(x *ast.SelectorExpr) int {
+	if t := c.info.TypeOf(x.X); t != nil {
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		if s, ok := t.Underlying().(*types.Struct); ok {
+			if i := fieldIndex(s, c.info.Uses[x.Sel]); i >= 0 {
+				return i
+			}
+		}
+	}
+	c.errorf(x.Sel.GetPos(), "undefined field %s", x.Sel.Value)
+	return 0
+}
+
+// method returns the index in prog.Funcs of the method selected
+// by x, or -1 if x selects a field or the method of an interface.
+func (c *compiler) method(x *ast.SelectorExpr) int {
+	fn, ok := c.info.Uses[x.Sel].(*types.Func)
+	if !ok || c.info.Specs[x] != nil {
+		return -1
+	}
+	n, ok := c.funcs[fn]
+	if !ok {
+		c.errorf(x.Sel.GetPos(), "undefined: %s", x.Sel.Value)
+	}
+	return n
+}
+
+// fieldIndex returns the index of the field obj of struct s, or -1.
+func fieldIndex(s *types.Struct, obj types.Object) int {
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i) == obj {
+			return i
+		}
+	}
+	return -1
+}
+
+// oper emits a call of the operator overload d for the operation
+// x op y with the operands x and y on the stack.
+func (c *compiler) oper(pos position.Pos, d *ast.OperDecl, op token.Operator) {
+	if _, swapped := op.Overloaded(); d.Oper.IsReversed() != swapped {
+		// the receiver is the right operand
+		c.emit(pos, OpSwap, 0)
+	}
+	c.emit(pos, OpOper, c.opers[d])
+}
+
+// convert emits the conversion of the value on the stack to type T.
+func (c *compiler) convert(pos position.Pos, T types.Type) {
+	switch t := T.Underlying().(type) {
+	case *types.Basic:
+		c.emit(pos, OpConvert, int(t.Kind()))
+	case *types.Slice, *types.Map, *types.Pointer, *types.Struct, *types.Signature:
+		// nothing to do
+	case *types.Interface:
+		// the value has been boxed as recorded by the type checker
+	default:
+		c.errorf(pos, "cannot convert to %s", T)
+	}
+}
+
+// zero emits the zero value of type T.
+func (c *compiler) zero(pos position.Pos, T types.Type) {
+	switch t := T.Underlying().(type) {
+	case *types.Basic:
+		var v Value
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			v = false
+		case t.Info()&types.IsInteger != 0:
+			v = int64(0)
+		case t.Info()&types.IsFloat != 0:
+			v = float64(0)
+		case t.Info()&types.IsString != 0:
+			v = ""
+		default:
+			c.errorf(pos, "invalid type %s", T)
+		}
+		c.emit(pos, OpConst, c.constant(pos, v))
+	case *types.Slice:
+		c.emit(pos, OpNilSlice, 0)
+	case *types.Map:
+		c.emit(pos, OpConst, c.constant(pos, (*Map)(nil)))
+	case *types.Pointer:
+		c.emit(pos, OpConst, c.constant(pos, Pointer{}))
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			c.zero(pos, t.Field(i).Type())
+		}
+		c.emit(pos, OpStruct, t.NumFields())
+	default:
+		c.emit(pos, OpNil, 0)
+	}
+}
+
+// constValue returns the value of the constant v, represented
+// according to the type T of its use.
+func (c *compiler) constValue(pos position.Pos, v constant.Value, T types.Type) Value {
+	switch v.Kind() {
+	case constant.Bool:
+		return constant.BoolVal(v)
+	case constant.String:
+		return constant.StringVal(v)
+	case constant.Int, constant.Float:
+		if isFloat(T) {
+			f, _ := constant.Float64Val(v)
+			return f
+		}
+		if i, ok := constant.Int64Val(constant.ToInt(v)); ok {
+			return i
+		}
+	}
+	c.errorf(pos, "invalid constant %s", v)
+	return nil
+}
+
+// constant returns the index of v in the constant pool,
+// adding v if necessary.
+func (c *compiler) constant(pos position.Pos, v Value) int {
+	if k, ok := c.consts[v]; ok {
+		return k
+	}
+	k := len(c.prog.Consts)
+	if k > 0xffff {
+		c.errorf(pos, "too many constants")
+	}
+	c.consts[v] = k
+	c.prog.Consts = append(c.prog.Consts, v)
+	return k
+}
+
+// isType reports whether x denotes a type.
+func (c *compiler) isType(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Name:
+		_, ok := c.info.Uses[x].(*types.TypeName)
+		return ok
+	case *ast.SliceType, *ast.MapType, *ast.StructType, *ast.InterfaceType:
+		return true
+	case *ast.IndexExpr:
+		return c.isType(x.X) // instance of a generic type
+	case *ast.Operation:
+		return x.Op == token.Mul && x.Y == nil && c.isType(x.X) // pointer type
+	case *ast.ParenExpr:
+		return c.isType(x.X)
+	}
+	return false
+}
+
+// typeOf returns the type recorded for x.
+func (c *compiler) typeOf(x ast.Expr) types.Type {
+	t := c.info.TypeOf(x)
+	if t == nil {
+		c.errorf(x.GetPos(), "missing type for %s", types.ExprString(x))
+	}
+	return t
+}
+
+// isNil reports whether x denotes the predeclared nil.
+func (c *compiler) isNil(x ast.Expr) bool {
+	n, _ := unparen(x).(*ast.Name)
+	_, ok := c.info.Uses[n].(*types.Nil)
+	return ok
+}
+
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = p.X
+	}
+}
+
+func isStruct(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+func isPointer(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+func isFloat(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsFloat != 0
+}
+
+// ----------------------------------------------------------------------------
+// Code generation
+
+// emit appends the instruction op with operand x to the current
+// function and returns its offset.
+func (c *compiler) emit(pos position.Pos, op Opcode, x int) int {
+	fn := c.fn
+	pc := len(fn.Code)
+	if pc > 0xffff {
+		c.errorf(pos, "function %s too large", fn.Name)
+	}
+	if pos.IsKnown() && (len(fn.lines) == 0 || fn.lines[len(fn.lines)-1].pos != pos) {
+		fn.lines = append(fn.lines, line{pc, pos})
+	}
+	fn.Code = append(fn.Code, byte(op))
+	switch op.Size() {
+	case 2:
+		fn.Code = append(fn.Code, byte(x))
+	case 3:
+		fn.Code = append(fn.Code, byte(x>>8), byte(x))
+	}
+	return pc
+}
+
+// patch sets the target of the jump at pc to the current offset.
+func (c *compiler) patch(pc int) {
+	target := len(c.fn.Code)
+	if target > 0xffff {
+		c.errorf(c.fn.Pos(pc), "function %s too large", c.fn.Name)
+	}
+	c.fn.Code[pc+1] = byte(target >> 8)
+	c.fn.Code[pc+2] = byte(target)
+}
+
+func (c *compiler) patchAll(list []int) {
+	for _, pc := range list {
+		c.patch(pc)
+	}
+}
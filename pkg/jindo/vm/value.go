@@ -0,0 +1,439 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package vm
+
+import (
+	"fmt"
+	"jindo/pkg/jindo/position"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Value is a jindo runtime value. Its dynamic type is one of
+//
+//	int64    for int and rune values
+//	float64  for float values
+//	string   for string values
+//	bool     for bool values
+//	[]Value  for slices
+//	*Map     for maps
+//	Pointer  for pointers
+//	*Struct  for structs
+//	*Func    for declared functions
+//	*Method  for method values
+//	*Closure for function literals
+//	*Builtin for predeclared functions
+//	*Iface   for non-nil interface values
+//	Tuple    for the results of a function with several results
+//
+// which is the same representation as used by package interp, except
+// for maps. The nil interface value and the zero value of function types
+// are nil, the zero value of map types is a nil *Map, and the zero value
+// of pointer types is the zero Pointer.
+type Value interface{}
+
+// A Tuple holds the results of a call of a function with several
+// results, which OpUnpack pushes onto the stack.
+type Tuple []Value
+
+// A Struct is a struct value. The compiler emits OpCopy where a
+// struct is read from a variable, slice element or field, so that
+// every variable holds a struct of its own. Structs are assigned to
+// variables other than locals field by field (see set), so that
+// pointers to their fields remain valid.
+type Struct struct {
+	Fields []Value
+}
+
+// set assigns v to the variable *ref. A struct is assigned to the
+// struct held by the variable, field by field.
+func set(ref *Value, v Value) {
+	if d, ok := (*ref).(*Struct); ok {
+		if s, ok := v.(*Struct); ok && len(s.Fields) == len(d.Fields) {
+			for i, f := range s.Fields {
+				set(&d.Fields[i], f)
+			}
+			return
+		}
+	}
+	*ref = v
+}
+
+// A Pointer is a pointer value: a reference to a global variable, the
+// variable held by a cell, a slice element or a struct field. The zero
+// value is the nil pointer.
+type Pointer struct {
+	Ref *Value
+}
+
+// A Map is a map value. Maps are references: the copies of a map
+// value share its entries. The entries are kept in increasing order of
+// their keys (see compareKeys), which is the order in which range loops
+// visit them. A nil *Map is a nil map, which has no entries and cannot
+// be assigned to.
+type Map struct {
+	Keys  []Value
+	Elems []Value
+}
+
+// find returns the index of the entry of m for key k, or the index at
+// which such an entry is inserted, and whether the entry exists.
+func (m *Map) find(k Value) (int, bool) {
+	if m == nil {
+		return 0, false
+	}
+	i := sort.Search(len(m.Keys), func(i int) bool { return compareKeys(m.Keys[i], k) >= 0 })
+	return i, i < len(m.Keys) && compareKeys(m.Keys[i], k) == 0
+}
+
+// set sets the element of m for key k to v, adding an entry if needed.
+// m must not be nil.
+func (m *Map) set(k, v Value) {
+	i, ok := m.find(k)
+	if !ok {
+		m.Keys = append(m.Keys, nil)
+		m.Elems = append(m.Elems, nil)
+		copy(m.Keys[i+1:], m.Keys[i:])
+		copy(m.Elems[i+1:], m.Elems[i:])
+		m.Keys[i] = k
+	}
+	m.Elems[i] = v
+}
+
+// delete removes the entry of m for key k, if any.
+func (m *Map) delete(k Value) {
+	if i, ok := m.find(k); ok {
+		m.Keys = append(m.Keys[:i], m.Keys[i+1:]...)
+		m.Elems = append(m.Elems[:i], m.Elems[i+1:]...)
+	}
+}
+
+// len returns the number of entries of m.
+func (m *Map) len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.Keys)
+}
+
+// compareKeys returns -1, 0 or +1 depending on whether the map key x is
+// less than, equal to or greater than the map key y of the same type.
+// The order is the one package interp uses: numbers and strings are
+// ordered by value, false before true, pointers by address, structs by
+// their fields in order, and interface values by the name of their
+// dynamic type, or the address of distinct types of the same name, then
+// value, with nil first.
+func compareKeys(x, y Value) int {
+	switch x := x.(type) {
+	case int64:
+		return compare(x < y.(int64), x > y.(int64))
+	case float64:
+		return compare(x < y.(float64), x > y.(float64))
+	case string:
+		return compare(x < y.(string), x > y.(string))
+	case bool:
+		return compare(!x && y.(bool), x && !y.(bool))
+	case Pointer:
+		xa, ya := address(x), address(y.(Pointer))
+		return compare(xa < ya, xa > ya)
+	case *Struct:
+		y := y.(*Struct)
+		for i, f := range x.Fields {
+			if c := compareKeys(f, y.Fields[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case *Iface:
+		y, _ := y.(*Iface)
+		if y == nil {
+			return +1
+		}
+		if x.Type != y.Type {
+			if xs, ys := x.Type.Name, y.Type.Name; xs != ys {
+				return compare(xs < ys, xs > ys)
+			}
+			xa, ya := reflect.ValueOf(x.Type).Pointer(), reflect.ValueOf(y.Type).Pointer()
+			return compare(xa < ya, xa > ya)
+		}
+		return compareKeys(x.Value, y.Value)
+	case nil:
+		return compare(y != nil, false)
+	}
+	return 0
+}
+
+// address returns the address of the variable that p points to,
+// or 0 if p is nil.
+func address(p Pointer) uintptr {
+	if p.Ref == nil {
+		return 0
+	}
+	return reflect.ValueOf(p.Ref).Pointer()
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return +1
+	}
+	return 0
+}
+
+// A mapIter is an iterator over the entries of a map, which OpIter
+// pushes onto the stack. It visits the keys the map had when the
+// iteration started, skipping those deleted since.
+type mapIter struct {
+	m    *Map
+	keys []Value
+	i    int // index of the next key
+}
+
+// next returns the key and element of the next entry, if any.
+func (it *mapIter) next() (k, v Value, ok bool) {
+	for it.i < len(it.keys) {
+		k := it.keys[it.i]
+		it.i++
+		if j, ok := it.m.find(k); ok {
+			return k, it.m.Elems[j], true
+		}
+	}
+	return nil, nil, false
+}
+
+// copyValue returns a copy of v if v is a struct, and v otherwise.
+// Nested structs are copied as well.
+func copyValue(v Value) Value {
+	s, ok := v.(*Struct)
+	if !ok {
+		return v
+	}
+	c := &Struct{Fields: make([]Value, len(s.Fields))}
+	for i, f := range s.Fields {
+		c.Fields[i] = copyValue(f)
+	}
+	return c
+}
+
+// A Method is a method value: the method Fn bound to a receiver.
+// Calling it calls Fn with the receiver as first argument, or with
+// the value the receiver points to if Deref is set, as for a method
+// with a value receiver of the dynamic pointer value of an interface.
+type Method struct {
+	Fn    *Func
+	Recv  Value
+	Deref bool
+}
+
+// A Closure is a function value backed by a function literal: the
+// compiled literal Fn together with the cells of its free variables,
+// the variables of the enclosing functions that it uses.
+type Closure struct {
+	Fn   *Func
+	Free []*Cell
+}
+
+// A Cell holds a local variable that is used by a function literal
+// or whose address is taken, so that the variable is shared by the
+// function declaring it, the closures referring to it and the pointers
+// to it. Cells are held in local variables and are only pushed onto
+// the stack as operands of OpClosure.
+type Cell struct {
+	Value Value
+}
+
+// An Iface is an interface value holding the value Value of the
+// dynamic type Type. Interface values are never modified; Value is
+// shared by their copies.
+type Iface struct {
+	Type  *Type
+	Value Value
+}
+
+// A Builtin is a predeclared function implemented in Go.
+type Builtin struct {
+	Name string
+	fn   func(m *machine, pos position.Pos, args []Value) Value
+}
+
+// builtins lists the predeclared functions; the operand of
+// OpBuiltin is an index into this list.
+var builtins = []*Builtin{
+	{"print", builtinPrint},
+	{"println", builtinPrintln},
+	{"len", builtinLen},
+	{"append", builtinAppend},
+	{"delete", builtinDelete},
+}
+
+// builtinIndex returns the index of the builtin named name, or -1.
+func builtinIndex(name string) int {
+	for i, b := range builtins {
+		if b.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func builtinPrint(m *machine, pos position.Pos, args []Value) Value {
+	var b strings.Builder
+	for _, x := range args {
+		b.WriteString(format(x))
+	}
+	m.write(b.String())
+	return nil
+}
+
+func builtinPrintln(m *machine, pos position.Pos, args []Value) Value {
+	var b strings.Builder
+	for i, x := range args {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(format(x))
+	}
+	b.WriteByte('\n')
+	m.write(b.String())
+	return nil
+}
+
+func builtinLen(m *machine, pos position.Pos, args []Value) Value {
+	if len(args) != 1 {
+		m.errorf(pos, "wrong number of arguments to len: got %d, want 1", len(args))
+	}
+	switch x := args[0].(type) {
+	case string:
+		return int64(len(x))
+	case []Value:
+		return int64(len(x))
+	case *Map:
+		return int64(x.len())
+	}
+	m.errorf(pos, "invalid argument %s for len", format(args[0]))
+	return nil
+}
+
+func builtinAppend(m *machine, pos position.Pos, args []Value) Value {
+	if len(args) == 0 {
+		m.errorf(pos, "not enough arguments to append")
+	}
+	s, ok := args[0].([]Value)
+	if !ok {
+		m.errorf(pos, "first argument to append must be a slice, got %s", format(args[0]))
+	}
+	r := append(s, args[1:]...)
+	if len(r) > cap(s) {
+		// the elements were copied to a new array; its structs
+		// are copies as well
+		for i := range s {
+			r[i] = copyValue(r[i])
+		}
+	}
+	return r
+}
+
+func builtinDelete(m *machine, pos position.Pos, args []Value) Value {
+	if len(args) != 2 {
+		m.errorf(pos, "wrong number of arguments to delete: got %d, want 2", len(args))
+	}
+	x, ok := args[0].(*Map)
+	if !ok {
+		m.errorf(pos, "first argument to delete must be a map, got %s", format(args[0]))
+	}
+	m.checkKey(pos, args[1])
+	x.delete(args[1])
+	return nil
+}
+
+// format returns the textual representation of v as printed by print.
+// A pointer to a struct, slice or map is printed as & followed by the
+// value it points to, other pointers, and pointers nested in the value,
+// as their address.
+func format(v Value) string {
+	if i, ok := v.(*Iface); ok {
+		v = i.Value
+	}
+	if p, ok := v.(Pointer); ok && p.Ref != nil {
+		switch (*p.Ref).(type) {
+		case *Struct, []Value, *Map:
+			return "&" + formatValue(*p.Ref)
+		}
+	}
+	return formatValue(v)
+}
+
+// formatValue is like format but prints all pointers as their address.
+func formatValue(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case []Value:
+		var b strings.Builder
+		b.WriteByte('[')
+		for i, x := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatValue(x))
+		}
+		b.WriteByte(']')
+		return b.String()
+	case *Map:
+		var b strings.Builder
+		b.WriteString("map[")
+		for i := 0; i < v.len(); i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatValue(v.Keys[i]))
+			b.WriteByte(':')
+			b.WriteString(formatValue(v.Elems[i]))
+		}
+		b.WriteByte(']')
+		return b.String()
+	case Pointer:
+		if v.Ref == nil {
+			return "<nil>"
+		}
+		return fmt.Sprintf("%p", v.Ref)
+	case *Struct:
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, x := range v.Fields {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatValue(x))
+		}
+		b.WriteByte('}')
+		return b.String()
+	case *Func:
+		return "func " + v.Name
+	case *Method:
+		return "func " + v.Fn.Name
+	case *Closure:
+		return "func literal"
+	case *Builtin:
+		return "builtin " + v.Name
+	case *Iface:
+		return formatValue(v.Value)
+	}
+	return "<?>"
+}
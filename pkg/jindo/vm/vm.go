@@ -0,0 +1,864 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+// Package vm implements a bytecode compiler and a stack-based virtual
+// machine for jindo programs.
+//
+// Compile translates a type-checked ast.File into a Program, Run
+// executes it, and Disassemble lists its code. The observable behavior
+// of a program is the same as with the tree-walking interpreter of
+// package interp.
+package vm
+
+import (
+	"fmt"
+	"io"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/token"
+	"jindo/pkg/jindo/types"
+	"unicode/utf8"
+)
+
+// Error describes a compilation or runtime error. Error implements
+// the error interface.
+type Error struct {
+	Pos position.Pos
+	Msg string
+}
+
+func (err Error) Error() string {
+	if !err.Pos.IsKnown() {
+		return err.Msg
+	}
+	return fmt.Sprintf("%s: %s", err.Pos, err.Msg)
+}
+
+var _ error = Error{} // verify that Error implements error
+
+// maxDepth bounds the call depth so that runaway recursion is
+// reported as a runtime error.
+const maxDepth = 10000
+
+// Run executes prog by initializing its global variables and
+// calling its main function. Output of the print and println
+// builtins is written to out. Run returns the first runtime
+// error encountered, if any.
+func Run(prog *Program, out io.Writer) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if e, ok := p.(Error); ok {
+				err = e
+				return
+			}
+			panic(p)
+		}
+	}()
+
+	m := &machine{
+		prog:    prog,
+		out:     out,
+		globals: make([]Value, prog.Globals),
+	}
+	if prog.Main == nil {
+		m.errorf(position.Pos{}, "function main is undeclared")
+	}
+	m.call(prog.Init)
+	m.call(prog.Main)
+	return nil
+}
+
+type machine struct {
+	prog    *Program
+	out     io.Writer
+	globals []Value
+	stack   []Value
+	frames  []frame
+}
+
+// A frame holds the state of a function activation. The locals of
+// the function start at stack[base]; when the function returns, the
+// stack is cut back to stack[:ret] and the result is pushed.
+type frame struct {
+	fn   *Func
+	pc   int
+	base int
+	ret  int
+}
+
+func (m *machine) errorf(pos position.Pos, format string, args ...interface{}) {
+	panic(Error{pos, fmt.Sprintf(format, args...)})
+}
+
+func (m *machine) write(s string) {
+	if _, err := io.WriteString(m.out, s); err != nil {
+		m.errorf(position.Pos{}, "write error: %s", err)
+	}
+}
+
+func (m *machine) push(x Value) {
+	m.stack = append(m.stack, x)
+}
+
+func (m *machine) pop() Value {
+	x := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return x
+}
+
+// call calls fn without arguments, runs it to completion and
+// discards its result.
+func (m *machine) call(fn *Func) {
+	depth := len(m.frames)
+	m.enter(position.Pos{}, fn, nil, len(m.stack))
+	m.execute(depth)
+	m.pop()
+}
+
+// enter starts a new activation of fn whose arguments are
+// the topmost fn.Params values of the stack. The cells free
+// of a closure become the locals following the parameters.
+func (m *machine) enter(pos position.Pos, fn *Func, free []*Cell, ret int) {
+	if len(m.frames) >= maxDepth {
+		m.errorf(pos, "stack overflow in call to %s", fn.Name)
+	}
+	base := len(m.stack) - fn.Params
+	for _, c := range free {
+		m.push(c)
+	}
+	for i := fn.Params + len(free); i < fn.Locals; i++ {
+		m.push(nil)
+	}
+	m.frames = append(m.frames, frame{fn: fn, base: base, ret: ret})
+}
+
+// execute runs the current function until the number
+// of frames drops to depth.
+func (m *machine) execute(depth int) {
+	for len(m.frames) > depth {
+		fr := &m.frames[len(m.frames)-1]
+		fn := fr.fn
+		pc := fr.pc
+		if pc >= len(fn.Code) {
+			m.errorf(fn.Pos(pc), "missing return at end of function %s", fn.Name)
+		}
+		op := Opcode(fn.Code[pc])
+		x := fn.operand(pc)
+		fr.pc += op.Size()
+
+		switch op {
+		case OpConst:
+			m.push(m.prog.Consts[x])
+
+		case OpNil:
+			m.push(nil)
+
+		case OpPop:
+			m.pop()
+
+		case OpDup:
+			m.push(m.stack[len(m.stack)-1])
+
+		case OpDup2:
+			n := len(m.stack)
+			m.push(m.stack[n-2])
+			m.push(m.stack[n-1])
+
+		case OpSwap:
+			n := len(m.stack)
+			m.stack[n-2], m.stack[n-1] = m.stack[n-1], m.stack[n-2]
+
+		case OpLoadLocal:
+			m.push(m.stack[fr.base+x])
+
+		case OpStoreLocal:
+			m.stack[fr.base+x] = m.pop()
+
+		case OpLoadGlobal:
+			m.push(m.globals[x])
+
+		case OpStoreGlobal:
+			set(&m.globals[x], m.pop())
+
+		case OpCell:
+			n := len(m.stack) - 1
+			m.stack[n] = &Cell{m.stack[n]}
+
+		case OpLoadCell:
+			m.push(m.stack[fr.base+x].(*Cell).Value)
+
+		case OpStoreCell:
+			set(&m.stack[fr.base+x].(*Cell).Value, m.pop())
+
+		case OpAddrGlobal:
+			m.push(Pointer{&m.globals[x]})
+
+		case OpAddrCell:
+			m.push(Pointer{&m.stack[fr.base+x].(*Cell).Value})
+
+		case OpFunc:
+			m.push(m.prog.Funcs[x])
+
+		case OpBuiltin:
+			m.push(builtins[x])
+
+		case OpClosure:
+			callee := m.prog.Funcs[x]
+			free := make([]*Cell, callee.Free)
+			for i := range free {
+				free[i] = m.stack[len(m.stack)-callee.Free+i].(*Cell)
+			}
+			m.stack = m.stack[:len(m.stack)-callee.Free]
+			m.push(&Closure{callee, free})
+
+		case OpMethod:
+			recv := m.pop()
+			n := len(m.stack) - 1
+			m.stack[n] = &Method{m.stack[n].(*Func), recv, false}
+
+		case OpCall:
+			f := len(m.stack) - x - 1
+			if meth, ok := m.stack[f].(*Method); ok {
+				// insert the receiver before the arguments
+				m.push(nil)
+				copy(m.stack[f+2:], m.stack[f+1:])
+				recv := meth.Recv
+				if meth.Deref {
+					recv = copyValue(*m.deref(fn.Pos(pc), recv))
+				}
+				m.stack[f], m.stack[f+1] = meth.Fn, recv
+				x++
+			}
+			switch callee := m.stack[f].(type) {
+			case *Func:
+				if callee.Params != x {
+					m.errorf(fn.Pos(pc), "wrong number of arguments in call to %s: got %d, want %d", callee.Name, x, callee.Params)
+				}
+				m.enter(fn.Pos(pc), callee, nil, f)
+			case *Closure:
+				if callee.Fn.Params != x {
+					m.errorf(fn.Pos(pc), "wrong number of arguments in call to %s: got %d, want %d", callee.Fn.Name, x, callee.Fn.Params)
+				}
+				m.enter(fn.Pos(pc), callee.Fn, callee.Free, f)
+			case *Builtin:
+				args := make([]Value, x)
+				copy(args, m.stack[f+1:])
+				m.stack = m.stack[:f]
+				m.push(callee.fn(m, fn.Pos(pc), args))
+			case nil:
+				m.errorf(fn.Pos(pc), "invalid memory address or nil pointer dereference")
+			default:
+				m.errorf(fn.Pos(pc), "cannot call non-function %s", format(callee))
+			}
+
+		case OpOper:
+			m.enter(fn.Pos(pc), m.prog.Funcs[x], nil, len(m.stack)-2)
+
+		case OpReturn:
+			result := m.pop()
+			m.stack = m.stack[:fr.ret]
+			m.frames = m.frames[:len(m.frames)-1]
+			m.push(result)
+
+		case OpTuple:
+			t := make(Tuple, x)
+			copy(t, m.stack[len(m.stack)-x:])
+			m.stack = m.stack[:len(m.stack)-x]
+			m.push(t)
+
+		case OpUnpack:
+			t, ok := m.pop().(Tuple)
+			if !ok || len(t) != x {
+				m.errorf(fn.Pos(pc), "cannot unpack %d results", x)
+			}
+			m.stack = append(m.stack, t...)
+
+		case OpUnary:
+			n := len(m.stack) - 1
+			m.stack[n] = m.unary(fn.Pos(pc), token.Operator(x), m.stack[n])
+
+		case OpBinary:
+			y := m.pop()
+			n := len(m.stack) - 1
+			m.stack[n] = m.binary(fn.Pos(pc), token.Operator(x), m.stack[n], y)
+
+		case OpConvert:
+			n := len(m.stack) - 1
+			m.stack[n] = m.convert(fn.Pos(pc), types.BasicKind(x), m.stack[n])
+
+		case OpBox:
+			n := len(m.stack) - 1
+			m.stack[n] = &Iface{m.prog.Types[x], m.stack[n]}
+
+		case OpDynMethod:
+			n := len(m.stack) - 1
+			m.stack[n] = m.dynMethod(fn.Pos(pc), m.stack[n], x)
+
+		case OpAssert:
+			n := len(m.stack) - 1
+			m.stack[n] = m.assert(fn.Pos(pc), m.stack[n], m.prog.Types[x])
+
+		case OpAssertOk:
+			z := m.pop()
+			n := len(m.stack) - 1
+			T := m.prog.Types[x]
+			ok := m.hasType(m.stack[n], T)
+			switch {
+			case !ok:
+				m.stack[n] = z
+			case !T.Iface:
+				m.stack[n] = copyValue(m.stack[n].(*Iface).Value)
+			}
+			m.push(ok)
+
+		case OpIsType:
+			n := len(m.stack) - 1
+			m.stack[n] = m.hasType(m.stack[n], m.prog.Types[x])
+
+		case OpSlice:
+			s := make([]Value, x)
+			copy(s, m.stack[len(m.stack)-x:])
+			m.stack = m.stack[:len(m.stack)-x]
+			m.push(s)
+
+		case OpNilSlice:
+			m.push([]Value(nil))
+
+		case OpIndex:
+			i := m.pop()
+			n := len(m.stack) - 1
+			switch v := m.stack[n].(type) {
+			case []Value:
+				m.stack[n] = v[m.index(fn.Pos(pc), i, len(v))]
+			case string:
+				m.stack[n] = int64(v[m.index(fn.Pos(pc), i, len(v))])
+			default:
+				m.errorf(fn.Pos(pc), "cannot index %s", format(v))
+			}
+
+		case OpSetIndex:
+			v := m.pop()
+			i := m.pop()
+			switch x := m.pop().(type) {
+			case []Value:
+				set(&x[m.index(fn.Pos(pc), i, len(x))], v)
+			case *Map:
+				if x == nil {
+					m.errorf(fn.Pos(pc), "assignment to entry in nil map")
+				}
+				m.checkKey(fn.Pos(pc), i)
+				x.set(i, v)
+			default:
+				m.errorf(fn.Pos(pc), "cannot assign to index of non-slice")
+			}
+
+		case OpMap:
+			mp := new(Map)
+			entries := m.stack[len(m.stack)-2*x:]
+			for i := 0; i < len(entries); i += 2 {
+				m.checkKey(fn.Pos(pc), entries[i])
+				mp.set(entries[i], entries[i+1])
+			}
+			m.stack = m.stack[:len(m.stack)-2*x]
+			m.push(mp)
+
+		case OpLookup:
+			z := m.pop()
+			k := m.pop()
+			n := len(m.stack) - 1
+			mp, ok := m.stack[n].(*Map)
+			if !ok {
+				m.errorf(fn.Pos(pc), "cannot index %s", format(m.stack[n]))
+			}
+			m.checkKey(fn.Pos(pc), k)
+			i, found := mp.find(k)
+			if found {
+				m.stack[n] = mp.Elems[i]
+			} else {
+				m.stack[n] = z
+			}
+			m.push(found)
+
+		case OpIter:
+			n := len(m.stack) - 1
+			mp, ok := m.stack[n].(*Map)
+			if !ok {
+				m.errorf(fn.Pos(pc), "cannot range over %s", format(m.stack[n]))
+			}
+			var keys []Value
+			if mp != nil {
+				keys = append(keys, mp.Keys...)
+			}
+			m.stack[n] = &mapIter{m: mp, keys: keys}
+
+		case OpStruct:
+			s := &Struct{Fields: make([]Value, x)}
+			copy(s.Fields, m.stack[len(m.stack)-x:])
+			m.stack = m.stack[:len(m.stack)-x]
+			m.push(s)
+
+		case OpField:
+			n := len(m.stack) - 1
+			m.stack[n] = m.field(fn.Pos(pc), m.stack[n], x)
+
+		case OpSetField:
+			v := m.pop()
+			set(m.fieldRef(fn.Pos(pc), m.pop(), x), v)
+
+		case OpCopy:
+			n := len(m.stack) - 1
+			m.stack[n] = copyValue(m.stack[n])
+
+		case OpNew:
+			n := len(m.stack) - 1
+			v := m.stack[n]
+			m.stack[n] = Pointer{&v}
+
+		case OpAddrIndex:
+			i := m.pop()
+			n := len(m.stack) - 1
+			s, ok := m.stack[n].([]Value)
+			if !ok {
+				m.errorf(fn.Pos(pc), "cannot take address of index of non-slice")
+			}
+			m.stack[n] = Pointer{&s[m.index(fn.Pos(pc), i, len(s))]}
+
+		case OpAddrField:
+			n := len(m.stack) - 1
+			m.stack[n] = Pointer{m.fieldRef(fn.Pos(pc), m.stack[n], x)}
+
+		case OpLoad:
+			n := len(m.stack) - 1
+			m.stack[n] = *m.deref(fn.Pos(pc), m.stack[n])
+
+		case OpStore:
+			v := m.pop()
+			set(m.deref(fn.Pos(pc), m.pop()), v)
+
+		case OpJump:
+			fr.pc = x
+
+		case OpNext:
+			i := int(m.pop().(int64))
+			n := len(m.stack) - 1
+			switch v := m.stack[n].(type) {
+			case []Value:
+				if i < len(v) {
+					m.stack[n] = v[i]
+					m.push(int64(i + 1))
+					break
+				}
+				m.stack = m.stack[:n]
+				fr.pc = x
+			case string:
+				if i < len(v) {
+					r, size := utf8.DecodeRuneInString(v[i:])
+					m.stack[n] = int64(r)
+					m.push(int64(i + size))
+					break
+				}
+				m.stack = m.stack[:n]
+				fr.pc = x
+			default:
+				m.errorf(fn.Pos(pc), "cannot range over %s", format(v))
+			}
+
+		case OpNextEntry:
+			n := len(m.stack) - 1
+			if k, v, ok := m.stack[n].(*mapIter).next(); ok {
+				m.stack[n] = v
+				m.push(k)
+				break
+			}
+			m.stack = m.stack[:n]
+			fr.pc = x
+
+		case OpJumpFalse, OpJumpTrue:
+			b, ok := m.pop().(bool)
+			if !ok {
+				m.errorf(fn.Pos(pc), "non-boolean condition")
+			}
+			if b == (op == OpJumpTrue) {
+				fr.pc = x
+			}
+
+		default:
+			m.errorf(fn.Pos(pc), "invalid opcode %s", op)
+		}
+	}
+}
+
+// dynMethod returns the method value for the interface method with
+// index k of the interface value x, bound to the dynamic value of x.
+func (m *machine) dynMethod(pos position.Pos, x Value, k int) Value {
+	i, ok := x.(*Iface)
+	if !ok {
+		m.errorf(pos, "invalid memory address or nil pointer dereference")
+	}
+	fn := i.Type.Methods[k]
+	if fn == nil {
+		m.errorf(pos, "%s has no method %s", i.Type.Name, m.prog.Methods[k])
+	}
+	return &Method{fn.Fn, i.Value, fn.Deref}
+}
+
+// assert returns the value of the type assertion x.(T) of the
+// interface value x.
+func (m *machine) assert(pos position.Pos, x Value, T *Type) Value {
+	i, _ := x.(*Iface)
+	if i == nil {
+		m.errorf(pos, "interface conversion: interface is nil, not %s", T.Name)
+	}
+	if T.Iface {
+		if name, ok := T.Missing[i.Type]; ok {
+			m.errorf(pos, "interface conversion: %s is not %s: missing method %s", i.Type.Name, T.Name, name)
+		}
+		return x
+	}
+	if i.Type != T {
+		m.errorf(pos, "interface conversion: interface is %s, not %s", i.Type.Name, T.Name)
+	}
+	return copyValue(i.Value)
+}
+
+// hasType reports whether the interface value x holds a value of
+// the type T, as tested by a case of a type switch.
+func (m *machine) hasType(x Value, T *Type) bool {
+	i, _ := x.(*Iface)
+	if i == nil {
+		return false
+	}
+	if T.Iface {
+		_, missing := T.Missing[i.Type]
+		return !missing
+	}
+	return i.Type == T
+}
+
+func (m *machine) index(pos position.Pos, i Value, n int) int {
+	k, ok := i.(int64)
+	if !ok {
+		m.errorf(pos, "non-integer index %s", format(i))
+	}
+	if k < 0 || k >= int64(n) {
+		m.errorf(pos, "index out of range [%d] with length %d", k, n)
+	}
+	return int(k)
+}
+
+// checkKey reports an error if the map key k is, or holds in one of
+// its fields, an interface value whose dynamic type is not comparable.
+func (m *machine) checkKey(pos position.Pos, k Value) {
+	switch k := k.(type) {
+	case *Iface:
+		switch k.Value.(type) {
+		case []Value, *Map, *Func, *Method, *Closure, *Builtin:
+			m.errorf(pos, "hash of unhashable type %s", k.Type.Name)
+		}
+		m.checkKey(pos, k.Value)
+	case *Struct:
+		for _, f := range k.Fields {
+			m.checkKey(pos, f)
+		}
+	}
+}
+
+// field returns field n of the struct x, or of the struct x points to.
+func (m *machine) field(pos position.Pos, x Value, n int) Value {
+	return *m.fieldRef(pos, x, n)
+}
+
+// fieldRef returns a reference to field n of the struct x, or of the
+// struct x points to.
+func (m *machine) fieldRef(pos position.Pos, x Value, n int) *Value {
+	if p, ok := x.(Pointer); ok {
+		x = *m.deref(pos, p)
+	}
+	s, ok := x.(*Struct)
+	if !ok || n >= len(s.Fields) {
+		m.errorf(pos, "cannot select field of non-struct %s", format(x))
+	}
+	return &s.Fields[n]
+}
+
+// deref returns the variable that the pointer x points to.
+func (m *machine) deref(pos position.Pos, x Value) *Value {
+	p, ok := x.(Pointer)
+	if !ok {
+		m.errorf(pos, "cannot indirect %s", format(x))
+	}
+	if p.Ref == nil {
+		m.errorf(pos, "invalid memory address or nil pointer dereference")
+	}
+	return p.Ref
+}
+
+// convert converts x to the basic type of the given kind.
+func (m *machine) convert(pos position.Pos, kind types.BasicKind, x Value) Value {
+	switch kind {
+	case types.Int, types.Rune:
+		switch x := x.(type) {
+		case int64:
+			return x
+		case float64:
+			return int64(x)
+		}
+	case types.Float:
+		switch x := x.(type) {
+		case int64:
+			return float64(x)
+		case float64:
+			return x
+		}
+	case types.String:
+		switch x := x.(type) {
+		case string:
+			return x
+		case int64:
+			return string(rune(x))
+		}
+	case types.Bool:
+		if x, ok := x.(bool); ok {
+			return x
+		}
+	}
+	m.errorf(pos, "cannot convert %s to %s", format(x), types.Typ[kind])
+	return nil
+}
+
+func (m *machine) unary(pos position.Pos, op token.Operator, x Value) Value {
+	switch op {
+	case token.Add:
+		switch x.(type) {
+		case int64, float64:
+			return x
+		}
+	case token.Sub:
+		switch x := x.(type) {
+		case int64:
+			return -x
+		case float64:
+			return -x
+		}
+	case token.Not:
+		if x, ok := x.(bool); ok {
+			return !x
+		}
+	}
+	m.errorf(pos, "invalid operation: operator %s not defined on %s", op, format(x))
+	return nil
+}
+
+func (m *machine) binary(pos position.Pos, op token.Operator, x, y Value) Value {
+	if op == token.Eql || op == token.Neq {
+		if eq, ok := nilEqual(x, y); ok {
+			return eq == (op == token.Eql)
+		}
+		if isIface(x) && isIface(y) {
+			return m.ifaceEqual(pos, x, y) == (op == token.Eql)
+		}
+	}
+
+	// mixed int and float operands are computed in float
+	switch xv := x.(type) {
+	case int64:
+		if _, ok := y.(float64); ok {
+			x = float64(xv)
+		}
+	case float64:
+		if yv, ok := y.(int64); ok {
+			y = float64(yv)
+		}
+	}
+
+	switch x := x.(type) {
+	case int64:
+		if y, ok := y.(int64); ok {
+			return m.intOp(pos, op, x, y)
+		}
+	case float64:
+		if y, ok := y.(float64); ok {
+			return m.floatOp(pos, op, x, y)
+		}
+	case string:
+		if y, ok := y.(string); ok {
+			switch op {
+			case token.Add:
+				return x + y
+			case token.Eql:
+				return x == y
+			case token.Neq:
+				return x != y
+			case token.Lss:
+				return x < y
+			case token.Leq:
+				return x <= y
+			case token.Gtr:
+				return x > y
+			case token.Geq:
+				return x >= y
+			}
+		}
+	case bool:
+		if y, ok := y.(bool); ok {
+			switch op {
+			case token.Eql:
+				return x == y
+			case token.Neq:
+				return x != y
+			}
+		}
+	case Pointer:
+		if y, ok := y.(Pointer); ok {
+			switch op {
+			case token.Eql:
+				return x == y
+			case token.Neq:
+				return x != y
+			}
+		}
+	case *Struct:
+		if y, ok := y.(*Struct); ok && len(x.Fields) == len(y.Fields) {
+			switch op {
+			case token.Eql, token.Neq:
+				eq := true
+				for i := range x.Fields {
+					if !m.binary(pos, token.Eql, x.Fields[i], y.Fields[i]).(bool) {
+						eq = false
+						break
+					}
+				}
+				return eq == (op == token.Eql)
+			}
+		}
+	}
+	m.errorf(pos, "invalid operation: %s %s %s", format(x), op, format(y))
+	return nil
+}
+
+// nilEqual reports whether the values x and y of a slice, map or
+// function type, one of which is nil, are equal, and whether they
+// are of such a type.
+func nilEqual(x, y Value) (eq, ok bool) {
+	switch x := x.(type) {
+	case []Value:
+		y, ok := y.([]Value)
+		return ok && x == nil && y == nil, ok
+	case *Map:
+		y, ok := y.(*Map)
+		return ok && x == nil && y == nil, ok
+	case *Func, *Method, *Closure, *Builtin:
+		return false, y == nil
+	case nil:
+		switch y.(type) {
+		case *Func, *Method, *Closure, *Builtin:
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// isIface reports whether v is an interface value.
+func isIface(v Value) bool {
+	switch v.(type) {
+	case nil, *Iface:
+		return true
+	}
+	return false
+}
+
+// ifaceEqual reports whether the interface values x and y are equal:
+// both are nil, or their dynamic types and values are equal.
+func (m *machine) ifaceEqual(pos position.Pos, x, y Value) bool {
+	xi, _ := x.(*Iface)
+	yi, _ := y.(*Iface)
+	if xi == nil || yi == nil {
+		return xi == yi
+	}
+	if xi.Type != yi.Type {
+		return false
+	}
+	switch xi.Value.(type) {
+	case []Value, *Map, *Func, *Method, *Closure, *Builtin:
+		m.errorf(pos, "comparing uncomparable type %s", xi.Type.Name)
+	}
+	return m.binary(pos, token.Eql, xi.Value, yi.Value).(bool)
+}
+
+func (m *machine) intOp(pos position.Pos, op token.Operator, x, y int64) Value {
+	switch op {
+	case token.Add:
+		return x + y
+	case token.Sub:
+		return x - y
+	case token.Mul:
+		return x * y
+	case token.Div, token.Rem:
+		if y == 0 {
+			m.errorf(pos, "integer divide by zero")
+		}
+		if op == token.Div {
+			return x / y
+		}
+		return x % y
+	case token.And:
+		return x & y
+	case token.Or:
+		return x | y
+	case token.Xor:
+		return x ^ y
+	case token.AndNot:
+		return x &^ y
+	case token.Shl, token.Shr:
+		if y < 0 {
+			m.errorf(pos, "negative shift amount")
+		}
+		if op == token.Shl {
+			return x << uint64(y)
+		}
+		return x >> uint64(y)
+	case token.Eql:
+		return x == y
+	case token.Neq:
+		return x != y
+	case token.Lss:
+		return x < y
+	case token.Leq:
+		return x <= y
+	case token.Gtr:
+		return x > y
+	case token.Geq:
+		return x >= y
+	}
+	m.errorf(pos, "invalid operation: operator %s not defined on int", op)
+	return nil
+}
+
+func (m *machine) floatOp(pos position.Pos, op token.Operator, x, y float64) Value {
+	switch op {
+	case token.Add:
+		return x + y
+	case token.Sub:
+		return x - y
+	case token.Mul:
+		return x * y
+	case token.Div:
+		return x / y
+	case token.Eql:
+		return x == y
+	case token.Neq:
+		return x != y
+	case token.Lss:
+		return x < y
+	case token.Leq:
+		return x <= y
+	case token.Gtr:
+		return x > y
+	case token.Geq:
+		return x >= y
+	}
+	m.errorf(pos, "invalid operation: operator %s not defined on float", op)
+	return nil
+}
@@ -0,0 +1,913 @@
+// Copyright 2024 The Jindo Authors. All rights reserved.
+// This file is part of jindo and is licensed under
+// the GNU General Public License version 3, which is available at
+// https://www.gnu.org/licenses/gpl-3.0.html or in the LICENSE file
+// located in the root directory of this source tree.
+
+package vm
+
+import (
+	"jindo/pkg/jindo/ast"
+	"jindo/pkg/jindo/interp"
+	"jindo/pkg/jindo/parser"
+	"jindo/pkg/jindo/position"
+	"jindo/pkg/jindo/types"
+	"strings"
+	"testing"
+)
+
+const src_ = "../../../example/test.paw"
+
+func compile(t *testing.T, f *ast.File) *Program {
+	t.Helper()
+	info, err := types.Check(f, func(err error) { t.Error(err) })
+	if err != nil {
+		t.FailNow()
+	}
+	prog, err := Compile(f, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func run(t *testing.T, src string) (string, error) {
+	t.Helper()
+	f, err := parser.Parse(position.NewFileBase("test.paw"), strings.NewReader(src), func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		t.FailNow()
+	}
+	var out strings.Builder
+	err = Run(compile(t, f), &out)
+	return out.String(), err
+}
+
+func TestRunFile(t *testing.T) {
+	f, err := parser.ParseFile(src_, func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		return // error already reported
+	}
+	var out, want strings.Builder
+	if err := Run(compile(t, f), &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(f, nil, &want); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want.String() {
+		t.Errorf("got %q, want %q", out.String(), want.String())
+	}
+}
+
+var runTests = []struct {
+	src, out string
+}{
+	{`space main; func main() { println(1 + 2 * 3, 7 / 2, 7 % 2, 1.5 * 2) }`, "7 3 1 3\n"},
+	{`space main; func main() { println("a" + "b", len("abc"), !true, -2 - 5, "abc"[1]) }`, "ab 3 false -7 98\n"},
+	{`space main; func main() { println(1 < 2, 2 <= 1, 3 == 3, "x" != "y", true && false || true) }`, "true false true true true\n"},
+	{`space main
+	var g = 10
+	var s []int
+	func main() {
+		s = []int{1, 2, 3}
+		s = append(s, 4)
+		s[0] = g
+		s[1] *= 5
+		println(s, len(s), s[3])
+	}`, "[10 10 3 4] 4 4\n"},
+	{`space main
+	func main() {
+		i := 0
+		n := 0
+		while i < 10 {
+			i += 1
+			if i == 3 {
+				n -= 100
+			} else if i == 6 {
+				break
+			}
+			n += i
+		}
+		println(i, n)
+	}`, "6 -85\n"},
+	{`space main
+	var s = []int{4, 5, 6}
+	func find(x int) int {
+		var i int
+		for i = 0; i < len(s); i += 1 {
+			if s[i] == x {
+				return i
+			}
+		}
+		return -1
+	}
+	func main() { println(find(6), find(1)) }`, "2 -1\n"},
+	{`space main
+	type Celsius float
+	var f float = 1
+	func main() {
+		var c Celsius
+		x := 3
+		{
+			x := 4
+			c = Celsius(float(x) / 8)
+		}
+		println(c, x, f / 2, int(2.9), string(65))
+	}`, "0.5 3 0.5 2 A\n"},
+	{`space main
+	func fib(n int) int {
+		if n < 2 {
+			return n
+		}
+		return fib(n - 1) + fib(n - 2)
+	}
+	func main() { f := fib; println(f(20)) }`, "6765\n"},
+	{`space main
+	type Vec []int
+	oper (a Vec) add (b Vec) Vec { return Vec([]int{a[0] + b[0], a[1] + b[1]}) }
+	oper (a Vec) rmul (k int) Vec { return Vec([]int{k * a[0], k * a[1]}) }
+	func main() {
+		v := Vec([]int{1, 2})
+		w := v + v
+		w += 2 * v
+		println(w[0], w[1])
+	}`, "4 8\n"},
+	{`space main
+	const (
+		KB = 1 << (10 * (iota + 1))
+		MB
+	)
+	const big = 1 << 70
+	const half float = 1 / 2.0
+	func main() {
+		const (
+			a = iota * 2
+			b
+		)
+		var f float = MB
+		println(MB, big >> 60, half, f / 2, a, b, true)
+	}`, "1048576 1024 0.5 524288 0 2 true\n"},
+	{`space main
+	type Point struct {
+		x, y int
+		name string
+	}
+	type Line struct {
+		a, b Point
+		w    float
+	}
+	func move(p Point, d int) Point {
+		p.x += d
+		return p
+	}
+	func main() {
+		p := Point{x: 1, y: 2}
+		q := p
+		q.x = 10
+		println(p, q, p == q, p != q)
+		ls := []Line{{a: p, w: 2}, {Point{}, Point{3, 4, "b"}, 0.5}}
+		ls[0].a.name = "a"
+		l := ls[1]
+		l.b.y = 40
+		println(ls, l.b.y)
+		var z Line
+		println(z, move(p, 5), p)
+		println(struct{ n int }{7}.n, (Point{}).name == "")
+	}`, "{1 2 } {10 2 } false true\n[{{1 2 a} {0 0 } 2} {{0 0 } {3 4 b} 0.5}] 40\n{{0 0 } {0 0 } 0} {6 2 } {1 2 }\n7 true\n"},
+	{`space main
+	type Point struct{ x, y int }
+	type Vec []int
+	func (p Point) norm() int { return p.x * p.x + p.y * p.y }
+	func (p Point) scale(k int) Point {
+		p.x *= k
+		p.y *= k
+		return p
+	}
+	func (p Point) add(q Point) Point { return Point{p.x + q.x, p.y + q.y} }
+	oper (p Point) add (q Point) Point { return p.add(q).add(q) }
+	func (v Vec) first() int { return v[0] }
+	func (Vec) name() string { return "vec" }
+	var origin = Point{1, 1}.scale(3)
+	func main() {
+		p := Point{3, 4}
+		q := p.scale(2)
+		f := p.norm
+		p.x = 0
+		v := Vec([]int{1, 2})
+		g := v.first
+		v[0] = 7
+		println(p, q, f(), p.norm(), p + q, origin, g(), v.name())
+	}`, "{0 4} {6 8} 25 16 {12 20} {3 3} 7 vec\n"},
+	{`space main
+
+	type Shape interface {
+		area() float
+		name() string
+	}
+
+	type Circle struct{ r float }
+
+	func (c Circle) area() float  { return 3 * c.r * c.r }
+	func (c Circle) name() string { return "circle" }
+
+	type Square struct{ s float }
+
+	func (q Square) area() float  { return q.s * q.s }
+	func (q Square) name() string { return "square" }
+
+	type Vec struct{ x, y int }
+
+	oper (a Vec) add (b Vec) Vec { return Vec{a.x + b.x, a.y + b.y} }
+
+	type Adder interface {
+		add(b Vec) Vec
+	}
+
+	var shapes = []Shape{Circle{1}, Square{2}}
+	var ad Adder = Vec{1, 2}
+
+	func total(list []Shape) float {
+		t := 0.0
+		for i := 0; i < len(list); i += 1 {
+			t += list[i].area()
+		}
+		return t
+	}
+
+	func main() {
+		var s Shape = Circle{2}
+		var e interface{} = 3
+		println(total(shapes), s.name(), e == 3, s == Circle{2})
+		c := s.(Circle)
+		println(c.r, ad.add(Vec{3, 4}))
+		f := s.area
+		println(f())
+		var z Shape
+		println(z == s, z, e)
+	}`, "7 circle true true\n2 {4 6}\n12\nfalse <nil> 3\n"},
+	{`space main
+
+	type Adder[T any] interface {
+		add(b T) T
+	}
+
+	type Vec struct {
+		x, y int
+	}
+
+	oper (a Vec) add (b Vec) Vec {
+		return Vec{a.x + b.x, a.y + b.y}
+	}
+
+	type Money struct {
+		cents int
+	}
+
+	func (m Money) add(n Money) Money {
+		return Money{m.cents + n.cents}
+	}
+
+	func sum[T Adder[T]](list []T, zero T) T {
+		s := zero
+		for i := 0; i < len(list); i += 1 {
+			s += list[i]
+		}
+		return s
+	}
+
+	func plus[T Adder[T]](a T, b T) T {
+		return a.add(b)
+	}
+
+	type Stack[T any] struct {
+		items []T
+	}
+
+	func (s Stack[T]) push(x T) Stack[T] {
+		return Stack[T]{append(s.items, x)}
+	}
+
+	func (s Stack[E]) top() E {
+		return s.items[len(s.items)-1]
+	}
+
+	func (s Stack[T]) size() int {
+		return len(s.items)
+	}
+
+	func max[T ordered](a T, b T) T {
+		if a > b {
+			return a
+		}
+		return b
+	}
+
+	func first[T any](list []T) T {
+		return list[0]
+	}
+
+	func main() {
+		v := sum([]Vec{Vec{1, 2}, Vec{3, 4}}, Vec{0, 0})
+		println(v.x, v.y)
+		m := sum([]Money{Money{5}, Money{7}}, Money{0})
+		println(m.cents)
+		w := plus(Vec{1, 1}, Vec{2, 2})
+		println(w.x, w.y)
+		println(plus(Money{1}, Money{2}).cents)
+		var s Stack[string]
+		s = s.push("a").push("b")
+		println(s.top(), s.size())
+		println(max(3, 7), max("a", "b"), max[float](1, 2.5))
+		println(first([]int{9, 8}))
+		f := max[int]
+		println(f(4, 2))
+		var i interface{} = max(1, 2)
+		println(i.(int))
+	}`, "4 6\n12\n3 3\n3\nb 2\n7 b 2.5\n9\n4\n2\n"},
+	{`space main
+
+	type Pair struct {
+		a, b int
+	}
+
+	func divmod(a int, b int) (int, int) {
+		return a / b, a % b
+	}
+
+	func swap(a string, b string) (string, string) {
+		return b, a
+	}
+
+	func lookup(list []string, s string) (int, bool) {
+		for i := 0; i < len(list); i += 1 {
+			if list[i] == s {
+				return i, true
+			}
+		}
+		return -1, false
+	}
+
+	func pass() (int, int) {
+		return divmod(17, 5)
+	}
+
+	func first[T any](list []T) (T, bool) {
+		var zero T
+		if len(list) == 0 {
+			return zero, false
+		}
+		return list[0], true
+	}
+
+	func main() {
+		q, r := divmod(7, 2)
+		println(q, r)
+		x, y := swap("a", "b")
+		println(x, y)
+		x, y = y, x
+		println(x, y)
+		i, ok := lookup([]string{"p", "q"}, "q")
+		println(i, ok)
+		_, ok = lookup([]string{"p"}, "z")
+		println(ok)
+		q, z := pass()
+		println(q, z)
+		s := []int{1, 2, 3}
+		s[0], s[2] = s[2], s[0]
+		println(s)
+		var p Pair
+		p.a, p.b = divmod(9, 4)
+		println(p)
+		f, found := first([]float{2.5})
+		println(f, found)
+		a, b, c := 1, "two", 3.0
+		println(a, b, c)
+		divmod(1, 1)
+	}`, "3 1\nb a\na b\n1 true\nfalse\n3 2\n[3 2 1]\n{2 1}\n2.5 true\n1 two 3\n"},
+	{`space main
+
+	type Op func(int, int) int
+
+	func apply(f func(x int) int, v int) int {
+		return f(v)
+	}
+
+	func counter() func() int {
+		n := 0
+		return func() int {
+			n += 1
+			return n
+		}
+	}
+
+	var double = func(x int) int { return 2 * x }
+
+	func main() {
+		c := counter()
+		c()
+		c()
+		println(c())
+		println(apply(double, 21))
+		k := 10
+		add := func(x int) int { return x + k }
+		k = 20
+		println(apply(add, 1))
+		var fs []func() int
+		for i := 0; i < 3; i += 1 {
+			fs = append(fs, func() int { return i * i })
+		}
+		for j := 0; j < len(fs); j += 1 {
+			print(fs[j](), " ")
+		}
+		println()
+		var sub Op = func(a int, b int) int { return a - b }
+		println(sub(10, 3))
+		sum := 0
+		each := func(s []int, f func(int)) {
+			for i := 0; i < len(s); i += 1 {
+				f(s[i])
+			}
+		}
+		each([]int{1, 2, 3}, func(v int) { sum += v })
+		println(sum)
+		var g func(int) int
+		g = func(n int) int {
+			if n <= 1 {
+				return 1
+			}
+			return n * g(n-1)
+		}
+		println(g(5))
+		inc := func() func() int {
+			x := 0
+			return func() int { x += 1; return x }
+		}()
+		inc()
+		println(inc())
+		var h interface{} = double
+		f := h.(func(x int) int)
+		println(f(4))
+	}`, "3\n42\n21\n0 1 4 \n7\n6\n120\n2\n8\n"},
+	{`space main
+
+	type Shape interface {
+		area() float
+	}
+
+	type Sq struct {
+		s float
+	}
+
+	func (q Sq) area() float {
+		return q.s * q.s
+	}
+
+	type Circle struct {
+		r float
+	}
+
+	func (c Circle) area() float {
+		return 3 * c.r * c.r
+	}
+
+	func classify(n int) string {
+		switch {
+		case n < 0:
+			return "negative"
+		case n == 0:
+			return "zero"
+		case n < 10:
+			return "small"
+		default:
+			return "large"
+		}
+	}
+
+	func name(d int) string {
+		switch d {
+		case 0, 6:
+			return "weekend"
+		case 1, 2, 3, 4, 5:
+			return "weekday"
+		}
+		return "?"
+	}
+
+	func describe(x interface{}) string {
+		switch v := x.(type) {
+		case int:
+			return "int " + string(65+v)
+		case string, bool:
+			return "string or bool"
+		case Shape:
+			if v.area() > 5 {
+				return "big shape"
+			}
+			return "shape"
+		default:
+			return "other"
+		}
+	}
+
+	func main() {
+		println(classify(-3), classify(0), classify(5), classify(50))
+		println(name(0), name(3), name(9))
+		println(describe(2), describe("s"), describe(true), describe(Sq{1}), describe(Circle{2}), describe(1.5))
+		for i := 0; i < 5; i += 1 {
+			switch i {
+			case 1:
+				print("one ")
+			case 3:
+				break
+			default:
+				print(i, " ")
+			}
+			print(".")
+		}
+		println()
+		var s Shape = Sq{2}
+		switch s {
+		case Sq{2}:
+			println("sq2")
+		case Circle{1}:
+			println("c1")
+		}
+		var e interface{} = 3
+		switch e {
+		case "a":
+			println("a")
+		case 3:
+			println("three")
+		}
+		switch 1 + 1 {
+		}
+		switch x := s.(type) {
+		case Sq:
+			println("sq", x.s)
+		}
+		c, ok := s.(Circle)
+		sh, isShape := e.(Shape)
+		println(c.r, ok, sh == nil, isShape)
+		wrap := func(a interface{}) string {
+			switch v := a.(type) {
+			case string:
+				f := func() string { return v + "!" }
+				return f()
+			}
+			return "?"
+		}
+		println(wrap("hi"), wrap(1))
+	}`, "negative zero small large\nweekend weekday ?\nint C string or bool string or bool shape big shape other\n0 .one .2 ..4 .\nsq2\nthree\nsq 2\n0 false true false\nhi! ?\n"},
+	{`space main
+
+	type P struct {
+		x int
+	}
+
+	type Names []string
+
+	func sum(xs []int) int {
+		s := 0
+		for _, x := range xs {
+			s += x
+		}
+		return s
+	}
+
+	func main() {
+		xs := []int{3, 1, 4}
+		for i, x := range xs {
+			print(i, ":", x, " ")
+		}
+		println(sum(xs))
+		for i, r := range "héllo" {
+			print(i, string(r), " ")
+		}
+		println()
+		var fs []func() int
+		for i, x := range []int{10, 20, 30} {
+			fs = append(fs, func() int { return i + x })
+		}
+		println(fs[0](), fs[1](), fs[2]())
+		n := 0
+		for range xs {
+			n += 1
+		}
+		for i, x := range xs {
+			xs = append(xs, x)
+			if i == 1 {
+				break
+			}
+		}
+		println(n, len(xs))
+		ps := []P{{1}, {2}}
+		for _, p := range ps {
+			p.x += 5
+			print(p.x, " ")
+		}
+		println(ps[0].x, ps[1].x)
+		for _, s := range Names([]string{"a", "b"}) {
+			print(s)
+		}
+		println()
+	}`, "0:3 1:1 2:4 8\n0h 1é 3l 4l 5o \n10 21 32\n3 5\n6 7 1 2\nab\n"},
+	{`space main
+
+	func find(grid [][]int, v int) int {
+	outer:
+		for i, row := range grid {
+			for _, x := range row {
+				if x == v {
+					return i
+				}
+				if x < 0 {
+					continue outer
+				}
+				if x > 100 {
+					break outer
+				}
+			}
+		}
+		return -1
+	}
+
+	func main() {
+		for i := 0; i < 5; i += 1 {
+			if i%2 == 0 {
+				continue
+			}
+			print(i, " ")
+		}
+		println()
+		i := 0
+	loop:
+		while true {
+			i += 1
+			switch {
+			case i < 3:
+				continue
+			case i == 5:
+				break loop
+			}
+			print(i, " ")
+		}
+		println()
+		grid := [][]int{{1, 2}, {-1, 3}, {4, 5}, {200, 6}, {6}}
+		println(find(grid, 5), find(grid, 3), find(grid, 6))
+		n := 0
+	rows:
+		for _, row := range grid {
+			switch len(row) {
+			case 1:
+				break rows
+			}
+			for range row {
+				n += 1
+				if n > 4 {
+					continue rows
+				}
+			}
+		}
+		println(n)
+		f := func() int {
+		inner:
+			for {
+				break inner
+			}
+			return 1
+		}
+		println(f())
+	}`, "1 3 \n3 4 \n2 -1 -1\n6\n1\n"},
+	{`space main
+
+	type P struct {
+		x, y int
+	}
+
+	func keys[K comparable, V any](m map[K]V) []K {
+		var ks []K
+		for k := range m {
+			ks = append(ks, k)
+		}
+		return ks
+	}
+
+	func main() {
+		m := map[string]int{"b": 2, "a": 1}
+		m["c"] = 3
+		m["a"] += 10
+		println(m, len(m), m["z"])
+		v, ok := m["b"]
+		w, found := m["q"]
+		println(v, ok, w, found)
+		delete(m, "b")
+		for k, v := range m {
+			print(k, v, " ")
+			delete(m, "c")
+		}
+		println(len(m))
+		var n map[int]bool
+		delete(n, 1)
+		println(n, len(n), n[3])
+		ps := map[P]string{{1, 2}: "a", {0, 5}: "b"}
+		println(ps, keys(ps))
+		pts := map[string]P{"o": {}}
+		p := pts["o"]
+		p.x = 7
+		println(pts, p)
+		groups := map[bool][]int{}
+		for _, x := range []int{1, 2, 3, 4} {
+			groups[x%2 == 0] = append(groups[x%2 == 0], x)
+		}
+		println(groups)
+		var e map[interface{}]int = map[interface{}]int{"x": 2, 1: 1}
+		e[P{}] = 3
+		println(e[1], e["x"], e[P{}], len(e))
+	}`, "map[a:11 b:2 c:3] 3 0\n2 true 0 false\na11 1\nmap[] 0 false\nmap[{0 5}:b {1 2}:a] [{0 5} {1 2}]\nmap[o:{0 0}] {7 0}\nmap[false:[1 3] true:[2 4]]\n1 2 3 3\n"},
+	{`space main
+
+	type P struct {
+		x int
+		y int
+	}
+
+	type Box struct {
+		p  P
+		xs []int
+	}
+
+	type Shape interface {
+		area() int
+	}
+
+	func (p P) area() int {
+		return p.x * p.y
+	}
+
+	func (p *P) scale(k int) {
+		p.x *= k
+		p.y *= k
+	}
+
+	var g = P{1, 2}
+
+	func swap(a *int, b *int) {
+		*a, *b = *b, *a
+	}
+
+	func main() {
+		var ps []*int
+		for i := 0; i < 3; i += 1 {
+			ps = append(ps, &i)
+		}
+		println(*ps[0], *ps[1], *ps[2])
+
+		b := Box{P{1, 2}, []int{5}}
+		q := &b.p.x
+		b = Box{P{7, 8}, nil}
+		println(*q, b)
+
+		s := []P{{1, 1}}
+		r := &s[0]
+		s = append(s, P{2, 2})
+		r.x = 9
+		println(s, *r)
+
+		a, c := 1, 2
+		swap(&a, &c)
+		println(a, c)
+
+		n := 5
+		pn := &n
+		*pn += 3
+		ppn := &pn
+		**ppn *= 2
+		println(n, *pn == 16, &*pn == pn)
+
+		gp := &g
+		gp.scale(3)
+		g = P{g.x + 1, g.y}
+		println(g, *gp)
+
+		var sh Shape = &P{2, 3}
+		println(sh.area())
+		f := sh.area
+		sh.(*P).scale(2)
+		println(f(), sh.area())
+
+		var nf func()
+		var xs []int
+		println(nf == nil, xs == nil, []int{} == nil, (*P)(nil) == nil)
+
+		var fs []func() int
+		for i := 0; i < 2; i += 1 {
+			p := P{i, i}
+			fs = append(fs, func() int { return p.x })
+			p.scale(10)
+		}
+		println(fs[0](), fs[1]())
+
+	}`, "0 1 2\n7 {{7 8} []}\n[{1 1} {2 2}] {9 1}\n2 1\n16 true true\n{4 6} {4 6}\n6\n24 24\ntrue true false true\n0 10\n"},
+	{`space main
+	type Len []int
+	oper (a Len) gtr (b Len) bool { return len(a) > len(b) }
+	oper (a Len) rgtr (k int) bool { return k > len(a) }
+	func f(s string, v Len) Len {
+		print(s)
+		return v
+	}
+	func g(s string, n int) int {
+		print(s)
+		return n
+	}
+	func main() {
+		a := Len([]int{1})
+		b := Len([]int{1, 2})
+		println(f("a", a) < f("b", b), g("c", 1) < g("d", 2), f("e", b) < g("f", 1))
+	}`, "abcdeftrue true false\n"},
+}
+
+func TestRun(t *testing.T) {
+	for _, test := range runTests {
+		out, err := run(t, test.src)
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("%s: got %q, want %q", test.src, out, test.out)
+		}
+	}
+}
+
+var errorTests = []struct {
+	src, err string
+}{
+	{`space main; func f() {}`, "function main is undeclared"},
+	{`space main; func main() { x := 0; println(1 / x) }`, "1:45: integer divide by zero"},
+	{`space main; func main() { s := []int{1}; println(s[1]) }`, "index out of range [1] with length 1"},
+	{`space main; func f() { f() }; func main() { f() }`, "stack overflow"},
+	{`space main; type S interface{}; func main() { var s S = 1; println(s.(string)) }`, "interface conversion: interface is int, not string"},
+	{`space main; func main() { var m map[string]int; m["a"] = 1 }`, "1:51: assignment to entry in nil map"},
+	{`space main; func main() { m := map[interface{}]int{}; m[[]int{1}] = 1 }`, "hash of unhashable type []int"},
+	{`space main; type P struct{ x int }; func main() { var p *P; println(p.x) }`, "1:70: invalid memory address or nil pointer dereference"},
+	{`space main; type P struct{ x int }; func (p P) f() {}; type F interface{ f() }; func main() { var p *P; var f F = p; g := f.f; g() }`, "1:129: invalid memory address or nil pointer dereference"},
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, test := range errorTests {
+		_, err := run(t, test.src)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	f, err := parser.Parse(position.NewFileBase("test.paw"), strings.NewReader(`space main
+var n = 2
+func main() {
+	while n > 0 {
+		n -= 1
+	}
+	println("done")
+}`), func(err error) { t.Error(err) }, 0)
+	if err != nil {
+		t.FailNow()
+	}
+	var out strings.Builder
+	if err := Disassemble(&out, compile(t, f)); err != nil {
+		t.Fatal(err)
+	}
+	const want = `const 0 = 2
+const 1 = 0
+const 2 = 1
+const 3 = "done"
+
+func init (params 0, locals 0)
+     2 0000  CONST 0 (2)
+       0003  STORE_GLOBAL 0
+     8 0006  NIL
+       0007  RETURN
+
+func main (params 0, locals 0)
+     4 0000  LOAD_GLOBAL 0
+       0003  CONST 1 (0)
+       0006  BINARY >
+       0008  JUMP_FALSE 25
+     5 0011  LOAD_GLOBAL 0
+       0014  CONST 2 (1)
+       0017  BINARY -
+       0019  STORE_GLOBAL 0
+     4 0022  JUMP 0
+     7 0025  BUILTIN 1 (println)
+       0027  CONST 3 ("done")
+       0030  CALL 1
+       0032  POP
+     8 0033  NIL
+       0034  RETURN
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}